@@ -0,0 +1,57 @@
+// Package blobstore хранит содержимое файлов (вложений заметок) вне базы данных.
+// BlobStore - интерфейс хранилища, реализации выбираются секцией attachments
+// конфигурации: каталог на диске, S3-совместимое хранилище или память процесса
+// (для тестов и запуска без БД).
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"diary-backend/config"
+)
+
+// ErrNotFound - содержимого с таким ключом нет.
+var ErrNotFound = errors.New("файл не найден")
+
+// BlobStore сохраняет и читает содержимое по ключу. Ключ - путь из сегментов,
+// разделенных "/", без "." и "..".
+type BlobStore interface {
+	// Put сохраняет size байт из r под ключом key, заменяя прежнее содержимое.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open открывает содержимое для чтения с произвольной позиции (запросы Range).
+	// Если ключа нет - ErrNotFound.
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	// Delete удаляет содержимое; отсутствие ключа ошибкой не считается.
+	Delete(ctx context.Context, key string) error
+}
+
+// New создает BlobStore по секции attachments конфигурации.
+func New(cfg config.AttachmentsConfig) (BlobStore, error) {
+	switch cfg.Driver {
+	case "local":
+		return NewLocal(cfg.Dir)
+	case "s3":
+		return NewS3(cfg.S3), nil
+	case "memory":
+		return NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("неподдерживаемое хранилище вложений %q", cfg.Driver)
+	}
+}
+
+// checkKey отсекает ключи, которые вышли бы за пределы хранилища.
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return fmt.Errorf("недопустимый ключ %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("недопустимый ключ %q", key)
+		}
+	}
+	return nil
+}
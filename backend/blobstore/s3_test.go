@@ -0,0 +1,270 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"diary-backend/config"
+)
+
+const (
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	testRegion    = "eu-central-1"
+	testBucket    = "diary-attachments"
+)
+
+// s3Stub - заглушка S3: проверяет подпись SigV4 каждого запроса и хранит объекты в памяти.
+// Неверная подпись - ответ 403 и, если t задан, ошибка теста.
+type s3Stub struct {
+	t         *testing.T
+	pathStyle bool
+
+	mu       sync.Mutex
+	objects  map[string][]byte
+	types    map[string]string
+	requests []string // "МЕТОД ключ Range"
+}
+
+func newS3Stub(t *testing.T, pathStyle bool) (*s3Stub, *httptest.Server) {
+	stub := &s3Stub{t: t, pathStyle: pathStyle, objects: map[string][]byte{}, types: map[string]string{}}
+	srv := httptest.NewServer(stub)
+	t.Cleanup(srv.Close)
+	return stub, srv
+}
+
+var authPattern = regexp.MustCompile(`^AWS4-HMAC-SHA256 Credential=([^/]+)/(\d{8})/([^/]+)/s3/aws4_request, SignedHeaders=([a-z0-9;-]+), Signature=([0-9a-f]{64})$`)
+
+// checkSignature заново вычисляет подпись запроса по спецификации SigV4.
+func (s *s3Stub) checkSignature(r *http.Request) error {
+	m := authPattern.FindStringSubmatch(r.Header.Get("Authorization"))
+	if m == nil {
+		return errors.New("неверный заголовок Authorization: " + r.Header.Get("Authorization"))
+	}
+	accessKey, day, region, signedHeaders, signature := m[1], m[2], m[3], m[4], m[5]
+	if accessKey != testAccessKey || region != testRegion {
+		return errors.New("чужой ключ доступа или регион: " + accessKey + ", " + region)
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	at, err := time.Parse("20060102T150405Z", amzDate)
+	if err != nil || at.Format("20060102") != day || time.Since(at).Abs() > 15*time.Minute {
+		return errors.New("неверный X-Amz-Date: " + amzDate)
+	}
+
+	// Без подписанного хеша тела S3 принимает только UNSIGNED-PAYLOAD или SHA-256 тела
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash != "UNSIGNED-PAYLOAD" {
+		return errors.New("неожиданный X-Amz-Content-Sha256: " + payloadHash)
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, name := range strings.Split(signedHeaders, ";") {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	if !strings.Contains(";"+signedHeaders+";", ";x-amz-content-sha256;") || !strings.Contains(";"+signedHeaders+";", ";host;") {
+		return errors.New("не подписаны host и x-amz-content-sha256: " + signedHeaders)
+	}
+
+	canonicalRequest := r.Method + "\n" + r.URL.EscapedPath() + "\n" + r.URL.RawQuery + "\n" +
+		canonicalHeaders.String() + "\n" + signedHeaders + "\n" + payloadHash
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + day + "/" + region + "/s3/aws4_request\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + testSecretKey)
+	for _, part := range []string{day, region, "s3", "aws4_request", stringToSign} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	if !hmac.Equal([]byte(hex.EncodeToString(key)), []byte(signature)) {
+		return errors.New("подпись не совпадает; канонический запрос:\n" + canonicalRequest)
+	}
+	return nil
+}
+
+// objectKey извлекает ключ объекта из пути или имени хоста, в зависимости от стиля адресации.
+func (s *s3Stub) objectKey(r *http.Request) (string, bool) {
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	if s.pathStyle {
+		key, ok := strings.CutPrefix(path, testBucket+"/")
+		return key, ok
+	}
+	return path, strings.HasPrefix(r.Host, testBucket+".")
+}
+
+func (s *s3Stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := s.checkSignature(r); err != nil {
+		if s.t != nil {
+			s.t.Errorf("%s %s: %v", r.Method, r.URL, err)
+		}
+		http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+		return
+	}
+	key, ok := s.objectKey(r)
+	if !ok {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, strings.TrimSpace(r.Method+" "+key+" "+r.Header.Get("Range")))
+
+	switch r.Method {
+	case http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil || int64(len(data)) != r.ContentLength {
+			http.Error(w, "IncompleteBody", http.StatusBadRequest)
+			return
+		}
+		s.objects[key] = data
+		s.types[key] = r.Header.Get("Content-Type")
+	case http.MethodHead, http.MethodGet:
+		data, ok := s.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", s.types[key])
+		status := http.StatusOK
+		if rng := r.Header.Get("Range"); rng != "" {
+			start, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+			if err != nil || start >= len(data) {
+				http.Error(w, "InvalidRange", http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			w.Header().Set("Content-Range", "bytes "+strconv.Itoa(start)+"-"+strconv.Itoa(len(data)-1)+"/"+strconv.Itoa(len(data)))
+			data, status = data[start:], http.StatusPartialContent
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(status)
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	case http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "MethodNotAllowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// newTestS3 создает клиент заглушки. Для адресации через имя хоста все соединения
+// направляются на заглушку: имя бакета.127.0.0.1 не разрешается в адрес.
+func newTestS3(srv *httptest.Server, pathStyle bool) *S3 {
+	s := NewS3(config.S3Config{
+		Endpoint:  srv.URL + "/",
+		Region:    testRegion,
+		Bucket:    testBucket,
+		AccessKey: testAccessKey,
+		SecretKey: testSecretKey,
+		PathStyle: pathStyle,
+	})
+	addr := srv.Listener.Addr().String()
+	s.client = &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}}
+	return s
+}
+
+func TestS3RoundTrip(t *testing.T) {
+	for _, pathStyle := range []bool{true, false} {
+		t.Run("path_style="+strconv.FormatBool(pathStyle), func(t *testing.T) {
+			stub, srv := newS3Stub(t, pathStyle)
+			s := newTestS3(srv, pathStyle)
+			ctx := context.Background()
+
+			// Символы, которые SigV4 требует кодировать в пути
+			key := "users/7/ab cd+ef=~1.txt"
+			content := []byte("Содержимое вложения для проверки запросов Range")
+
+			if err := s.Put(ctx, key, bytes.NewReader(content), int64(len(content)), "text/plain; charset=utf-8"); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			stub.mu.Lock()
+			contentType := stub.types[key]
+			stub.mu.Unlock()
+			if contentType != "text/plain; charset=utf-8" {
+				t.Errorf("Content-Type объекта: %q", contentType)
+			}
+
+			obj, err := s.Open(ctx, key)
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			data, err := io.ReadAll(obj)
+			if err != nil || !bytes.Equal(data, content) {
+				t.Fatalf("чтение: %q, %v", data, err)
+			}
+
+			// После Seek чтение продолжается новым запросом с Range
+			if _, err := obj.Seek(-5, io.SeekEnd); err != nil {
+				t.Fatalf("Seek: %v", err)
+			}
+			tail, err := io.ReadAll(obj)
+			if err != nil || !bytes.Equal(tail, content[len(content)-5:]) {
+				t.Fatalf("чтение после Seek: %q, %v", tail, err)
+			}
+			obj.Close()
+
+			if err := s.Delete(ctx, key); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if _, err := s.Open(ctx, key); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Open после Delete: %v, ожидалась ErrNotFound", err)
+			}
+			if err := s.Delete(ctx, key); err != nil {
+				t.Fatalf("повторный Delete: %v", err)
+			}
+
+			want := []string{
+				"PUT " + key,
+				"HEAD " + key,
+				"GET " + key + " bytes=0-",
+				"GET " + key + " bytes=" + strconv.Itoa(len(content)-5) + "-",
+				"DELETE " + key,
+				"HEAD " + key,
+				"DELETE " + key,
+			}
+			stub.mu.Lock()
+			got := strings.Join(stub.requests, "\n")
+			stub.mu.Unlock()
+			if got != strings.Join(want, "\n") {
+				t.Errorf("запросы:\n%s\nожидались:\n%s", got, strings.Join(want, "\n"))
+			}
+		})
+	}
+}
+
+func TestS3WrongSecret(t *testing.T) {
+	stub, srv := newS3Stub(t, true)
+	stub.t = nil // Ошибка подписи здесь ожидаема
+	s := newTestS3(srv, true)
+	s.secretKey = "wrong-secret"
+
+	err := s.Put(context.Background(), "users/7/file", strings.NewReader("x"), 1, "")
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("Put с неверным ключом: %v, ожидалась ошибка 403", err)
+	}
+}
@@ -0,0 +1,528 @@
+// Package config загружает типизированную конфигурацию сервера из переменных
+// окружения и необязательного YAML-файла.
+//
+// Порядок применения: значения по умолчанию -> YAML-файл -> переменные окружения.
+// Каждое поле, которое можно переопределить из окружения, помечено тегом `env`.
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvConfigPath - переменная окружения с путем к YAML-файлу конфигурации.
+const EnvConfigPath = "DIARY_CONFIG"
+
+// Config - полная конфигурация сервера.
+type Config struct {
+	Server       ServerConfig       `yaml:"server"`
+	Database     DatabaseConfig     `yaml:"database"`
+	JWT          JWTConfig          `yaml:"jwt"`
+	Mail         MailConfig         `yaml:"mail"`
+	SMTP         SMTPConfig         `yaml:"smtp"`
+	Verification VerificationConfig `yaml:"verification"`
+	TwoFactor    TwoFactorConfig    `yaml:"two_factor"`
+	Trash        TrashConfig        `yaml:"trash"`
+	Attachments  AttachmentsConfig  `yaml:"attachments"`
+	Encryption   EncryptionConfig   `yaml:"encryption"`
+}
+
+// ServerConfig - параметры HTTP-сервера.
+type ServerConfig struct {
+	Addr       string `yaml:"addr" env:"DIARY_ADDR"`               // Адрес прослушивания, например ":8080"
+	CORSOrigin string `yaml:"cors_origin" env:"DIARY_CORS_ORIGIN"` // Значение Access-Control-Allow-Origin
+}
+
+// DatabaseConfig - параметры подключения к БД.
+type DatabaseConfig struct {
+	Driver string `yaml:"driver" env:"DIARY_DB_DRIVER"` // "postgres", "sqlite" или "memory" (без БД, данные не сохраняются)
+	DSN    string `yaml:"dsn" env:"DIARY_DB_DSN"`       // Строка подключения; для "sqlite" - путь к файлу базы
+	// AutoMigrate применяет недостающие миграции схемы при запуске сервера.
+	AutoMigrate bool `yaml:"auto_migrate" env:"DIARY_DB_AUTO_MIGRATE"`
+}
+
+// JWTConfig - параметры подписи токенов.
+type JWTConfig struct {
+	Secret     string        `yaml:"secret" env:"DIARY_JWT_SECRET"`
+	TTL        time.Duration `yaml:"ttl" env:"DIARY_JWT_TTL"`                 // Время жизни токена доступа
+	RefreshTTL time.Duration `yaml:"refresh_ttl" env:"DIARY_JWT_REFRESH_TTL"` // Время жизни токена обновления
+}
+
+// MailConfig - выбор способа отправки почты.
+type MailConfig struct {
+	Driver string `yaml:"driver" env:"DIARY_MAIL_DRIVER"` // "smtp", "resend", "file", "log" или "memory"
+	From   string `yaml:"from" env:"DIARY_MAIL_FROM"`     // Email, с которого отправляются письма
+	Dir    string `yaml:"dir" env:"DIARY_MAIL_DIR"`       // Каталог для .eml-файлов (драйвер "file")
+	// TemplatesDir - каталог с шаблонами писем, заменяющими встроенные (та же структура: <язык>/<вид>.txt|.html).
+	TemplatesDir  string       `yaml:"templates_dir" env:"DIARY_MAIL_TEMPLATES_DIR"`
+	DefaultLocale string       `yaml:"default_locale" env:"DIARY_MAIL_DEFAULT_LOCALE"` // Язык писем, если язык пользователя не поддерживается
+	Resend        ResendConfig `yaml:"resend"`
+	Outbox        OutboxConfig `yaml:"outbox"`
+}
+
+// OutboxConfig - фоновая доставка писем из очереди email_outbox.
+type OutboxConfig struct {
+	PollInterval time.Duration `yaml:"poll_interval" env:"DIARY_OUTBOX_POLL_INTERVAL"` // Как часто проверять очередь
+	BatchSize    int           `yaml:"batch_size" env:"DIARY_OUTBOX_BATCH_SIZE"`       // Писем за одну проверку
+	SendTimeout  time.Duration `yaml:"send_timeout" env:"DIARY_OUTBOX_SEND_TIMEOUT"`   // Таймаут отправки одного письма
+	MaxAttempts  int           `yaml:"max_attempts" env:"DIARY_OUTBOX_MAX_ATTEMPTS"`   // После стольких неудач письмо помечается как недоставленное
+	RetryBackoff time.Duration `yaml:"retry_backoff" env:"DIARY_OUTBOX_RETRY_BACKOFF"` // Пауза перед первым повтором, дальше удваивается
+	MaxBackoff   time.Duration `yaml:"max_backoff" env:"DIARY_OUTBOX_MAX_BACKOFF"`     // Максимальная пауза между повторами
+}
+
+// ResendConfig - параметры HTTP API Resend (драйвер "resend").
+type ResendConfig struct {
+	APIKey  string `yaml:"api_key" env:"DIARY_RESEND_API_KEY"`
+	BaseURL string `yaml:"base_url" env:"DIARY_RESEND_BASE_URL"` // Можно указать локальную заглушку
+}
+
+// SMTPConfig - параметры SMTP-сервера (драйвер "smtp").
+type SMTPConfig struct {
+	Host     string `yaml:"host" env:"DIARY_SMTP_HOST"`
+	Port     int    `yaml:"port" env:"DIARY_SMTP_PORT"`
+	Username string `yaml:"username" env:"DIARY_SMTP_USERNAME"` // Пустое значение отключает аутентификацию
+	Password string `yaml:"password" env:"DIARY_SMTP_PASSWORD"` // Пароль приложения для SMTP
+	// TLS: "auto" - STARTTLS, если сервер его поддерживает; "starttls" - обязательный STARTTLS;
+	// "tls" - TLS с момента подключения (обычно порт 465); "none" - без шифрования.
+	TLS  string `yaml:"tls" env:"DIARY_SMTP_TLS"`
+	From string `yaml:"from" env:"DIARY_SMTP_FROM"` // Устарело: используйте mail.from
+}
+
+// VerificationConfig - параметры кодов верификации.
+type VerificationConfig struct {
+	CodeTTL     time.Duration `yaml:"code_ttl" env:"DIARY_VERIFICATION_CODE_TTL"`         // Срок действия кода
+	MaxAttempts int           `yaml:"max_attempts" env:"DIARY_VERIFICATION_MAX_ATTEMPTS"` // Неудачных попыток ввода, после которых код блокируется
+	// Ограничения выдачи новых кодов: иначе блокировка после MaxAttempts ошибок обходится
+	// запросом нового кода. Письмо при регистрации тоже считается.
+	ResendCooldown time.Duration `yaml:"resend_cooldown" env:"DIARY_VERIFICATION_RESEND_COOLDOWN"` // Не чаще одного кода за этот интервал
+	ResendLimit    int           `yaml:"resend_limit" env:"DIARY_VERIFICATION_RESEND_LIMIT"`       // Не больше стольких кодов одного вида за ResendWindow
+	ResendWindow   time.Duration `yaml:"resend_window" env:"DIARY_VERIFICATION_RESEND_WINDOW"`
+	// CodeSecret - ключ HMAC-SHA256 для хешей кодов в БД: без него 6-значный код
+	// восстанавливается по хешу перебором. Пустой - используется jwt.secret.
+	CodeSecret string `yaml:"code_secret" env:"DIARY_VERIFICATION_CODE_SECRET"`
+}
+
+// TwoFactorConfig - параметры двухфакторной аутентификации (TOTP).
+type TwoFactorConfig struct {
+	Issuer      string        `yaml:"issuer" env:"DIARY_2FA_ISSUER"`             // Название сервиса в приложении-аутентификаторе
+	TokenTTL    time.Duration `yaml:"token_ttl" env:"DIARY_2FA_TOKEN_TTL"`       // Время жизни промежуточного токена после проверки пароля
+	MaxAttempts int           `yaml:"max_attempts" env:"DIARY_2FA_MAX_ATTEMPTS"` // Неудачных попыток ввода кода подряд до блокировки
+	Lockout     time.Duration `yaml:"lockout" env:"DIARY_2FA_LOCKOUT"`           // На сколько блокируется ввод кода после MaxAttempts ошибок
+}
+
+// TrashConfig - корзина удаленных заметок.
+type TrashConfig struct {
+	Retention     time.Duration `yaml:"retention" env:"DIARY_TRASH_RETENTION"`           // Сколько заметка хранится в корзине до окончательного удаления
+	PurgeInterval time.Duration `yaml:"purge_interval" env:"DIARY_TRASH_PURGE_INTERVAL"` // Как часто удалять заметки с истекшим сроком
+}
+
+// AttachmentsConfig - вложения заметок и хранилище их содержимого.
+type AttachmentsConfig struct {
+	Driver    string `yaml:"driver" env:"DIARY_ATTACHMENTS_DRIVER"`         // "local", "s3" или "memory" (данные не сохраняются)
+	Dir       string `yaml:"dir" env:"DIARY_ATTACHMENTS_DIR"`               // Каталог файлов (драйвер "local")
+	MaxSize   int64  `yaml:"max_size" env:"DIARY_ATTACHMENTS_MAX_SIZE"`     // Байт в одном файле
+	UserQuota int64  `yaml:"user_quota" env:"DIARY_ATTACHMENTS_USER_QUOTA"` // Байт всех вложений одного пользователя
+	// AllowedTypes - MIME-типы, которые можно загружать. Тип определяется по содержимому
+	// файла, а не по имени или заголовку Content-Type запроса.
+	AllowedTypes []string `yaml:"allowed_types" env:"DIARY_ATTACHMENTS_ALLOWED_TYPES"`
+	S3           S3Config `yaml:"s3"`
+}
+
+// S3Config - S3-совместимое хранилище (драйвер "s3").
+type S3Config struct {
+	Endpoint  string `yaml:"endpoint" env:"DIARY_S3_ENDPOINT"` // Например https://s3.eu-central-1.amazonaws.com; можно указать MinIO или локальную заглушку
+	Region    string `yaml:"region" env:"DIARY_S3_REGION"`
+	Bucket    string `yaml:"bucket" env:"DIARY_S3_BUCKET"`
+	AccessKey string `yaml:"access_key" env:"DIARY_S3_ACCESS_KEY"`
+	SecretKey string `yaml:"secret_key" env:"DIARY_S3_SECRET_KEY"`
+	PathStyle bool   `yaml:"path_style" env:"DIARY_S3_PATH_STYLE"` // Бакет в пути (endpoint/bucket/key), как у MinIO; иначе в имени хоста
+}
+
+// EncryptionConfig - шифрование заголовков и текстов заметок в БД ключами сервера (в отличие
+// от сквозного шифрования, ключ которого знает только пользователь). Без мастер-ключей
+// заметки хранятся открытыми.
+type EncryptionConfig struct {
+	// MasterKeys - мастер-ключи в виде "id:ключ", ключ - 32 случайных байта в base64.
+	// Прежний ключ оставляют в списке, пока фоновая задача не перешифрует ключи данных новым.
+	MasterKeys []string `yaml:"master_keys" env:"DIARY_ENCRYPTION_MASTER_KEYS"`
+	ActiveKey  string   `yaml:"active_key" env:"DIARY_ENCRYPTION_ACTIVE_KEY"` // id мастер-ключа для новых и перешифрованных ключей данных
+	// DataKeyTTL - через сколько ключ данных пользователя заменяется новым, а его заметки
+	// перешифровываются; 0 - не заменять.
+	DataKeyTTL     time.Duration `yaml:"data_key_ttl" env:"DIARY_ENCRYPTION_DATA_KEY_TTL"`
+	RotateInterval time.Duration `yaml:"rotate_interval" env:"DIARY_ENCRYPTION_ROTATE_INTERVAL"` // Как часто искать, что перешифровать
+	BatchSize      int           `yaml:"batch_size" env:"DIARY_ENCRYPTION_BATCH_SIZE"`           // Заметок и ревизий за один запрос перешифрования
+}
+
+// Enabled сообщает, включено ли шифрование в БД.
+func (e EncryptionConfig) Enabled() bool {
+	return len(e.MasterKeys) > 0
+}
+
+// Keys разбирает MasterKeys: id -> 32-байтный ключ.
+func (e EncryptionConfig) Keys() (map[string][]byte, error) {
+	keys := make(map[string][]byte, len(e.MasterKeys))
+	for i, entry := range e.MasterKeys {
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("ключ %d: ожидается формат id:ключ", i+1)
+		}
+		if _, dup := keys[id]; dup {
+			return nil, fmt.Errorf("ключ %q указан дважды", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("ключ %q: ожидается 32 байта в base64", id)
+		}
+		keys[id] = key
+	}
+	return keys, nil
+}
+
+// Default возвращает конфигурацию со значениями по умолчанию.
+// Секреты (DSN, ключ JWT, пароль SMTP) намеренно не заполняются.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:       ":8080",
+			CORSOrigin: "*",
+		},
+		Database: DatabaseConfig{
+			Driver:      "postgres",
+			AutoMigrate: true,
+		},
+		JWT: JWTConfig{
+			TTL:        15 * time.Minute,
+			RefreshTTL: 30 * 24 * time.Hour,
+		},
+		Mail: MailConfig{
+			Driver:        "smtp",
+			DefaultLocale: "ru",
+			Resend: ResendConfig{
+				BaseURL: "https://api.resend.com",
+			},
+			Outbox: OutboxConfig{
+				PollInterval: 2 * time.Second,
+				BatchSize:    10,
+				SendTimeout:  30 * time.Second,
+				MaxAttempts:  8,
+				RetryBackoff: 30 * time.Second,
+				MaxBackoff:   time.Hour,
+			},
+		},
+		SMTP: SMTPConfig{
+			Host: "smtp.gmail.com",
+			Port: 587,
+			TLS:  "auto",
+		},
+		Verification: VerificationConfig{
+			CodeTTL:        15 * time.Minute,
+			MaxAttempts:    5,
+			ResendCooldown: time.Minute,
+			ResendLimit:    5,
+			ResendWindow:   24 * time.Hour,
+		},
+		TwoFactor: TwoFactorConfig{
+			Issuer:      "DiaryApp",
+			TokenTTL:    5 * time.Minute,
+			MaxAttempts: 5,
+			Lockout:     15 * time.Minute,
+		},
+		Trash: TrashConfig{
+			Retention:     30 * 24 * time.Hour,
+			PurgeInterval: time.Hour,
+		},
+		Attachments: AttachmentsConfig{
+			Driver:       "local",
+			Dir:          "attachments",
+			MaxSize:      10 << 20,
+			UserQuota:    200 << 20,
+			AllowedTypes: []string{"image/jpeg", "image/png", "image/gif", "image/webp", "application/pdf", "text/plain"},
+			S3: S3Config{
+				Region: "us-east-1",
+			},
+		},
+		Encryption: EncryptionConfig{
+			DataKeyTTL:     90 * 24 * time.Hour,
+			RotateInterval: 10 * time.Minute,
+			BatchSize:      200,
+		},
+	}
+}
+
+// Load собирает конфигурацию: значения по умолчанию, затем файл path
+// (если он задан), затем переменные окружения. Результат проверяется Validate.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyEnv(reflect.ValueOf(cfg).Elem()); err != nil {
+		return nil, err
+	}
+
+	// Совместимость со старыми конфигурациями, где отправитель задавался в smtp.from
+	if cfg.Mail.From == "" {
+		cfg.Mail.From = cfg.SMTP.From
+	}
+
+	if cfg.Verification.CodeSecret == "" {
+		cfg.Verification.CodeSecret = cfg.JWT.Secret
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile читает YAML-файл поверх текущих значений. Неизвестные ключи считаются ошибкой,
+// чтобы опечатки в конфигурации не проходили незамеченными.
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("не удалось открыть файл конфигурации: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("ошибка разбора файла конфигурации %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate проверяет конфигурацию и возвращает все найденные ошибки сразу.
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Server.Addr == "" {
+		add("server.addr (DIARY_ADDR): адрес сервера не задан")
+	}
+
+	switch c.Database.Driver {
+	case "postgres", "sqlite":
+		if c.Database.DSN == "" {
+			add("database.dsn (DIARY_DB_DSN): строка подключения не задана")
+		}
+	case "memory":
+	default:
+		add("database.driver (DIARY_DB_DRIVER): неподдерживаемый драйвер %q", c.Database.Driver)
+	}
+
+	if len(c.JWT.Secret) < 32 {
+		add("jwt.secret (DIARY_JWT_SECRET): ключ должен содержать не менее 32 символов")
+	}
+	if c.JWT.TTL <= 0 {
+		add("jwt.ttl (DIARY_JWT_TTL): время жизни токена должно быть положительным")
+	}
+	if c.JWT.RefreshTTL <= c.JWT.TTL {
+		add("jwt.refresh_ttl (DIARY_JWT_REFRESH_TTL): токен обновления должен жить дольше токена доступа")
+	}
+
+	if c.Mail.From == "" {
+		add("mail.from (DIARY_MAIL_FROM): адрес отправителя не задан")
+	}
+	if c.Mail.DefaultLocale == "" {
+		add("mail.default_locale (DIARY_MAIL_DEFAULT_LOCALE): язык писем по умолчанию не задан")
+	}
+	switch c.Mail.Driver {
+	case "smtp":
+		if c.SMTP.Host == "" {
+			add("smtp.host (DIARY_SMTP_HOST): SMTP-сервер не задан")
+		}
+		if c.SMTP.Port <= 0 || c.SMTP.Port > 65535 {
+			add("smtp.port (DIARY_SMTP_PORT): недопустимый порт %d", c.SMTP.Port)
+		}
+		switch c.SMTP.TLS {
+		case "auto", "starttls", "tls", "none":
+		default:
+			add("smtp.tls (DIARY_SMTP_TLS): неподдерживаемый режим %q", c.SMTP.TLS)
+		}
+	case "resend":
+		if c.Mail.Resend.APIKey == "" {
+			add("mail.resend.api_key (DIARY_RESEND_API_KEY): ключ API не задан")
+		}
+		if c.Mail.Resend.BaseURL == "" {
+			add("mail.resend.base_url (DIARY_RESEND_BASE_URL): адрес API не задан")
+		}
+	case "file":
+		if c.Mail.Dir == "" {
+			add("mail.dir (DIARY_MAIL_DIR): каталог для писем не задан")
+		}
+	case "log", "memory":
+	default:
+		add("mail.driver (DIARY_MAIL_DRIVER): неподдерживаемый драйвер %q", c.Mail.Driver)
+	}
+	if o := c.Mail.Outbox; o.PollInterval <= 0 || o.SendTimeout <= 0 || o.RetryBackoff <= 0 || o.MaxBackoff < o.RetryBackoff {
+		add("mail.outbox: интервалы должны быть положительными, max_backoff - не меньше retry_backoff")
+	}
+	if c.Mail.Outbox.BatchSize < 1 || c.Mail.Outbox.MaxAttempts < 1 {
+		add("mail.outbox: batch_size и max_attempts должны быть не меньше 1")
+	}
+
+	if c.Verification.CodeTTL <= 0 {
+		add("verification.code_ttl (DIARY_VERIFICATION_CODE_TTL): срок действия кода должен быть положительным")
+	}
+	if c.Verification.MaxAttempts < 1 {
+		add("verification.max_attempts (DIARY_VERIFICATION_MAX_ATTEMPTS): нужна хотя бы одна попытка")
+	}
+	if c.Verification.ResendCooldown < 0 || c.Verification.ResendWindow <= 0 || c.Verification.ResendLimit < 1 {
+		add("verification.resend_*: resend_cooldown не может быть отрицательным, resend_window должен быть положительным, resend_limit - не меньше 1")
+	}
+	if len(c.Verification.CodeSecret) < 32 {
+		add("verification.code_secret (DIARY_VERIFICATION_CODE_SECRET): ключ должен быть не короче 32 символов")
+	}
+
+	if c.TwoFactor.Issuer == "" || strings.Contains(c.TwoFactor.Issuer, ":") {
+		add("two_factor.issuer (DIARY_2FA_ISSUER): название не задано или содержит ':'")
+	}
+	if c.TwoFactor.TokenTTL <= 0 {
+		add("two_factor.token_ttl (DIARY_2FA_TOKEN_TTL): время жизни токена должно быть положительным")
+	}
+	if c.TwoFactor.MaxAttempts < 1 {
+		add("two_factor.max_attempts (DIARY_2FA_MAX_ATTEMPTS): нужна хотя бы одна попытка")
+	}
+	if c.TwoFactor.Lockout <= 0 {
+		add("two_factor.lockout (DIARY_2FA_LOCKOUT): время блокировки должно быть положительным")
+	}
+
+	if c.Trash.Retention <= 0 || c.Trash.PurgeInterval <= 0 {
+		add("trash: retention (DIARY_TRASH_RETENTION) и purge_interval (DIARY_TRASH_PURGE_INTERVAL) должны быть положительными")
+	}
+
+	switch a := c.Attachments; a.Driver {
+	case "local":
+		if a.Dir == "" {
+			add("attachments.dir (DIARY_ATTACHMENTS_DIR): каталог вложений не задан")
+		}
+	case "s3":
+		if u, err := url.Parse(a.S3.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("attachments.s3.endpoint (DIARY_S3_ENDPOINT): ожидается адрес http(s)://host[:port]")
+		}
+		if a.S3.Region == "" || a.S3.Bucket == "" {
+			add("attachments.s3: region (DIARY_S3_REGION) и bucket (DIARY_S3_BUCKET) должны быть заданы")
+		}
+		if a.S3.AccessKey == "" || a.S3.SecretKey == "" {
+			add("attachments.s3: access_key (DIARY_S3_ACCESS_KEY) и secret_key (DIARY_S3_SECRET_KEY) должны быть заданы")
+		}
+	case "memory":
+	default:
+		add("attachments.driver (DIARY_ATTACHMENTS_DRIVER): неподдерживаемое хранилище %q", a.Driver)
+	}
+	if c.Attachments.MaxSize <= 0 || c.Attachments.UserQuota < c.Attachments.MaxSize {
+		add("attachments: max_size (DIARY_ATTACHMENTS_MAX_SIZE) должен быть положительным, user_quota (DIARY_ATTACHMENTS_USER_QUOTA) - не меньше max_size")
+	}
+	if len(c.Attachments.AllowedTypes) == 0 {
+		add("attachments.allowed_types (DIARY_ATTACHMENTS_ALLOWED_TYPES): не задан ни один тип файлов")
+	}
+
+	if e := c.Encryption; e.Enabled() {
+		if keys, err := e.Keys(); err != nil {
+			add("encryption.master_keys (DIARY_ENCRYPTION_MASTER_KEYS): %v", err)
+		} else if _, ok := keys[e.ActiveKey]; !ok {
+			add("encryption.active_key (DIARY_ENCRYPTION_ACTIVE_KEY): ключа %q нет в encryption.master_keys", e.ActiveKey)
+		}
+		if e.DataKeyTTL < 0 || e.RotateInterval <= 0 || e.BatchSize < 1 {
+			add("encryption: data_key_ttl не может быть отрицательным, rotate_interval и batch_size должны быть положительными")
+		}
+	} else if e.ActiveKey != "" {
+		add("encryption.active_key (DIARY_ENCRYPTION_ACTIVE_KEY): задан, но encryption.master_keys пуст")
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("некорректная конфигурация:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// applyEnv рекурсивно обходит структуру и заполняет поля с тегом `env`
+// значениями из соответствующих переменных окружения.
+func applyEnv(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		sf := t.Field(i)
+
+		if field.Kind() == reflect.Struct && field.Type() != durationType {
+			if err := applyEnv(field); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := sf.Tag.Get("env")
+		if name == "" {
+			continue
+		}
+		raw, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setField(field, raw); err != nil {
+			return fmt.Errorf("переменная окружения %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// setField разбирает строковое значение в соответствии с типом поля.
+func setField(field reflect.Value, raw string) error {
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("неподдерживаемый тип %s", field.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("неподдерживаемый тип %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package config
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "test-secret-test-secret-test-secret"
+
+// clearEnv убирает переменные DIARY_* окружения, в котором запущены тесты;
+// после теста они восстанавливаются.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, kv := range os.Environ() {
+		if name, _, _ := strings.Cut(kv, "="); strings.HasPrefix(name, "DIARY_") {
+			t.Setenv(name, "")
+			os.Unsetenv(name)
+		}
+	}
+}
+
+// writeConfig записывает YAML-файл конфигурации и возвращает путь к нему.
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("файл конфигурации: %v", err)
+	}
+	return path
+}
+
+// minimalYAML - файл, с которым конфигурация проходит проверку.
+const minimalYAML = `
+database:
+  driver: memory
+jwt:
+  secret: ` + testSecret + `
+mail:
+  driver: log
+  from: diary@example.com
+`
+
+func TestLoadPrecedence(t *testing.T) {
+	clearEnv(t)
+	path := writeConfig(t, minimalYAML+`
+server:
+  addr: ":9000"
+  cors_origin: https://diary.example.com
+smtp:
+  port: 2525
+two_factor:
+  issuer: Из файла
+  token_ttl: 10m
+trash:
+  retention: 48h
+attachments:
+  allowed_types: [image/png]
+`)
+	// Окружение перекрывает файл, остальные поля файла и значения по умолчанию сохраняются
+	t.Setenv("DIARY_ADDR", ":7000")
+	t.Setenv("DIARY_2FA_TOKEN_TTL", "1m30s")
+	t.Setenv("DIARY_SMTP_PORT", "465")
+	t.Setenv("DIARY_DB_AUTO_MIGRATE", "false")
+	t.Setenv("DIARY_ATTACHMENTS_ALLOWED_TYPES", " text/plain, application/pdf,")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	tests := []struct {
+		name      string
+		got, want any
+	}{
+		{"server.addr из окружения", cfg.Server.Addr, ":7000"},
+		{"server.cors_origin из файла", cfg.Server.CORSOrigin, "https://diary.example.com"},
+		{"two_factor.token_ttl из окружения", cfg.TwoFactor.TokenTTL, 90 * time.Second},
+		{"two_factor.issuer из файла", cfg.TwoFactor.Issuer, "Из файла"},
+		{"two_factor.max_attempts по умолчанию", cfg.TwoFactor.MaxAttempts, 5},
+		{"trash.retention из файла", cfg.Trash.Retention, 48 * time.Hour},
+		{"smtp.port из окружения", cfg.SMTP.Port, 465},
+		{"database.auto_migrate из окружения", cfg.Database.AutoMigrate, false},
+		{"attachments.allowed_types из окружения", strings.Join(cfg.Attachments.AllowedTypes, " "), "text/plain application/pdf"},
+		{"jwt.ttl по умолчанию", cfg.JWT.TTL, 15 * time.Minute},
+		{"verification.code_secret из jwt.secret", cfg.Verification.CodeSecret, testSecret},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: %v, ожидалось %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadWithoutFile(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DIARY_DB_DRIVER", "memory")
+	t.Setenv("DIARY_JWT_SECRET", testSecret)
+	t.Setenv("DIARY_VERIFICATION_CODE_SECRET", strings.Repeat("c", 32))
+	t.Setenv("DIARY_MAIL_DRIVER", "log")
+	t.Setenv("DIARY_SMTP_FROM", "old@example.com") // Устаревший способ задать отправителя
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Mail.From != "old@example.com" || cfg.Verification.CodeSecret != strings.Repeat("c", 32) || cfg.Server.Addr != ":8080" {
+		t.Errorf("конфигурация: %+v", cfg)
+	}
+}
+
+func TestLoadRejectsInvalid(t *testing.T) {
+	key := func(b byte) string { return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), 32))) }
+	tests := []struct {
+		name string
+		yaml string            // Добавляется к minimalYAML
+		env  map[string]string // Переменные окружения
+		want string            // Часть текста ошибки
+	}{
+		{"неизвестный ключ в файле", "unknown: 1\n", nil, "field unknown not found"},
+		{"неверный тип в файле", "smtp:\n  port: много\n", nil, "ошибка разбора файла конфигурации"},
+		{"длительность в окружении", "", map[string]string{"DIARY_JWT_TTL": "15"}, "DIARY_JWT_TTL"},
+		{"число в окружении", "", map[string]string{"DIARY_SMTP_PORT": "smtp"}, "DIARY_SMTP_PORT"},
+		{"bool в окружении", "", map[string]string{"DIARY_DB_AUTO_MIGRATE": "иногда"}, "DIARY_DB_AUTO_MIGRATE"},
+		{"неизвестный драйвер БД", "", map[string]string{"DIARY_DB_DRIVER": "mysql"}, `неподдерживаемый драйвер "mysql"`},
+		{"DSN для SQLite", "", map[string]string{"DIARY_DB_DRIVER": "sqlite"}, "database.dsn"},
+		{"короткий ключ JWT из окружения перекрывает файл", "", map[string]string{"DIARY_JWT_SECRET": "short"}, "jwt.secret"},
+		{"refresh_ttl не дольше ttl", "", map[string]string{"DIARY_JWT_REFRESH_TTL": "15m"}, "jwt.refresh_ttl"},
+		{"режим TLS", "smtp:\n  tls: ssl\n", map[string]string{"DIARY_MAIL_DRIVER": "smtp"}, `неподдерживаемый режим "ssl"`},
+		{"issuer с двоеточием", "", map[string]string{"DIARY_2FA_ISSUER": "a:b"}, "two_factor.issuer"},
+		{"квота меньше размера файла", "", map[string]string{"DIARY_ATTACHMENTS_USER_QUOTA": "1"}, "user_quota"},
+		{"endpoint S3", "", map[string]string{"DIARY_ATTACHMENTS_DRIVER": "s3", "DIARY_S3_ENDPOINT": "s3.example.com"}, "attachments.s3.endpoint"},
+		{"мастер-ключ не base64", "", map[string]string{"DIARY_ENCRYPTION_MASTER_KEYS": "k1:не-ключ", "DIARY_ENCRYPTION_ACTIVE_KEY": "k1"}, `ключ "k1"`},
+		{"действующего ключа нет в списке", "", map[string]string{"DIARY_ENCRYPTION_MASTER_KEYS": "k1:" + key('a'), "DIARY_ENCRYPTION_ACTIVE_KEY": "k2"}, "encryption.active_key"},
+		{"ключ указан дважды", "", map[string]string{"DIARY_ENCRYPTION_MASTER_KEYS": "k1:" + key('a') + ",k1:" + key('b'), "DIARY_ENCRYPTION_ACTIVE_KEY": "k1"}, "указан дважды"},
+		{"действующий ключ без мастер-ключей", "", map[string]string{"DIARY_ENCRYPTION_ACTIVE_KEY": "k1"}, "master_keys пуст"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			cfg, err := Load(writeConfig(t, minimalYAML+tt.yaml))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Load = %v; ожидалась ошибка с %q", err, tt.want)
+			}
+			if cfg != nil {
+				t.Error("при ошибке возвращена конфигурация")
+			}
+		})
+	}
+
+	t.Run("файла нет", func(t *testing.T) {
+		clearEnv(t)
+		if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+			t.Fatal("ожидалась ошибка")
+		}
+	})
+}
+
+func TestValidateReportsAllErrors(t *testing.T) {
+	err := Default().Validate()
+	if err == nil {
+		t.Fatal("конфигурация по умолчанию без секретов прошла проверку")
+	}
+	for _, want := range []string{"database.dsn", "jwt.secret", "mail.from", "verification.code_secret"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("нет ошибки %s в %v", want, err)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// Package diff сравнивает две версии текста построчно: находит наибольшую общую
+// подпоследовательность строк и возвращает список строк с пометками (как diff -u).
+// Используется для сравнения ревизий заметок.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Виды строк в результате сравнения.
+const (
+	OpEqual  = "equal"  // Строка есть в обеих версиях
+	OpDelete = "delete" // Только в старой версии
+	OpInsert = "insert" // Только в новой версии
+)
+
+// MaxCells - ограничение на размер таблицы LCS (произведение числа различающихся строк).
+// Для больших изменений сравнение упрощается: вся изменившаяся середина текста
+// показывается как удаленная и добавленная заново.
+const MaxCells = 4_000_000
+
+// Line - строка результата. OldLine и NewLine - номера строки (с 1) в старой
+// и новой версии; 0, если строки в этой версии нет.
+type Line struct {
+	Op      string `json:"op"`
+	Text    string `json:"text"`
+	OldLine int    `json:"old_line,omitempty"`
+	NewLine int    `json:"new_line,omitempty"`
+}
+
+// Lines сравнивает тексты a (старый) и b (новый) построчно.
+func Lines(a, b string) []Line {
+	return compare(splitLines(a), splitLines(b))
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(strings.ReplaceAll(s, "\r\n", "\n"), "\n"), "\n")
+}
+
+func compare(a, b []string) []Line {
+	// Общие начало и конец не участвуют в поиске LCS
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	result := make([]Line, 0, len(a)+len(b))
+	for i := 0; i < prefix; i++ {
+		result = append(result, Line{Op: OpEqual, Text: a[i], OldLine: i + 1, NewLine: i + 1})
+	}
+	result = append(result, middle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix], prefix, prefix)...)
+	for i := suffix; i > 0; i-- {
+		result = append(result, Line{Op: OpEqual, Text: a[len(a)-i], OldLine: len(a) - i + 1, NewLine: len(b) - i + 1})
+	}
+	return result
+}
+
+// middle сравнивает различающуюся часть текстов; offA и offB - число строк перед ней.
+func middle(a, b []string, offA, offB int) []Line {
+	var result []Line
+	deleteAll := func(from int) {
+		for i := from; i < len(a); i++ {
+			result = append(result, Line{Op: OpDelete, Text: a[i], OldLine: offA + i + 1})
+		}
+	}
+	insertAll := func(from int) {
+		for j := from; j < len(b); j++ {
+			result = append(result, Line{Op: OpInsert, Text: b[j], NewLine: offB + j + 1})
+		}
+	}
+	if len(a)*len(b) == 0 || len(a)*len(b) > MaxCells {
+		deleteAll(0)
+		insertAll(0)
+		return result
+	}
+
+	// lcs[i][j] - длина LCS для a[i:] и b[j:]
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			result = append(result, Line{Op: OpEqual, Text: a[i], OldLine: offA + i + 1, NewLine: offB + j + 1})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			result = append(result, Line{Op: OpDelete, Text: a[i], OldLine: offA + i + 1})
+			i++
+		default:
+			result = append(result, Line{Op: OpInsert, Text: b[j], NewLine: offB + j + 1})
+			j++
+		}
+	}
+	deleteAll(i)
+	insertAll(j)
+	return result
+}
+
+// Unified форматирует результат Lines как унифицированный diff с context строками
+// контекста вокруг изменений. Заголовки файлов берутся из oldName и newName.
+// Если изменений нет, возвращается пустая строка.
+func Unified(lines []Line, oldName, newName string, context int) string {
+	var b strings.Builder
+	for start := 0; start < len(lines); {
+		// Следующее изменение
+		for start < len(lines) && lines[start].Op == OpEqual {
+			start++
+		}
+		if start == len(lines) {
+			break
+		}
+
+		// Блок: от context строк до изменения до context строк после последнего изменения,
+		// если между изменениями не больше 2*context одинаковых строк
+		from := max(start-context, 0)
+		to, equal := start, 0
+		for k := start; k < len(lines); k++ {
+			if lines[k].Op != OpEqual {
+				to, equal = k, 0
+				continue
+			}
+			if equal++; equal > 2*context {
+				break
+			}
+		}
+		to = min(to+context+1, len(lines))
+
+		if b.Len() == 0 {
+			fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+		}
+		oldStart, oldCount, newStart, newCount := hunkRange(lines[from:to])
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+		for _, l := range lines[from:to] {
+			switch l.Op {
+			case OpEqual:
+				b.WriteString(" ")
+			case OpDelete:
+				b.WriteString("-")
+			case OpInsert:
+				b.WriteString("+")
+			}
+			b.WriteString(l.Text)
+			b.WriteString("\n")
+		}
+		start = to
+	}
+	return b.String()
+}
+
+// hunkRange вычисляет начало и длину блока в старой и новой версии.
+func hunkRange(lines []Line) (oldStart, oldCount, newStart, newCount int) {
+	for _, l := range lines {
+		if l.OldLine > 0 {
+			if oldStart == 0 {
+				oldStart = l.OldLine
+			}
+			oldCount++
+		}
+		if l.NewLine > 0 {
+			if newStart == 0 {
+				newStart = l.NewLine
+			}
+			newCount++
+		}
+	}
+	return oldStart, oldCount, newStart, newCount
+}
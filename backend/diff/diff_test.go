@@ -0,0 +1,98 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// format записывает результат построчно: пометка как в diff -u, текст и номера строк.
+func format(lines []Line) string {
+	var b strings.Builder
+	for _, l := range lines {
+		mark := map[string]string{OpEqual: " ", OpDelete: "-", OpInsert: "+"}[l.Op]
+		fmt.Fprintf(&b, "%s%s %d,%d\n", mark, l.Text, l.OldLine, l.NewLine)
+	}
+	return b.String()
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name, a, b string
+		want       string
+	}{
+		{"без изменений", "a\nb\n", "a\nb", " a 1,1\n b 2,2\n"},
+		{"оба пустые", "", "", ""},
+		{"из пустого", "", "a\nb", "+a 0,1\n+b 0,2\n"},
+		{"в пустой", "a\nb", "", "-a 1,0\n-b 2,0\n"},
+		{"вставка в середину", "a\nc", "a\nb\nc", " a 1,1\n+b 0,2\n c 2,3\n"},
+		{"вставка в конец", "a", "a\nb", " a 1,1\n+b 0,2\n"},
+		{"удаление из начала", "a\nb\nc", "b\nc", "-a 1,0\n b 2,1\n c 3,2\n"},
+		{"замена строки", "a\nb\nc", "a\nB\nc", " a 1,1\n-b 2,0\n+B 0,2\n c 3,3\n"},
+		{"общая середина", "x\nb\ny", "b\nz", "-x 1,0\n b 2,1\n-y 3,0\n+z 0,2\n"},
+		{"окончания строк не важны", "a\r\nb\r\n", "a\nb\n", " a 1,1\n b 2,2\n"},
+		{"пустые строки", "a\n\nb", "a\nb", " a 1,1\n- 2,0\n b 3,2\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := format(Lines(tt.a, tt.b)); got != tt.want {
+				t.Errorf("Lines:\n%s\nожидалось:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinesMaxCells(t *testing.T) {
+	// Середина больше MaxCells сравнивается упрощенно: все старые строки удалены, все новые добавлены
+	n := 2001
+	a, b := make([]string, n), make([]string, n)
+	for i := range n {
+		a[i], b[i] = fmt.Sprintf("a%d", i), fmt.Sprintf("b%d", i)
+	}
+	b[n/2] = a[n/2] // Общая строка, которую полное сравнение нашло бы
+	lines := Lines("общее\n"+strings.Join(a, "\n"), "общее\n"+strings.Join(b, "\n"))
+	if len(lines) != 1+2*n || lines[0].Op != OpEqual || lines[1].Op != OpDelete || lines[n+1].Op != OpInsert {
+		t.Fatalf("строк %d: %+v", len(lines), lines[:2])
+	}
+}
+
+func TestUnified(t *testing.T) {
+	var a []string
+	for i := 1; i <= 12; i++ {
+		a = append(a, fmt.Sprintf("строка %d", i))
+	}
+	b := append([]string{}, a...)
+	b[1] = "вторая"
+	b = append(b[:10], b[11:]...) // Удалена строка 11
+
+	want := `--- old
++++ new
+@@ -1,5 +1,5 @@
+ строка 1
+-строка 2
++вторая
+ строка 3
+ строка 4
+ строка 5
+@@ -8,5 +8,4 @@
+ строка 8
+ строка 9
+ строка 10
+-строка 11
+ строка 12
+`
+	if got := Unified(Lines(strings.Join(a, "\n"), strings.Join(b, "\n")), "old", "new", 3); got != want {
+		t.Errorf("Unified:\n%s\nожидалось:\n%s", got, want)
+	}
+
+	// Изменения ближе 2*context строк друг к другу попадают в один блок
+	b[7] = "восьмая"
+	got := Unified(Lines(strings.Join(a, "\n"), strings.Join(b, "\n")), "old", "new", 3)
+	if strings.Count(got, "@@ ") != 1 || !strings.HasPrefix(got, "--- old\n+++ new\n@@ -1,12 +1,11 @@\n") {
+		t.Errorf("один блок:\n%s", got)
+	}
+
+	if got := Unified(Lines("a\nb", "a\nb"), "old", "new", 3); got != "" {
+		t.Errorf("без изменений: %q", got)
+	}
+}
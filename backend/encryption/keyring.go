@@ -0,0 +1,315 @@
+// Package encryption шифрует заголовки и тексты заметок в БД (config.EncryptionConfig).
+// У каждого пользователя свой ключ данных (AES-256-GCM), который хранится зашифрованным
+// мастер-ключом сервера. Store расшифровывает заметки прозрачно для обработчиков,
+// Rotator в фоне перешифровывает ключи данных и уже сохраненные заметки.
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"diary-backend/config"
+	"diary-backend/models"
+	"diary-backend/store"
+)
+
+// dataKeySize - длина ключа данных (AES-256).
+const dataKeySize = 32
+
+// Keyring - мастер-ключи сервера и кеш расшифрованных ключей данных.
+type Keyring struct {
+	keys   store.DataKeyStore
+	master map[string]cipher.AEAD
+	active string // ID мастер-ключа для новых и перешифрованных ключей данных
+
+	mu    sync.Mutex
+	cache map[int]cipher.AEAD // ID ключа данных -> шифр; ключ с этим ID никогда не меняется
+}
+
+// NewKeyring разбирает мастер-ключи из конфигурации.
+func NewKeyring(keys store.DataKeyStore, cfg config.EncryptionConfig) (*Keyring, error) {
+	raw, err := cfg.Keys()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := raw[cfg.ActiveKey]; !ok {
+		return nil, fmt.Errorf("мастер-ключа %q нет в списке", cfg.ActiveKey)
+	}
+	master := make(map[string]cipher.AEAD, len(raw))
+	for id, key := range raw {
+		if master[id], err = newGCM(key); err != nil {
+			return nil, err
+		}
+	}
+	return &Keyring{keys: keys, master: master, active: cfg.ActiveKey, cache: make(map[int]cipher.AEAD)}, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal шифрует plaintext случайным nonce и возвращает nonce вместе с шифртекстом.
+func seal(aead cipher.AEAD, plaintext, additional []byte) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+	return aead.Seal(nonce, nonce, plaintext, additional)
+}
+
+// open расшифровывает результат seal.
+func open(aead cipher.AEAD, sealed, additional []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("шифртекст короче nonce")
+	}
+	n := aead.NonceSize()
+	return aead.Open(nil, sealed[:n], sealed[n:], additional)
+}
+
+// wrapData - дополнительные данные при шифровании ключа данных: ключ одного пользователя
+// нельзя подложить другому.
+func wrapData(userID int) []byte {
+	return []byte("user:" + strconv.Itoa(userID))
+}
+
+// wrap шифрует ключ данных пользователя действующим мастер-ключом.
+func (kr *Keyring) wrap(userID int, key []byte) *models.DataKey {
+	sealed := seal(kr.master[kr.active], key, wrapData(userID))
+	return &models.DataKey{
+		UserID:      userID,
+		MasterKeyID: kr.active,
+		WrappedKey:  base64.StdEncoding.EncodeToString(sealed),
+	}
+}
+
+// unwrap расшифровывает ключ данных k.
+func (kr *Keyring) unwrap(k *models.DataKey) ([]byte, error) {
+	master, ok := kr.master[k.MasterKeyID]
+	if !ok {
+		return nil, fmt.Errorf("ключ данных %d зашифрован мастер-ключом %q, которого нет в конфигурации", k.ID, k.MasterKeyID)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(k.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("ключ данных %d: %w", k.ID, err)
+	}
+	key, err := open(master, sealed, wrapData(k.UserID))
+	if err != nil {
+		return nil, fmt.Errorf("не удалось расшифровать ключ данных %d: %w", k.ID, err)
+	}
+	return key, nil
+}
+
+// newDataKey создает ключ данных пользователя, еще не сохраненный в БД.
+func (kr *Keyring) newDataKey(userID int) *models.DataKey {
+	key := make([]byte, dataKeySize)
+	rand.Read(key)
+	return kr.wrap(userID, key)
+}
+
+// cipher возвращает шифр ключа данных k, расшифровывая ключ при первом обращении.
+func (kr *Keyring) cipher(k *models.DataKey) (cipher.AEAD, error) {
+	kr.mu.Lock()
+	aead, ok := kr.cache[k.ID]
+	kr.mu.Unlock()
+	if ok {
+		return aead, nil
+	}
+
+	key, err := kr.unwrap(k)
+	if err != nil {
+		return nil, err
+	}
+	if aead, err = newGCM(key); err != nil {
+		return nil, err
+	}
+	kr.mu.Lock()
+	kr.cache[k.ID] = aead
+	kr.mu.Unlock()
+	return aead, nil
+}
+
+// cipherByID возвращает шифр ключа данных id (в том числе выведенного из оборота).
+func (kr *Keyring) cipherByID(ctx context.Context, id int) (cipher.AEAD, error) {
+	kr.mu.Lock()
+	aead, ok := kr.cache[id]
+	kr.mu.Unlock()
+	if ok {
+		return aead, nil
+	}
+
+	k, err := kr.keys.GetDataKey(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("ключ данных %d: %w", id, err)
+	}
+	return kr.cipher(k)
+}
+
+// activeKey возвращает действующий ключ данных пользователя и его шифр. Ключ создается
+// при первой записи заметки пользователя.
+func (kr *Keyring) activeKey(ctx context.Context, userID int) (*models.DataKey, cipher.AEAD, error) {
+	k, err := kr.keys.ActiveDataKey(ctx, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		k = kr.newDataKey(userID)
+		err = kr.keys.CreateDataKey(ctx, k)
+		if errors.Is(err, store.ErrConflict) {
+			// Ключ только что создал параллельный запрос
+			k, err = kr.keys.ActiveDataKey(ctx, userID)
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := kr.cipher(k)
+	return k, aead, err
+}
+
+// Поля заметки, к которым привязан шифртекст (textAAD).
+const (
+	fieldTitle   = "title"
+	fieldContent = "content"
+)
+
+// textAAD - дополнительные данные шифртекста в формате models.CipherV2: заголовок или
+// текст нельзя подложить в другое поле, другую заметку или другому пользователю. Ревизии
+// шифруются так же, как заметка: одинаковые заголовок и текст заметки и ее последней
+// ревизии хранятся одинаковым шифртекстом.
+func textAAD(userID, noteID int, field string) []byte {
+	return []byte("note:" + strconv.Itoa(userID) + ":" + strconv.Itoa(noteID) + ":" + field)
+}
+
+// keyPrefix - начало текстов в формате models.CipherV1, зашифрованных ключом данных keyID.
+func keyPrefix(keyID int) string {
+	return models.EncryptedTextPrefix + strconv.Itoa(keyID) + ":"
+}
+
+// sealText шифрует text в формате models.CipherV2; пустой text остается пустым.
+func sealText(aead cipher.AEAD, text string, additional []byte) string {
+	if text == "" {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(seal(aead, []byte(text), additional))
+}
+
+// openText расшифровывает непустой text, зашифрованный c шифром aead ключа c.KeyID.
+func openText(aead cipher.AEAD, c models.TextCipher, text string, additional []byte) (string, error) {
+	encoded := text
+	switch c.Version {
+	case models.CipherV1:
+		var ok bool
+		if encoded, ok = strings.CutPrefix(text, keyPrefix(c.KeyID)); !ok {
+			return "", fmt.Errorf("текст не в формате %s ключа данных %d", models.EncryptedTextPrefix, c.KeyID)
+		}
+		additional = nil // До привязки к заметке тексты шифровались без дополнительных данных
+	case models.CipherV2:
+	default:
+		return "", fmt.Errorf("неизвестный формат зашифрованного текста %d", c.Version)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("неверный формат зашифрованного текста: %w", err)
+	}
+	plaintext, err := open(aead, sealed, additional)
+	if err != nil {
+		return "", fmt.Errorf("не удалось расшифровать текст ключом данных %d: %w", c.KeyID, err)
+	}
+	return string(plaintext), nil
+}
+
+// decrypt расшифровывает на месте заголовок и текст заметки noteID пользователя userID
+// (или ее ревизии), зашифрованные c. Нулевой c - открытые тексты, они остаются как есть.
+// content может быть nil: в списке ревизий текста нет.
+func (kr *Keyring) decrypt(ctx context.Context, c models.TextCipher, userID, noteID int, title, content *string) error {
+	if c == (models.TextCipher{}) {
+		return nil
+	}
+	aead, err := kr.cipherByID(ctx, c.KeyID)
+	if err != nil {
+		return err
+	}
+	for _, f := range []struct {
+		name string
+		text *string
+	}{{fieldTitle, title}, {fieldContent, content}} {
+		if f.text == nil || *f.text == "" {
+			continue
+		}
+		plain, err := openText(aead, c, *f.text, textAAD(userID, noteID, f.name))
+		if err != nil {
+			return err
+		}
+		*f.text = plain
+	}
+	return nil
+}
+
+// sealNote шифрует заголовок и текст n (n.ID уже назначен) ключом данных k и заполняет
+// n.Cipher. previous - та же заметка в том виде, как она лежит в БД (nil для новой):
+// неизменные заголовок и текст сохраняют прежний шифртекст. К хранилищу не обращается,
+// поэтому годится для store.DataKeyStore.CreateSealedNote.
+func (kr *Keyring) sealNote(k *models.DataKey, aead cipher.AEAD, n, previous *models.Note) {
+	if n.Title == "" && n.Content == "" {
+		// Нечего шифровать (в том числе у заметок со сквозным шифрованием): ключ не нужен
+		n.Cipher = models.TextCipher{}
+		return
+	}
+	next := models.TextCipher{KeyID: k.ID, Version: models.CipherV2}
+	var oldTitle, oldContent string
+	if previous != nil && previous.Cipher == next {
+		oldTitle, oldContent = previous.Title, previous.Content
+	}
+	for _, f := range []struct {
+		name string
+		text *string
+		old  string
+	}{{fieldTitle, &n.Title, oldTitle}, {fieldContent, &n.Content, oldContent}} {
+		additional := textAAD(n.UserID, n.ID, f.name)
+		if f.old != "" {
+			if plain, err := openText(aead, next, f.old, additional); err == nil && plain == *f.text {
+				*f.text = f.old
+				continue
+			}
+		}
+		*f.text = sealText(aead, *f.text, additional)
+	}
+	n.Cipher = next
+}
+
+// reencrypt перешифровывает заголовок и текст t действующим ключом данных пользователя
+// и заполняет t.Cipher. memo запоминает результат для одинаковых исходных текстов одного
+// поля заметки, чтобы заметка и ее ревизии с одинаковым шифртекстом его и сохранили.
+func (kr *Keyring) reencrypt(ctx context.Context, t *models.NoteText, memo map[string]string) error {
+	k, aead, err := kr.activeKey(ctx, t.UserID)
+	if err != nil {
+		return err
+	}
+	title, content := t.Title, t.Content
+	if err := kr.decrypt(ctx, t.Cipher, t.UserID, t.NoteID, &title, &content); err != nil {
+		return err
+	}
+	for _, f := range []struct {
+		name  string
+		text  *string
+		plain string
+	}{{fieldTitle, &t.Title, title}, {fieldContent, &t.Content, content}} {
+		if *f.text == "" {
+			continue
+		}
+		key := fmt.Sprintf("%d:%s:%d:%d:%s", t.NoteID, f.name, t.Cipher.KeyID, t.Cipher.Version, *f.text)
+		if _, ok := memo[key]; !ok {
+			memo[key] = sealText(aead, f.plain, textAAD(t.UserID, t.NoteID, f.name))
+		}
+		*f.text = memo[key]
+	}
+	t.Cipher = models.TextCipher{KeyID: k.ID, Version: models.CipherV2}
+	return nil
+}
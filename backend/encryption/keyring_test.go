@@ -0,0 +1,155 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"diary-backend/config"
+	"diary-backend/models"
+	"diary-backend/store/memory"
+)
+
+// testEncryption - конфигурация с мастер-ключами "old" и "new"; действующий - active.
+func testEncryption(active string) config.EncryptionConfig {
+	key := func(b byte) string {
+		return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+	}
+	return config.EncryptionConfig{
+		MasterKeys:     []string{"old:" + key('o'), "new:" + key('n')},
+		ActiveKey:      active,
+		RotateInterval: time.Hour,
+		BatchSize:      2,
+	}
+}
+
+func newTestKeyring(t *testing.T, st *memory.Store, cfg config.EncryptionConfig) *Keyring {
+	t.Helper()
+	ring, err := NewKeyring(st, cfg)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	return ring
+}
+
+func TestNewKeyringUnknownActiveKey(t *testing.T) {
+	if _, err := NewKeyring(memory.New(), testEncryption("missing")); err == nil {
+		t.Fatal("ожидалась ошибка: действующего мастер-ключа нет в списке")
+	}
+}
+
+func TestWrapUnwrap(t *testing.T) {
+	mem := memory.New()
+	old := newTestKeyring(t, mem, testEncryption("old"))
+	ring := newTestKeyring(t, mem, testEncryption("new"))
+	key := bytes.Repeat([]byte{7}, dataKeySize)
+
+	wrapped := old.wrap(7, key)
+	if wrapped.MasterKeyID != "old" || strings.Contains(wrapped.WrappedKey, base64.StdEncoding.EncodeToString(key)) {
+		t.Fatalf("ключ данных: %+v", wrapped)
+	}
+	// Прежний мастер-ключ остается в списке, пока ключи данных не перешифрованы
+	got, err := ring.unwrap(wrapped)
+	if err != nil || !bytes.Equal(got, key) {
+		t.Fatalf("unwrap = %x, %v", got, err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(k *models.DataKey)
+	}{
+		{"ключ другого пользователя", func(k *models.DataKey) { k.UserID = 8 }},
+		{"неизвестный мастер-ключ", func(k *models.DataKey) { k.MasterKeyID = "lost" }},
+		{"другой мастер-ключ", func(k *models.DataKey) { k.MasterKeyID = "new" }},
+		{"испорченный base64", func(k *models.DataKey) { k.WrappedKey = "не base64" }},
+		{"короче nonce", func(k *models.DataKey) { k.WrappedKey = "AAAA" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := *wrapped
+			tt.modify(&k)
+			if _, err := ring.unwrap(&k); err == nil {
+				t.Fatal("ожидалась ошибка")
+			}
+		})
+	}
+}
+
+func TestDecryptBindsText(t *testing.T) {
+	ctx := context.Background()
+	mem := memory.New()
+	ring := newTestKeyring(t, mem, testEncryption("new"))
+	k, aead, err := ring.activeKey(ctx, 1)
+	if err != nil {
+		t.Fatalf("activeKey: %v", err)
+	}
+
+	n := models.Note{ID: 5, UserID: 1, Title: "Заголовок", Content: "Текст"}
+	ring.sealNote(k, aead, &n, nil)
+	if n.Cipher != (models.TextCipher{KeyID: k.ID, Version: models.CipherV2}) || n.Title == "Заголовок" || n.Content == "Текст" {
+		t.Fatalf("зашифрованная заметка: %+v", n)
+	}
+	legacy := keyPrefix(k.ID) + base64.StdEncoding.EncodeToString(seal(aead, []byte("Старый текст"), nil))
+
+	tests := []struct {
+		name           string
+		cipher         models.TextCipher
+		userID, noteID int
+		title, content string
+		want           string // Ожидаемый заголовок; "" - ошибка
+	}{
+		{"та же заметка", n.Cipher, 1, 5, n.Title, n.Content, "Заголовок"},
+		{"заголовок и текст переставлены", n.Cipher, 1, 5, n.Content, n.Title, ""},
+		{"шифртекст другой заметки", n.Cipher, 1, 6, n.Title, n.Content, ""},
+		{"шифртекст другого пользователя", n.Cipher, 2, 5, n.Title, n.Content, ""},
+		{"неизвестный ключ данных", models.TextCipher{KeyID: 999, Version: models.CipherV2}, 1, 5, n.Title, n.Content, ""},
+		{"неизвестный формат", models.TextCipher{KeyID: k.ID, Version: 3}, 1, 5, n.Title, n.Content, ""},
+		{"открытый текст", models.TextCipher{}, 1, 5, "enc:v1:1:похоже на шифртекст", "", "enc:v1:1:похоже на шифртекст"},
+		{"формат v1 без привязки к заметке", models.TextCipher{KeyID: k.ID, Version: models.CipherV1}, 1, 6, legacy, "", "Старый текст"},
+		{"формат v2, помеченный как v1", models.TextCipher{KeyID: k.ID, Version: models.CipherV1}, 1, 5, n.Title, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			title, content := tt.title, tt.content
+			err := ring.decrypt(ctx, tt.cipher, tt.userID, tt.noteID, &title, &content)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("ожидалась ошибка, расшифровано %q", title)
+				}
+				return
+			}
+			if err != nil || title != tt.want {
+				t.Fatalf("decrypt = %q, %v; ожидался %q", title, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSealNoteKeepsUnchangedText(t *testing.T) {
+	ctx := context.Background()
+	mem := memory.New()
+	ring := newTestKeyring(t, mem, testEncryption("new"))
+	k, aead, _ := ring.activeKey(ctx, 1)
+
+	previous := models.Note{ID: 5, UserID: 1, Title: "Заголовок", Content: "Текст"}
+	ring.sealNote(k, aead, &previous, nil)
+
+	n := models.Note{ID: 5, UserID: 1, Title: "Заголовок", Content: "Новый текст"}
+	ring.sealNote(k, aead, &n, &previous)
+	if n.Title != previous.Title {
+		t.Error("неизменный заголовок зашифрован заново")
+	}
+	if n.Content == previous.Content {
+		t.Error("измененный текст сохранил прежний шифртекст")
+	}
+
+	// Заметка без открытого текста (сквозное шифрование) не привязывается к ключу
+	empty := models.Note{ID: 6, UserID: 1, Encrypted: &models.Envelope{Ciphertext: "x"}}
+	ring.sealNote(k, aead, &empty, nil)
+	if empty.Cipher != (models.TextCipher{}) {
+		t.Errorf("Cipher пустой заметки: %+v", empty.Cipher)
+	}
+}
@@ -0,0 +1,193 @@
+package encryption
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"diary-backend/config"
+	"diary-backend/models"
+	"diary-backend/store"
+)
+
+// Rotator периодически перешифровывает то, что зашифровано не действующими ключами:
+// ключи данных прежних мастер-ключей - действующим мастер-ключом, а заметки и ревизии,
+// открытые или зашифрованные выведенными из оборота ключами данных, - действующим ключом
+// данных владельца. Ключи данных старше cfg.DataKeyTTL заменяются новыми.
+type Rotator struct {
+	keys store.DataKeyStore
+	ring *Keyring
+	cfg  config.EncryptionConfig
+}
+
+// NewRotator создает фоновое перешифрование.
+func NewRotator(keys store.DataKeyStore, ring *Keyring, cfg config.EncryptionConfig) *Rotator {
+	return &Rotator{keys: keys, ring: ring, cfg: cfg}
+}
+
+// Run перешифровывает сразу и затем каждые cfg.RotateInterval, пока ctx не отменен.
+func (r *Rotator) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.RotateInterval)
+	defer ticker.Stop()
+
+	for {
+		r.rewrapDataKeys(ctx)
+		r.rotateDataKeys(ctx)
+		r.reencryptNotes(ctx)
+		r.deleteRetiredKeys(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// batches вызывает step, пока он обрабатывает полные пачки и хоть что-то в них меняет,
+// и возвращает общее число изменений. Ошибку step выводит в журнал с префиксом what.
+func (r *Rotator) batches(ctx context.Context, what string, step func() (found, done int, err error)) int {
+	total := 0
+	for {
+		found, done, err := step()
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("%s: %v", what, err)
+			}
+			break
+		}
+		total += done
+		if found < r.cfg.BatchSize || done == 0 {
+			break
+		}
+	}
+	return total
+}
+
+// rewrapDataKeys перешифровывает ключи данных действующим мастер-ключом после его смены.
+func (r *Rotator) rewrapDataKeys(ctx context.Context) {
+	total := r.batches(ctx, "Ошибка перешифрования ключей данных", func() (int, int, error) {
+		list, err := r.keys.ListDataKeysToRewrap(ctx, r.ring.active, r.cfg.BatchSize)
+		if err != nil {
+			return 0, 0, err
+		}
+		done := 0
+		for _, k := range list {
+			key, err := r.ring.unwrap(&k)
+			if err != nil {
+				log.Printf("Ошибка перешифрования ключей данных: %v", err)
+				continue
+			}
+			next := r.ring.wrap(k.UserID, key)
+			next.ID = k.ID
+			if err := r.keys.RewrapDataKey(ctx, next, k.MasterKeyID); err != nil {
+				if !errors.Is(err, store.ErrVersionConflict) {
+					log.Printf("Ошибка перешифрования ключа данных %d: %v", k.ID, err)
+				}
+				continue
+			}
+			done++
+		}
+		return len(list), done, nil
+	})
+	if total > 0 {
+		log.Printf("Ключей данных перешифровано мастер-ключом %q: %d", r.ring.active, total)
+	}
+}
+
+// rotateDataKeys заменяет ключи данных старше cfg.DataKeyTTL. Заметки, зашифрованные
+// замененными ключами, перешифровывает reencryptNotes.
+func (r *Rotator) rotateDataKeys(ctx context.Context) {
+	if r.cfg.DataKeyTTL == 0 {
+		return
+	}
+	total := r.batches(ctx, "Ошибка замены ключей данных", func() (int, int, error) {
+		list, err := r.keys.ListExpiredDataKeys(ctx, time.Now().Add(-r.cfg.DataKeyTTL), r.cfg.BatchSize)
+		if err != nil {
+			return 0, 0, err
+		}
+		done := 0
+		for _, k := range list {
+			if err := r.keys.RotateDataKey(ctx, k.ID, r.ring.newDataKey(k.UserID)); err != nil {
+				if !errors.Is(err, store.ErrConflict) {
+					log.Printf("Ошибка замены ключа данных %d: %v", k.ID, err)
+				}
+				continue
+			}
+			done++
+		}
+		return len(list), done, nil
+	})
+	if total > 0 {
+		log.Printf("Заменено ключей данных: %d", total)
+	}
+}
+
+// reencryptNotes шифрует действующими ключами данных заголовки и тексты заметок и ревизий:
+// открытые (сохраненные до включения шифрования) и зашифрованные замененными ключами.
+// Тексты обходятся по курсору, поэтому те, что не удалось перешифровать (например,
+// зашифрованные ключом, которого больше нет), не мешают остальным; их число выводится
+// в журнал в конце прохода. Возвращает число перешифрованных и неудавшихся текстов.
+func (r *Rotator) reencryptNotes(ctx context.Context) (done, failed int) {
+	var after models.NoteText
+	// Одинаковый шифртекст заметки и ее последней ревизии остается одинаковым: иначе
+	// следующее сохранение неизмененной заметки записало бы лишнюю ревизию. Тексты идут
+	// по порядку заметок, поэтому memo нужен только для текущей, даже на стыке страниц.
+	var memo map[string]string
+	for {
+		texts, err := r.keys.ListStaleNoteTexts(ctx, after, r.cfg.BatchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Ошибка перешифрования заметок: %v", err)
+			}
+			break
+		}
+		for _, t := range texts {
+			if memo == nil || t.NoteID != after.NoteID {
+				memo = map[string]string{}
+			}
+			after = t
+			next := t
+			if err := r.ring.reencrypt(ctx, &next, memo); err != nil {
+				log.Printf("Ошибка перешифрования заметки %d (ревизия %d): %v", t.NoteID, t.Revision, err)
+				failed++
+				continue
+			}
+			if err := r.keys.RewriteNoteText(ctx, t, next); err != nil {
+				// Конфликт: заметку изменили, и она уже сохранена действующим ключом
+				if !errors.Is(err, store.ErrVersionConflict) {
+					log.Printf("Ошибка перешифрования заметки %d (ревизия %d): %v", t.NoteID, t.Revision, err)
+					failed++
+				}
+				continue
+			}
+			done++
+		}
+		if len(texts) < r.cfg.BatchSize {
+			break
+		}
+	}
+	if done > 0 {
+		log.Printf("Перешифровано заметок и ревизий: %d", done)
+	}
+	if failed > 0 {
+		log.Printf("Не удалось перешифровать заметок и ревизий: %d; они остаются зашифрованными прежними ключами", failed)
+	}
+	return done, failed
+}
+
+// deleteRetiredKeys удаляет замененные ключи данных, которыми больше ничего не зашифровано.
+// Ключ удаляется не раньше следующего прохода после замены: запрос, начавший запись старым
+// ключом до замены, успеет завершиться, и его запись перешифрует reencryptNotes.
+func (r *Rotator) deleteRetiredKeys(ctx context.Context) {
+	n, err := r.keys.DeleteRetiredDataKeys(ctx, time.Now().Add(-r.cfg.RotateInterval))
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Ошибка удаления замененных ключей данных: %v", err)
+		}
+		return
+	}
+	if n > 0 {
+		log.Printf("Удалено замененных ключей данных: %d", n)
+	}
+}
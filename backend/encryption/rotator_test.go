@@ -0,0 +1,130 @@
+package encryption
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"diary-backend/models"
+	"diary-backend/store/memory"
+)
+
+func TestReencryptNotesSkipsUndecryptable(t *testing.T) {
+	ctx := context.Background()
+	mem := memory.New()
+	cfg := testEncryption("new")
+	r := NewRotator(mem, newTestKeyring(t, mem, cfg), cfg)
+
+	// Первые по порядку заметки зашифрованы ключом данных, которого нет: их тексты
+	// занимают целые страницы, но не должны мешать перешифровать остальные
+	lost := models.TextCipher{KeyID: 999, Version: models.CipherV2}
+	for range 2 {
+		mem.CreateNote(ctx, &models.Note{UserID: 1, Title: "AAAA", Content: "AAAA", Cipher: lost})
+	}
+	var plain []int
+	for range 3 {
+		n := &models.Note{UserID: 1, Title: "Открытая", Content: "Текст"}
+		mem.CreateNote(ctx, n)
+		plain = append(plain, n.ID)
+	}
+
+	// Каждая заметка - запись в notes и ревизия 1
+	done, failed := r.reencryptNotes(ctx)
+	if done != 6 || failed != 4 {
+		t.Fatalf("перешифровано %d, ошибок %d; ожидалось 6 и 4", done, failed)
+	}
+	for _, id := range plain {
+		n, _ := mem.GetNote(ctx, 1, id)
+		if n.Cipher.Version != models.CipherV2 || n.Title == "Открытая" || n.Content == "Текст" {
+			t.Errorf("заметка %d не зашифрована: %+v", id, n)
+		}
+	}
+
+	// Неудачные тексты остаются и снова попадают в отчет следующего прохода
+	if done, failed := r.reencryptNotes(ctx); done != 0 || failed != 4 {
+		t.Errorf("второй проход: перешифровано %d, ошибок %d; ожидалось 0 и 4", done, failed)
+	}
+}
+
+func TestReencryptNotesUpgradesV1(t *testing.T) {
+	ctx := context.Background()
+	mem := memory.New()
+	cfg := testEncryption("new")
+	cfg.BatchSize = 1 // Заметка и ревизия на разных страницах
+	ring := newTestKeyring(t, mem, cfg)
+	es := NewStore(mem, ring)
+
+	// Заметка, зашифрованная до привязки шифртекста к заметке
+	k, aead, _ := ring.activeKey(ctx, 1)
+	legacy := func(text string) string {
+		return keyPrefix(k.ID) + base64.StdEncoding.EncodeToString(seal(aead, []byte(text), nil))
+	}
+	n := &models.Note{UserID: 1, Title: legacy("Старая"), Content: legacy("Текст"), Cipher: models.TextCipher{KeyID: k.ID, Version: models.CipherV1}}
+	mem.CreateNote(ctx, n)
+	if got, err := es.GetNote(ctx, 1, n.ID); err != nil || got.Title != "Старая" {
+		t.Fatalf("GetNote до перешифрования = %+v, %v", got, err)
+	}
+
+	if done, failed := NewRotator(mem, ring, cfg).reencryptNotes(ctx); done != 2 || failed != 0 {
+		t.Fatalf("перешифровано %d, ошибок %d; ожидалось 2 и 0", done, failed)
+	}
+	raw, _ := mem.GetNote(ctx, 1, n.ID)
+	if raw.Cipher != (models.TextCipher{KeyID: k.ID, Version: models.CipherV2}) {
+		t.Fatalf("Cipher после перешифрования: %+v", raw.Cipher)
+	}
+	// Заметка и ее ревизия по-прежнему хранятся одинаковым шифртекстом
+	rev, _ := mem.GetNoteRevision(ctx, 1, n.ID, 1)
+	if rev.Title != raw.Title || rev.Content != raw.Content {
+		t.Error("шифртекст заметки и ревизии разошелся")
+	}
+	if got, err := es.GetNote(ctx, 1, n.ID); err != nil || got.Title != "Старая" || got.Content != "Текст" {
+		t.Fatalf("GetNote после перешифрования = %+v, %v", got, err)
+	}
+}
+
+func TestRotatorReplacesKeys(t *testing.T) {
+	ctx := context.Background()
+	mem := memory.New()
+	old := NewStore(mem, newTestKeyring(t, mem, testEncryption("old")))
+	var ids []int
+	for _, title := range []string{"Первая", "Вторая", "Третья"} {
+		n := &models.Note{UserID: 1, Title: title, Content: "Текст"}
+		old.CreateNote(ctx, n)
+		ids = append(ids, n.ID)
+	}
+	first, _ := mem.ActiveDataKey(ctx, 1)
+
+	// Новый мастер-ключ и истекший срок ключа данных
+	cfg := testEncryption("new")
+	cfg.DataKeyTTL = time.Nanosecond
+	cfg.RotateInterval = time.Nanosecond
+	ring := newTestKeyring(t, mem, cfg)
+	r := NewRotator(mem, ring, cfg)
+
+	r.rewrapDataKeys(ctx)
+	if k, _ := mem.GetDataKey(ctx, first.ID); k.MasterKeyID != "new" {
+		t.Fatalf("ключ данных не перешифрован новым мастер-ключом: %+v", k)
+	}
+	r.rotateDataKeys(ctx)
+	next, _ := mem.ActiveDataKey(ctx, 1)
+	if next.ID == first.ID {
+		t.Fatal("ключ данных не заменен")
+	}
+	if done, failed := r.reencryptNotes(ctx); done != 6 || failed != 0 {
+		t.Fatalf("перешифровано %d, ошибок %d; ожидалось 6 и 0", done, failed)
+	}
+	time.Sleep(time.Millisecond)
+	r.deleteRetiredKeys(ctx)
+	if _, err := mem.GetDataKey(ctx, first.ID); err == nil {
+		t.Error("замененный ключ данных не удален")
+	}
+
+	es := NewStore(mem, ring)
+	for i, id := range ids {
+		n, err := es.GetNote(ctx, 1, id)
+		if err != nil || n.Title != []string{"Первая", "Вторая", "Третья"}[i] {
+			t.Errorf("заметка %d после замены ключей: %+v, %v", id, n, err)
+		}
+	}
+}
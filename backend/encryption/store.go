@@ -0,0 +1,178 @@
+package encryption
+
+import (
+	"context"
+
+	"diary-backend/models"
+	"diary-backend/search"
+	"diary-backend/store"
+)
+
+// Store - store.Store, который шифрует заголовки и тексты заметок перед записью в БД
+// и расшифровывает их при чтении. Остальные методы передаются хранилищу как есть.
+//
+// Полнотекстовый поиск и сортировка по заголовку в БД по шифртексту невозможны, поэтому
+// для них Store читает и расшифровывает все заметки пользователя, как хранилище без
+// полнотекстового поиска.
+type Store struct {
+	store.Store
+	keys *Keyring
+}
+
+var _ store.Store = (*Store)(nil)
+
+// NewStore оборачивает хранилище st.
+func NewStore(st store.Store, keys *Keyring) *Store {
+	return &Store{Store: st, keys: keys}
+}
+
+func (s *Store) decryptNotes(ctx context.Context, notes []models.Note) ([]models.Note, error) {
+	for i := range notes {
+		if _, err := s.decryptNote(ctx, &notes[i]); err != nil {
+			return nil, err
+		}
+	}
+	return notes, nil
+}
+
+func (s *Store) decryptNote(ctx context.Context, n *models.Note) (*models.Note, error) {
+	if err := s.keys.decrypt(ctx, n.Cipher, n.UserID, n.ID, &n.Title, &n.Content); err != nil {
+		return nil, err
+	}
+	n.Cipher = models.TextCipher{}
+	return n, nil
+}
+
+// create сохраняет новую заметку: шифртекст привязан к ID заметки, поэтому заголовок
+// и текст шифруются внутри CreateSealedNote, когда ID уже назначен. n заполняется
+// результатом с открытыми заголовком и текстом.
+func (s *Store) create(ctx context.Context, n *models.Note, imported bool) error {
+	k, aead, err := s.keys.activeKey(ctx, n.UserID)
+	if err != nil {
+		return err
+	}
+	sealed := *n
+	sealed.Title, sealed.Content = "", ""
+	err = s.Store.CreateSealedNote(ctx, &sealed, imported, func(sn *models.Note) error {
+		sn.Title, sn.Content = n.Title, n.Content
+		s.keys.sealNote(k, aead, sn, nil)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sealed.Title, sealed.Content, sealed.Cipher = n.Title, n.Content, models.TextCipher{}
+	*n = sealed
+	return nil
+}
+
+func (s *Store) ListNotes(ctx context.Context, userID int) ([]models.Note, error) {
+	notes, err := s.Store.ListNotes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.decryptNotes(ctx, notes)
+}
+
+func (s *Store) QueryNotes(ctx context.Context, userID int, q store.NoteQuery) ([]models.Note, error) {
+	if q.Sort == store.NoteSortTitle {
+		notes, err := s.ListNotes(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		return store.FilterNotes(notes, q), nil
+	}
+	notes, err := s.Store.QueryNotes(ctx, userID, q)
+	if err != nil {
+		return nil, err
+	}
+	return s.decryptNotes(ctx, notes)
+}
+
+func (s *Store) SearchNotes(ctx context.Context, userID int, q search.Query, limit int) ([]models.NoteSearchResult, error) {
+	notes, err := s.ListNotes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return search.Filter(notes, q, limit), nil
+}
+
+func (s *Store) GetNote(ctx context.Context, userID, noteID int) (*models.Note, error) {
+	n, err := s.Store.GetNote(ctx, userID, noteID)
+	if err != nil {
+		return nil, err
+	}
+	return s.decryptNote(ctx, n)
+}
+
+func (s *Store) CreateNote(ctx context.Context, n *models.Note) error {
+	return s.create(ctx, n, false)
+}
+
+func (s *Store) ImportNote(ctx context.Context, n *models.Note) error {
+	return s.create(ctx, n, true)
+}
+
+func (s *Store) UpdateNote(ctx context.Context, n *models.Note) error {
+	// Неизменные заголовок и текст сохраняются прежним шифртекстом: со свежим nonce
+	// хранилище сочло бы их изменившимися и записало лишнюю ревизию
+	current, err := s.Store.GetNote(ctx, n.UserID, n.ID)
+	if err != nil {
+		return err
+	}
+	k, aead, err := s.keys.activeKey(ctx, n.UserID)
+	if err != nil {
+		return err
+	}
+	sealed := *n
+	s.keys.sealNote(k, aead, &sealed, current)
+	if err := s.Store.UpdateNote(ctx, &sealed); err != nil {
+		return err
+	}
+	sealed.Title, sealed.Content, sealed.Cipher = n.Title, n.Content, models.TextCipher{}
+	*n = sealed
+	return nil
+}
+
+func (s *Store) ListNoteRevisions(ctx context.Context, userID, noteID int) ([]models.NoteRevision, error) {
+	revisions, err := s.Store.ListNoteRevisions(ctx, userID, noteID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range revisions {
+		r := &revisions[i]
+		if err := s.keys.decrypt(ctx, r.Cipher, userID, r.NoteID, &r.Title, nil); err != nil {
+			return nil, err
+		}
+		r.Cipher = models.TextCipher{}
+	}
+	return revisions, nil
+}
+
+func (s *Store) GetNoteRevision(ctx context.Context, userID, noteID, rev int) (*models.NoteRevision, error) {
+	r, err := s.Store.GetNoteRevision(ctx, userID, noteID, rev)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.keys.decrypt(ctx, r.Cipher, userID, r.NoteID, &r.Title, &r.Content); err != nil {
+		return nil, err
+	}
+	r.Cipher = models.TextCipher{}
+	return r, nil
+}
+
+func (s *Store) ListTrash(ctx context.Context, userID int) ([]models.Note, error) {
+	notes, err := s.Store.ListTrash(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.decryptNotes(ctx, notes)
+}
+
+func (s *Store) RestoreNote(ctx context.Context, userID, noteID int) (*models.Note, error) {
+	n, err := s.Store.RestoreNote(ctx, userID, noteID)
+	if err != nil {
+		return nil, err
+	}
+	return s.decryptNote(ctx, n)
+}
@@ -0,0 +1,119 @@
+package encryption
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"diary-backend/models"
+	"diary-backend/search"
+	"diary-backend/store/memory"
+)
+
+func TestStoreEncryptsNotes(t *testing.T) {
+	ctx := context.Background()
+	mem := memory.New()
+	es := NewStore(mem, newTestKeyring(t, mem, testEncryption("new")))
+
+	n := &models.Note{UserID: 1, Title: "Отпуск", Content: "Поехали на море", Tags: []string{"лето"}}
+	if err := es.CreateNote(ctx, n); err != nil {
+		t.Fatalf("CreateNote: %v", err)
+	}
+	if n.ID == 0 || n.Title != "Отпуск" || n.Content != "Поехали на море" || n.Cipher != (models.TextCipher{}) {
+		t.Fatalf("созданная заметка: %+v", n)
+	}
+
+	// В хранилище - шифртекст, помеченный ключом данных
+	raw, _ := mem.GetNote(ctx, 1, n.ID)
+	key, _ := mem.ActiveDataKey(ctx, 1)
+	if raw.Cipher != (models.TextCipher{KeyID: key.ID, Version: models.CipherV2}) ||
+		strings.Contains(raw.Title, "Отпуск") || strings.Contains(raw.Content, "море") {
+		t.Fatalf("заметка в хранилище: %+v", raw)
+	}
+
+	got, err := es.GetNote(ctx, 1, n.ID)
+	if err != nil || got.Title != "Отпуск" || got.Content != "Поехали на море" {
+		t.Fatalf("GetNote = %+v, %v", got, err)
+	}
+
+	// Неизменный заголовок сохраняет шифртекст; сохранение без изменений не пишет ревизию
+	n.Content = "Поехали в горы"
+	if err := es.UpdateNote(ctx, n); err != nil || n.Content != "Поехали в горы" {
+		t.Fatalf("UpdateNote = %+v, %v", n, err)
+	}
+	updated, _ := mem.GetNote(ctx, 1, n.ID)
+	if updated.Title != raw.Title || updated.Content == raw.Content {
+		t.Error("заголовок зашифрован заново или текст не изменился")
+	}
+	if err := es.UpdateNote(ctx, n); err != nil {
+		t.Fatalf("UpdateNote: %v", err)
+	}
+
+	revisions, err := es.ListNoteRevisions(ctx, 1, n.ID)
+	if err != nil || len(revisions) != 2 || revisions[0].Title != "Отпуск" || revisions[1].Title != "Отпуск" {
+		t.Fatalf("ListNoteRevisions = %+v, %v", revisions, err)
+	}
+	first, err := es.GetNoteRevision(ctx, 1, n.ID, 1)
+	if err != nil || first.Content != "Поехали на море" {
+		t.Fatalf("GetNoteRevision = %+v, %v", first, err)
+	}
+
+	q, _ := search.Parse("горы")
+	results, err := es.SearchNotes(ctx, 1, q, 10)
+	if err != nil || len(results) != 1 || results[0].ID != n.ID {
+		t.Errorf("SearchNotes = %+v, %v", results, err)
+	}
+
+	if err := es.DeleteNote(ctx, 1, n.ID); err != nil {
+		t.Fatalf("DeleteNote: %v", err)
+	}
+	trash, err := es.ListTrash(ctx, 1)
+	if err != nil || len(trash) != 1 || trash[0].Title != "Отпуск" {
+		t.Fatalf("ListTrash = %+v, %v", trash, err)
+	}
+	restored, err := es.RestoreNote(ctx, 1, n.ID)
+	if err != nil || restored.Content != "Поехали в горы" {
+		t.Fatalf("RestoreNote = %+v, %v", restored, err)
+	}
+}
+
+func TestStoreImportKeepsDates(t *testing.T) {
+	ctx := context.Background()
+	mem := memory.New()
+	es := NewStore(mem, newTestKeyring(t, mem, testEncryption("new")))
+
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	n := &models.Note{UserID: 1, Title: "Старая запись", Content: "Текст", CreatedAt: created, UpdatedAt: created}
+	if err := es.ImportNote(ctx, n); err != nil {
+		t.Fatalf("ImportNote: %v", err)
+	}
+	got, err := es.GetNote(ctx, 1, n.ID)
+	if err != nil || got.Title != "Старая запись" || !got.CreatedAt.Equal(created) {
+		t.Fatalf("GetNote = %+v, %v", got, err)
+	}
+}
+
+func TestStoreRejectsMovedCiphertext(t *testing.T) {
+	ctx := context.Background()
+	mem := memory.New()
+	es := NewStore(mem, newTestKeyring(t, mem, testEncryption("new")))
+
+	a := &models.Note{UserID: 1, Title: "A", Content: "Секрет заметки A"}
+	b := &models.Note{UserID: 1, Title: "B", Content: "Текст B"}
+	es.CreateNote(ctx, a)
+	es.CreateNote(ctx, b)
+
+	// Подмена в БД: текст заметки A записан в заметку B тем же ключом
+	rawA, _ := mem.GetNote(ctx, 1, a.ID)
+	rawB, _ := mem.GetNote(ctx, 1, b.ID)
+	old := models.NoteText{UserID: 1, NoteID: b.ID, Title: rawB.Title, Content: rawB.Content, Cipher: rawB.Cipher}
+	moved := old
+	moved.Content = rawA.Content
+	if err := mem.RewriteNoteText(ctx, old, moved); err != nil {
+		t.Fatalf("RewriteNoteText: %v", err)
+	}
+	if got, err := es.GetNote(ctx, 1, b.ID); err == nil {
+		t.Fatalf("чужой шифртекст расшифрован: %+v", got)
+	}
+}
@@ -0,0 +1,60 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"diary-backend/models"
+)
+
+// csvWriter пишет таблицу с заголовком id, title, content, tags, created_at, updated_at, encrypted.
+// Теги перечисляются через "; ". У зашифрованных заметок encrypted - конверт в JSON,
+// у открытых пусто. Файл начинается с BOM, чтобы Excel распознал UTF-8.
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSV(w io.Writer) (*csvWriter, error) {
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return nil, err
+	}
+	c := &csvWriter{w: csv.NewWriter(w)}
+	return c, c.w.Write([]string{"id", "title", "content", "tags", "created_at", "updated_at", "encrypted"})
+}
+
+func (c *csvWriter) WriteNote(n *models.Note) error {
+	var encrypted []byte
+	if n.Encrypted != nil {
+		var err error
+		if encrypted, err = json.Marshal(n.Encrypted); err != nil {
+			return err
+		}
+	}
+	return c.w.Write([]string{
+		strconv.Itoa(n.ID),
+		escapeFormula(n.Title),
+		escapeFormula(n.Content),
+		escapeFormula(strings.Join(n.Tags, "; ")),
+		n.CreatedAt.UTC().Format(time.RFC3339),
+		n.UpdatedAt.UTC().Format(time.RFC3339),
+		string(encrypted),
+	})
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// escapeFormula защищает от выполнения ячейки как формулы в табличных редакторах
+// (CSV injection): значение, начинающееся с =, +, -, @ или табуляции, получает префикс '.
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,93 @@
+// Package export выгружает дневник пользователя в переносимые форматы: JSON со всеми
+// метаданными, ZIP с Markdown-файлом на каждую заметку и CSV. Заметки передаются
+// писателю по одной, поэтому выгрузка не требует держать весь дневник в памяти.
+package export
+
+import (
+	"io"
+	"time"
+
+	"diary-backend/models"
+)
+
+// Поддерживаемые форматы выгрузки.
+const (
+	FormatJSON     = "json"
+	FormatMarkdown = "markdown"
+	FormatCSV      = "csv"
+)
+
+// Version - версия структуры JSON-выгрузки; меняется при несовместимых изменениях.
+const Version = 1
+
+// Header - данные выгрузки, известные до первой заметки.
+type Header struct {
+	ExportedAt time.Time
+	User       *models.User
+	Tags       []models.Tag
+}
+
+// Writer записывает заметки в выбранном формате. Close дописывает окончание
+// выгрузки и должен вызываться после последней заметки.
+type Writer interface {
+	WriteNote(n *models.Note) error
+	Close() error
+}
+
+// New создает писателя формата format поверх w. ok == false - неизвестный формат.
+func New(format string, w io.Writer, h Header) (wr Writer, ok bool, err error) {
+	switch format {
+	case FormatJSON:
+		wr, err = newJSON(w, h)
+	case FormatMarkdown:
+		wr = newMarkdown(w)
+	case FormatCSV:
+		wr, err = newCSV(w)
+	default:
+		return nil, false, nil
+	}
+	return wr, true, err
+}
+
+// ContentType возвращает MIME-тип и расширение файла выгрузки формата format.
+func ContentType(format string) (mimeType, ext string) {
+	switch format {
+	case FormatMarkdown:
+		return "application/zip", "zip"
+	case FormatCSV:
+		return "text/csv; charset=utf-8", "csv"
+	default:
+		return "application/json", "json"
+	}
+}
+
+// note - заметка в выгрузке: без служебных полей API (user_id, устаревший tag).
+type note struct {
+	ID        int       `json:"id" yaml:"id"`
+	Title     string    `json:"title" yaml:"title"`
+	Content   string    `json:"content" yaml:"-"`
+	Tags      []string  `json:"tags" yaml:"tags"`
+	Version   int       `json:"version" yaml:"-"`
+	CreatedAt time.Time `json:"created_at" yaml:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" yaml:"updated_at"`
+
+	// Зашифрованные заметки выгружаются как есть: расшифровать их может только клиент
+	Encrypted *models.Envelope `json:"encrypted,omitempty" yaml:"encrypted,omitempty"`
+}
+
+func fromModel(n *models.Note) note {
+	tags := n.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return note{
+		ID:        n.ID,
+		Title:     n.Title,
+		Content:   n.Content,
+		Tags:      tags,
+		Encrypted: n.Encrypted,
+		Version:   n.Version,
+		CreatedAt: n.CreatedAt.UTC(),
+		UpdatedAt: n.UpdatedAt.UTC(),
+	}
+}
@@ -0,0 +1,169 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"diary-backend/models"
+)
+
+var update = flag.Bool("update", false, "перезаписать эталонные файлы в testdata")
+
+// golden сравнивает got с testdata/name; с -update перезаписывает эталон.
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("эталон %s: %v", path, err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("эталон %s: %v", path, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s не совпадает с эталоном:\n%s\nожидалось:\n%s", name, got, want)
+	}
+}
+
+// testExport выгружает тестовый дневник в формате format.
+func testExport(t *testing.T, format string) []byte {
+	t.Helper()
+
+	msk := time.FixedZone("MSK", 3*60*60) // Время в выгрузке всегда в UTC
+	at := func(day, hour int) time.Time { return time.Date(2024, 1, day, hour, 0, 0, 0, msk) }
+	h := Header{
+		ExportedAt: at(10, 12),
+		User:       &models.User{ID: 7, Username: "ivan", Email: "ivan@example.com", Locale: "ru", PasswordHash: "секрет", CreatedAt: at(1, 9)},
+		Tags:       []models.Tag{{ID: 1, Name: "дом", Color: "#ff0000", NoteCount: 1, CreatedAt: at(1, 10)}, {ID: 2, Name: "работа", CreatedAt: at(1, 11)}},
+	}
+	notes := []models.Note{
+		{ID: 1, UserID: 7, Title: "Первая запись", Content: "Текст\nв две строки", Tags: []string{"дом", "работа"}, Version: 2, CreatedAt: at(2, 8), UpdatedAt: at(3, 8)},
+		{ID: 2, UserID: 7, Title: `Цитата "в кавычках", с запятой`, Content: "=СУММ(A1:A2)\n", Tag: "устаревший", Version: 1, CreatedAt: at(4, 8), UpdatedAt: at(4, 8)},
+		{ID: 3, UserID: 7, Tags: []string{"дом"}, Version: 1, CreatedAt: at(5, 8), UpdatedAt: at(5, 8),
+			Encrypted: &models.Envelope{Algorithm: "AES-256-GCM", Ciphertext: "Y2lwaGVy", Nonce: "bm9uY2U=", WrappedKey: "a2V5"}},
+	}
+
+	var buf bytes.Buffer
+	wr, ok, err := New(format, &buf, h)
+	if !ok || err != nil {
+		t.Fatalf("New(%q) = %v, %v", format, ok, err)
+	}
+	for i := range notes {
+		if err := wr.WriteNote(&notes[i]); err != nil {
+			t.Fatalf("WriteNote: %v", err)
+		}
+	}
+	if err := wr.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestJSON(t *testing.T) {
+	data := testExport(t, FormatJSON)
+	if !json.Valid(data) || !bytes.HasSuffix(data, []byte("]}\n")) {
+		t.Fatalf("выгрузка не JSON: %s", data)
+	}
+	if bytes.Contains(data, []byte("секрет")) {
+		t.Error("в выгрузку попал хеш пароля")
+	}
+	// Эталон с отступами, чтобы его было удобно читать
+	var indented bytes.Buffer
+	json.Indent(&indented, data, "", "  ")
+	golden(t, "export.json", indented.Bytes())
+
+	// Выгрузка без заметок - тоже корректный JSON
+	var buf bytes.Buffer
+	wr, _, _ := New(FormatJSON, &buf, Header{User: &models.User{}})
+	wr.Close()
+	if !json.Valid(buf.Bytes()) || !strings.Contains(buf.String(), `"notes":[]}`) {
+		t.Errorf("пустая выгрузка: %s", buf.Bytes())
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	data := testExport(t, FormatMarkdown)
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("архив: %v", err)
+	}
+
+	// Эталон - файлы архива подряд: имя, время изменения и содержимое
+	var out bytes.Buffer
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		body, _ := io.ReadAll(rc)
+		rc.Close()
+		fmt.Fprintf(&out, "=== %s (%s)\n%s", f.Name, f.Modified.UTC().Format(time.RFC3339), body)
+	}
+	golden(t, "export.md.txt", out.Bytes())
+}
+
+func TestCSV(t *testing.T) {
+	data := testExport(t, FormatCSV)
+	golden(t, "export.csv", data)
+
+	// Кавычки, запятые и переводы строк переживают разбор обратно
+	records, err := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff")))).ReadAll()
+	if err != nil || len(records) != 4 {
+		t.Fatalf("разбор CSV: %d строк, %v", len(records), err)
+	}
+	if records[1][2] != "Текст\nв две строки" || records[2][1] != `Цитата "в кавычках", с запятой` || records[2][2] != "'=СУММ(A1:A2)\n" {
+		t.Errorf("строки после разбора: %q", records[1:3])
+	}
+}
+
+func TestNewUnknownFormat(t *testing.T) {
+	if _, ok, _ := New("xml", io.Discard, Header{}); ok {
+		t.Error("ожидался ok == false для неизвестного формата")
+	}
+}
+
+func TestSlug(t *testing.T) {
+	tests := []struct{ title, want string }{
+		{"Первая запись", "первая-запись"},
+		{"  Hello, World!  ", "hello-world"},
+		{"C++ и Go 1.25", "c-и-go-1-25"},
+		{"!!!", "note"},
+		{"", "note"},
+		{strings.Repeat("я", 60), strings.Repeat("я", maxSlugLength)},
+	}
+	for _, tt := range tests {
+		if got := slug(tt.title); got != tt.want {
+			t.Errorf("slug(%q) = %q, ожидалось %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestEscapeFormula(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"=1+1", "'=1+1"},
+		{"+7 900", "'+7 900"},
+		{"-минус", "'-минус"},
+		{"@cmd", "'@cmd"},
+		{"\tтаб", "'\tтаб"},
+		{"обычный текст = 1", "обычный текст = 1"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := escapeFormula(tt.in); got != tt.want {
+			t.Errorf("escapeFormula(%q) = %q, ожидалось %q", tt.in, got, tt.want)
+		}
+	}
+}
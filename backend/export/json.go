@@ -0,0 +1,86 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"diary-backend/models"
+)
+
+// jsonHeader - начало JSON-выгрузки; массив notes дописывается по одной заметке.
+type jsonHeader struct {
+	Format     string     `json:"format"`
+	Version    int        `json:"version"`
+	ExportedAt time.Time  `json:"exported_at"`
+	User       jsonUser   `json:"user"`
+	Tags       []jsonTag  `json:"tags"`
+	Notes      []struct{} `json:"notes"` // Пустой массив; заметки пишутся вместо его конца
+}
+
+type jsonUser struct {
+	ID        int       `json:"id"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	Locale    string    `json:"locale"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type jsonTag struct {
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type jsonWriter struct {
+	w     io.Writer
+	first bool
+}
+
+func newJSON(w io.Writer, h Header) (*jsonWriter, error) {
+	header := jsonHeader{
+		Format:     "diary-export",
+		Version:    Version,
+		ExportedAt: h.ExportedAt.UTC(),
+		User: jsonUser{
+			ID:        h.User.ID,
+			Username:  h.User.Username,
+			Email:     h.User.Email,
+			Locale:    h.User.Locale,
+			CreatedAt: h.User.CreatedAt.UTC(),
+		},
+		Tags:  make([]jsonTag, 0, len(h.Tags)),
+		Notes: []struct{}{},
+	}
+	for _, t := range h.Tags {
+		header.Tags = append(header.Tags, jsonTag{Name: t.Name, Color: t.Color, CreatedAt: t.CreatedAt.UTC()})
+	}
+
+	data, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	// Отрезаем "]}" пустого массива notes: заметки и окончание допишут WriteNote и Close
+	if _, err := w.Write(data[:len(data)-2]); err != nil {
+		return nil, err
+	}
+	return &jsonWriter{w: w, first: true}, nil
+}
+
+func (j *jsonWriter) WriteNote(n *models.Note) error {
+	data, err := json.Marshal(fromModel(n))
+	if err != nil {
+		return err
+	}
+	if !j.first {
+		data = append([]byte{','}, data...)
+	}
+	j.first = false
+	_, err = j.w.Write(data)
+	return err
+}
+
+func (j *jsonWriter) Close() error {
+	_, err := io.WriteString(j.w, "]}\n")
+	return err
+}
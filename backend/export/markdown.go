@@ -0,0 +1,87 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+
+	"diary-backend/models"
+)
+
+// maxSlugLength - символов заголовка в имени файла заметки.
+const maxSlugLength = 50
+
+// markdownWriter пишет ZIP-архив с файлом notes/<дата>-<id>-<заголовок>.md на каждую
+// заметку. Файл начинается с YAML front matter (id, title, tags, created_at, updated_at),
+// за которым следует текст заметки.
+type markdownWriter struct {
+	zw *zip.Writer
+}
+
+func newMarkdown(w io.Writer) *markdownWriter {
+	return &markdownWriter{zw: zip.NewWriter(w)}
+}
+
+func (m *markdownWriter) WriteNote(n *models.Note) error {
+	front, err := yaml.Marshal(fromModel(n))
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("---\n")
+	buf.Write(front)
+	buf.WriteString("---\n\n")
+	buf.WriteString(n.Content)
+	if n.Content != "" && !strings.HasSuffix(n.Content, "\n") {
+		buf.WriteString("\n")
+	}
+
+	f, err := m.zw.CreateHeader(&zip.FileHeader{
+		Name:     fmt.Sprintf("notes/%s-%d-%s.md", n.CreatedAt.UTC().Format("2006-01-02"), n.ID, slug(n.Title)),
+		Method:   zip.Deflate,
+		Modified: n.UpdatedAt.UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(buf.Bytes())
+	return err
+}
+
+func (m *markdownWriter) Close() error {
+	return m.zw.Close()
+}
+
+// slug превращает заголовок в часть имени файла: буквы и цифры в нижнем регистре
+// (кириллица сохраняется), остальное - дефисы.
+func slug(title string) string {
+	var b strings.Builder
+	count := 0
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if count == maxSlugLength {
+			break
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+				count++
+			}
+			b.WriteRune(r)
+			count++
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	if b.Len() == 0 {
+		return "note"
+	}
+	return b.String()
+}
@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"diary-backend/blobstore"
+	"diary-backend/config"
+	"diary-backend/middleware"
+	"diary-backend/models"
+	"diary-backend/store"
+
+	"github.com/gorilla/mux"
+)
+
+// Ограничения загрузки вложений (размер файла и квота - в config.AttachmentsConfig).
+const (
+	maxAttachmentMemory = 1 << 20 // Байт формы в памяти; остальное multipart пишет во временный файл
+	maxFilenameLength   = 255     // Символов в имени файла
+)
+
+// writeAttachmentError выбирает HTTP-статус для ошибки при работе с вложениями.
+func writeAttachmentError(w http.ResponseWriter, err error) {
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Заметка или вложение не найдены", http.StatusNotFound)
+		return
+	}
+	http.Error(w, "Ошибка работы с вложениями: "+err.Error(), http.StatusInternalServerError)
+}
+
+// attachmentIDFromPath читает {attachmentId} из пути запроса.
+func attachmentIDFromPath(w http.ResponseWriter, r *http.Request) (int, bool) {
+	attachmentID, err := strconv.Atoi(mux.Vars(r)["attachmentId"])
+	if err != nil {
+		http.Error(w, "Неверный ID вложения", http.StatusBadRequest)
+		return 0, false
+	}
+	return attachmentID, true
+}
+
+// UploadAttachment обрабатывает POST /notes/{id}/attachments: multipart/form-data с
+// файлом в поле file. Тип файла определяется по содержимому и должен входить в
+// cfg.AllowedTypes; размер ограничен cfg.MaxSize, все вложения пользователя - cfg.UserQuota.
+func UploadAttachment(attachments store.AttachmentStore, notes store.NoteStore, blobs blobstore.BlobStore, cfg config.AttachmentsConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := middleware.GetUserIDFromContext(r.Context()) // UserID гарантирован middleware
+		noteID, ok := noteIDFromPath(w, r)
+		if !ok {
+			return
+		}
+		if _, err := notes.GetNote(r.Context(), userID, noteID); err != nil {
+			writeAttachmentError(w, err)
+			return
+		}
+
+		// 1. Файл из формы
+		tooLarge := fmt.Sprintf("Файл больше %d МБ", cfg.MaxSize>>20)
+		r.Body = http.MaxBytesReader(w, r.Body, cfg.MaxSize+1<<20) // Запас на поля и границы формы
+		if err := r.ParseMultipartForm(maxAttachmentMemory); err != nil {
+			var maxBytes *http.MaxBytesError
+			if errors.As(err, &maxBytes) {
+				http.Error(w, tooLarge, http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Ожидается multipart/form-data с файлом в поле file", http.StatusBadRequest)
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "Не передан файл в поле file", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		switch {
+		case header.Size == 0:
+			http.Error(w, "Файл пуст", http.StatusBadRequest)
+			return
+		case header.Size > cfg.MaxSize:
+			http.Error(w, tooLarge, http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		// 2. Квота: окончательно проверяется при сохранении, здесь - чтобы не загружать файл зря
+		used, err := attachments.AttachmentUsage(r.Context(), userID)
+		if err != nil {
+			writeAttachmentError(w, err)
+			return
+		}
+		if used+header.Size > cfg.UserQuota {
+			writeQuotaExceeded(w, cfg)
+			return
+		}
+
+		// 3. Тип по содержимому: расширению и Content-Type клиента не доверяем
+		contentType, err := sniffContentType(file)
+		if err != nil {
+			http.Error(w, "Ошибка чтения файла", http.StatusInternalServerError)
+			return
+		}
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		if !slices.Contains(cfg.AllowedTypes, mediaType) {
+			http.Error(w, "Тип файла "+mediaType+" не поддерживается", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		// 4. Содержимое в хранилище, затем запись о вложении
+		key, err := newBlobKey(userID)
+		if err != nil {
+			http.Error(w, "Ошибка сохранения файла", http.StatusInternalServerError)
+			return
+		}
+		hash := sha256.New()
+		if err := blobs.Put(r.Context(), key, io.TeeReader(file, hash), header.Size, contentType); err != nil {
+			log.Printf("Ошибка сохранения вложения пользователя %d: %v", userID, err)
+			http.Error(w, "Ошибка сохранения файла", http.StatusInternalServerError)
+			return
+		}
+
+		a := models.Attachment{
+			NoteID:      noteID,
+			UserID:      userID,
+			BlobKey:     key,
+			Filename:    attachmentFilename(header.Filename),
+			ContentType: contentType,
+			Size:        header.Size,
+			SHA256:      hex.EncodeToString(hash.Sum(nil)),
+		}
+		if err := attachments.CreateAttachment(r.Context(), &a, cfg.UserQuota); err != nil {
+			deleteBlob(context.WithoutCancel(r.Context()), blobs, key)
+			if errors.Is(err, store.ErrQuotaExceeded) {
+				writeQuotaExceeded(w, cfg)
+				return
+			}
+			writeAttachmentError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", fmt.Sprintf("/notes/%d/attachments/%d", noteID, a.ID))
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(a)
+	}
+}
+
+// ListAttachments обрабатывает GET /notes/{id}/attachments: вложения заметки в порядке добавления.
+func ListAttachments(attachments store.AttachmentStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := middleware.GetUserIDFromContext(r.Context())
+		noteID, ok := noteIDFromPath(w, r)
+		if !ok {
+			return
+		}
+
+		list, err := attachments.ListAttachments(r.Context(), userID, noteID)
+		if err != nil {
+			writeAttachmentError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	}
+}
+
+// DownloadAttachment обрабатывает GET /notes/{id}/attachments/{attachmentId}: содержимое
+// файла с типом, определенным при загрузке. Поддерживаются запросы Range и условные
+// запросы по ETag (SHA-256 содержимого). Изображения отдаются для показа в браузере,
+// остальные файлы и любые файлы с ?download=1 - для сохранения.
+func DownloadAttachment(attachments store.AttachmentStore, blobs blobstore.BlobStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := middleware.GetUserIDFromContext(r.Context())
+		noteID, ok := noteIDFromPath(w, r)
+		if !ok {
+			return
+		}
+		attachmentID, ok := attachmentIDFromPath(w, r)
+		if !ok {
+			return
+		}
+
+		a, err := attachments.GetAttachment(r.Context(), userID, noteID, attachmentID)
+		if err != nil {
+			writeAttachmentError(w, err)
+			return
+		}
+		content, err := blobs.Open(r.Context(), a.BlobKey)
+		if err != nil {
+			log.Printf("Ошибка чтения содержимого вложения %d: %v", a.ID, err)
+			http.Error(w, "Ошибка чтения файла", http.StatusInternalServerError)
+			return
+		}
+		defer content.Close()
+
+		disposition := "attachment"
+		if strings.HasPrefix(a.ContentType, "image/") && r.URL.Query().Get("download") != "1" {
+			disposition = "inline"
+		}
+		w.Header().Set("Content-Type", a.ContentType)
+		w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": a.Filename}))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+		w.Header().Set("ETag", `"`+a.SHA256+`"`)
+		w.Header().Set("Cache-Control", "private, max-age=31536000, immutable") // Содержимое вложения не меняется
+		http.ServeContent(w, r, "", a.CreatedAt, content)
+	}
+}
+
+// DeleteAttachment обрабатывает DELETE /notes/{id}/attachments/{attachmentId}.
+// Если содержимое не удалось удалить сразу, его удалит фоновая очистка.
+func DeleteAttachment(attachments store.AttachmentStore, blobs blobstore.BlobStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := middleware.GetUserIDFromContext(r.Context())
+		noteID, ok := noteIDFromPath(w, r)
+		if !ok {
+			return
+		}
+		attachmentID, ok := attachmentIDFromPath(w, r)
+		if !ok {
+			return
+		}
+
+		a, err := attachments.DeleteAttachment(r.Context(), userID, noteID, attachmentID)
+		if err != nil {
+			writeAttachmentError(w, err)
+			return
+		}
+		ctx := context.WithoutCancel(r.Context())
+		if deleteBlob(ctx, blobs, a.BlobKey) {
+			if err := attachments.DeleteOrphanAttachment(ctx, a.ID); err != nil {
+				log.Printf("Ошибка удаления вложения %d: %v", a.ID, err)
+			}
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func writeQuotaExceeded(w http.ResponseWriter, cfg config.AttachmentsConfig) {
+	http.Error(w, fmt.Sprintf("Превышена квота вложений (%d МБ)", cfg.UserQuota>>20), http.StatusRequestEntityTooLarge)
+}
+
+// deleteBlob удаляет содержимое вложения и сообщает, удалось ли это. Ошибка
+// только записывается в журнал: клиенту она уже не важна.
+func deleteBlob(ctx context.Context, blobs blobstore.BlobStore, key string) bool {
+	if err := blobs.Delete(ctx, key); err != nil {
+		log.Printf("Ошибка удаления содержимого вложения %s: %v", key, err)
+		return false
+	}
+	return true
+}
+
+// sniffContentType определяет MIME-тип по первым 512 байтам (http.DetectContentType)
+// и возвращает файл к началу.
+func sniffContentType(f io.ReadSeeker) (string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(head[:n]), nil
+}
+
+// newBlobKey создает ключ содержимого нового вложения. Ключ случайный и не зависит
+// от имени файла.
+func newBlobKey(userID int) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("users/%d/%s", userID, hex.EncodeToString(b)), nil
+}
+
+// attachmentFilename оставляет от имени файла клиента только последний компонент пути
+// без управляющих символов.
+func attachmentFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name))
+	if name == "" || name == "." || name == "/" {
+		return "file"
+	}
+	if runes := []rune(name); len(runes) > maxFilenameLength {
+		name = string(runes[:maxFilenameLength])
+	}
+	return name
+}
@@ -0,0 +1,179 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+
+	"diary-backend/blobstore"
+	"diary-backend/config"
+	"diary-backend/handlers"
+	"diary-backend/models"
+)
+
+// pngHeader - начало PNG-файла, по которому http.DetectContentType определяет image/png.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00")
+
+// withAttachments добавляет маршруты вложений с содержимым в памяти и настройками cfg.
+func (s *testServer) withAttachments(cfg config.AttachmentsConfig) {
+	blobs := blobstore.NewMemory()
+	s.router.Handle("/notes/{id}/attachments", s.auth(handlers.UploadAttachment(s.store, s.store, blobs, cfg))).Methods("POST")
+	s.router.Handle("/notes/{id}/attachments/{attachmentId:[0-9]+}", s.auth(handlers.DownloadAttachment(s.store, blobs))).Methods("GET")
+}
+
+// upload загружает файл в поле file формы; partType - Content-Type, заявленный клиентом.
+func (s *testServer) upload(t *testing.T, token string, noteID int, filename, partType string, data []byte) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename=%q`, filename))
+	header.Set("Content-Type", partType)
+	part, err := form.CreatePart(header)
+	if err != nil {
+		t.Fatalf("форма: %v", err)
+	}
+	part.Write(data)
+	form.Close()
+
+	return s.do(t, "POST", fmt.Sprintf("/notes/%d/attachments", noteID), token, body.Bytes(), "Content-Type", form.FormDataContentType())
+}
+
+func testAttachmentsConfig() config.AttachmentsConfig {
+	cfg := config.Default().Attachments
+	cfg.Driver = "memory"
+	cfg.MaxSize = 4 << 10
+	cfg.UserQuota = 6 << 10
+	return cfg
+}
+
+func TestUploadAttachment(t *testing.T) {
+	s := newTestServer(t)
+	s.withAttachments(testAttachmentsConfig())
+	token := s.signup(t, "files@example.com")
+	other := s.signup(t, "stranger@example.com")
+	note, _ := s.createNote(t, token, models.Note{Title: "С файлами", Content: "Текст"})
+
+	tests := []struct {
+		name     string
+		token    string
+		filename string
+		partType string
+		data     []byte
+		want     int
+		wantType string
+	}{
+		// Тип определяется по содержимому, а не по имени файла и заявленному типу
+		{"PNG под видом текста", token, "photo.txt", "text/plain", pngHeader, http.StatusCreated, "image/png"},
+		{"текст под видом PDF", token, "report.pdf", "application/pdf", []byte("обычный текст"), http.StatusCreated, "text/plain; charset=utf-8"},
+		{"неразрешенный тип", token, "picture.png", "image/png", []byte("MZ\x90\x00\x03\x00\x00\x00\x04\x00\x00\x00\xff\xff\x00\x00"), http.StatusUnsupportedMediaType, ""},
+		{"пустой файл", token, "empty.txt", "text/plain", nil, http.StatusBadRequest, ""},
+		{"больше max_size", token, "big.txt", "text/plain", bytes.Repeat([]byte("a"), 5<<10), http.StatusRequestEntityTooLarge, ""},
+		{"чужая заметка", other, "photo.png", "image/png", pngHeader, http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.upload(t, tt.token, note.ID, tt.filename, tt.partType, tt.data)
+			if rec.Code != tt.want {
+				t.Fatalf("код ответа %d, ожидался %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if rec.Code != http.StatusCreated {
+				return
+			}
+
+			var a models.Attachment
+			decode(t, rec, &a)
+			if a.ContentType != tt.wantType || a.Filename != tt.filename || a.Size != int64(len(tt.data)) {
+				t.Errorf("вложение %+v: ожидался тип %q", a, tt.wantType)
+			}
+			if want := fmt.Sprintf("/notes/%d/attachments/%d", note.ID, a.ID); rec.Header().Get("Location") != want {
+				t.Errorf("Location %q, ожидался %q", rec.Header().Get("Location"), want)
+			}
+		})
+	}
+}
+
+func TestDownloadAttachmentRange(t *testing.T) {
+	s := newTestServer(t)
+	s.withAttachments(testAttachmentsConfig())
+	token := s.signup(t, "range@example.com")
+	other := s.signup(t, "peeker@example.com")
+	note, _ := s.createNote(t, token, models.Note{Title: "С файлом", Content: "Текст"})
+
+	content := []byte("0123456789abcdefghij")
+	rec := s.upload(t, token, note.ID, "digits.txt", "text/plain", content)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("загрузка: %d %s", rec.Code, rec.Body)
+	}
+	var a models.Attachment
+	decode(t, rec, &a)
+	path := fmt.Sprintf("/notes/%d/attachments/%d", note.ID, a.ID)
+
+	tests := []struct {
+		name      string
+		token     string
+		header    []string
+		want      int
+		wantBody  string
+		wantRange string
+	}{
+		{"файл целиком", token, nil, http.StatusOK, string(content), ""},
+		{"диапазон", token, []string{"Range", "bytes=5-9"}, http.StatusPartialContent, "56789", "bytes 5-9/20"},
+		{"хвост файла", token, []string{"Range", "bytes=-4"}, http.StatusPartialContent, "ghij", "bytes 16-19/20"},
+		{"диапазон за концом файла", token, []string{"Range", "bytes=100-"}, http.StatusRequestedRangeNotSatisfiable, "", ""},
+		{"совпадающий If-None-Match", token, []string{"If-None-Match", `"` + a.SHA256 + `"`}, http.StatusNotModified, "", ""},
+		{"чужое вложение", other, nil, http.StatusNotFound, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(t, "GET", path, tt.token, nil, tt.header...)
+			if rec.Code != tt.want {
+				t.Fatalf("код ответа %d, ожидался %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if tt.wantBody == "" {
+				return
+			}
+
+			if body, _ := io.ReadAll(rec.Body); string(body) != tt.wantBody {
+				t.Errorf("содержимое %q, ожидалось %q", body, tt.wantBody)
+			}
+			if got := rec.Header().Get("Content-Range"); got != tt.wantRange {
+				t.Errorf("Content-Range %q, ожидался %q", got, tt.wantRange)
+			}
+			if rec.Header().Get("Content-Type") != "text/plain; charset=utf-8" || rec.Header().Get("X-Content-Type-Options") != "nosniff" {
+				t.Errorf("заголовки ответа: %v", rec.Header())
+			}
+		})
+	}
+}
+
+func TestUploadAttachmentQuota(t *testing.T) {
+	s := newTestServer(t)
+	s.withAttachments(testAttachmentsConfig()) // Файл до 4 КБ, квота 6 КБ
+	token := s.signup(t, "quota@example.com")
+	note, _ := s.createNote(t, token, models.Note{Title: "Квота", Content: "Текст"})
+	file := bytes.Repeat([]byte("a"), 3<<10)
+
+	for i, want := range []int{http.StatusCreated, http.StatusCreated, http.StatusRequestEntityTooLarge} {
+		if rec := s.upload(t, token, note.ID, "part.txt", "text/plain", file); rec.Code != want {
+			t.Fatalf("загрузка %d: код ответа %d, ожидался %d: %s", i+1, rec.Code, want, rec.Body)
+		}
+	}
+
+	// Отклоненный файл не занимает квоту
+	user, err := s.store.GetUserByEmail(context.Background(), "quota@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	used, err := s.store.AttachmentUsage(context.Background(), user.ID)
+	if err != nil || used != 6<<10 {
+		t.Fatalf("занято %d байт, ожидалось %d: %v", used, 6<<10, err)
+	}
+}
@@ -1,134 +1,138 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
-	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 
+	"diary-backend/config"
+	"diary-backend/mailer"
 	"diary-backend/models"
+	"diary-backend/store"
 	"diary-backend/utils"
 )
 
 // RegisterHandler обрабатывает регистрацию пользователя с верификацией по email
-func RegisterHandler(db *sql.DB) http.HandlerFunc {
-    return func(w http.ResponseWriter, r *http.Request) {
-        var req models.RegisterRequest
-        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-            http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
-            return
-        }
-
-        // НОВАЯ ПРОВЕРКА: Валидация пароля
-        if !utils.ValidatePassword(req.Password) {
-            http.Error(w, "Пароль должен состоять из 8 символов, строчной и заглавной буквы, спец. символа(!@#$%^&*)", http.StatusBadRequest)
-            return
-        }
-        
-        // --- 1. Генерация данных для верификации ---
-        code := utils.GenerateVerificationCode()
-        expiryTime := time.Now().Add(15 * time.Minute) // Код действует 15 минут
+func RegisterHandler(users store.UserStore, emails *mailer.Templates, verification config.VerificationConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.RegisterRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+			return
+		}
 
-        // 2. Хеширование пароля
-        hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-        if err != nil {
-            http.Error(w, "Ошибка хеширования пароля", http.StatusInternalServerError)
-            return
-        }
+		// НОВАЯ ПРОВЕРКА: Валидация пароля
+		if !utils.ValidatePassword(req.Password) {
+			http.Error(w, "Пароль должен состоять из 8 символов, строчной и заглавной буквы, спец. символа(!@#$%^&*)", http.StatusBadRequest)
+			return
+		}
 
-        // --- 3. Вставка в БД с полями верификации ---
-        var userID int
-        sqlStatement := `
-            INSERT INTO users 
-                (username, email, password_hash, is_verified, verification_code, code_expiry_time, created_at)
-            VALUES 
-                ($1, $2, $3, $4, $5, $6, NOW()) 
-            RETURNING id
-        `
-        
-        err = db.QueryRow(sqlStatement, 
-            req.Username, 
-            req.Email, 
-            string(hashedPassword), 
-            false,           // is_verified = false по умолчанию
-            code,            // код верификации
-            expiryTime,      // время истечения
-        ).Scan(&userID)
+		// --- 1. Генерация данных для верификации ---
+		code := utils.GenerateVerificationCode()
+		expiryTime := time.Now().Add(verification.CodeTTL) // Срок действия кода задается в конфигурации
 
-        if err != nil {
-            // Обработка ошибки дубликата (например, нарушена уникальность email)
-            if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" { // 23505 - код ошибки уникальности PostgreSQL
-                http.Error(w, "Пользователь с таким email уже зарегистрирован", http.StatusConflict)
-                return
-            }
-            // Другие ошибки БД
-            http.Error(w, "Ошибка регистрации пользователя: "+err.Error(), http.StatusInternalServerError)
-            return 
-        }
+		// 2. Хеширование пароля
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			http.Error(w, "Ошибка хеширования пароля", http.StatusInternalServerError)
+			return
+		}
 
-        // --- 4. Отправка письма ---
-        // Отправляем письмо только после успешной регистрации и сохранения в БД
-        emailErr := utils.SendVerificationEmail(req.Email, code)
-        
-        if emailErr != nil {
-            // Если письмо не отправлено, логируем ошибку, но клиенту можно вернуть успешный статус
-            // или статус с предупреждением. В этом примере возвращаем ошибку сервера, 
-            // чтобы уведомить пользователя, что что-то пошло не так.
-            http.Error(w, "Регистрация успешна, но не удалось отправить верификационное письмо. Попробуйте войти позже.", http.StatusInternalServerError)
-            return
-        }
+		// --- 3. Сохранение пользователя с полями верификации и письма с кодом в очередь ---
+		// Письмо ставится в очередь в той же транзакции и отправляется в фоне (mailer.Worker),
+		// поэтому медленный или недоступный SMTP не влияет на регистрацию.
+		user := models.User{
+			Username:             req.Username,
+			Email:                req.Email,
+			PasswordHash:         string(hashedPassword),
+			IsVerified:           false, // is_verified = false по умолчанию
+			Locale:               requestLocale(r, emails, req.Locale),
+			VerificationCodeHash: utils.HashVerificationCode(verification.CodeSecret, code),
+			CodeExpiryTime:       expiryTime,
+		}
+		msg, err := emails.Verification(user.Locale, req.Email, mailer.CodeData{Username: req.Username, Code: code, TTL: verification.CodeTTL})
+		if err != nil {
+			http.Error(w, "Ошибка формирования письма", http.StatusInternalServerError)
+			return
+		}
+		if err := users.CreateUser(r.Context(), &user, mailer.Queued(models.EmailKindVerification, msg)); err != nil {
+			// Обработка ошибки дубликата (нарушена уникальность email)
+			if errors.Is(err, store.ErrConflict) {
+				http.Error(w, "Пользователь с таким email уже зарегистрирован", http.StatusConflict)
+				return
+			}
+			// Другие ошибки БД
+			http.Error(w, "Ошибка регистрации пользователя: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
 
-        // 5. Успешный ответ
-        w.WriteHeader(http.StatusCreated)
-        json.NewEncoder(w).Encode(map[string]string{
-            "message": "Аккаунт создан. Проверьте почту для верификации.",
-            "email": req.Email, // Возвращаем email, чтобы фронтенд мог направить на страницу верификации
-        })
-    }
+		// 4. Успешный ответ
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Аккаунт создан. Проверьте почту для верификации.",
+			"email":   req.Email, // Возвращаем email, чтобы фронтенд мог направить на страницу верификации
+		})
+	}
 }
 
-func LoginHandler(db *sql.DB) http.HandlerFunc {
+func LoginHandler(users store.UserStore, refresh store.TokenStore, tokens *utils.TokenManager, twoFactor config.TwoFactorConfig) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req models.LoginRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
-			return 
+			return
 		}
 
 		// 1. Поиск пользователя и хеша
-		var user models.User
-		var passwordHash string
-		// err := db.QueryRow("SELECT id, password_hash FROM users WHERE username = $1", req.Username).Scan(&user.ID, &passwordHash)
-		err := db.QueryRow("SELECT id, password_hash FROM users WHERE email = $1", req.Email).Scan(&user.ID, &passwordHash) 
-		// Примечание: req.Username будет содержать email, который ввел пользователь.
-
+		// Примечание: req.Email содержит email, который ввел пользователь (поле "username" в JSON).
+		user, err := users.GetUserByEmail(r.Context(), req.Email)
 		if err != nil {
-			if err == sql.ErrNoRows {
+			if errors.Is(err, store.ErrNotFound) {
 				http.Error(w, "Неверное имя пользователя или пароль", http.StatusUnauthorized)
-				return 
+				return
 			}
 			http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
 			return
 		}
 
 		// 2. Сравнение паролей
-		if err = bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(req.Password)); err != nil {
+		if err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
 			http.Error(w, "Неверное имя пользователя или пароль", http.StatusUnauthorized)
-			return 
+			return
 		}
 
-		// 3. Создание JWT-токена
-		tokenString, err := utils.GenerateToken(user.ID)
+		// 3. Если включена 2FA - вместо токенов выдаем промежуточный токен для /auth/2fa/verify.
+		// Пока ввод кода заблокирован после неверных попыток, промежуточный токен не выдается.
+		if writeTOTPLocked(w, user) {
+			return
+		}
+		if user.TOTPEnabled {
+			mfaToken, err := tokens.GenerateMFAToken(user.ID, twoFactor.TokenTTL)
+			if err != nil {
+				http.Error(w, "Ошибка генерации токена", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(models.MFAChallengeResponse{
+				MFARequired: true,
+				MFAToken:    mfaToken,
+				ExpiresIn:   int(twoFactor.TokenTTL.Seconds()),
+			})
+			return
+		}
+
+		// 4. Создание JWT-токена и токена обновления
+		resp, err := issueTokens(r.Context(), tokens, refresh, user.ID)
 		if err != nil {
 			http.Error(w, "Ошибка генерации токена", http.StatusInternalServerError)
-			return 
+			return
 		}
 
-		// 4. Ответ с токеном
+		// 5. Ответ с токенами
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"token": tokenString})
+		json.NewEncoder(w).Encode(resp)
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,135 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"diary-backend/models"
+)
+
+func TestRegisterHandler(t *testing.T) {
+	s := newTestServer(t)
+	s.signup(t, "taken@example.com")
+
+	tests := []struct {
+		name string
+		body any
+		want int
+	}{
+		{"новый пользователь", models.RegisterRequest{Username: "new", Email: "new@example.com", Password: testPassword}, http.StatusCreated},
+		{"слабый пароль", models.RegisterRequest{Username: "weak", Email: "weak@example.com", Password: "password"}, http.StatusBadRequest},
+		{"email занят", models.RegisterRequest{Username: "dup", Email: "taken@example.com", Password: testPassword}, http.StatusConflict},
+		{"неверный JSON", []byte("{"), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(t, "POST", "/register", "", tt.body)
+			if rec.Code != tt.want {
+				t.Fatalf("код ответа %d, ожидался %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+
+	// Письмо с кодом ставится в очередь вместе с пользователем
+	if code := s.queuedCode(t, "new@example.com", models.EmailKindVerification); len(code) != 6 {
+		t.Errorf("код в письме: %q", code)
+	}
+}
+
+func TestVerifyHandler(t *testing.T) {
+	s := newTestServer(t)
+	s.signup(t, "verified@example.com")
+	rec := s.do(t, "POST", "/register", "", models.RegisterRequest{Username: "u", Email: "pending@example.com", Password: testPassword})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("регистрация: %d %s", rec.Code, rec.Body)
+	}
+	code := s.queuedCode(t, "pending@example.com", models.EmailKindVerification)
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+
+	tests := []struct {
+		name string
+		req  models.VerificationRequest
+		want int
+	}{
+		{"неизвестный email", models.VerificationRequest{Email: "nobody@example.com", Code: code}, http.StatusNotFound},
+		{"неверный код", models.VerificationRequest{Email: "pending@example.com", Code: wrong}, http.StatusUnauthorized},
+		{"верный код", models.VerificationRequest{Email: "pending@example.com", Code: code}, http.StatusOK},
+		{"повторная верификация", models.VerificationRequest{Email: "pending@example.com", Code: code}, http.StatusBadRequest},
+		{"уже верифицирован", models.VerificationRequest{Email: "verified@example.com", Code: code}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(t, "POST", "/verify", "", tt.req)
+			if rec.Code != tt.want {
+				t.Fatalf("код ответа %d, ожидался %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestLoginHandler(t *testing.T) {
+	s := newTestServer(t)
+	s.signup(t, "login@example.com")
+
+	tests := []struct {
+		name string
+		body any
+		want int
+	}{
+		{"верный пароль", models.LoginRequest{Email: "login@example.com", Password: testPassword}, http.StatusOK},
+		{"неверный пароль", models.LoginRequest{Email: "login@example.com", Password: "Wr0ngPass!"}, http.StatusUnauthorized},
+		{"неизвестный email", models.LoginRequest{Email: "nobody@example.com", Password: testPassword}, http.StatusUnauthorized},
+		{"неверный JSON", []byte("{"), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(t, "POST", "/login", "", tt.body)
+			if rec.Code != tt.want {
+				t.Fatalf("код ответа %d, ожидался %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+
+			var resp models.TokenResponse
+			decode(t, rec, &resp)
+			if resp.Token == "" || resp.RefreshToken == "" {
+				t.Fatalf("в ответе нет токенов: %s", rec.Body)
+			}
+			if rec := s.do(t, "GET", "/notes", resp.Token, nil); rec.Code != http.StatusOK {
+				t.Fatalf("GET /notes с новым токеном: %d %s", rec.Code, rec.Body)
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	s := newTestServer(t)
+	token := s.signup(t, "mw@example.com")
+	loggedOut := s.signup(t, "out@example.com")
+	if rec := s.do(t, "POST", "/auth/logout", loggedOut, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("выход: %d %s", rec.Code, rec.Body)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"действующий токен", token, http.StatusOK},
+		{"без токена", "", http.StatusUnauthorized},
+		{"поддельный токен", token + "x", http.StatusUnauthorized},
+		{"отозванный токен", loggedOut, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(t, "GET", "/notes", tt.token, nil)
+			if rec.Code != tt.want {
+				t.Fatalf("код ответа %d, ожидался %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"diary-backend/middleware"
+	"diary-backend/models"
+	"diary-backend/store"
+)
+
+// Ограничения конверта зашифрованной заметки (размеры - после декодирования base64).
+const (
+	gcmNonceSize      = 12
+	gcmTagSize        = 16
+	wrappedKeySize    = 40        // 32-байтный ключ после AES-KW
+	maxCiphertextSize = 640 << 10 // Заголовок и текст максимальной длины в JSON с запасом на экранирование
+)
+
+// Ограничения параметров вывода ключа из парольной фразы (models.UserKeys). Нижние
+// границы - рекомендации OWASP; верхние защищают клиентов от зависания при разборе.
+const (
+	minPBKDF2Iterations = 600_000
+	maxPBKDF2Iterations = 10_000_000
+	minArgon2Iterations = 2
+	maxArgon2Iterations = 100
+	minArgon2Memory     = 19 << 10 // КиБ
+	maxArgon2Memory     = 4 << 20  // КиБ
+	maxArgon2Threads    = 16
+	minSaltSize         = 16
+	maxSaltSize         = 64
+	maxKeysBody         = 4 << 10 // Байт тела PUT /e2ee/keys
+)
+
+// decodeBase64 декодирует поле name конверта или ключей и проверяет длину результата.
+func decodeBase64(name, value string, minSize, maxSize int) error {
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return fmt.Errorf("Поле %s должно быть в base64", name)
+	}
+	if len(data) < minSize || len(data) > maxSize {
+		if minSize == maxSize {
+			return fmt.Errorf("Поле %s должно содержать %d байт", name, minSize)
+		}
+		return fmt.Errorf("Поле %s должно содержать от %d до %d байт", name, minSize, maxSize)
+	}
+	return nil
+}
+
+// validateEncryptedNote проверяет заметку с конвертом e: заголовок и текст у нее только
+// в шифртексте, а поля конверта - base64 подходящей длины. Без конверта проверять нечего.
+func validateEncryptedNote(title, content string, e *models.Envelope) error {
+	if e == nil {
+		return nil
+	}
+	if title != "" || content != "" {
+		return errors.New("Заметка зашифрована: заголовок и текст передаются только в поле encrypted, title и content должны быть пустыми")
+	}
+	if e.Algorithm != models.EnvelopeAES256GCM {
+		return fmt.Errorf("Поле encrypted.alg: поддерживается только %s", models.EnvelopeAES256GCM)
+	}
+	if err := decodeBase64("encrypted.ciphertext", e.Ciphertext, gcmTagSize+1, maxCiphertextSize); err != nil {
+		return err
+	}
+	if err := decodeBase64("encrypted.nonce", e.Nonce, gcmNonceSize, gcmNonceSize); err != nil {
+		return err
+	}
+	return decodeBase64("encrypted.wrapped_key", e.WrappedKey, wrappedKeySize, wrappedKeySize)
+}
+
+// validateUserKeys проверяет параметры KDF, соль и зашифрованный мастер-ключ.
+func validateUserKeys(k *models.UserKeys) error {
+	switch k.KDF {
+	case models.KDFPBKDF2:
+		if k.Iterations < minPBKDF2Iterations || k.Iterations > maxPBKDF2Iterations {
+			return fmt.Errorf("Для %s iterations должно быть от %d до %d", k.KDF, minPBKDF2Iterations, maxPBKDF2Iterations)
+		}
+		if k.Memory != 0 || k.Parallelism != 0 {
+			return fmt.Errorf("Для %s поля memory и parallelism не используются", k.KDF)
+		}
+	case models.KDFArgon2id:
+		if k.Iterations < minArgon2Iterations || k.Iterations > maxArgon2Iterations {
+			return fmt.Errorf("Для %s iterations должно быть от %d до %d", k.KDF, minArgon2Iterations, maxArgon2Iterations)
+		}
+		if k.Memory < minArgon2Memory || k.Memory > maxArgon2Memory {
+			return fmt.Errorf("Для %s memory должно быть от %d до %d КиБ", k.KDF, minArgon2Memory, maxArgon2Memory)
+		}
+		if k.Parallelism < 1 || k.Parallelism > maxArgon2Threads {
+			return fmt.Errorf("Для %s parallelism должно быть от 1 до %d", k.KDF, maxArgon2Threads)
+		}
+	default:
+		return fmt.Errorf("Поле kdf: ожидается %s или %s", models.KDFPBKDF2, models.KDFArgon2id)
+	}
+	if err := decodeBase64("salt", k.Salt, minSaltSize, maxSaltSize); err != nil {
+		return err
+	}
+	return decodeBase64("wrapped_master_key", k.WrappedMasterKey, wrappedKeySize, wrappedKeySize)
+}
+
+// userKeysETag возвращает ETag ключей: он меняется с каждой их заменой.
+func userKeysETag(k *models.UserKeys) string {
+	return `"` + strconv.Itoa(k.Version) + `"`
+}
+
+// GetUserKeys обрабатывает GET /e2ee/keys: параметры KDF, соль и зашифрованный мастер-ключ,
+// по которым клиент восстанавливает мастер-ключ из парольной фразы. 404 - пользователь
+// еще не включал шифрование.
+func GetUserKeys(keys store.UserKeyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := middleware.GetUserIDFromContext(r.Context()) // UserID гарантирован middleware
+
+		k, err := keys.GetUserKeys(r.Context(), userID)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				http.Error(w, "Ключи шифрования еще не сохранены", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Ошибка получения ключей шифрования", http.StatusInternalServerError)
+			return
+		}
+
+		writeCached(w, r, userKeysETag(k), k)
+	}
+}
+
+// PutUserKeys обрабатывает PUT /e2ee/keys: сохраняет ключи при включении шифрования или
+// заменяет их при смене парольной фразы. Замена требует If-Match с ETag текущих ключей
+// (иначе 428): ключи, перезаписанные по ошибке, лишат доступа ко всем зашифрованным
+// заметкам. Сервер не может проверить, что новый мастер-ключ тот же, - это задача клиента.
+func PutUserKeys(keys store.UserKeyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := middleware.GetUserIDFromContext(r.Context())
+
+		// 1. Разбор и проверка ключей
+		var k models.UserKeys
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxKeysBody)).Decode(&k); err != nil {
+			http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+			return
+		}
+		if err := validateUserKeys(&k); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		k.UserID = userID
+		k.Version = 0
+
+		// 2. Создание или замена текущих ключей
+		current, err := keys.GetUserKeys(r.Context(), userID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Ошибка получения ключей шифрования", http.StatusInternalServerError)
+			return
+		}
+		ifMatch := r.Header.Get("If-Match")
+		status := http.StatusCreated
+		if current != nil {
+			if ifMatch == "" {
+				http.Error(w, "Ключи шифрования уже сохранены: для замены передайте их ETag в заголовке If-Match", http.StatusPreconditionRequired)
+				return
+			}
+			if !etagMatches(ifMatch, userKeysETag(current), false) {
+				writeKeysConflict(w, current)
+				return
+			}
+			k.Version = current.Version
+			status = http.StatusOK
+		} else if ifMatch != "" {
+			http.Error(w, "Ключи шифрования еще не сохранены", http.StatusPreconditionFailed)
+			return
+		}
+
+		if err := keys.SaveUserKeys(r.Context(), &k); err != nil {
+			if errors.Is(err, store.ErrConflict) || errors.Is(err, store.ErrVersionConflict) {
+				// Ключи сохранили или заменили параллельным запросом
+				if latest, err := keys.GetUserKeys(r.Context(), userID); err == nil {
+					writeKeysConflict(w, latest)
+					return
+				}
+				http.Error(w, "Ключи шифрования изменены другим запросом", http.StatusPreconditionFailed)
+				return
+			}
+			http.Error(w, "Ошибка сохранения ключей шифрования", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", userKeysETag(&k))
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(k)
+	}
+}
+
+// writeKeysConflict отвечает 412 с текущими ключами, как writeVersionConflict для заметок.
+func writeKeysConflict(w http.ResponseWriter, current *models.UserKeys) {
+	w.Header().Set("ETag", userKeysETag(current))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusPreconditionFailed)
+	json.NewEncoder(w).Encode(current)
+}
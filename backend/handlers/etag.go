@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"diary-backend/models"
+)
+
+// noteETag возвращает сильный ETag заметки: он меняется вместе с ее версией.
+func noteETag(n *models.Note) string {
+	return `"` + strconv.Itoa(n.Version) + `"`
+}
+
+// etagMatches сообщает, совпадает ли etag с одним из значений заголовка If-Match или
+// If-None-Match ("*" совпадает с любым). При слабом сравнении (weak) префикс W/
+// не учитывается, при сильном слабые ETag не совпадают ни с чем (RFC 9110, 8.8.3.2).
+func etagMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		} else if candidate == etag && !strings.HasPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// writeCached отправляет v как JSON с заголовком ETag. Если клиент уже знает это
+// состояние (If-None-Match), отвечает 304 без тела. Пустой etag вычисляется по телу ответа.
+func writeCached(w http.ResponseWriter, r *http.Request, etag string, v any) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(v); err != nil {
+		http.Error(w, "Ошибка формирования ответа", http.StatusInternalServerError)
+		return
+	}
+	if etag == "" {
+		sum := sha256.Sum256(body.Bytes())
+		etag = `W/"` + hex.EncodeToString(sum[:16]) + `"`
+	}
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache") // Кешировать можно, но только с проверкой
+	if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatches(inm, etag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body.Bytes())
+}
+
+// writeVersionConflict отвечает 412 Precondition Failed с актуальной серверной копией
+// заметки, чтобы клиент мог объединить ее со своими изменениями.
+func writeVersionConflict(w http.ResponseWriter, current *models.Note) {
+	w.Header().Set("ETag", noteETag(current))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusPreconditionFailed)
+	json.NewEncoder(w).Encode(current)
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"diary-backend/export"
+	"diary-backend/middleware"
+	"diary-backend/store"
+)
+
+// exportPageSize - заметок, читаемых из хранилища за один запрос при выгрузке.
+const exportPageSize = 200
+
+// ExportHandler обрабатывает GET /export?format=json|markdown|csv (по умолчанию json):
+// весь дневник пользователя файлом для скачивания. Заметки читаются страницами и сразу
+// отправляются клиенту, от старых к новым.
+func ExportHandler(users store.UserStore, notes store.NoteStore, tags store.TagStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := middleware.GetUserIDFromContext(r.Context()) // UserID гарантирован middleware
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = export.FormatJSON
+		}
+
+		// 1. Данные, которые пишутся до заметок
+		user, err := users.GetUserByID(r.Context(), userID)
+		if err != nil {
+			http.Error(w, "Ошибка получения пользователя", http.StatusInternalServerError)
+			return
+		}
+		userTags, err := tags.ListTags(r.Context(), userID)
+		if err != nil {
+			http.Error(w, "Ошибка получения тегов", http.StatusInternalServerError)
+			return
+		}
+
+		// 2. Заголовки ответа: после первой записи статус уже не изменить
+		now := time.Now()
+		mimeType, ext := export.ContentType(format)
+		w.Header().Set("Content-Type", mimeType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="diary-%s.%s"`, now.UTC().Format("2006-01-02"), ext))
+		w.Header().Set("Cache-Control", "no-store")
+
+		wr, ok, err := export.New(format, w, export.Header{ExportedAt: now, User: user, Tags: userTags})
+		if !ok {
+			w.Header().Del("Content-Disposition")
+			http.Error(w, "Параметр format: ожидается json, markdown или csv", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			abortExport(userID, err)
+		}
+
+		// 3. Заметки постранично
+		q := store.NoteQuery{Sort: store.NoteSortCreated, Limit: exportPageSize}
+		for {
+			page, err := notes.QueryNotes(r.Context(), userID, q)
+			if err != nil {
+				abortExport(userID, err)
+			}
+			for i := range page {
+				if err := wr.WriteNote(&page[i]); err != nil {
+					abortExport(userID, err)
+				}
+			}
+			if len(page) < exportPageSize {
+				break
+			}
+			after := store.CursorAfter(page[len(page)-1], q.Sort)
+			q.After = &after
+		}
+		if err := wr.Close(); err != nil {
+			abortExport(userID, err)
+		}
+	}
+}
+
+// abortExport обрывает соединение при ошибке посреди выгрузки: статус 200 уже
+// отправлен, а так клиент не примет обрезанный файл за целый.
+func abortExport(userID int, err error) {
+	log.Printf("Выгрузка дневника пользователя %d прервана: %v", userID, err)
+	panic(http.ErrAbortHandler)
+}
@@ -0,0 +1,89 @@
+package handlers_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"diary-backend/handlers"
+	"diary-backend/models"
+)
+
+func TestExport(t *testing.T) {
+	s := newTestServer(t)
+	s.router.Handle("/export", s.auth(handlers.ExportHandler(s.store, s.store, s.store))).Methods("GET")
+	token := s.signup(t, "export@example.com")
+	s.createNote(t, s.signup(t, "other-export@example.com"), models.Note{Title: "Чужая"})
+
+	// Ровно две страницы выгрузки (exportPageSize = 200): третий запрос вернет пустую страницу.
+	// Заметки создаются от новых к старым, выгрузка должна идти по дате создания
+	const total = 400
+	ctx := context.Background()
+	user, _ := s.store.GetUserByEmail(ctx, "export@example.com")
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range total {
+		created := start.Add(time.Duration(total-i) * time.Minute)
+		n := models.Note{UserID: user.ID, Title: "Заметка", Content: "Текст, с запятой", CreatedAt: created, UpdatedAt: created}
+		if err := s.store.ImportNote(ctx, &n); err != nil {
+			t.Fatalf("ImportNote: %v", err)
+		}
+	}
+
+	t.Run("json", func(t *testing.T) {
+		rec := s.do(t, "GET", "/export", token, nil)
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" ||
+			!strings.HasPrefix(rec.Header().Get("Content-Disposition"), `attachment; filename="diary-`) {
+			t.Fatalf("выгрузка: %d %v", rec.Code, rec.Header())
+		}
+		var body struct {
+			User  struct{ Email string }
+			Notes []models.Note
+		}
+		decode(t, rec, &body)
+		if body.User.Email != "export@example.com" || len(body.Notes) != total {
+			t.Fatalf("пользователь %q, заметок %d; ожидалось %d", body.User.Email, len(body.Notes), total)
+		}
+		for i := 1; i < total; i++ {
+			if !body.Notes[i].CreatedAt.After(body.Notes[i-1].CreatedAt) {
+				t.Fatalf("заметки %d и %d не по возрастанию даты создания", body.Notes[i-1].ID, body.Notes[i].ID)
+			}
+		}
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		rec := s.do(t, "GET", "/export?format=csv", token, nil)
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/csv; charset=utf-8" {
+			t.Fatalf("выгрузка: %d %v", rec.Code, rec.Header())
+		}
+		records, err := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(rec.Body.Bytes(), []byte("\ufeff")))).ReadAll()
+		if err != nil || len(records) != total+1 || records[1][2] != "Текст, с запятой" {
+			t.Fatalf("CSV: %d строк, %v", len(records), err)
+		}
+	})
+
+	t.Run("markdown", func(t *testing.T) {
+		rec := s.do(t, "GET", "/export?format=markdown", token, nil)
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/zip" {
+			t.Fatalf("выгрузка: %d %v", rec.Code, rec.Header())
+		}
+		zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+		if err != nil {
+			t.Fatalf("архив: %v", err)
+		}
+		if len(zr.File) != total {
+			t.Errorf("файлов в архиве %d, ожидалось %d", len(zr.File), total)
+		}
+	})
+
+	t.Run("неизвестный формат", func(t *testing.T) {
+		rec := s.do(t, "GET", "/export?format=xml", token, nil)
+		if rec.Code != http.StatusBadRequest || rec.Header().Get("Content-Disposition") != "" {
+			t.Fatalf("выгрузка: %d %v", rec.Code, rec.Header())
+		}
+	})
+}
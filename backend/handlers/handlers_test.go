@@ -0,0 +1,165 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"diary-backend/config"
+	"diary-backend/handlers"
+	"diary-backend/mailer"
+	"diary-backend/middleware"
+	"diary-backend/models"
+	"diary-backend/store/memory"
+	"diary-backend/utils"
+)
+
+const testPassword = "Passw0rd!"
+
+// testServer - маршруты аутентификации и заметок поверх хранилища в памяти,
+// собранные так же, как в main.go.
+type testServer struct {
+	store  *memory.Store
+	emails *mailer.Templates
+	cfg    *config.Config
+	router *mux.Router
+	auth   func(http.Handler) http.Handler // AuthMiddleware для маршрутов, добавленных тестом
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.JWT.Secret = "test-secret-test-secret-test-secret"
+	cfg.Verification.CodeSecret = cfg.JWT.Secret
+	emails, err := mailer.LoadTemplates("", cfg.Mail.DefaultLocale)
+	if err != nil {
+		t.Fatalf("шаблоны писем: %v", err)
+	}
+
+	st := memory.New()
+	tokens := utils.NewTokenManager(cfg.JWT)
+	authMiddleware := middleware.AuthMiddleware(tokens, st)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/register", handlers.RegisterHandler(st, emails, cfg.Verification)).Methods("POST")
+	r.HandleFunc("/login", handlers.LoginHandler(st, st, tokens, cfg.TwoFactor)).Methods("POST")
+	r.HandleFunc("/verify", handlers.VerifyHandler(st, st, tokens, cfg.Verification)).Methods("POST")
+	r.Handle("/auth/logout", authMiddleware(handlers.LogoutHandler(st, st))).Methods("POST")
+	r.HandleFunc("/auth/2fa/verify", handlers.TwoFactorLoginHandler(st, st, st, st, tokens, cfg.TwoFactor)).Methods("POST")
+
+	notes := r.PathPrefix("/notes").Subrouter()
+	notes.Use(authMiddleware)
+	notes.HandleFunc("", handlers.GetNotes(st)).Methods("GET")
+	notes.HandleFunc("", handlers.CreateNote(st)).Methods("POST")
+	notes.HandleFunc("/{id}", handlers.UpdateNote(st)).Methods("PUT")
+	notes.HandleFunc("/{id}", handlers.GetNote(st)).Methods("GET")
+	notes.HandleFunc("/{id}", handlers.DeleteNote(st)).Methods("DELETE")
+
+	return &testServer{store: st, emails: emails, cfg: cfg, router: r, auth: authMiddleware}
+}
+
+// do выполняет запрос к маршрутизатору. body кодируется в JSON, если это не []byte.
+func (s *testServer) do(t *testing.T, method, path, token string, body any, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var data []byte
+	switch b := body.(type) {
+	case nil:
+	case []byte:
+		data = b
+	default:
+		var err error
+		if data, err = json.Marshal(b); err != nil {
+			t.Fatalf("тело запроса: %v", err)
+		}
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(data))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+var codePattern = regexp.MustCompile(`\b\d{6}\b`)
+
+// queuedCode возвращает код из последнего письма вида kind, поставленного в очередь для email.
+func (s *testServer) queuedCode(t *testing.T, email, kind string) string {
+	t.Helper()
+
+	ctx := context.Background()
+	user, err := s.store.GetUserByEmail(ctx, email)
+	if err != nil {
+		t.Fatalf("пользователь %s: %v", email, err)
+	}
+	msg, err := s.store.LatestEmail(ctx, user.ID, kind)
+	if err != nil {
+		t.Fatalf("письмо %s для %s: %v", kind, email, err)
+	}
+	code := codePattern.FindString(msg.TextBody)
+	if code == "" {
+		t.Fatalf("в письме нет кода: %q", msg.TextBody)
+	}
+	return code
+}
+
+// signup регистрирует и верифицирует пользователя и возвращает его токен доступа.
+func (s *testServer) signup(t *testing.T, email string) string {
+	t.Helper()
+
+	rec := s.do(t, "POST", "/register", "", models.RegisterRequest{Username: "user", Email: email, Password: testPassword})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("регистрация: %d %s", rec.Code, rec.Body)
+	}
+	rec = s.do(t, "POST", "/verify", "", models.VerificationRequest{Email: email, Code: s.queuedCode(t, email, models.EmailKindVerification)})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("верификация: %d %s", rec.Code, rec.Body)
+	}
+	var resp models.TokenResponse
+	decode(t, rec, &resp)
+	return resp.Token
+}
+
+// decode разбирает JSON-ответ в v.
+func decode(t *testing.T, rec *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("ответ %q: %v", rec.Body, err)
+	}
+}
+
+// deliverMail запускает в фоне обработчик очереди писем, который отправляет письма в
+// mailer.Memory, и останавливает его в конце теста.
+func (s *testServer) deliverMail(t *testing.T) *mailer.Memory {
+	t.Helper()
+
+	mail := mailer.NewMemory("noreply@example.com")
+	outbox := s.cfg.Mail.Outbox
+	outbox.PollInterval = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go mailer.NewWorker(s.store, mail, outbox).Run(ctx)
+	return mail
+}
+
+// waitFor повторяет check, пока он не вернет true, но не дольше пяти секунд.
+func waitFor(t *testing.T, what string, check func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !check(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("не дождались: %s", what)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"diary-backend/importer"
+	"diary-backend/middleware"
+	"diary-backend/models"
+	"diary-backend/store"
+)
+
+// Ограничения загрузки POST /import.
+const (
+	maxImportSize   = 32 << 20 // Байт в загружаемом файле
+	maxImportMemory = 8 << 20  // Байт формы в памяти; остальное multipart пишет во временный файл
+)
+
+// ImportHandler обрабатывает POST /import: multipart/form-data с файлом в поле file
+// и необязательным полем format (markdown, json или dayone; по умолчанию определяется
+// по содержимому). Заметки сохраняются с исходными датами создания и изменения и
+// тегами. Запись, совпадающая по заголовку и тексту с существующей заметкой или с уже
+// импортированной, пропускается. Ответ - отчет со статусом каждой записи; ошибка в
+// одной записи не мешает импорту остальных.
+func ImportHandler(notes store.NoteStore, tags store.TagStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := middleware.GetUserIDFromContext(r.Context()) // UserID гарантирован middleware
+
+		// 1. Файл из формы
+		r.Body = http.MaxBytesReader(w, r.Body, maxImportSize+1<<20) // Запас на поля и границы формы
+		if err := r.ParseMultipartForm(maxImportMemory); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "Файл импорта больше 32 МБ", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Ожидается multipart/form-data с файлом в поле file", http.StatusBadRequest)
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "Не передан файл в поле file", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		if header.Size > maxImportSize {
+			http.Error(w, "Файл импорта больше 32 МБ", http.StatusRequestEntityTooLarge)
+			return
+		}
+		data, err := io.ReadAll(file)
+		if err != nil {
+			http.Error(w, "Ошибка чтения файла", http.StatusInternalServerError)
+			return
+		}
+
+		// 2. Разбор источника
+		parsed, err := importer.Parse(r.FormValue("format"), data)
+		if err != nil {
+			http.Error(w, "Не удалось разобрать файл: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// 3. Отпечатки существующих заметок для поиска дубликатов
+		seen, err := noteHashes(r.Context(), notes, userID)
+		if err != nil {
+			http.Error(w, "Ошибка получения заметок", http.StatusInternalServerError)
+			return
+		}
+		if err := importTagColors(r.Context(), tags, userID, parsed.Tags); err != nil {
+			http.Error(w, "Ошибка создания тегов", http.StatusInternalServerError)
+			return
+		}
+
+		// 4. Заметки по одной
+		report := models.ImportReport{Format: parsed.Format, Items: make([]models.ImportItem, 0, len(parsed.Items))}
+		now := time.Now()
+		for i := range parsed.Items {
+			item := importNote(r.Context(), notes, userID, &parsed.Items[i], seen, now)
+			switch item.Status {
+			case models.ImportImported:
+				report.Imported++
+			case models.ImportSkipped:
+				report.Skipped++
+			default:
+				report.Failed++
+			}
+			report.Items = append(report.Items, item)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	}
+}
+
+// importNote проверяет и сохраняет одну запись источника; seen пополняется
+// отпечатком импортированной заметки.
+func importNote(ctx context.Context, notes store.NoteStore, userID int, it *importer.Item, seen map[string]int, now time.Time) models.ImportItem {
+	item := models.ImportItem{Source: it.Source, Title: it.Note.Title}
+	fail := func(err error) models.ImportItem {
+		item.Status = models.ImportFailed
+		item.Error = err.Error()
+		return item
+	}
+	if it.Err != nil {
+		return fail(it.Err)
+	}
+
+	n := it.Note
+	n.UserID = userID
+	if strings.TrimSpace(n.Title) == "" && strings.TrimSpace(n.Content) == "" && n.Encrypted == nil {
+		return fail(errors.New("Пустая заметка"))
+	}
+	tags, err := noteTags(n.Tags, "")
+	if err != nil {
+		return fail(err)
+	}
+	n.Tags = tags
+	if err := validateNoteText(n.Title, n.Content); err != nil {
+		return fail(err)
+	}
+	if err := validateEncryptedNote(n.Title, n.Content, n.Encrypted); err != nil {
+		return fail(err)
+	}
+	if n.CreatedAt.IsZero() {
+		n.CreatedAt = now
+	}
+	if n.UpdatedAt.Before(n.CreatedAt) {
+		n.UpdatedAt = n.CreatedAt
+	}
+
+	hash := importer.Hash(&n)
+	if id, ok := seen[hash]; ok {
+		item.Status = models.ImportSkipped
+		item.NoteID = id
+		return item
+	}
+	if err := notes.ImportNote(ctx, &n); err != nil {
+		log.Printf("Импорт заметки %q пользователя %d: %v", it.Source, userID, err)
+		return fail(errors.New("Ошибка сохранения заметки"))
+	}
+	seen[hash] = n.ID
+	item.Status = models.ImportImported
+	item.NoteID = n.ID
+	return item
+}
+
+// noteHashes возвращает отпечатки (importer.Hash) всех заметок пользователя вне
+// корзины с их ID. Заметки читаются страницами, как при выгрузке.
+func noteHashes(ctx context.Context, notes store.NoteStore, userID int) (map[string]int, error) {
+	hashes := make(map[string]int)
+	q := store.NoteQuery{Sort: store.NoteSortCreated, Limit: exportPageSize}
+	for {
+		page, err := notes.QueryNotes(ctx, userID, q)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range page {
+			hashes[importer.Hash(&n)] = n.ID
+		}
+		if len(page) < exportPageSize {
+			return hashes, nil
+		}
+		after := store.CursorAfter(page[len(page)-1], q.Sort)
+		q.After = &after
+	}
+}
+
+// importTagColors создает теги из выгрузки DiaryApp с их цветами, если у пользователя
+// таких еще нет. Цвета существующих тегов не меняются; теги с неверным именем или
+// цветом пропускаются - заметки получат их без цвета.
+func importTagColors(ctx context.Context, tags store.TagStore, userID int, imported []importer.Tag) error {
+	if len(imported) == 0 {
+		return nil
+	}
+	existing, err := tags.ListTags(ctx, userID)
+	if err != nil {
+		return err
+	}
+	names := make(map[string]bool, len(existing))
+	for _, t := range existing {
+		names[strings.ToLower(t.Name)] = true
+	}
+	for _, it := range imported {
+		name, err := normalizeTagName(it.Name)
+		if err != nil || names[strings.ToLower(name)] || it.Color == "" || !tagColorPattern.MatchString(it.Color) {
+			continue
+		}
+		t := models.Tag{UserID: userID, Name: name, Color: strings.ToLower(it.Color)}
+		if err := tags.CreateTag(ctx, &t); err != nil && !errors.Is(err, store.ErrConflict) {
+			return err
+		}
+		names[strings.ToLower(name)] = true
+	}
+	return nil
+}
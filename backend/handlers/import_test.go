@@ -0,0 +1,104 @@
+package handlers_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"diary-backend/handlers"
+	"diary-backend/importer"
+	"diary-backend/models"
+)
+
+// importFile отправляет data в POST /import как файл формы.
+func (s *testServer) importFile(t *testing.T, token, format string, data []byte) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if format != "" {
+		mw.WriteField("format", format)
+	}
+	fw, _ := mw.CreateFormFile("file", "import.zip")
+	fw.Write(data)
+	mw.Close()
+	return s.do(t, "POST", "/import", token, body.Bytes(), "Content-Type", mw.FormDataContentType())
+}
+
+// markdownZip собирает ZIP с файлами .md: имя файла - ключ, содержимое - значение.
+func markdownZip(t *testing.T, files ...string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for i := 0; i+1 < len(files); i += 2 {
+		w, err := zw.Create(files[i])
+		if err != nil {
+			t.Fatalf("архив: %v", err)
+		}
+		w.Write([]byte(files[i+1]))
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestImportSkipsDuplicates(t *testing.T) {
+	s := newTestServer(t)
+	s.router.Handle("/import", s.auth(handlers.ImportHandler(s.store, s.store))).Methods("POST")
+	token := s.signup(t, "import@example.com")
+	existing, _ := s.createNote(t, token, models.Note{Title: "Уже есть", Content: "Текст"})
+
+	data := markdownZip(t,
+		"a.md", "# Новая\n\nТекст",
+		"b.md", "# Уже есть\n\nТекст\r\n", // Дубликат существующей заметки: окончания строк не важны
+		"c.md", "---\ntitle: Новая\n---\nТекст", // Дубликат записи a.md из того же архива
+		"d.md", "---\ntitle: Без конца\n",
+	)
+	rec := s.importFile(t, token, "", data)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("импорт: %d %s", rec.Code, rec.Body)
+	}
+	var report models.ImportReport
+	decode(t, rec, &report)
+	if report.Format != "markdown" || report.Imported != 1 || report.Skipped != 2 || report.Failed != 1 {
+		t.Fatalf("отчет: %+v", report)
+	}
+	if report.Items[1].NoteID != existing.ID || report.Items[2].NoteID != report.Items[0].NoteID {
+		t.Errorf("пропущенные записи должны ссылаться на существующие копии: %+v", report.Items)
+	}
+
+	// Повторный импорт того же архива ничего не добавляет
+	decode(t, s.importFile(t, token, "markdown", data), &report)
+	if report.Imported != 0 || report.Skipped != 3 {
+		t.Errorf("повторный импорт: %+v", report)
+	}
+	var list []models.Note
+	decode(t, s.do(t, "GET", "/notes", token, nil), &list)
+	if len(list) != 2 {
+		t.Errorf("заметок после импорта: %d, ожидалось 2", len(list))
+	}
+}
+
+func TestImportRejectsArchive(t *testing.T) {
+	s := newTestServer(t)
+	s.router.Handle("/import", s.auth(handlers.ImportHandler(s.store, s.store))).Methods("POST")
+	token := s.signup(t, "bomb@example.com")
+
+	var files []string
+	for i := range importer.MaxItems + 1 {
+		files = append(files, fmt.Sprintf("%d.md", i), "")
+	}
+	rec := s.importFile(t, token, "", markdownZip(t, files...))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("архив с лишними файлами: %d %s", rec.Code, rec.Body)
+	}
+	var list []models.Note
+	decode(t, s.do(t, "GET", "/notes", token, nil), &list)
+	if len(list) != 0 {
+		t.Errorf("из отклоненного архива импортировано %d заметок", len(list))
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"diary-backend/mailer"
+	"diary-backend/models"
+	"diary-backend/store"
+)
+
+// requestLocale выбирает язык писем: явно запрошенный, иначе первый поддерживаемый
+// из заголовка Accept-Language, иначе язык по умолчанию.
+func requestLocale(r *http.Request, emails *mailer.Templates, requested string) string {
+	if requested != "" && emails.Supports(requested) {
+		return requested
+	}
+	for _, tag := range acceptLanguages(r.Header.Get("Accept-Language")) {
+		// "en-US" -> "en"
+		primary, _, _ := strings.Cut(tag, "-")
+		if emails.Supports(primary) {
+			return primary
+		}
+	}
+	return emails.DefaultLocale()
+}
+
+// acceptLanguages разбирает Accept-Language в список языков по убыванию веса q.
+func acceptLanguages(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var langs []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		langs = append(langs, weighted{strings.ToLower(tag), q})
+	}
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+
+	tags := make([]string, len(langs))
+	for i, l := range langs {
+		tags[i] = l.tag
+	}
+	return tags
+}
+
+// LocaleHandler обрабатывает PUT /account/locale: меняет язык писем пользователя.
+func LocaleHandler(users store.UserStore, emails *mailer.Templates) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := currentUser(w, r, users)
+		if !ok {
+			return
+		}
+
+		var req models.LocaleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+			return
+		}
+		if !emails.Supports(req.Locale) {
+			http.Error(w, "Язык не поддерживается. Доступны: "+strings.Join(emails.Locales(), ", "), http.StatusBadRequest)
+			return
+		}
+
+		if err := users.SetLocale(r.Context(), user.ID, req.Locale); err != nil {
+			http.Error(w, "Ошибка БД при сохранении языка", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
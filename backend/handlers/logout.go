@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"diary-backend/middleware"
+	"diary-backend/models"
+	"diary-backend/store"
+	"diary-backend/utils"
+)
+
+// LogoutHandler обрабатывает POST /auth/logout: отзывает текущий токен доступа и,
+// если в теле передан refresh_token, все семейство этого токена обновления.
+func LogoutHandler(refresh store.TokenStore, revocations store.RevocationStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := middleware.GetClaimsFromContext(r.Context())
+		if !ok {
+			http.Error(w, "Ошибка аутентификации пользователя", http.StatusInternalServerError)
+			return
+		}
+
+		// Тело необязательно: клиент может не хранить токен обновления
+		var req models.RefreshRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+			return
+		}
+
+		// 1. Отзыв токена доступа до момента его естественного истечения
+		if claims.Id != "" {
+			expiresAt := time.Unix(claims.ExpiresAt, 0)
+			if err := revocations.RevokeToken(r.Context(), claims.Id, claims.UserID, expiresAt); err != nil {
+				http.Error(w, "Ошибка сервера при выходе", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		// 2. Отзыв семейства токена обновления (только своего)
+		if req.RefreshToken != "" {
+			rt, err := refresh.GetRefreshToken(r.Context(), utils.HashRefreshToken(req.RefreshToken))
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				http.Error(w, "Ошибка сервера при выходе", http.StatusInternalServerError)
+				return
+			}
+			if rt != nil && rt.UserID == claims.UserID {
+				if err := refresh.RevokeTokenFamily(r.Context(), rt.FamilyID); err != nil {
+					http.Error(w, "Ошибка сервера при выходе", http.StatusInternalServerError)
+					return
+				}
+			}
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// LogoutAllHandler обрабатывает POST /auth/logout-all: завершает все сессии пользователя
+// на всех устройствах, включая текущую.
+func LogoutAllHandler(revocations store.RevocationStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := middleware.GetClaimsFromContext(r.Context())
+		if !ok {
+			http.Error(w, "Ошибка аутентификации пользователя", http.StatusInternalServerError)
+			return
+		}
+
+		if err := revocations.RevokeUserTokens(r.Context(), claims.UserID, time.Now()); err != nil {
+			http.Error(w, "Ошибка сервера при выходе", http.StatusInternalServerError)
+			return
+		}
+		// Граница хранится с точностью до секунды: текущий токен мог быть выпущен в ту же секунду
+		if claims.Id != "" {
+			expiresAt := time.Unix(claims.ExpiresAt, 0)
+			if err := revocations.RevokeToken(r.Context(), claims.Id, claims.UserID, expiresAt); err != nil {
+				http.Error(w, "Ошибка сервера при выходе", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
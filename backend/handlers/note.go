@@ -1,19 +1,20 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"diary-backend/middleware"
 	"diary-backend/models"
+	"diary-backend/store"
 
 	"github.com/gorilla/mux"
 )
 
 // CreateNote обрабатывает POST /notes
-func CreateNote(db *sql.DB) http.HandlerFunc {
+func CreateNote(notes store.NoteStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// 1. Получение UserID из контекста, установленного AuthMiddleware
 		userID, ok := middleware.GetUserIDFromContext(r.Context())
@@ -21,7 +22,7 @@ func CreateNote(db *sql.DB) http.HandlerFunc {
 			http.Error(w, "Ошибка аутентификации (userID отсутствует)", http.StatusInternalServerError)
 			return
 		}
-		
+
 		var note models.Note
 		if err := json.NewDecoder(r.Body).Decode(&note); err != nil {
 			http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
@@ -29,27 +30,40 @@ func CreateNote(db *sql.DB) http.HandlerFunc {
 		}
 
 		note.UserID = userID // Устанавливаем ID текущего пользователя
-		
-		// 2. Вставка в БД, включая user_id и tag
-		sqlStatement := `INSERT INTO notes (user_id, title, content, tag, created_at, updated_at) 
-			VALUES ($1, $2, $3, $4, NOW(), NOW()) 
-			RETURNING id, created_at, updated_at`
-		
-		err := db.QueryRow(sqlStatement, note.UserID, note.Title, note.Content, note.Tag).Scan(&note.ID, &note.CreatedAt, &note.UpdatedAt)
-
+		tags, err := noteTags(note.Tags, note.Tag)
 		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		note.Tags = tags
+		if err := validateNoteText(note.Title, note.Content); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validateEncryptedNote(note.Title, note.Content, note.Encrypted); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// 2. Сохранение заметки, включая user_id и теги (новые теги создаются автоматически)
+		if err := notes.CreateNote(r.Context(), &note); err != nil {
 			http.Error(w, "Ошибка при создании заметки: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", noteETag(&note))
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(note)
 	}
 }
 
-// UpdateNote обрабатывает PUT /notes/{id}
-func UpdateNote(db *sql.DB) http.HandlerFunc {
+// UpdateNote обрабатывает PUT /notes/{id}: заметка перезаписывается целиком, не переданные
+// поля становятся пустыми (частичное изменение - PatchNote). Запрос без encrypted делает
+// заметку открытой, даже если раньше она была зашифрована на клиенте. С заголовком If-Match
+// заметка перезаписывается, только если ее ETag не изменился с момента чтения клиентом;
+// иначе - 412 с текущей копией.
+func UpdateNote(notes store.NoteStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// 1. Получение UserID из контекста
 		userID, ok := middleware.GetUserIDFromContext(r.Context())
@@ -68,29 +82,72 @@ func UpdateNote(db *sql.DB) http.HandlerFunc {
 
 		// 3. Декодирование данных для обновления
 		var updatedFields struct {
-			Title   string `json:"title"`
-			Content string `json:"content"`
-			Tag     string `json:"tag"`
+			Title   string   `json:"title"`
+			Content string   `json:"content"`
+			Tags    []string `json:"tags"`
+			Tag     string   `json:"tag"` // Устарело: один тег вместо tags
+
+			Encrypted *models.Envelope `json:"encrypted"` // Без него заметка сохраняется открытой
 		}
 		if err := json.NewDecoder(r.Body).Decode(&updatedFields); err != nil {
 			http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
 			return
 		}
+		tags, err := noteTags(updatedFields.Tags, updatedFields.Tag)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validateNoteText(updatedFields.Title, updatedFields.Content); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validateEncryptedNote(updatedFields.Title, updatedFields.Content, updatedFields.Encrypted); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
-		// 4. SQL UPDATE: Обновление заметки, принадлежащей пользователю.
-		// Используем RETURNING для получения обновленных данных и нового updated_at.
-		sqlStatement := `UPDATE notes 
-						 SET title = $1, content = $2, tag = $3, updated_at = NOW() 
-						 WHERE id = $4 AND user_id = $5 
-						 RETURNING id, user_id, title, content, tag, created_at, updated_at`
-
-		var updatedNote models.Note
-		err = db.QueryRow(sqlStatement, updatedFields.Title, updatedFields.Content, updatedFields.Tag, noteID, userID).Scan(
-			&updatedNote.ID, &updatedNote.UserID, &updatedNote.Title, &updatedNote.Content, &updatedNote.Tag, &updatedNote.CreatedAt, &updatedNote.UpdatedAt)
+		// 4. Обновление заметки, принадлежащей пользователю.
+		// Хранилище заполняет updatedNote актуальными данными и новым updated_at.
+		updatedNote := models.Note{
+			ID:      noteID,
+			UserID:  userID,
+			Title:   updatedFields.Title,
+			Content: updatedFields.Content,
+			Tags:    tags,
 
-		if err != nil {
-			if err == sql.ErrNoRows {
-				// Если RowsAffected = 0, значит заметка не найдена или не принадлежит пользователю
+			Encrypted: updatedFields.Encrypted,
+		}
+		if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+			// Версия, которую видел клиент: хранилище проверит ее атомарно с записью
+			current, err := notes.GetNote(r.Context(), userID, noteID)
+			if err != nil {
+				// Без текущей версии запись стала бы безусловной - ровно то, от чего защищает If-Match
+				if errors.Is(err, store.ErrNotFound) {
+					http.Error(w, "Заметка не найдена или не принадлежит пользователю", http.StatusNotFound)
+					return
+				}
+				http.Error(w, "Ошибка при получении заметки: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if !etagMatches(ifMatch, noteETag(current), false) {
+				writeVersionConflict(w, current)
+				return
+			}
+			updatedNote.Version = current.Version
+		}
+		if err := notes.UpdateNote(r.Context(), &updatedNote); err != nil {
+			if errors.Is(err, store.ErrVersionConflict) {
+				// Заметку изменили между проверкой If-Match и записью
+				if current, err := notes.GetNote(r.Context(), userID, noteID); err == nil {
+					writeVersionConflict(w, current)
+					return
+				}
+				http.Error(w, "Заметка изменена другим запросом", http.StatusPreconditionFailed)
+				return
+			}
+			if errors.Is(err, store.ErrNotFound) {
+				// Заметка не найдена или не принадлежит пользователю
 				http.Error(w, "Заметка не найдена или не принадлежит пользователю", http.StatusNotFound)
 				return
 			}
@@ -100,48 +157,53 @@ func UpdateNote(db *sql.DB) http.HandlerFunc {
 
 		// 5. Отправка обновленной заметки в ответе
 		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", noteETag(&updatedNote))
 		json.NewEncoder(w).Encode(updatedNote)
 	}
 }
 
-// GetNotes обрабатывает GET /notes (получение всех заметок пользователя)
-func GetNotes(db *sql.DB) http.HandlerFunc {
+// GetNotes обрабатывает GET /notes: страница заметок пользователя с фильтрами
+// и сортировкой (параметры - см. parseNoteQuery). Тело ответа - массив заметок;
+// если есть следующая страница, ее курсор передается в заголовках X-Next-Cursor и Link.
+// ETag страницы вычисляется по ее содержимому и поддерживает If-None-Match.
+func GetNotes(notes store.NoteStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID, ok := middleware.GetUserIDFromContext(r.Context())
 		if !ok {
 			http.Error(w, "Ошибка аутентификации пользователя", http.StatusInternalServerError)
 			return
 		}
-		
-		// Запрос только для заметок текущего пользователя
-		sqlStatement := `SELECT id, user_id, title, content, tag, created_at, updated_at 
-			FROM notes WHERE user_id = $1 ORDER BY created_at DESC`
-		
-		rows, err := db.Query(sqlStatement, userID)
+
+		// 1. Разбор параметров выборки
+		q, err := parseNoteQuery(r.URL.Query())
 		if err != nil {
-			http.Error(w, "Ошибка получения заметок: "+err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		defer rows.Close()
 
-		notes := []models.Note{}
-		for rows.Next() {
-			var note models.Note
-			err := rows.Scan(&note.ID, &note.UserID, &note.Title, &note.Content, &note.Tag, &note.CreatedAt, &note.UpdatedAt)
-			if err != nil {
-				http.Error(w, "Ошибка сканирования заметки", http.StatusInternalServerError)
-				return
-			}
-			notes = append(notes, note)
+		// 2. Запрос только для заметок текущего пользователя.
+		// Лишняя заметка показывает, что за этой страницей есть следующая.
+		limit := q.Limit
+		if limit > 0 {
+			q.Limit++
 		}
-		
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(notes)
+		list, err := notes.QueryNotes(r.Context(), userID, q)
+		if err != nil {
+			http.Error(w, "Ошибка получения заметок: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if limit > 0 && len(list) > limit {
+			list = list[:limit]
+			setNextPage(w, r, encodeCursor(q, store.CursorAfter(list[limit-1], q.Sort)))
+		}
+
+		writeCached(w, r, "", list)
 	}
 }
 
-// GetNote обрабатывает GET /notes/{id} (получение одной заметки)
-func GetNote(db *sql.DB) http.HandlerFunc {
+// GetNote обрабатывает GET /notes/{id} (получение одной заметки). ETag ответа - версия
+// заметки; с совпадающим If-None-Match возвращается 304.
+func GetNote(notes store.NoteStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID, ok := middleware.GetUserIDFromContext(r.Context())
 		if !ok {
@@ -156,50 +218,43 @@ func GetNote(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		var note models.Note
-		sqlStatement := `SELECT id, user_id, title, content, tag, created_at, updated_at 
-			FROM notes WHERE id = $1 AND user_id = $2`
-		
-		err = db.QueryRow(sqlStatement, noteID, userID).Scan(
-			&note.ID, &note.UserID, &note.Title, &note.Content, &note.Tag, &note.CreatedAt, &note.UpdatedAt)
-
+		note, err := notes.GetNote(r.Context(), userID, noteID)
 		if err != nil {
-			if err == sql.ErrNoRows {
+			if errors.Is(err, store.ErrNotFound) {
 				http.Error(w, "Заметка не найдена или не принадлежит пользователю", http.StatusNotFound)
 				return
 			}
 			http.Error(w, "Ошибка сервера при получении заметки", http.StatusInternalServerError)
 			return
 		}
-		
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(note)
+
+		writeCached(w, r, noteETag(note), note)
 	}
 }
 
-
-// DeleteNote обрабатывает DELETE /notes/{id}
-func DeleteNote(db *sql.DB) http.HandlerFunc {
+// DeleteNote обрабатывает DELETE /notes/{id}: заметка перемещается в корзину
+// (см. ListTrash) и окончательно удаляется через trash.retention.
+func DeleteNote(notes store.NoteStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID, _ := middleware.GetUserIDFromContext(r.Context()) // UserID гарантирован middleware
 		vars := mux.Vars(r)
-		noteID, _ := strconv.Atoi(vars["id"])
-
-		// Удаление: WHERE id = $1 AND user_id = $2 - двойная проверка
-		sqlStatement := `DELETE FROM notes WHERE id = $1 AND user_id = $2`
-		
-		result, err := db.Exec(sqlStatement, noteID, userID)
+		noteID, err := strconv.Atoi(vars["id"])
 		if err != nil {
-			http.Error(w, "Ошибка при удалении заметки", http.StatusInternalServerError)
+			http.Error(w, "Неверный ID заметки", http.StatusBadRequest)
 			return
 		}
 
-		rowsAffected, _ := result.RowsAffected()
-		if rowsAffected == 0 {
-			http.Error(w, "Заметка не найдена или не принадлежит пользователю", http.StatusNotFound)
+		// Удаление только заметки текущего пользователя - двойная проверка в хранилище.
+		// Заметка, уже лежащая в корзине, считается ненайденной.
+		if err := notes.DeleteNote(r.Context(), userID, noteID); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				http.Error(w, "Заметка не найдена или не принадлежит пользователю", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Ошибка при удалении заметки", http.StatusInternalServerError)
 			return
 		}
 
 		w.WriteHeader(http.StatusNoContent) // 204
 	}
-}
\ No newline at end of file
+}
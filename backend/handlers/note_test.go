@@ -0,0 +1,239 @@
+package handlers_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"diary-backend/handlers"
+	"diary-backend/middleware"
+	"diary-backend/models"
+	"diary-backend/store"
+)
+
+// createNote создает заметку и возвращает ее вместе с ETag.
+func (s *testServer) createNote(t *testing.T, token string, note models.Note) (models.Note, string) {
+	t.Helper()
+
+	rec := s.do(t, "POST", "/notes", token, note)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("создание заметки: %d %s", rec.Code, rec.Body)
+	}
+	var created models.Note
+	decode(t, rec, &created)
+	return created, rec.Header().Get("ETag")
+}
+
+func TestCreateNote(t *testing.T) {
+	s := newTestServer(t)
+	token := s.signup(t, "create@example.com")
+
+	tests := []struct {
+		name     string
+		body     any
+		want     int
+		wantTags []string
+	}{
+		{"заметка с тегами", models.Note{Title: "Первая", Content: "Текст", Tags: []string{"работа", "дом"}}, http.StatusCreated, []string{"дом", "работа"}},
+		{"устаревшее поле tag", map[string]string{"title": "Вторая", "content": "Текст", "tag": "дом"}, http.StatusCreated, []string{"дом"}},
+		{"длинный заголовок", models.Note{Title: strings.Repeat("я", 201), Content: "Текст"}, http.StatusBadRequest, nil},
+		{"неверный JSON", []byte("{"), http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(t, "POST", "/notes", token, tt.body)
+			if rec.Code != tt.want {
+				t.Fatalf("код ответа %d, ожидался %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if rec.Code != http.StatusCreated {
+				return
+			}
+
+			var note models.Note
+			decode(t, rec, &note)
+			if note.ID == 0 || note.Version != 1 {
+				t.Errorf("id %d, версия %d", note.ID, note.Version)
+			}
+			if fmt.Sprint(note.Tags) != fmt.Sprint(tt.wantTags) {
+				t.Errorf("теги %v, ожидались %v", note.Tags, tt.wantTags)
+			}
+			if rec.Header().Get("ETag") == "" {
+				t.Error("нет ETag")
+			}
+		})
+	}
+
+	// Созданные заметки видны в списке, новые первыми
+	rec := s.do(t, "GET", "/notes", token, nil)
+	var list []models.Note
+	decode(t, rec, &list)
+	if len(list) != 2 || list[0].Title != "Вторая" {
+		t.Fatalf("список заметок: %s", rec.Body)
+	}
+}
+
+func TestGetNote(t *testing.T) {
+	s := newTestServer(t)
+	token := s.signup(t, "owner@example.com")
+	other := s.signup(t, "other@example.com")
+	note, etag := s.createNote(t, token, models.Note{Title: "Заметка", Content: "Текст"})
+	path := fmt.Sprintf("/notes/%d", note.ID)
+
+	tests := []struct {
+		name   string
+		token  string
+		path   string
+		header []string
+		want   int
+	}{
+		{"своя заметка", token, path, nil, http.StatusOK},
+		{"чужая заметка", other, path, nil, http.StatusNotFound},
+		{"несуществующая заметка", token, "/notes/999999", nil, http.StatusNotFound},
+		{"неверный ID", token, "/notes/abc", nil, http.StatusBadRequest},
+		{"совпадающий If-None-Match", token, path, []string{"If-None-Match", etag}, http.StatusNotModified},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(t, "GET", tt.path, tt.token, nil, tt.header...)
+			if rec.Code != tt.want {
+				t.Fatalf("код ответа %d, ожидался %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+
+			var got models.Note
+			decode(t, rec, &got)
+			if got.ID != note.ID || got.Title != note.Title || got.Content != note.Content {
+				t.Errorf("заметка %+v, ожидалась %+v", got, note)
+			}
+		})
+	}
+}
+
+func TestUpdateNote(t *testing.T) {
+	s := newTestServer(t)
+	token := s.signup(t, "update@example.com")
+	other := s.signup(t, "intruder@example.com")
+	note, etag := s.createNote(t, token, models.Note{Title: "Было", Content: "Текст"})
+	path := fmt.Sprintf("/notes/%d", note.ID)
+
+	tests := []struct {
+		name        string
+		token       string
+		path        string
+		body        any
+		header      []string
+		want        int
+		wantTitle   string
+		wantVersion int
+	}{
+		{"с актуальным If-Match", token, path, models.Note{Title: "Стало", Content: "Новый текст"}, []string{"If-Match", etag}, http.StatusOK, "Стало", 2},
+		{"с устаревшим If-Match", token, path, models.Note{Title: "Поздно", Content: "Текст"}, []string{"If-Match", etag}, http.StatusPreconditionFailed, "Стало", 2},
+		{"без If-Match", token, path, models.Note{Title: "Еще раз", Content: "Текст"}, nil, http.StatusOK, "Еще раз", 3},
+		{"чужая заметка", other, path, models.Note{Title: "Чужое", Content: "Текст"}, nil, http.StatusNotFound, "", 0},
+		{"If-Match для несуществующей заметки", token, "/notes/999999", models.Note{Title: "Нет", Content: "Текст"}, []string{"If-Match", etag}, http.StatusNotFound, "", 0},
+		{"длинный заголовок", token, path, models.Note{Title: strings.Repeat("я", 201)}, nil, http.StatusBadRequest, "", 0},
+		{"неверный JSON", token, path, []byte("{"), nil, http.StatusBadRequest, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(t, "PUT", tt.path, tt.token, tt.body, tt.header...)
+			if rec.Code != tt.want {
+				t.Fatalf("код ответа %d, ожидался %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if tt.wantTitle == "" {
+				return
+			}
+
+			// И при успехе, и при конфликте версий в ответе текущая заметка
+			var got models.Note
+			decode(t, rec, &got)
+			if got.Title != tt.wantTitle || got.Version != tt.wantVersion {
+				t.Errorf("заголовок %q версии %d, ожидался %q версии %d", got.Title, got.Version, tt.wantTitle, tt.wantVersion)
+			}
+		})
+	}
+}
+
+// brokenNotes - хранилище заметок, которое не может прочитать заметку.
+type brokenNotes struct {
+	store.NoteStore
+}
+
+func (brokenNotes) GetNote(context.Context, int, int) (*models.Note, error) {
+	return nil, errors.New("соединение с БД потеряно")
+}
+
+func TestUpdateNoteIfMatchStoreError(t *testing.T) {
+	s := newTestServer(t)
+	token := s.signup(t, "broken@example.com")
+	note, etag := s.createNote(t, token, models.Note{Title: "Было", Content: "Текст"})
+
+	// Без текущей версии условная запись не должна превратиться в безусловную
+	r := mux.NewRouter()
+	r.Handle("/notes/{id}", withUser(note.UserID, handlers.UpdateNote(brokenNotes{s.store}))).Methods("PUT")
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/notes/%d", note.ID), strings.NewReader(`{"title":"Стало","content":"Текст"}`))
+	req.Header.Set("If-Match", etag)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("код ответа %d, ожидался 500: %s", rec.Code, rec.Body)
+	}
+
+	got, err := s.store.GetNote(context.Background(), note.UserID, note.ID)
+	if err != nil || got.Title != "Было" {
+		t.Fatalf("заметка изменена: %+v, %v", got, err)
+	}
+}
+
+// withUser передает обработчику userID так же, как AuthMiddleware.
+func withUser(userID int, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), middleware.UserIDKey, userID)))
+	})
+}
+
+func TestDeleteNote(t *testing.T) {
+	s := newTestServer(t)
+	token := s.signup(t, "delete@example.com")
+	other := s.signup(t, "thief@example.com")
+	note, _ := s.createNote(t, token, models.Note{Title: "Удалить", Content: "Текст"})
+	path := fmt.Sprintf("/notes/%d", note.ID)
+
+	tests := []struct {
+		name  string
+		token string
+		path  string
+		want  int
+	}{
+		{"чужая заметка", other, path, http.StatusNotFound},
+		{"неверный ID", token, "/notes/abc", http.StatusBadRequest},
+		{"своя заметка", token, path, http.StatusNoContent},
+		{"повторное удаление", token, path, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(t, "DELETE", tt.path, tt.token, nil)
+			if rec.Code != tt.want {
+				t.Fatalf("код ответа %d, ожидался %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+
+	// Заметка в корзине не видна ни по ID, ни в списке
+	if rec := s.do(t, "GET", path, token, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("GET удаленной заметки: %d", rec.Code)
+	}
+	var list []models.Note
+	decode(t, s.do(t, "GET", "/notes", token, nil), &list)
+	if len(list) != 0 {
+		t.Errorf("удаленная заметка в списке: %+v", list)
+	}
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"diary-backend/store"
+)
+
+// Размер страницы GET /notes. Без limit и cursor список отдается целиком, как до появления
+// пагинации: старые клиенты не знают о курсоре и иначе молча теряли бы заметки.
+const (
+	defaultNotesLimit = 50
+	maxNotesLimit     = 200
+)
+
+// noteCursor - содержимое непрозрачного курсора пагинации. Вместе с позицией хранится
+// порядок сортировки, чтобы курсор нельзя было применить к другому порядку.
+type noteCursor struct {
+	Sort  string    `json:"s"`
+	Desc  bool      `json:"d,omitempty"`
+	Time  time.Time `json:"t,omitzero"`
+	Title string    `json:"v,omitempty"`
+	ID    int       `json:"id"`
+}
+
+var errBadCursor = errors.New("Неверный курсор")
+
+func encodeCursor(q store.NoteQuery, c store.NoteCursor) string {
+	data, _ := json.Marshal(noteCursor{Sort: q.Sort, Desc: q.Desc, Time: c.Time, Title: c.Title, ID: c.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(q store.NoteQuery, raw string) (*store.NoteCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, errBadCursor
+	}
+	var c noteCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID <= 0 {
+		return nil, errBadCursor
+	}
+	if c.Sort != q.Sort || c.Desc != q.Desc {
+		return nil, errors.New("Курсор получен для другого порядка сортировки")
+	}
+	return &store.NoteCursor{Time: c.Time, Title: c.Title, ID: c.ID}, nil
+}
+
+// parseNoteQuery разбирает параметры GET /notes:
+//
+//	limit      - размер страницы (не больше 200; без limit - 50, если передан cursor, иначе все заметки)
+//	cursor     - значение X-Next-Cursor предыдущей страницы
+//	tag        - только заметки с этим тегом
+//	encrypted  - true - только зашифрованные заметки, false - только открытые
+//	from, to   - диапазон дат (RFC 3339 или ГГГГ-ММ-ДД; день в to включается целиком)
+//	date_field - поле для from/to: created_at (по умолчанию) или updated_at
+//	sort       - created_at (по умолчанию), updated_at или title
+//	order      - asc или desc (по умолчанию desc для дат и asc для заголовка)
+func parseNoteQuery(params url.Values) (store.NoteQuery, error) {
+	q := store.NoteQuery{
+		Tag:       params.Get("tag"),
+		DateField: store.NoteSortCreated,
+		Sort:      store.NoteSortCreated,
+	}
+	if params.Has("cursor") {
+		q.Limit = defaultNotesLimit
+	}
+
+	if raw := params.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxNotesLimit {
+			return q, errors.New("Параметр limit должен быть от 1 до " + strconv.Itoa(maxNotesLimit))
+		}
+		q.Limit = limit
+	}
+
+	switch field := params.Get("date_field"); field {
+	case "", store.NoteSortCreated:
+	case store.NoteSortUpdated:
+		q.DateField = field
+	default:
+		return q, errors.New("Параметр date_field должен быть created_at или updated_at")
+	}
+
+	switch sort := params.Get("sort"); sort {
+	case "", store.NoteSortCreated:
+	case store.NoteSortUpdated, store.NoteSortTitle:
+		q.Sort = sort
+	default:
+		return q, errors.New("Параметр sort должен быть created_at, updated_at или title")
+	}
+
+	switch order := params.Get("order"); order {
+	case "":
+		q.Desc = q.Sort != store.NoteSortTitle
+	case "asc", "desc":
+		q.Desc = order == "desc"
+	default:
+		return q, errors.New("Параметр order должен быть asc или desc")
+	}
+
+	if raw := params.Get("encrypted"); raw != "" {
+		encrypted, err := strconv.ParseBool(raw)
+		if err != nil {
+			return q, errors.New("Параметр encrypted должен быть true или false")
+		}
+		q.Encrypted = &encrypted
+	}
+
+	var err error
+	if q.From, err = parseDateParam(params.Get("from"), false); err != nil {
+		return q, errors.New("Неверная дата в параметре from")
+	}
+	if q.To, err = parseDateParam(params.Get("to"), true); err != nil {
+		return q, errors.New("Неверная дата в параметре to")
+	}
+
+	if raw := params.Get("cursor"); raw != "" {
+		if q.After, err = decodeCursor(q, raw); err != nil {
+			return q, err
+		}
+	}
+	return q, nil
+}
+
+// parseDateParam разбирает дату в формате RFC 3339 или ГГГГ-ММ-ДД (полночь UTC).
+// Для верхней границы (endOfDay) дата без времени означает конец этого дня.
+func parseDateParam(raw string, endOfDay bool) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, raw)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// setNextPage добавляет к ответу ссылку на следующую страницу: курсор в X-Next-Cursor
+// и тот же запрос с новым курсором в заголовке Link (RFC 8288).
+func setNextPage(w http.ResponseWriter, r *http.Request, cursor string) {
+	params := r.URL.Query()
+	params.Set("cursor", cursor)
+	next := url.URL{Path: r.URL.Path, RawQuery: params.Encode()}
+
+	w.Header().Set("X-Next-Cursor", cursor)
+	w.Header().Set("Link", `<`+next.String()+`>; rel="next"`)
+}
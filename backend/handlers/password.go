@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"diary-backend/config"
+	"diary-backend/mailer"
+	"diary-backend/models"
+	"diary-backend/store"
+	"diary-backend/utils"
+)
+
+// ForgotPasswordHandler обрабатывает POST /password/forgot: отправляет код сброса пароля.
+// Ответ одинаков для существующего и несуществующего email, чтобы по нему нельзя было
+// проверить, зарегистрирован ли адрес. Поэтому и код сверх ограничений выдачи (как
+// в ResendCodeHandler) молча не отправляется.
+func ForgotPasswordHandler(users store.UserStore, outbox store.OutboxStore, emails *mailer.Templates, verification config.VerificationConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.ForgotPasswordRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+			http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+			return
+		}
+
+		// --- 1. Поиск пользователя ---
+		user, err := users.GetUserByEmail(r.Context(), req.Email)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Ошибка БД при поиске пользователя", http.StatusInternalServerError)
+			return
+		}
+
+		wait := time.Duration(0)
+		if user != nil {
+			if wait, err = codeResendWait(r.Context(), outbox, user.ID, models.EmailKindPasswordReset, verification); err != nil {
+				http.Error(w, "Ошибка БД при чтении очереди писем", http.StatusInternalServerError)
+				return
+			}
+			if wait > 0 {
+				log.Printf("Код сброса пароля пользователю %d не отправлен: превышены ограничения выдачи", user.ID)
+			}
+		}
+
+		if user != nil && wait == 0 {
+			// --- 2. Генерация и сохранение кода, письмо - в очередь в той же транзакции ---
+			code := utils.GenerateVerificationCode()
+			expiryTime := time.Now().Add(verification.CodeTTL)
+			msg, err := emails.PasswordReset(user.Locale, user.Email, mailer.CodeData{Username: user.Username, Code: code, TTL: verification.CodeTTL})
+			if err != nil {
+				http.Error(w, "Ошибка формирования письма", http.StatusInternalServerError)
+				return
+			}
+
+			email := mailer.Queued(models.EmailKindPasswordReset, msg)
+			if err := users.SetPasswordResetCode(r.Context(), user.ID, utils.HashVerificationCode(verification.CodeSecret, code), expiryTime, email); err != nil {
+				http.Error(w, "Ошибка БД при сохранении кода", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		// 3. Ответ без раскрытия существования аккаунта
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Если аккаунт с таким email существует, на него отправлен код сброса пароля.",
+		})
+	}
+}
+
+// ResetPasswordHandler обрабатывает POST /password/reset: проверяет код, устанавливает
+// новый пароль и завершает все существующие сессии пользователя.
+func ResetPasswordHandler(users store.UserStore, verification config.VerificationConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.ResetPasswordRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+			return
+		}
+
+		// 1. Валидация нового пароля
+		if !utils.ValidatePassword(req.NewPassword) {
+			http.Error(w, "Пароль должен состоять из 8 символов, строчной и заглавной буквы, спец. символа(!@#$%^&*)", http.StatusBadRequest)
+			return
+		}
+
+		// 2. Поиск пользователя. Неизвестный email и неверный код неразличимы для клиента.
+		user, err := users.GetUserByEmail(r.Context(), req.Email)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Ошибка БД при поиске пользователя", http.StatusInternalServerError)
+			return
+		}
+
+		// 3. Проверка кода, числа попыток и срока его действия
+		if user == nil {
+			http.Error(w, "Неверный код", http.StatusUnauthorized)
+			return
+		}
+		if !checkCode(r.Context(), w, user.ID, user.ResetCodeHash, req.Code, user.ResetCodeExpiry,
+			user.ResetCodeAttempts, verification, users.RecordResetCodeFailure) {
+			return
+		}
+
+		// 4. Хеширование и сохранение нового пароля
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+		if err != nil {
+			http.Error(w, "Ошибка хеширования пароля", http.StatusInternalServerError)
+			return
+		}
+		if err := users.ResetPassword(r.Context(), user.ID, string(hashedPassword)); err != nil {
+			http.Error(w, "Ошибка БД при смене пароля", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Пароль пользователя %d сброшен, все сессии завершены", user.ID)
+
+		// 5. Успешный ответ: пользователь входит заново с новым паролем
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Пароль успешно изменен. Войдите с новым паролем.",
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"unicode/utf8"
+
+	"diary-backend/middleware"
+	"diary-backend/models"
+	"diary-backend/store"
+)
+
+// Ограничения на текст заметки (в символах).
+const (
+	maxTitleLength   = 200
+	maxContentLength = 100_000
+)
+
+// maxPatchBody - ограничение на размер тела PATCH (текст в UTF-8 плюс экранирование JSON).
+const maxPatchBody = 1 << 20
+
+// patchRetries - сколько раз PATCH без If-Match повторяется, если заметку изменили
+// между чтением и записью. Патч относительный, поэтому его можно применить заново.
+const patchRetries = 3
+
+// validateNoteText проверяет длину заголовка и текста заметки.
+func validateNoteText(title, content string) error {
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		return errors.New("Заголовок длиннее " + strconv.Itoa(maxTitleLength) + " символов")
+	}
+	if utf8.RuneCountInString(content) > maxContentLength {
+		return errors.New("Текст заметки длиннее " + strconv.Itoa(maxContentLength) + " символов")
+	}
+	return nil
+}
+
+// notePatch - разобранное тело PATCH /notes/{id}. nil - поле не передано.
+type notePatch struct {
+	Title         *string
+	Content       *string
+	ContentAppend *string
+	Tags          *[]string // Пустой срез - снять все теги
+	Encrypted     *models.Envelope
+	Decrypt       bool // encrypted: null - заметка становится открытой
+}
+
+// parseNotePatch разбирает JSON Merge Patch (RFC 7396) для заметки. null удаляет
+// значение поля: заголовок и текст становятся пустыми, теги снимаются. Кроме полей
+// заметки (title, content, tags и устаревшего tag) поддерживается content_append -
+// строка, дописываемая в конец текста как есть. encrypted заменяет зашифрованное содержимое
+// (открытая заметка при этом шифруется), encrypted: null делает заметку открытой.
+func parseNotePatch(body []byte) (*notePatch, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil || fields == nil {
+		return nil, errors.New("Тело запроса должно быть JSON-объектом")
+	}
+
+	// str читает строковое поле; null превращается в пустую строку
+	str := func(name string) (*string, error) {
+		raw, ok := fields[name]
+		if !ok {
+			return nil, nil
+		}
+		var v *string
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, fmt.Errorf("Поле %s должно быть строкой", name)
+		}
+		if v == nil {
+			v = new(string)
+		}
+		return v, nil
+	}
+
+	var patch notePatch
+	var legacyTag *string
+	var err error
+	for name, raw := range fields {
+		switch name {
+		case "title":
+			patch.Title, err = str(name)
+		case "content":
+			patch.Content, err = str(name)
+		case "content_append":
+			patch.ContentAppend, err = str(name)
+		case "tag":
+			legacyTag, err = str(name)
+		case "tags":
+			var tags []string
+			if json.Unmarshal(raw, &tags) != nil {
+				err = errors.New("Поле tags должно быть массивом строк")
+			}
+			if tags == nil {
+				tags = []string{}
+			}
+			patch.Tags = &tags
+		case "encrypted":
+			if err = json.Unmarshal(raw, &patch.Encrypted); err != nil {
+				err = errors.New("Поле encrypted должно быть объектом или null")
+			}
+			patch.Decrypt = patch.Encrypted == nil
+		default:
+			err = fmt.Errorf("Поле %s нельзя изменить; допустимы title, content, content_append, tags, encrypted", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if patch.Content != nil && patch.ContentAppend != nil {
+		return nil, errors.New("Поля content и content_append нельзя передавать вместе")
+	}
+	if patch.Encrypted != nil && (patch.Title != nil || patch.Content != nil || patch.ContentAppend != nil) {
+		return nil, errors.New("Зашифрованные заголовок и текст передаются только в поле encrypted")
+	}
+	// Как и в PUT, устаревшее поле tag учитывается, только если нет tags
+	if patch.Tags == nil && legacyTag != nil {
+		tags := []string{}
+		if *legacyTag != "" {
+			tags = append(tags, *legacyTag)
+		}
+		patch.Tags = &tags
+	}
+	if patch.Tags != nil {
+		tags, err := noteTags(*patch.Tags, "")
+		if err != nil {
+			return nil, err
+		}
+		patch.Tags = &tags
+	}
+	return &patch, nil
+}
+
+// apply возвращает копию заметки с примененным патчем.
+func (p *notePatch) apply(n models.Note) models.Note {
+	if p.Title != nil {
+		n.Title = *p.Title
+	}
+	if p.Content != nil {
+		n.Content = *p.Content
+	}
+	if p.ContentAppend != nil {
+		n.Content += *p.ContentAppend
+	}
+	if p.Tags != nil {
+		n.Tags = *p.Tags
+	}
+	if p.Encrypted != nil {
+		n.Title, n.Content = "", ""
+		n.Encrypted = p.Encrypted
+	}
+	if p.Decrypt {
+		n.Encrypted = nil
+	}
+	return n
+}
+
+// PatchNote обрабатывает PATCH /notes/{id}: частичное изменение заметки в формате
+// JSON Merge Patch (см. parseNotePatch). Не переданные поля не меняются. С заголовком
+// If-Match патч применяется, только если ETag заметки не изменился (иначе - 412).
+func PatchNote(notes store.NoteStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := middleware.GetUserIDFromContext(r.Context()) // UserID гарантирован middleware
+		noteID, ok := noteIDFromPath(w, r)
+		if !ok {
+			return
+		}
+
+		// 1. Разбор патча
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/merge-patch+json" && mediaType != "application/json" {
+			http.Error(w, "Ожидается Content-Type: application/merge-patch+json", http.StatusUnsupportedMediaType)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchBody))
+		if err != nil {
+			http.Error(w, "Слишком большое тело запроса", http.StatusRequestEntityTooLarge)
+			return
+		}
+		patch, err := parseNotePatch(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// 2. Чтение, применение и запись с проверкой версии. Если заметку изменили
+		// параллельно, без If-Match патч применяется к новой версии заново.
+		ifMatch := r.Header.Get("If-Match")
+		for attempt := 1; ; attempt++ {
+			current, err := notes.GetNote(r.Context(), userID, noteID)
+			if err != nil {
+				if errors.Is(err, store.ErrNotFound) {
+					http.Error(w, "Заметка не найдена или не принадлежит пользователю", http.StatusNotFound)
+					return
+				}
+				http.Error(w, "Ошибка сервера при получении заметки", http.StatusInternalServerError)
+				return
+			}
+			if ifMatch != "" && !etagMatches(ifMatch, noteETag(current), false) {
+				writeVersionConflict(w, current)
+				return
+			}
+
+			patched := patch.apply(*current)
+			if err := validateNoteText(patched.Title, patched.Content); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := validateEncryptedNote(patched.Title, patched.Content, patched.Encrypted); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if patched.Title == current.Title && patched.Content == current.Content && patched.Encrypted.Equal(current.Encrypted) &&
+				slices.Equal(slices.Sorted(slices.Values(patched.Tags)), current.Tags) {
+				// Ничего не меняется: версия остается прежней
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("ETag", noteETag(current))
+				json.NewEncoder(w).Encode(current)
+				return
+			}
+
+			err = notes.UpdateNote(r.Context(), &patched)
+			if errors.Is(err, store.ErrVersionConflict) && ifMatch == "" && attempt < patchRetries {
+				continue
+			}
+			if errors.Is(err, store.ErrVersionConflict) {
+				if latest, err := notes.GetNote(r.Context(), userID, noteID); err == nil {
+					writeVersionConflict(w, latest)
+					return
+				}
+				http.Error(w, "Заметка изменена другим запросом", http.StatusPreconditionFailed)
+				return
+			}
+			if err != nil {
+				if errors.Is(err, store.ErrNotFound) {
+					http.Error(w, "Заметка не найдена или не принадлежит пользователю", http.StatusNotFound)
+					return
+				}
+				http.Error(w, "Ошибка при обновлении заметки: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("ETag", noteETag(&patched))
+			json.NewEncoder(w).Encode(patched)
+			return
+		}
+	}
+}
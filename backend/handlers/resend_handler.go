@@ -1,17 +1,24 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
-	"diary-backend/models" 
-	"diary-backend/utils"  
+	"diary-backend/config"
+	"diary-backend/mailer"
+	"diary-backend/models"
+	"diary-backend/store"
+	"diary-backend/utils"
 )
 
 // ResendCodeHandler возвращает http.HandlerFunc для повторной отправки кода верификации.
-func ResendCodeHandler(db *sql.DB) http.HandlerFunc {
+// Новый код выдается не чаще verification.ResendCooldown и не больше verification.ResendLimit
+// раз за verification.ResendWindow (иначе 429 с Retry-After): каждый код дает новые
+// verification.MaxAttempts попыток, и без ограничения код можно было бы подобрать.
+func ResendCodeHandler(users store.UserStore, outbox store.OutboxStore, emails *mailer.Templates, verification config.VerificationConfig) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -28,21 +35,9 @@ func ResendCodeHandler(db *sql.DB) http.HandlerFunc {
 		}
 
 		// --- 1. Поиск пользователя по Email ---
-		var user models.User
-		
-		// SQL-запрос для поиска пользователя
-		sqlStatement := `
-			SELECT id, is_verified 
-			FROM users 
-			WHERE email = $1
-		`
-		err := db.QueryRow(sqlStatement, req.Email).Scan(
-			&user.ID,
-			&user.IsVerified,
-		)
-
+		user, err := users.GetUserByEmail(r.Context(), req.Email)
 		if err != nil {
-			if err == sql.ErrNoRows {
+			if errors.Is(err, store.ErrNotFound) {
 				http.Error(w, "Пользователь не найден", http.StatusNotFound)
 				return
 			}
@@ -55,42 +50,71 @@ func ResendCodeHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		// --- 2. Генерация и обновление кода в БД ---
-		newCode := utils.GenerateVerificationCode() // Функция из utils
-		expiryTime := time.Now().Add(5 * time.Minute)
-
-		updateStatement := `
-			UPDATE users 
-			SET verification_code = $1, code_expiry_time = $2
-			WHERE id = $3
-		`
-		_, err = db.Exec(updateStatement, newCode, expiryTime, user.ID)
+		wait, err := codeResendWait(r.Context(), outbox, user.ID, models.EmailKindVerification, verification)
 		if err != nil {
-			http.Error(w, "Ошибка БД при обновлении кода", http.StatusInternalServerError)
+			http.Error(w, "Ошибка БД при чтении очереди писем", http.StatusInternalServerError)
+			return
+		}
+		if wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			http.Error(w, "Новый код можно запросить позже", http.StatusTooManyRequests)
 			return
 		}
 
-		// --- 3. Отправка Email ---
-		if err := utils.SendVerificationEmail(req.Email, newCode); err != nil {
-			// Логирование ошибки отправки, но клиенту сообщаем об успешном обновлении
-			// выводим сообщение в консоль:
-			returnErrorResponse(w, "Ошибка отправки email. Код обновлен в БД.", http.StatusInternalServerError)
+		// --- 2. Генерация и обновление кода в БД, письмо - в очередь в той же транзакции ---
+		newCode := utils.GenerateVerificationCode() // Функция из utils
+		expiryTime := time.Now().Add(verification.CodeTTL)
+		msg, err := emails.Verification(user.Locale, user.Email, mailer.CodeData{Username: user.Username, Code: newCode, TTL: verification.CodeTTL})
+		if err != nil {
+			http.Error(w, "Ошибка формирования письма", http.StatusInternalServerError)
 			return
 		}
 
+		email := mailer.Queued(models.EmailKindVerification, msg)
+		if err := users.SetVerificationCode(r.Context(), user.ID, utils.HashVerificationCode(verification.CodeSecret, newCode), expiryTime, email); err != nil {
+			http.Error(w, "Ошибка БД при обновлении кода", http.StatusInternalServerError)
+			return
+		}
 
-		// 4. Успешный ответ
+		// 3. Успешный ответ: письмо отправится в фоне
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(map[string]string{
-			"message": "Новый код верификации успешно отправлен.",
+			"message": "Новый код верификации будет отправлен в течение минуты.",
 		})
 	}
 }
 
-// Хелпер для возврата JSON-ответа с ошибкой
-func returnErrorResponse(w http.ResponseWriter, msg string, statusCode int) {
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": msg,
-	})
-}
\ No newline at end of file
+// VerificationEmailStatusHandler обрабатывает GET /verification/status?email=...:
+// состояние доставки последнего письма с кодом верификации, чтобы страница
+// верификации могла подсказать, что письмо еще в пути или не дошло.
+func VerificationEmailStatusHandler(users store.UserStore, outbox store.OutboxStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := users.GetUserByEmail(r.Context(), r.URL.Query().Get("email"))
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				http.Error(w, "Пользователь не найден", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Ошибка БД при поиске пользователя", http.StatusInternalServerError)
+			return
+		}
+		// Для подтвержденных аккаунтов статус не раскрывается
+		if user.IsVerified {
+			http.Error(w, "Аккаунт уже верифицирован", http.StatusBadRequest)
+			return
+		}
+
+		email, err := outbox.LatestEmail(r.Context(), user.ID, models.EmailKindVerification)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				http.Error(w, "Письмо с кодом не найдено. Запросите новый код.", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Ошибка БД при чтении очереди писем", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(email)
+	}
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"diary-backend/diff"
+	"diary-backend/middleware"
+	"diary-backend/models"
+	"diary-backend/store"
+)
+
+// diffContext - строк контекста вокруг изменений в унифицированном diff.
+const diffContext = 3
+
+// noteIDFromPath читает {id} заметки из пути запроса.
+func noteIDFromPath(w http.ResponseWriter, r *http.Request) (int, bool) {
+	noteID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID заметки", http.StatusBadRequest)
+		return 0, false
+	}
+	return noteID, true
+}
+
+// writeRevisionError выбирает HTTP-статус для ошибки при работе с ревизиями.
+func writeRevisionError(w http.ResponseWriter, err error) {
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Заметка или ревизия не найдена", http.StatusNotFound)
+		return
+	}
+	http.Error(w, "Ошибка получения ревизий: "+err.Error(), http.StatusInternalServerError)
+}
+
+// ListNoteRevisions обрабатывает GET /notes/{id}/revisions: список ревизий, новые первыми.
+// Текст ревизий в список не входит - его возвращает GET /notes/{id}/revisions/{rev}.
+func ListNoteRevisions(notes store.NoteStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := middleware.GetUserIDFromContext(r.Context()) // UserID гарантирован middleware
+		noteID, ok := noteIDFromPath(w, r)
+		if !ok {
+			return
+		}
+
+		revisions, err := notes.ListNoteRevisions(r.Context(), userID, noteID)
+		if err != nil {
+			writeRevisionError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(revisions)
+	}
+}
+
+// GetNoteRevision обрабатывает GET /notes/{id}/revisions/{rev}.
+func GetNoteRevision(notes store.NoteStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := middleware.GetUserIDFromContext(r.Context())
+		noteID, ok := noteIDFromPath(w, r)
+		if !ok {
+			return
+		}
+		rev, _ := strconv.Atoi(mux.Vars(r)["rev"]) // Маршрут пропускает только цифры
+
+		revision, err := notes.GetNoteRevision(r.Context(), userID, noteID, rev)
+		if err != nil {
+			writeRevisionError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(revision)
+	}
+}
+
+// DiffNoteRevisions обрабатывает GET /notes/{id}/revisions/diff?from=N&to=M: построчное
+// сравнение текста двух ревизий. По умолчанию to - последняя ревизия, from - предшествующая ей.
+// С format=unified ответ - текст в формате diff -u. Зашифрованные ревизии сервер
+// сравнить не может (409).
+func DiffNoteRevisions(notes store.NoteStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := middleware.GetUserIDFromContext(r.Context())
+		noteID, ok := noteIDFromPath(w, r)
+		if !ok {
+			return
+		}
+
+		// 1. Номера ревизий
+		revisions, err := notes.ListNoteRevisions(r.Context(), userID, noteID)
+		if err != nil {
+			writeRevisionError(w, err)
+			return
+		}
+		to, err := revisionParam(r, "to", revisions[0].Revision)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		from, err := revisionParam(r, "from", previousRevision(revisions, to))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// 2. Обе ревизии целиком
+		oldRev, err := notes.GetNoteRevision(r.Context(), userID, noteID, from)
+		if err != nil {
+			writeRevisionError(w, err)
+			return
+		}
+		newRev, err := notes.GetNoteRevision(r.Context(), userID, noteID, to)
+		if err != nil {
+			writeRevisionError(w, err)
+			return
+		}
+
+		// 3. Сравнение: текст зашифрованных ревизий есть только у клиента
+		if oldRev.Encrypted != nil || newRev.Encrypted != nil {
+			http.Error(w, "Зашифрованные ревизии сравниваются на клиенте: получите их через GET /notes/{id}/revisions/{rev}", http.StatusConflict)
+			return
+		}
+		lines := diff.Lines(oldRev.Content, newRev.Content)
+		if r.URL.Query().Get("format") == "unified" {
+			w.Header().Set("Content-Type", "text/x-diff; charset=utf-8")
+			fmt.Fprint(w, diff.Unified(lines,
+				fmt.Sprintf("note-%d rev %d", noteID, from),
+				fmt.Sprintf("note-%d rev %d", noteID, to), diffContext))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.NoteDiff{
+			From:        from,
+			To:          to,
+			OldTitle:    oldRev.Title,
+			NewTitle:    newRev.Title,
+			TagsAdded:   subtractTags(newRev.Tags, oldRev.Tags),
+			TagsRemoved: subtractTags(oldRev.Tags, newRev.Tags),
+			Lines:       lines,
+		})
+	}
+}
+
+// previousRevision возвращает номер ревизии, предшествующей to, или to, если ее нет.
+// Номера могут идти с пропусками: при шифровании заметки ее открытые ревизии удаляются.
+func previousRevision(revisions []models.NoteRevision, to int) int {
+	for _, r := range revisions { // Новые первыми
+		if r.Revision < to {
+			return r.Revision
+		}
+	}
+	return to
+}
+
+// revisionParam читает номер ревизии из параметра запроса; def - значение по умолчанию.
+func revisionParam(r *http.Request, name string, def int) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	rev, err := strconv.Atoi(raw)
+	if err != nil || rev < 1 {
+		return 0, fmt.Errorf("Неверный номер ревизии в параметре %s", name)
+	}
+	return rev, nil
+}
+
+// subtractTags возвращает теги из a, которых нет в b.
+func subtractTags(a, b []string) []string {
+	result := []string{}
+	for _, tag := range a {
+		if !slices.Contains(b, tag) {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+// RestoreNoteRevision обрабатывает POST /notes/{id}/revisions/{rev}/restore: заголовок,
+// текст и теги заметки заменяются содержимым ревизии. У зашифрованной на клиенте ревизии
+// восстанавливается зашифрованное содержимое. Восстановление - обычное изменение: оно само
+// становится новой ревизией, так что его тоже можно отменить.
+func RestoreNoteRevision(notes store.NoteStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := middleware.GetUserIDFromContext(r.Context())
+		noteID, ok := noteIDFromPath(w, r)
+		if !ok {
+			return
+		}
+		rev, _ := strconv.Atoi(mux.Vars(r)["rev"])
+
+		revision, err := notes.GetNoteRevision(r.Context(), userID, noteID, rev)
+		if err != nil {
+			writeRevisionError(w, err)
+			return
+		}
+
+		note := models.Note{
+			ID:      noteID,
+			UserID:  userID,
+			Title:   revision.Title,
+			Content: revision.Content,
+			Tags:    revision.Tags,
+
+			Encrypted: revision.Encrypted,
+		}
+		if err := notes.UpdateNote(r.Context(), &note); err != nil {
+			writeRevisionError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", noteETag(&note))
+		json.NewEncoder(w).Encode(note)
+	}
+}
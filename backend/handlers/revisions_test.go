@@ -0,0 +1,120 @@
+package handlers_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"diary-backend/diff"
+	"diary-backend/handlers"
+	"diary-backend/models"
+)
+
+// withRevisions добавляет маршруты ревизий так же, как в main.go.
+func (s *testServer) withRevisions() {
+	s.router.Handle("/notes/{id}/revisions", s.auth(handlers.ListNoteRevisions(s.store))).Methods("GET")
+	s.router.Handle("/notes/{id}/revisions/diff", s.auth(handlers.DiffNoteRevisions(s.store))).Methods("GET")
+	s.router.Handle("/notes/{id}/revisions/{rev:[0-9]+}", s.auth(handlers.GetNoteRevision(s.store))).Methods("GET")
+	s.router.Handle("/notes/{id}/revisions/{rev:[0-9]+}/restore", s.auth(handlers.RestoreNoteRevision(s.store))).Methods("POST")
+}
+
+func TestDiffNoteRevisions(t *testing.T) {
+	s := newTestServer(t)
+	s.withRevisions()
+	token := s.signup(t, "diff@example.com")
+
+	note, _ := s.createNote(t, token, models.Note{Title: "План", Content: "a\nb\nc", Tags: []string{"дом"}})
+	path := fmt.Sprintf("/notes/%d/revisions", note.ID)
+	rec := s.do(t, "PUT", fmt.Sprintf("/notes/%d", note.ID), token, models.Note{Title: "План 2", Content: "a\nB\nc\nd", Tags: []string{"работа"}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("изменение заметки: %d %s", rec.Code, rec.Body)
+	}
+
+	// По умолчанию сравниваются две последние ревизии
+	var d models.NoteDiff
+	decode(t, s.do(t, "GET", path+"/diff", token, nil), &d)
+	if d.From != 1 || d.To != 2 || d.OldTitle != "План" || d.NewTitle != "План 2" ||
+		fmt.Sprint(d.TagsAdded) != "[работа]" || fmt.Sprint(d.TagsRemoved) != "[дом]" {
+		t.Errorf("сравнение: %+v", d)
+	}
+	var ops []string
+	for _, l := range d.Lines {
+		ops = append(ops, l.Op+":"+l.Text)
+	}
+	if got := strings.Join(ops, " "); got != "equal:a delete:b insert:B equal:c insert:d" {
+		t.Errorf("строки: %s", got)
+	}
+
+	rec = s.do(t, "GET", path+"/diff?from=1&to=2&format=unified", token, nil)
+	want := fmt.Sprintf("--- note-%[1]d rev 1\n+++ note-%[1]d rev 2\n@@ -1,3 +1,4 @@\n a\n-b\n+B\n c\n+d\n", note.ID)
+	if rec.Code != http.StatusOK || rec.Body.String() != want {
+		t.Errorf("format=unified: %d\n%s", rec.Code, rec.Body)
+	}
+
+	other := s.signup(t, "other-diff@example.com")
+	tests := []struct {
+		query, token string
+		want         int
+	}{
+		{"?from=0", token, http.StatusBadRequest},
+		{"?to=x", token, http.StatusBadRequest},
+		{"?from=1&to=9", token, http.StatusNotFound},
+		{"", other, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if rec := s.do(t, "GET", path+"/diff"+tt.query, tt.token, nil); rec.Code != tt.want {
+			t.Errorf("%q: %d, ожидалось %d", tt.query, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestRestoreNoteRevision(t *testing.T) {
+	s := newTestServer(t)
+	s.withRevisions()
+	token := s.signup(t, "restore@example.com")
+
+	note, _ := s.createNote(t, token, models.Note{Title: "Черновик", Content: "Первая версия", Tags: []string{"идея"}})
+	path := fmt.Sprintf("/notes/%d", note.ID)
+	s.do(t, "PUT", path, token, models.Note{Title: "Чистовик", Content: "Вторая версия"})
+
+	// Восстановление первой ревизии возвращает ее заголовок, текст и теги
+	rec := s.do(t, "POST", path+"/revisions/1/restore", token, nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") == "" {
+		t.Fatalf("восстановление: %d %s", rec.Code, rec.Body)
+	}
+	var restored models.Note
+	decode(t, rec, &restored)
+	var got models.Note
+	decode(t, s.do(t, "GET", path, token, nil), &got)
+	for _, n := range []models.Note{restored, got} {
+		if n.Title != "Черновик" || n.Content != "Первая версия" || fmt.Sprint(n.Tags) != "[идея]" {
+			t.Errorf("заметка после восстановления: %+v", n)
+		}
+	}
+
+	// Восстановление само стало ревизией 3 и совпадает с ревизией 1
+	var revisions []models.NoteRevision
+	decode(t, s.do(t, "GET", path+"/revisions", token, nil), &revisions)
+	if len(revisions) != 3 || revisions[0].Revision != 3 {
+		t.Fatalf("ревизии: %+v", revisions)
+	}
+	var d models.NoteDiff
+	decode(t, s.do(t, "GET", path+"/revisions/diff?from=1&to=3", token, nil), &d)
+	if d.OldTitle != d.NewTitle || len(d.TagsAdded)+len(d.TagsRemoved) != 0 || len(d.Lines) != 1 || d.Lines[0].Op != diff.OpEqual {
+		t.Errorf("ревизия 3 отличается от ревизии 1: %+v", d)
+	}
+
+	// Восстановление можно отменить, восстановив ревизию 2
+	decode(t, s.do(t, "POST", path+"/revisions/2/restore", token, nil), &restored)
+	if restored.Title != "Чистовик" || restored.Content != "Вторая версия" || len(restored.Tags) != 0 {
+		t.Errorf("повторное восстановление: %+v", restored)
+	}
+
+	if rec := s.do(t, "POST", path+"/revisions/9/restore", token, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("несуществующая ревизия: %d", rec.Code)
+	}
+	if rec := s.do(t, "POST", path+"/revisions/1/restore", s.signup(t, "other-restore@example.com"), nil); rec.Code != http.StatusNotFound {
+		t.Errorf("чужая заметка: %d", rec.Code)
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"diary-backend/middleware"
+	"diary-backend/search"
+	"diary-backend/store"
+)
+
+// Ограничения выдачи поиска.
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	snippetWords       = 30 // Длина фрагмента текста в словах
+	titleWords         = 50 // Заголовки обычно короткие и показываются целиком
+)
+
+// SearchNotes обрабатывает GET /notes/search?q=...&limit=...: полнотекстовый поиск
+// по заметкам пользователя. Поддерживаются "фразы в кавычках" и префиксы (слово*).
+// Результаты отсортированы по релевантности и содержат фрагменты с подсветкой.
+func SearchNotes(notes store.NoteStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := middleware.GetUserIDFromContext(r.Context())
+		if !ok {
+			http.Error(w, "Ошибка аутентификации пользователя", http.StatusInternalServerError)
+			return
+		}
+
+		// 1. Разбор запроса
+		q, err := search.Parse(r.URL.Query().Get("q"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		limit := defaultSearchLimit
+		if raw := r.URL.Query().Get("limit"); raw != "" {
+			limit, err = strconv.Atoi(raw)
+			if err != nil || limit < 1 || limit > maxSearchLimit {
+				http.Error(w, "Параметр limit должен быть от 1 до "+strconv.Itoa(maxSearchLimit), http.StatusBadRequest)
+				return
+			}
+		}
+
+		// 2. Поиск (по индексу в PostgreSQL или в памяти для остальных хранилищ)
+		results, err := notes.SearchNotes(r.Context(), userID, q, limit)
+		if err != nil {
+			http.Error(w, "Ошибка поиска заметок: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// 3. Фрагменты с подсветкой найденных слов
+		for i := range results {
+			results[i].TitleHighlight = search.Highlight(results[i].Title, q, titleWords)
+			results[i].Snippet = search.Highlight(results[i].Content, q, snippetWords)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(results)
+	}
+}
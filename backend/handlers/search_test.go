@@ -0,0 +1,52 @@
+package handlers_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"diary-backend/handlers"
+	"diary-backend/models"
+)
+
+func TestSearchNotes(t *testing.T) {
+	s := newTestServer(t)
+	s.router.Handle("/search", s.auth(handlers.SearchNotes(s.store))).Methods("GET")
+	token := s.signup(t, "search@example.com")
+	other := s.signup(t, "other@example.com")
+
+	long := strings.Repeat("Обычный день без происшествий. ", 20) + "Вечером <b>кофе</b> & пирог."
+	s.createNote(t, token, models.Note{Title: "Прогулка", Content: long})
+	best, _ := s.createNote(t, token, models.Note{Title: "Кофе <утро>", Content: "Кофе, кофе"})
+	s.createNote(t, token, models.Note{Title: "Чай", Content: "Без кофеина"})
+	s.createNote(t, other, models.Note{Title: "Кофе", Content: "Чужая заметка"})
+
+	var results []models.NoteSearchResult
+	rec := s.do(t, "GET", "/search?q=%D0%BA%D0%BE%D1%84%D0%B5", token, nil) // q=кофе
+	if rec.Code != http.StatusOK {
+		t.Fatalf("поиск: %d %s", rec.Code, rec.Body)
+	}
+	decode(t, rec, &results)
+	if len(results) != 2 || results[0].ID != best.ID || results[0].Rank <= results[1].Rank {
+		t.Fatalf("результаты: %+v", results)
+	}
+	if got := results[0].TitleHighlight; got != "<mark>Кофе</mark> &lt;утро&gt;" {
+		t.Errorf("подсветка заголовка: %q", got)
+	}
+	// Фрагмент длинного текста - окно вокруг вхождения; HTML из текста экранирован
+	snippet := results[1].Snippet
+	if !strings.HasPrefix(snippet, "…") || !strings.Contains(snippet, "&lt;b&gt;<mark>кофе</mark>&lt;/b&gt; &amp; пирог.") {
+		t.Errorf("фрагмент: %q", snippet)
+	}
+
+	decode(t, s.do(t, "GET", "/search?q=%D0%BA%D0%BE%D1%84*&limit=1", token, nil), &results) // q=коф*
+	if len(results) != 1 || results[0].ID != best.ID {
+		t.Errorf("limit=1: %+v", results)
+	}
+
+	for _, query := range []string{"", "q=", "q=%22%22", "q=a&limit=0", "q=a&limit=101", "q=a&limit=x"} {
+		if rec := s.do(t, "GET", "/search?"+query, token, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: %d, ожидалось 400", query, rec.Code)
+		}
+	}
+}
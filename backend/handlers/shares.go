@@ -0,0 +1,405 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"diary-backend/middleware"
+	"diary-backend/models"
+	"diary-backend/store"
+	"diary-backend/utils"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Ограничения публичных ссылок на заметки.
+const (
+	minSharePasswordLength   = 4
+	maxSharePasswordLength   = 72      // Байт: длиннее bcrypt не принимает
+	maxSharePasswordFailures = 10      // Неверных паролей подряд, после которых ссылка блокируется
+	maxShareBody             = 4 << 10 // Байт тела POST /notes/{id}/shares и POST /s/{token}
+	sharePasswordHeader      = "X-Share-Password"
+)
+
+// shareMessages - ответы на ссылку, которая больше не открывается, по ее состоянию.
+var shareMessages = map[string]string{
+	models.ShareExpired:   "Срок действия ссылки истек",
+	models.ShareExhausted: "Лимит просмотров по ссылке исчерпан",
+	models.ShareLocked:    "Ссылка заблокирована после нескольких неверных паролей",
+	models.ShareRevoked:   "Ссылка отозвана владельцем",
+}
+
+// shareStatus возвращает состояние ссылки sh на момент now (models.Share*).
+func shareStatus(sh *models.NoteShare, now time.Time) string {
+	switch {
+	case sh.RevokedAt != nil:
+		return models.ShareRevoked
+	case sh.ExpiresAt != nil && !sh.ExpiresAt.After(now):
+		return models.ShareExpired
+	case sh.MaxViews > 0 && sh.Views >= sh.MaxViews:
+		return models.ShareExhausted
+	case sh.PasswordFailures >= maxSharePasswordFailures:
+		return models.ShareLocked
+	}
+	return models.ShareActive
+}
+
+// describeShare заполняет поля ссылки, которые видит ее владелец, но нет в БД.
+func describeShare(sh *models.NoteShare, now time.Time) {
+	sh.HasPassword = sh.PasswordHash != ""
+	sh.Status = shareStatus(sh, now)
+}
+
+// writeShareError выбирает HTTP-статус для ошибки при работе со ссылками владельца.
+func writeShareError(w http.ResponseWriter, err error) {
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Заметка или ссылка не найдены", http.StatusNotFound)
+		return
+	}
+	http.Error(w, "Ошибка работы со ссылками: "+err.Error(), http.StatusInternalServerError)
+}
+
+// validateShareRequest проверяет параметры новой ссылки.
+func validateShareRequest(req *models.CreateShareRequest, now time.Time) error {
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		return errors.New("Поле expires_at должно быть в будущем")
+	}
+	if req.MaxViews < 0 {
+		return errors.New("Поле max_views не может быть отрицательным")
+	}
+	if req.Password != "" && (len([]rune(req.Password)) < minSharePasswordLength || len(req.Password) > maxSharePasswordLength) {
+		return errors.New("Пароль ссылки должен содержать от " + strconv.Itoa(minSharePasswordLength) +
+			" символов и не больше " + strconv.Itoa(maxSharePasswordLength) + " байт")
+	}
+	return nil
+}
+
+// CreateShare обрабатывает POST /notes/{id}/shares: создает публичную ссылку на заметку
+// с необязательными сроком действия, лимитом просмотров и паролем (тело можно не
+// передавать). Токен и путь ссылки есть только в этом ответе: сервер хранит хеш токена.
+// Заметку, зашифрованную на устройстве, сервер показать не может - 409.
+func CreateShare(shares store.ShareStore, notes store.NoteStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := middleware.GetUserIDFromContext(r.Context()) // UserID гарантирован middleware
+		noteID, ok := noteIDFromPath(w, r)
+		if !ok {
+			return
+		}
+
+		// 1. Параметры ссылки
+		var req models.CreateShareRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxShareBody)).Decode(&req); err != nil && err != io.EOF {
+			http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+			return
+		}
+		now := time.Now()
+		if err := validateShareRequest(&req, now); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// 2. Заметка должна быть открытой: расшифровать ее для читателя ссылки нечем
+		note, err := notes.GetNote(r.Context(), userID, noteID)
+		if err != nil {
+			writeShareError(w, err)
+			return
+		}
+		if note.Encrypted != nil {
+			http.Error(w, "Заметка зашифрована на устройстве: сервер не может показать ее по ссылке", http.StatusConflict)
+			return
+		}
+
+		// 3. Токен и пароль сохраняются только хешами
+		token, tokenHash, err := utils.GenerateShareToken()
+		if err != nil {
+			http.Error(w, "Ошибка создания ссылки", http.StatusInternalServerError)
+			return
+		}
+		sh := models.NoteShare{
+			NoteID:    noteID,
+			UserID:    userID,
+			TokenHash: tokenHash,
+			ExpiresAt: req.ExpiresAt,
+			MaxViews:  req.MaxViews,
+		}
+		if req.Password != "" {
+			hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+			if err != nil {
+				http.Error(w, "Ошибка создания ссылки", http.StatusInternalServerError)
+				return
+			}
+			sh.PasswordHash = string(hash)
+		}
+		if err := shares.CreateShare(r.Context(), &sh); err != nil {
+			writeShareError(w, err)
+			return
+		}
+
+		describeShare(&sh, now)
+		sh.Token = token
+		sh.URL = "/s/" + token
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(sh)
+	}
+}
+
+// ListShares обрабатывает GET /notes/{id}/shares: все ссылки заметки с их состоянием
+// и числом просмотров, новые первыми. Токенов в ответе нет.
+func ListShares(shares store.ShareStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := middleware.GetUserIDFromContext(r.Context())
+		noteID, ok := noteIDFromPath(w, r)
+		if !ok {
+			return
+		}
+
+		list, err := shares.ListShares(r.Context(), userID, noteID)
+		if err != nil {
+			writeShareError(w, err)
+			return
+		}
+		now := time.Now()
+		for i := range list {
+			describeShare(&list[i], now)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	}
+}
+
+// RevokeShare обрабатывает DELETE /notes/{id}/shares/{shareId}: ссылка перестает
+// открываться сразу, но остается в списке ссылок заметки.
+func RevokeShare(shares store.ShareStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := middleware.GetUserIDFromContext(r.Context())
+		noteID, ok := noteIDFromPath(w, r)
+		if !ok {
+			return
+		}
+		shareID, err := strconv.Atoi(mux.Vars(r)["shareId"])
+		if err != nil {
+			http.Error(w, "Неверный ID ссылки", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := shares.RevokeShare(r.Context(), userID, noteID, shareID); err != nil {
+			writeShareError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// sharePage - данные страницы публичной ссылки (shareTemplate): заметка, форма
+// с паролем или подтверждением просмотра либо сообщение об ошибке.
+type sharePage struct {
+	Note         *models.SharedNote
+	Message      string
+	Form         bool
+	Password     bool // Форма запрашивает пароль
+	ViewsLimited bool
+}
+
+// shareTemplate - страница заметки для браузера. Текст заметки выводится как есть,
+// с экранированием, без разметки.
+var shareTemplate = template.Must(template.New("share").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{with .Note}}{{.Title}}{{else}}Заметка{{end}}</title>
+<style>
+body{font-family:system-ui,sans-serif;max-width:42rem;margin:2rem auto;padding:0 1rem;color:#222;line-height:1.5}
+.content{white-space:pre-wrap;overflow-wrap:anywhere}
+.meta{color:#777;font-size:.875rem}
+.message{color:#a33}
+input,button{font:inherit;padding:.25rem .5rem}
+</style>
+</head>
+<body>
+{{with .Note}}
+<h1>{{.Title}}</h1>
+<p class="meta">Изменено {{.UpdatedAt.UTC.Format "02.01.2006 15:04"}} UTC</p>
+<div class="content">{{.Content}}</div>
+{{else}}
+<h1>Заметка</h1>
+{{with .Message}}<p class="message">{{.}}</p>{{end}}
+{{if .Form}}
+<form method="post">
+{{if .Password}}<p><label>Пароль <input type="password" name="password" required autofocus></label></p>{{end}}
+<p><button type="submit">Открыть заметку</button></p>
+</form>
+{{if .ViewsLimited}}<p class="meta">Число просмотров по этой ссылке ограничено.</p>{{end}}
+{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+// wantsHTML выбирает формат ответа публичной ссылки: параметр format=html|json,
+// иначе HTML для браузеров (Accept с text/html) и JSON для остальных.
+func wantsHTML(r *http.Request) (html, ok bool) {
+	switch r.URL.Query().Get("format") {
+	case "html":
+		return true, true
+	case "json":
+		return false, true
+	case "":
+		return strings.Contains(r.Header.Get("Accept"), "text/html"), true
+	}
+	return false, false
+}
+
+// writeSharePage отвечает страницей page в HTML или, в JSON-формате, заметкой либо
+// текстом сообщения, как остальные обработчики.
+func writeSharePage(w http.ResponseWriter, html bool, status int, page sharePage) {
+	if !html {
+		if page.Note == nil {
+			http.Error(w, page.Message, status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(page.Note)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; form-action 'self'; frame-ancestors 'none'; base-uri 'none'")
+	w.WriteHeader(status)
+	if err := shareTemplate.Execute(w, page); err != nil {
+		log.Printf("Ошибка вывода страницы ссылки: %v", err)
+	}
+}
+
+// sharePassword читает пароль ссылки: из заголовка X-Share-Password, а в POST - из поля
+// password формы или JSON-тела.
+func sharePassword(w http.ResponseWriter, r *http.Request) (string, error) {
+	password := ""
+	if r.Method == http.MethodPost {
+		r.Body = http.MaxBytesReader(w, r.Body, maxShareBody)
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+			var body struct {
+				Password string `json:"password"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+				return "", err
+			}
+			password = body.Password
+		} else {
+			if err := r.ParseForm(); err != nil {
+				return "", err
+			}
+			password = r.PostForm.Get("password")
+		}
+	}
+	if password == "" {
+		password = r.Header.Get(sharePasswordHeader)
+	}
+	return password, nil
+}
+
+// ViewShare обрабатывает GET и POST /s/{token} - публичный доступ к заметке по ссылке
+// без входа в аккаунт, только для чтения: заголовок, текст и даты в JSON или HTML
+// (wantsHTML). Пароль передается заголовком X-Share-Password или в POST полем password.
+// Каждый показ заметки засчитывается в лимит просмотров; браузеру ссылка с паролем или
+// лимитом сначала показывает форму, чтобы просмотр не потратили предпросмотры ссылок
+// в мессенджерах. Ссылка, которая больше не действует, отвечает 410.
+func ViewShare(shares store.ShareStore, notes store.NoteStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		html, ok := wantsHTML(r)
+		if !ok {
+			http.Error(w, "Параметр format: ожидается html или json", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Referrer-Policy", "no-referrer") // Токен не должен уйти в Referer
+		w.Header().Set("X-Robots-Tag", "noindex")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+
+		// 1. Ссылка и ее состояние
+		sh, err := shares.GetShareByToken(r.Context(), utils.HashShareToken(mux.Vars(r)["token"]))
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				writeSharePage(w, html, http.StatusNotFound, sharePage{Message: "Ссылка не найдена"})
+				return
+			}
+			writeSharePage(w, html, http.StatusInternalServerError, sharePage{Message: "Ошибка открытия ссылки"})
+			return
+		}
+		now := time.Now()
+		if status := shareStatus(sh, now); status != models.ShareActive {
+			writeSharePage(w, html, http.StatusGone, sharePage{Message: shareMessages[status]})
+			return
+		}
+		form := sharePage{Form: true, Password: sh.PasswordHash != "", ViewsLimited: sh.MaxViews > 0}
+		if html && r.Method == http.MethodGet && (form.Password || form.ViewsLimited) {
+			writeSharePage(w, html, http.StatusOK, form)
+			return
+		}
+
+		// 2. Пароль
+		if sh.PasswordHash != "" {
+			password, err := sharePassword(w, r)
+			if err != nil {
+				writeSharePage(w, html, http.StatusBadRequest, sharePage{Message: "Неверный формат запроса"})
+				return
+			}
+			if password == "" {
+				form.Message = "Ссылка защищена паролем"
+				writeSharePage(w, html, http.StatusUnauthorized, form)
+				return
+			}
+			if bcrypt.CompareHashAndPassword([]byte(sh.PasswordHash), []byte(password)) != nil {
+				failures, err := shares.RecordSharePasswordFailure(r.Context(), sh.ID)
+				if err == nil && failures >= maxSharePasswordFailures {
+					writeSharePage(w, html, http.StatusGone, sharePage{Message: shareMessages[models.ShareLocked]})
+					return
+				}
+				form.Message = "Неверный пароль"
+				writeSharePage(w, html, http.StatusUnauthorized, form)
+				return
+			}
+		}
+
+		// 3. Заметка: в корзине или зашифрованная на устройстве по ссылке не открывается
+		note, err := notes.GetNote(r.Context(), sh.UserID, sh.NoteID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			writeSharePage(w, html, http.StatusInternalServerError, sharePage{Message: "Ошибка открытия ссылки"})
+			return
+		}
+		if note == nil || note.Encrypted != nil {
+			writeSharePage(w, html, http.StatusNotFound, sharePage{Message: "Заметка недоступна"})
+			return
+		}
+
+		// 4. Просмотр засчитывается, только если ссылка все еще действует
+		if err := shares.UseShare(r.Context(), sh.ID, now); err != nil {
+			if errors.Is(err, store.ErrConflict) {
+				writeSharePage(w, html, http.StatusGone, sharePage{Message: "Ссылка больше не действует"})
+				return
+			}
+			writeSharePage(w, html, http.StatusInternalServerError, sharePage{Message: "Ошибка открытия ссылки"})
+			return
+		}
+
+		writeSharePage(w, html, http.StatusOK, sharePage{Note: &models.SharedNote{
+			Title:     note.Title,
+			Content:   note.Content,
+			CreatedAt: note.CreatedAt,
+			UpdatedAt: note.UpdatedAt,
+		}})
+	}
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+
+	"diary-backend/middleware"
+	"diary-backend/models"
+	"diary-backend/store"
+)
+
+// Ограничения на теги.
+const (
+	maxTagLength   = 50 // Символов в имени тега
+	maxTagsPerNote = 20
+)
+
+var tagColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// normalizeTagName убирает лишние пробелы в имени тега и проверяет его длину.
+func normalizeTagName(name string) (string, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" {
+		return "", errors.New("Имя тега не может быть пустым")
+	}
+	if utf8.RuneCountInString(name) > maxTagLength {
+		return "", errors.New("Имя тега длиннее " + strconv.Itoa(maxTagLength) + " символов")
+	}
+	return name, nil
+}
+
+// noteTags собирает теги заметки из запроса. Клиенты с одним тегом присылают поле tag
+// вместо tags; оно учитывается, только если tags не передан.
+func noteTags(tags []string, legacyTag string) ([]string, error) {
+	if tags == nil && strings.TrimSpace(legacyTag) != "" {
+		tags = []string{legacyTag}
+	}
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		name, err := normalizeTagName(tag)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(result, name) {
+			result = append(result, name)
+		}
+	}
+	if len(result) > maxTagsPerNote {
+		return nil, errors.New("У заметки может быть не больше " + strconv.Itoa(maxTagsPerNote) + " тегов")
+	}
+	return result, nil
+}
+
+// tagIDFromPath читает {id} из пути запроса.
+func tagIDFromPath(w http.ResponseWriter, r *http.Request) (int, bool) {
+	tagID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID тега", http.StatusBadRequest)
+		return 0, false
+	}
+	return tagID, true
+}
+
+// writeTagError выбирает HTTP-статус для ошибки хранилища тегов.
+func writeTagError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, "Тег не найден", http.StatusNotFound)
+	case errors.Is(err, store.ErrConflict):
+		http.Error(w, "Тег с таким именем уже есть. Чтобы объединить теги, используйте POST /tags/{id}/merge", http.StatusConflict)
+	default:
+		http.Error(w, "Ошибка БД при работе с тегами: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// applyTagRequest переносит в тег переданные поля запроса с проверкой.
+func applyTagRequest(t *models.Tag, req models.TagRequest) error {
+	if req.Name != nil {
+		name, err := normalizeTagName(*req.Name)
+		if err != nil {
+			return err
+		}
+		t.Name = name
+	}
+	if req.Color != nil {
+		if *req.Color != "" && !tagColorPattern.MatchString(*req.Color) {
+			return errors.New("Цвет тега должен быть в формате #rrggbb")
+		}
+		t.Color = strings.ToLower(*req.Color)
+	}
+	return nil
+}
+
+// ListTagsHandler обрабатывает GET /tags: теги пользователя с числом заметок.
+func ListTagsHandler(tags store.TagStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := middleware.GetUserIDFromContext(r.Context()) // UserID гарантирован middleware
+
+		list, err := tags.ListTags(r.Context(), userID)
+		if err != nil {
+			writeTagError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	}
+}
+
+// CreateTagHandler обрабатывает POST /tags: новый тег без заметок.
+func CreateTagHandler(tags store.TagStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := middleware.GetUserIDFromContext(r.Context())
+
+		var req models.TagRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == nil {
+			http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+			return
+		}
+		tag := models.Tag{UserID: userID}
+		if err := applyTagRequest(&tag, req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := tags.CreateTag(r.Context(), &tag); err != nil {
+			writeTagError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(tag)
+	}
+}
+
+// UpdateTagHandler обрабатывает PATCH /tags/{id}: переименование и смена цвета.
+// Заметки ссылаются на тег по ID, поэтому новое имя сразу видно во всех заметках.
+func UpdateTagHandler(tags store.TagStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := middleware.GetUserIDFromContext(r.Context())
+		tagID, ok := tagIDFromPath(w, r)
+		if !ok {
+			return
+		}
+
+		var req models.TagRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+			return
+		}
+
+		tag, err := tags.GetTag(r.Context(), userID, tagID)
+		if err != nil {
+			writeTagError(w, err)
+			return
+		}
+		if err := applyTagRequest(tag, req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := tags.UpdateTag(r.Context(), tag); err != nil {
+			writeTagError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tag)
+	}
+}
+
+// MergeTagsHandler обрабатывает POST /tags/{id}/merge: все заметки с тегом {id}
+// получают тег into, а тег {id} удаляется. Возвращает итоговый тег.
+func MergeTagsHandler(tags store.TagStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := middleware.GetUserIDFromContext(r.Context())
+		tagID, ok := tagIDFromPath(w, r)
+		if !ok {
+			return
+		}
+
+		var req models.MergeTagsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Into == 0 {
+			http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+			return
+		}
+		if req.Into == tagID {
+			http.Error(w, "Нельзя объединить тег с самим собой", http.StatusBadRequest)
+			return
+		}
+
+		if err := tags.MergeTags(r.Context(), userID, tagID, req.Into); err != nil {
+			writeTagError(w, err)
+			return
+		}
+		merged, err := tags.GetTag(r.Context(), userID, req.Into)
+		if err != nil {
+			writeTagError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(merged)
+	}
+}
+
+// DeleteTagHandler обрабатывает DELETE /tags/{id}: тег снимается со всех заметок.
+func DeleteTagHandler(tags store.TagStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := middleware.GetUserIDFromContext(r.Context())
+		tagID, ok := tagIDFromPath(w, r)
+		if !ok {
+			return
+		}
+
+		if err := tags.DeleteTag(r.Context(), userID, tagID); err != nil {
+			writeTagError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent) // 204
+	}
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"diary-backend/models"
+	"diary-backend/store"
+	"diary-backend/utils"
+)
+
+// issueTokens выдает JWT доступа и токен обновления, открывающий новое семейство.
+// Используется при входе и верификации аккаунта.
+func issueTokens(ctx context.Context, tokens *utils.TokenManager, refresh store.TokenStore, userID int) (*models.TokenResponse, error) {
+	family, err := utils.GenerateTokenFamily()
+	if err != nil {
+		return nil, err
+	}
+	rt, plain, err := newRefreshToken(tokens, userID, family)
+	if err != nil {
+		return nil, err
+	}
+	if err := refresh.CreateRefreshToken(ctx, rt); err != nil {
+		return nil, err
+	}
+	return tokenResponse(tokens, userID, plain)
+}
+
+// newRefreshToken создает (но не сохраняет) токен обновления семейства family.
+func newRefreshToken(tokens *utils.TokenManager, userID int, family string) (*models.RefreshToken, string, error) {
+	plain, hash, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return nil, "", err
+	}
+	return &models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  family,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(tokens.RefreshTTL()),
+	}, plain, nil
+}
+
+// tokenResponse дополняет токен обновления свежим JWT доступа.
+func tokenResponse(tokens *utils.TokenManager, userID int, refreshToken string) (*models.TokenResponse, error) {
+	access, err := tokens.GenerateToken(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.TokenResponse{
+		Token:        access,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(tokens.TTL().Seconds()),
+	}, nil
+}
+
+// RefreshHandler обрабатывает POST /auth/refresh: обменивает токен обновления на новую пару.
+// Каждый токен обновления одноразовый. Повторное предъявление уже использованного токена
+// означает, что он был украден, поэтому отзывается все семейство (все устройства этого входа).
+func RefreshHandler(refresh store.TokenStore, tokens *utils.TokenManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.RefreshRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+			http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+			return
+		}
+
+		// 1. Поиск токена по хешу
+		current, err := refresh.GetRefreshToken(r.Context(), utils.HashRefreshToken(req.RefreshToken))
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				http.Error(w, "Недействительный токен обновления", http.StatusUnauthorized)
+				return
+			}
+			http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+			return
+		}
+
+		// 2. Обнаружение повторного использования. Токен, отозванный при выходе
+		// (без замены), просто недействителен; уже замененный ротацией - признак кражи.
+		if current.RevokedAt != nil && current.ReplacedBy == nil {
+			http.Error(w, "Токен обновления отозван", http.StatusUnauthorized)
+			return
+		}
+		if current.RevokedAt != nil {
+			revokeFamily(r.Context(), refresh, current)
+			http.Error(w, "Токен обновления уже использован. Все сессии этого входа завершены.", http.StatusUnauthorized)
+			return
+		}
+
+		// 3. Проверка срока действия
+		if time.Now().After(current.ExpiresAt) {
+			http.Error(w, "Срок действия токена обновления истек", http.StatusUnauthorized)
+			return
+		}
+
+		// 4. Ротация: старый токен отзывается, новый выдается в том же семействе
+		next, plain, err := newRefreshToken(tokens, current.UserID, current.FamilyID)
+		if err != nil {
+			http.Error(w, "Ошибка генерации токена", http.StatusInternalServerError)
+			return
+		}
+		if err := refresh.RotateRefreshToken(r.Context(), current.ID, next); err != nil {
+			if errors.Is(err, store.ErrConflict) {
+				// Токен успели использовать параллельно - это тоже повторное использование
+				revokeFamily(r.Context(), refresh, current)
+				http.Error(w, "Токен обновления уже использован. Все сессии этого входа завершены.", http.StatusUnauthorized)
+				return
+			}
+			http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+			return
+		}
+
+		resp, err := tokenResponse(tokens, current.UserID, plain)
+		if err != nil {
+			http.Error(w, "Ошибка генерации токена", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// revokeFamily отзывает семейство токена и пишет предупреждение в лог.
+func revokeFamily(ctx context.Context, refresh store.TokenStore, t *models.RefreshToken) {
+	log.Printf("Повторное использование токена обновления (пользователь %d, семейство %s): семейство отозвано", t.UserID, t.FamilyID)
+	if err := refresh.RevokeTokenFamily(ctx, t.FamilyID); err != nil {
+		log.Printf("Ошибка отзыва семейства токенов %s: %v", t.FamilyID, err)
+	}
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"diary-backend/config"
+	"diary-backend/middleware"
+	"diary-backend/store"
+)
+
+// writeTrashError выбирает HTTP-статус для ошибки при работе с корзиной.
+func writeTrashError(w http.ResponseWriter, err error) {
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Заметка не найдена в корзине", http.StatusNotFound)
+		return
+	}
+	http.Error(w, "Ошибка работы с корзиной: "+err.Error(), http.StatusInternalServerError)
+}
+
+// ListTrash обрабатывает GET /notes/trash: заметки в корзине, недавно удаленные первыми.
+// У каждой заметки указано время окончательного удаления (purge_at).
+func ListTrash(trash store.TrashStore, cfg config.TrashConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := middleware.GetUserIDFromContext(r.Context()) // UserID гарантирован middleware
+
+		list, err := trash.ListTrash(r.Context(), userID)
+		if err != nil {
+			writeTrashError(w, err)
+			return
+		}
+		for i := range list {
+			purgeAt := list[i].DeletedAt.Add(cfg.Retention)
+			list[i].PurgeAt = &purgeAt
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	}
+}
+
+// RestoreNote обрабатывает POST /notes/{id}/restore: возвращает заметку из корзины
+// вместе с тегами и историей изменений.
+func RestoreNote(trash store.TrashStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := middleware.GetUserIDFromContext(r.Context())
+		noteID, ok := noteIDFromPath(w, r)
+		if !ok {
+			return
+		}
+
+		note, err := trash.RestoreNote(r.Context(), userID, noteID)
+		if err != nil {
+			writeTrashError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", noteETag(note))
+		json.NewEncoder(w).Encode(note)
+	}
+}
+
+// PurgeNote обрабатывает DELETE /notes/trash/{id}: окончательное удаление заметки
+// из корзины. Заметку вне корзины так удалить нельзя (404).
+func PurgeNote(trash store.TrashStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := middleware.GetUserIDFromContext(r.Context())
+		noteID, ok := noteIDFromPath(w, r)
+		if !ok {
+			return
+		}
+
+		if err := trash.PurgeNote(r.Context(), userID, noteID); err != nil {
+			writeTrashError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// EmptyTrash обрабатывает DELETE /notes/trash: окончательно удаляет все заметки
+// в корзине и возвращает их число.
+func EmptyTrash(trash store.TrashStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := middleware.GetUserIDFromContext(r.Context())
+
+		deleted, err := trash.EmptyTrash(r.Context(), userID)
+		if err != nil {
+			writeTrashError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{"deleted": deleted})
+	}
+}
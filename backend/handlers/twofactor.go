@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"diary-backend/config"
+	"diary-backend/middleware"
+	"diary-backend/models"
+	"diary-backend/store"
+	"diary-backend/utils"
+)
+
+// recoveryCodeCount - количество кодов восстановления, выдаваемых при включении 2FA.
+const recoveryCodeCount = 10
+
+// currentUser загружает пользователя, которым авторизован запрос.
+func currentUser(w http.ResponseWriter, r *http.Request, users store.UserStore) (*models.User, bool) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Ошибка аутентификации пользователя", http.StatusInternalServerError)
+		return nil, false
+	}
+	user, err := users.GetUserByID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Ошибка БД при поиске пользователя", http.StatusInternalServerError)
+		return nil, false
+	}
+	return user, true
+}
+
+// newRecoveryCodes генерирует коды восстановления и их хеши для хранения.
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	codes, err = utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes = make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = utils.HashRecoveryCode(code)
+	}
+	return codes, hashes, nil
+}
+
+// TwoFactorSetupHandler обрабатывает POST /auth/2fa/setup: создает секрет TOTP,
+// ожидающий подтверждения, и возвращает его вместе с otpauth:// URI для QR-кода.
+func TwoFactorSetupHandler(users store.UserStore, twoFactor store.TwoFactorStore, cfg config.TwoFactorConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := currentUser(w, r, users)
+		if !ok {
+			return
+		}
+		if user.TOTPEnabled {
+			http.Error(w, "Двухфакторная аутентификация уже включена", http.StatusConflict)
+			return
+		}
+
+		secret, err := utils.GenerateTOTPSecret()
+		if err != nil {
+			http.Error(w, "Ошибка генерации секрета", http.StatusInternalServerError)
+			return
+		}
+		if err := twoFactor.SetTOTPSecret(r.Context(), user.ID, secret); err != nil {
+			http.Error(w, "Ошибка БД при сохранении секрета", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.TwoFactorSetupResponse{
+			Secret:     secret,
+			OTPAuthURI: utils.TOTPURI(cfg.Issuer, user.Email, secret),
+		})
+	}
+}
+
+// TwoFactorConfirmHandler обрабатывает POST /auth/2fa/confirm: первый верный код из приложения
+// включает 2FA. В ответе - коды восстановления, которые больше нигде не показываются.
+func TwoFactorConfirmHandler(users store.UserStore, twoFactor store.TwoFactorStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := currentUser(w, r, users)
+		if !ok {
+			return
+		}
+
+		var req models.TwoFactorCodeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+			return
+		}
+
+		if user.TOTPEnabled {
+			http.Error(w, "Двухфакторная аутентификация уже включена", http.StatusConflict)
+			return
+		}
+		if user.TOTPSecret == "" {
+			http.Error(w, "Сначала вызовите /auth/2fa/setup", http.StatusBadRequest)
+			return
+		}
+
+		counter, valid := utils.ValidateTOTP(user.TOTPSecret, req.Code, time.Now())
+		if !valid {
+			http.Error(w, "Неверный код", http.StatusUnauthorized)
+			return
+		}
+
+		codes, hashes, err := newRecoveryCodes()
+		if err != nil {
+			http.Error(w, "Ошибка генерации кодов восстановления", http.StatusInternalServerError)
+			return
+		}
+		if err := twoFactor.EnableTOTP(r.Context(), user.ID, counter, hashes); err != nil {
+			http.Error(w, "Ошибка БД при включении 2FA", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.RecoveryCodesResponse{RecoveryCodes: codes})
+	}
+}
+
+// TwoFactorDisableHandler обрабатывает POST /auth/2fa/disable. Требует пароль и код TOTP
+// (или код восстановления, если устройство потеряно).
+func TwoFactorDisableHandler(users store.UserStore, twoFactor store.TwoFactorStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := currentUser(w, r, users)
+		if !ok {
+			return
+		}
+
+		var req models.TwoFactorDisableRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+			return
+		}
+
+		if !user.TOTPEnabled {
+			http.Error(w, "Двухфакторная аутентификация не включена", http.StatusBadRequest)
+			return
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+			http.Error(w, "Неверный пароль", http.StatusUnauthorized)
+			return
+		}
+
+		// Код TOTP или, если не подошел, код восстановления
+		err := useTOTPCode(r, twoFactor, user, req.Code)
+		if errors.Is(err, errInvalidSecondFactor) {
+			err = twoFactor.UseRecoveryCode(r.Context(), user.ID, utils.HashRecoveryCode(req.Code))
+		}
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) || errors.Is(err, errInvalidSecondFactor) {
+				http.Error(w, "Неверный код", http.StatusUnauthorized)
+				return
+			}
+			http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+			return
+		}
+
+		if err := twoFactor.DisableTOTP(r.Context(), user.ID); err != nil {
+			http.Error(w, "Ошибка БД при выключении 2FA", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// TwoFactorRecoveryCodesHandler обрабатывает POST /auth/2fa/recovery-codes: выдает новый набор
+// кодов восстановления взамен всех старых. Требует текущий код TOTP.
+func TwoFactorRecoveryCodesHandler(users store.UserStore, twoFactor store.TwoFactorStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := currentUser(w, r, users)
+		if !ok {
+			return
+		}
+
+		var req models.TwoFactorCodeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+			return
+		}
+		if !user.TOTPEnabled {
+			http.Error(w, "Двухфакторная аутентификация не включена", http.StatusBadRequest)
+			return
+		}
+
+		if err := useTOTPCode(r, twoFactor, user, req.Code); err != nil {
+			if errors.Is(err, errInvalidSecondFactor) {
+				http.Error(w, "Неверный код", http.StatusUnauthorized)
+				return
+			}
+			http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+			return
+		}
+
+		codes, hashes, err := newRecoveryCodes()
+		if err != nil {
+			http.Error(w, "Ошибка генерации кодов восстановления", http.StatusInternalServerError)
+			return
+		}
+		if err := twoFactor.ReplaceRecoveryCodes(r.Context(), user.ID, hashes); err != nil {
+			http.Error(w, "Ошибка БД при сохранении кодов", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.RecoveryCodesResponse{RecoveryCodes: codes})
+	}
+}
+
+// writeTOTPLocked отвечает 429 с Retry-After, если ввод второго фактора пользователя
+// заблокирован, и сообщает, был ли ответ записан.
+func writeTOTPLocked(w http.ResponseWriter, user *models.User) bool {
+	wait := time.Until(user.TOTPLockedUntil)
+	if !user.TOTPEnabled || wait <= 0 {
+		return false
+	}
+	minutes := int(wait.Minutes()) + 1
+	w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+	http.Error(w, "Слишком много неверных кодов. Повторите вход через "+strconv.Itoa(minutes)+" мин.", http.StatusTooManyRequests)
+	return true
+}
+
+// errInvalidSecondFactor - код неверен или уже был использован.
+var errInvalidSecondFactor = errors.New("неверный код")
+
+// useTOTPCode проверяет код TOTP и отмечает его шаг использованным, чтобы один и тот же
+// код нельзя было предъявить дважды.
+func useTOTPCode(r *http.Request, twoFactor store.TwoFactorStore, user *models.User, code string) error {
+	counter, valid := utils.ValidateTOTP(user.TOTPSecret, code, time.Now())
+	if !valid {
+		return errInvalidSecondFactor
+	}
+	err := twoFactor.UseTOTPCounter(r.Context(), user.ID, counter)
+	if errors.Is(err, store.ErrConflict) {
+		return errInvalidSecondFactor
+	}
+	return err
+}
+
+// TwoFactorLoginHandler обрабатывает POST /auth/2fa/verify - второй шаг входа.
+// Обменивает промежуточный токен из /login и код TOTP (или код восстановления)
+// на обычную пару токенов. Промежуточный токен одноразовый. Неверные коды считаются
+// для пользователя, а не для токена: после cfg.MaxAttempts ошибок подряд ввод кода
+// блокируется на cfg.Lockout (429), и новый вход по паролю счетчик не сбрасывает.
+// После блокировки каждая следующая ошибка блокирует снова, пока не будет введен верный код.
+func TwoFactorLoginHandler(users store.UserStore, twoFactor store.TwoFactorStore, refresh store.TokenStore,
+	revocations store.RevocationStore, tokens *utils.TokenManager, cfg config.TwoFactorConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.TwoFactorLoginRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+			return
+		}
+
+		// 1. Проверка промежуточного токена
+		claims, err := tokens.ValidateMFAToken(req.MFAToken)
+		if err != nil {
+			http.Error(w, "Недействительный или истекший токен. Войдите заново.", http.StatusUnauthorized)
+			return
+		}
+		revoked, err := revocations.IsTokenRevoked(r.Context(), claims.Id)
+		if err != nil {
+			http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+			return
+		}
+		if revoked {
+			http.Error(w, "Недействительный или истекший токен. Войдите заново.", http.StatusUnauthorized)
+			return
+		}
+		expiresAt := time.Unix(claims.ExpiresAt, 0)
+
+		user, err := users.GetUserByID(r.Context(), claims.UserID)
+		if err != nil || !user.TOTPEnabled {
+			http.Error(w, "Недействительный или истекший токен. Войдите заново.", http.StatusUnauthorized)
+			return
+		}
+		if writeTOTPLocked(w, user) {
+			return
+		}
+
+		// 2. Проверка второго фактора
+		if req.RecoveryCode != "" {
+			err = twoFactor.UseRecoveryCode(r.Context(), user.ID, utils.HashRecoveryCode(req.RecoveryCode))
+			if errors.Is(err, store.ErrNotFound) {
+				err = errInvalidSecondFactor
+			}
+		} else {
+			err = useTOTPCode(r, twoFactor, user, req.Code)
+		}
+
+		if errors.Is(err, errInvalidSecondFactor) {
+			failures, ferr := twoFactor.RecordTOTPFailure(r.Context(), user.ID)
+			if ferr != nil {
+				http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+				return
+			}
+			if failures >= cfg.MaxAttempts {
+				// Попытки исчерпаны: ввод кода блокируется, токен сгорает
+				log.Printf("Исчерпаны попытки ввода второго фактора (пользователь %d)", user.ID)
+				user.TOTPLockedUntil = time.Now().Add(cfg.Lockout)
+				if err := twoFactor.LockTOTP(r.Context(), user.ID, user.TOTPLockedUntil); err != nil {
+					http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+					return
+				}
+				if err := revocations.RevokeToken(r.Context(), claims.Id, user.ID, expiresAt); err != nil {
+					log.Printf("Ошибка отзыва промежуточного токена: %v", err)
+				}
+				writeTOTPLocked(w, user)
+				return
+			}
+			http.Error(w, "Неверный код", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+			return
+		}
+
+		// 3. Промежуточный токен одноразовый
+		if err := revocations.RevokeToken(r.Context(), claims.Id, user.ID, expiresAt); err != nil {
+			http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+			return
+		}
+
+		// 4. Выдача обычной пары токенов
+		resp, err := issueTokens(r.Context(), tokens, refresh, user.ID)
+		if err != nil {
+			http.Error(w, "Ошибка генерации токена", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
@@ -0,0 +1,124 @@
+package handlers_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"diary-backend/models"
+)
+
+const totpSecret = "JBSWY3DPEHPK3PXP"
+
+// totpCode вычисляет код TOTP (RFC 6238, SHA-1, 6 цифр, шаг 30 секунд) для момента t.
+func totpCode(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(at.Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:])&0x7fffffff)%1000000)
+}
+
+// enableTOTP включает пользователю 2FA с секретом totpSecret и возвращает его ID.
+func (s *testServer) enableTOTP(t *testing.T, email string) int {
+	t.Helper()
+	ctx := context.Background()
+	user, err := s.store.GetUserByEmail(ctx, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store.SetTOTPSecret(ctx, user.ID, totpSecret); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store.EnableTOTP(ctx, user.ID, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	return user.ID
+}
+
+func TestTwoFactorLockoutSurvivesLogin(t *testing.T) {
+	s := newTestServer(t)
+	s.signup(t, "mfa@example.com")
+	userID := s.enableTOTP(t, "mfa@example.com")
+	maxAttempts := s.cfg.TwoFactor.MaxAttempts
+
+	var mfaToken string
+	login := func(t *testing.T, want int) {
+		t.Helper()
+		rec := s.do(t, "POST", "/login", "", models.LoginRequest{Email: "mfa@example.com", Password: testPassword})
+		if rec.Code != want {
+			t.Fatalf("вход: код ответа %d, ожидался %d: %s", rec.Code, want, rec.Body)
+		}
+		if want == http.StatusOK {
+			var resp models.MFAChallengeResponse
+			decode(t, rec, &resp)
+			mfaToken = resp.MFAToken
+		}
+	}
+	verify := func(t *testing.T, code string, want int) *http.Response {
+		t.Helper()
+		rec := s.do(t, "POST", "/auth/2fa/verify", "", models.TwoFactorLoginRequest{MFAToken: mfaToken, Code: code})
+		if rec.Code != want {
+			t.Fatalf("код %s: ответ %d, ожидался %d: %s", code, rec.Code, want, rec.Body)
+		}
+		return rec.Result()
+	}
+	// unlock имитирует истечение блокировки
+	unlock := func(t *testing.T) {
+		t.Helper()
+		if err := s.store.LockTOTP(context.Background(), userID, time.Now().Add(-time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wrong := "000000"
+	if totpCode(t, totpSecret, time.Now()) == wrong {
+		wrong = "111111"
+	}
+
+	// 1. Первый вход: все попытки, кроме последней
+	login(t, http.StatusOK)
+	for i := 1; i < maxAttempts; i++ {
+		verify(t, wrong, http.StatusUnauthorized)
+	}
+
+	// 2. Новый вход по паролю не дает новых попыток: следующая ошибка блокирует
+	earlier := mfaToken
+	login(t, http.StatusOK)
+	resp := verify(t, wrong, http.StatusTooManyRequests)
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("нет Retry-After")
+	}
+	login(t, http.StatusTooManyRequests)
+	// Во время блокировки не принимается даже верный код с промежуточным токеном другого входа
+	mfaToken = earlier
+	verify(t, totpCode(t, totpSecret, time.Now()), http.StatusTooManyRequests)
+
+	// 3. После блокировки счетчик не обнулен: одна ошибка блокирует снова
+	unlock(t)
+	login(t, http.StatusOK)
+	verify(t, wrong, http.StatusTooManyRequests)
+
+	// 4. Верный код сбрасывает счетчик
+	unlock(t)
+	login(t, http.StatusOK)
+	verify(t, totpCode(t, totpSecret, time.Now()), http.StatusOK)
+	user, err := s.store.GetUserByID(context.Background(), userID)
+	if err != nil || user.TOTPFailures != 0 || !user.TOTPLockedUntil.IsZero() {
+		t.Fatalf("после верного кода: ошибок %d, блокировка до %v, %v", user.TOTPFailures, user.TOTPLockedUntil, err)
+	}
+	login(t, http.StatusOK)
+	verify(t, wrong, http.StatusUnauthorized)
+}
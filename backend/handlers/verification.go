@@ -1,96 +1,127 @@
 package handlers
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
+	"diary-backend/config"
 	"diary-backend/models"
+	"diary-backend/store"
 	"diary-backend/utils"
 )
 
+// checkCode проверяет одноразовый код из письма против сохраненного хеша. Код блокируется
+// после cfg.MaxAttempts неудачных попыток (recordFailure увеличивает счетчик в БД), поэтому
+// 6-значный код нельзя подобрать перебором. При отказе пишет ответ и возвращает false.
+func checkCode(ctx context.Context, w http.ResponseWriter, userID int, codeHash, code string, expiry time.Time,
+	attempts int, cfg config.VerificationConfig, recordFailure func(context.Context, int) (int, error)) bool {
+	if attempts >= cfg.MaxAttempts {
+		http.Error(w, "Превышено число попыток. Запросите новый код.", http.StatusTooManyRequests)
+		return false
+	}
 
-func VerifyHandler(db *sql.DB) http.HandlerFunc {
-    return func(w http.ResponseWriter, r *http.Request) {
-        if r.Method != http.MethodPost {
-            http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-            return
-        }
+	if !utils.CheckVerificationCode(cfg.CodeSecret, codeHash, code) {
+		failures, err := recordFailure(ctx, userID)
+		if err != nil {
+			http.Error(w, "Ошибка БД при проверке кода", http.StatusInternalServerError)
+			return false
+		}
+		if failures >= cfg.MaxAttempts {
+			log.Printf("Код пользователя %d заблокирован после %d неудачных попыток", userID, failures)
+			http.Error(w, "Превышено число попыток. Запросите новый код.", http.StatusTooManyRequests)
+			return false
+		}
+		http.Error(w, "Неверный код", http.StatusUnauthorized)
+		return false
+	}
 
-        var req models.VerificationRequest
-        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-            http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
-            return
-        }
-        
-        // --- 1. Поиск пользователя по Email и проверка кода/статуса ---
-        var user models.User
-        
-        // SQL-запрос для поиска пользователя и извлечения всех необходимых данных
-        sqlStatement := `
-            SELECT id, email, is_verified, verification_code, code_expiry_time
-            FROM users 
-            WHERE email = $1
-        `
-        err := db.QueryRow(sqlStatement, req.Email).Scan(
-            &user.ID,
-            &user.Email,
-            &user.IsVerified,
-            &user.VerificationCode,
-            &user.CodeExpiryTime,
-        )
+	if time.Now().After(expiry) {
+		http.Error(w, "Срок действия кода истек", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
 
-        if err != nil {
-            if err == sql.ErrNoRows {
-                http.Error(w, "Пользователь не найден или неверный email", http.StatusNotFound)
-                return
-            }
-            http.Error(w, "Ошибка БД при поиске пользователя", http.StatusInternalServerError)
-            return
-        }
+// codeResendWait проверяет ограничения выдачи нового кода вида kind (письма models.EmailKind*):
+// не чаще cfg.ResendCooldown и не больше cfg.ResendLimit за cfg.ResendWindow. Возвращает 0,
+// если код можно выдать, иначе - через сколько попробовать снова.
+func codeResendWait(ctx context.Context, outbox store.OutboxStore, userID int, kind string, cfg config.VerificationConfig) (time.Duration, error) {
+	now := time.Now()
+	latest, err := outbox.LatestEmail(ctx, userID, kind)
+	if errors.Is(err, store.ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if wait := latest.CreatedAt.Add(cfg.ResendCooldown).Sub(now); wait > 0 {
+		return wait, nil
+	}
+	sent, err := outbox.CountEmails(ctx, userID, kind, now.Add(-cfg.ResendWindow))
+	if err != nil {
+		return 0, err
+	}
+	if sent >= cfg.ResendLimit {
+		return cfg.ResendWindow, nil // Верхняя граница: лимит освободится, когда из окна выйдет самое раннее письмо
+	}
+	return 0, nil
+}
 
-        if user.IsVerified {
-            http.Error(w, "Аккаунт уже верифицирован", http.StatusBadRequest)
-            return
-        }
+func VerifyHandler(users store.UserStore, refresh store.TokenStore, tokens *utils.TokenManager, verification config.VerificationConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
 
-        // 2. Проверка кода
-        if user.VerificationCode != req.Code {
-            http.Error(w, "Неверный код верификации", http.StatusUnauthorized)
-            return
-        }
+		var req models.VerificationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+			return
+		}
 
-        // 3. Проверка времени истечения
-        if time.Now().After(user.CodeExpiryTime) {
-            http.Error(w, "Срок действия кода истек", http.StatusUnauthorized)
-            return
-        }
+		// --- 1. Поиск пользователя по Email и проверка кода/статуса ---
+		user, err := users.GetUserByEmail(r.Context(), req.Email)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				http.Error(w, "Пользователь не найден или неверный email", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Ошибка БД при поиске пользователя", http.StatusInternalServerError)
+			return
+		}
 
-        // --- 4. Успех: Обновление статуса в БД ---
-        updateStatement := `
-            UPDATE users 
-            SET is_verified = TRUE, verification_code = '', code_expiry_time = NULL
-            WHERE id = $1
-        `
-        _, err = db.Exec(updateStatement, user.ID)
-        if err != nil {
-            http.Error(w, "Ошибка БД при обновлении статуса", http.StatusInternalServerError)
-            return
-        }
+		if user.IsVerified {
+			http.Error(w, "Аккаунт уже верифицирован", http.StatusBadRequest)
+			return
+		}
 
-        // --- 5. Выдача JWT-токена ---
-        tokenString, err := utils.GenerateToken(user.ID) 
-        if err != nil {
-            http.Error(w, "Ошибка генерации токена", http.StatusInternalServerError)
-            return
-        }
+		// 2-3. Проверка кода, числа попыток и времени истечения
+		if !checkCode(r.Context(), w, user.ID, user.VerificationCodeHash, req.Code, user.CodeExpiryTime,
+			user.VerificationAttempts, verification, users.RecordVerificationFailure) {
+			return
+		}
 
-        // 6. Успешный ответ
-        w.WriteHeader(http.StatusOK)
-        json.NewEncoder(w).Encode(map[string]string{
-            "token":   tokenString, 
-            "message": "Аккаунт успешно верифицирован!",
-        })
-    }
-}
\ No newline at end of file
+		// --- 4. Успех: Обновление статуса в БД ---
+		if err := users.MarkVerified(r.Context(), user.ID); err != nil {
+			http.Error(w, "Ошибка БД при обновлении статуса", http.StatusInternalServerError)
+			return
+		}
+
+		// --- 5. Выдача JWT-токена и токена обновления ---
+		resp, err := issueTokens(r.Context(), tokens, refresh, user.ID)
+		if err != nil {
+			http.Error(w, "Ошибка генерации токена", http.StatusInternalServerError)
+			return
+		}
+
+		// 6. Успешный ответ
+		resp.Message = "Аккаунт успешно верифицирован!"
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
@@ -0,0 +1,43 @@
+package handlers_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"diary-backend/mailer"
+	"diary-backend/models"
+)
+
+func TestRegisterSendsVerificationCode(t *testing.T) {
+	s := newTestServer(t)
+	mail := s.deliverMail(t)
+
+	rec := s.do(t, "POST", "/register", "", models.RegisterRequest{Username: "Анна", Email: "anna@example.com", Password: testPassword})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("регистрация: %d %s", rec.Code, rec.Body)
+	}
+
+	var msg mailer.Message
+	waitFor(t, "письмо с кодом", func() (ok bool) {
+		msg, ok = mail.Last("anna@example.com")
+		return ok
+	})
+	if msg.From != "noreply@example.com" || !strings.Contains(msg.Text, "Анна") {
+		t.Errorf("письмо от %q: %q", msg.From, msg.Text)
+	}
+	code := codePattern.FindString(msg.Text)
+	if code == "" || !strings.Contains(msg.HTML, code) {
+		t.Fatalf("код в текстовой и HTML-версии письма: %q, %q", msg.Text, msg.HTML)
+	}
+
+	// Код из письма подтверждает аккаунт, после чего вход работает
+	rec = s.do(t, "POST", "/verify", "", models.VerificationRequest{Email: "anna@example.com", Code: code})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("верификация кодом из письма: %d %s", rec.Code, rec.Body)
+	}
+	rec = s.do(t, "POST", "/login", "", models.LoginRequest{Email: "anna@example.com", Password: testPassword})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("вход: %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,232 @@
+// Package importer разбирает архивы других дневников и выгрузки DiaryApp в заметки:
+// ZIP с Markdown-файлами (YAML front matter), JSON-выгрузку DiaryApp (пакет export)
+// и JSON-архив Day One (как есть или в ZIP). Сохранение заметок и отчет об импорте -
+// забота вызывающего.
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"diary-backend/models"
+)
+
+// Поддерживаемые форматы импорта.
+const (
+	FormatMarkdown = "markdown"
+	FormatJSON     = "json"
+	FormatDayOne   = "dayone"
+)
+
+// Ограничения на содержимое архива. Сжатый файл может быть в тысячи раз меньше
+// распакованного, поэтому распаковка ограничена и по числу файлов, и по сумме байт.
+const (
+	MaxItems        = 10_000    // Заметок в одном импорте и файлов в одном ZIP-архиве
+	MaxFileSize     = 1 << 20   // Байт в одном файле архива после распаковки
+	MaxUnpackedSize = 128 << 20 // Байт во всех файлах архива после распаковки
+)
+
+// errTooManyItems - записей в источнике больше MaxItems.
+var errTooManyItems = fmt.Errorf("в архиве больше %d заметок", MaxItems)
+
+// ErrUnknownFormat - формат не указан и не определяется по содержимому.
+var ErrUnknownFormat = errors.New("не удалось определить формат: ожидается ZIP с Markdown-файлами, выгрузка DiaryApp в JSON или архив Day One")
+
+// Item - одна запись источника. Source указывает, откуда она взята (путь в архиве
+// или номер записи). Если запись не удалось разобрать, заполнено Err, а не Note.
+// Note.CreatedAt и Note.UpdatedAt нулевые, если источник их не содержит.
+type Item struct {
+	Source string
+	Note   models.Note
+	Err    error
+}
+
+// Tag - тег с цветом из выгрузки DiaryApp.
+type Tag struct {
+	Name  string
+	Color string
+}
+
+// Result - разобранный источник.
+type Result struct {
+	Format string
+	Items  []Item
+	Tags   []Tag // Только для выгрузки DiaryApp
+}
+
+// Parse разбирает данные формата format ("" - определить по содержимому).
+func Parse(format string, data []byte) (*Result, error) {
+	if format == "" {
+		var err error
+		if format, err = Detect(data); err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		res *Result
+		err error
+	)
+	switch format {
+	case FormatMarkdown:
+		res, err = parseMarkdownZip(data)
+	case FormatJSON:
+		res, err = parseExport(data)
+	case FormatDayOne:
+		res, err = parseDayOne(data)
+	default:
+		return nil, fmt.Errorf("неизвестный формат %q: ожидается markdown, json или dayone", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	res.Format = format
+	return res, nil
+}
+
+// Detect определяет формат по содержимому: ZIP с файлами .md - markdown, JSON
+// с массивом entries (или ZIP с таким JSON) - dayone, JSON с массивом notes - json.
+func Detect(data []byte) (string, error) {
+	if isZip(data) {
+		zr, err := openZip(data)
+		if err != nil {
+			return "", err
+		}
+		for _, f := range zr.File {
+			if !skipFile(f) && strings.EqualFold(path.Ext(f.Name), ".md") {
+				return FormatMarkdown, nil
+			}
+		}
+		_, err = dayOneJournal(zr)
+		switch {
+		case err == nil:
+			return FormatDayOne, nil
+		case errors.Is(err, errUnpackedTooLarge):
+			return "", err
+		}
+		return "", ErrUnknownFormat
+	}
+
+	var probe struct {
+		Notes   json.RawMessage `json:"notes"`
+		Entries json.RawMessage `json:"entries"`
+	}
+	if json.Unmarshal(data, &probe) == nil {
+		switch {
+		case probe.Entries != nil:
+			return FormatDayOne, nil
+		case probe.Notes != nil:
+			return FormatJSON, nil
+		}
+	}
+	return "", ErrUnknownFormat
+}
+
+// Hash - отпечаток содержимого заметки для поиска дубликатов: заголовок и текст
+// без учета окончаний строк и пробелов по краям, у зашифрованной заметки - шифртекст.
+func Hash(n *models.Note) string {
+	if n.Encrypted != nil {
+		sum := sha256.Sum256([]byte("encrypted\x00" + n.Encrypted.Ciphertext))
+		return hex.EncodeToString(sum[:])
+	}
+	normalize := func(s string) string {
+		return strings.TrimSpace(strings.ReplaceAll(s, "\r\n", "\n"))
+	}
+	sum := sha256.Sum256([]byte(normalize(n.Title) + "\x00" + normalize(n.Content)))
+	return hex.EncodeToString(sum[:])
+}
+
+func isZip(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("PK\x03\x04"))
+}
+
+// skipFile отсекает каталоги и служебные файлы архиваторов (__MACOSX, скрытые файлы).
+func skipFile(f *zip.File) bool {
+	if f.FileInfo().IsDir() || strings.HasPrefix(f.Name, "__MACOSX/") {
+		return true
+	}
+	return strings.HasPrefix(path.Base(f.Name), ".")
+}
+
+// errUnpackedTooLarge - распакованные файлы архива превысили MaxUnpackedSize.
+// В отличие от ошибки одного файла, прерывает весь импорт.
+var errUnpackedTooLarge = fmt.Errorf("архив больше %d МБ после распаковки", MaxUnpackedSize>>20)
+
+// zipArchive - ZIP-архив с общим бюджетом распаковки: каждый прочитанный байт
+// вычитается из left.
+type zipArchive struct {
+	*zip.Reader
+	left int64
+}
+
+// openZip открывает ZIP-архив. Архив, в котором больше MaxItems файлов, отклоняется
+// до чтения содержимого.
+func openZip(data []byte) (*zipArchive, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("поврежденный ZIP-архив: %w", err)
+	}
+	if len(zr.File) > MaxItems {
+		return nil, fmt.Errorf("в архиве больше %d файлов", MaxItems)
+	}
+	return &zipArchive{Reader: zr, left: MaxUnpackedSize}, nil
+}
+
+// readFile читает файл архива, не больше limit байт. Если при этом исчерпан общий
+// бюджет распаковки, возвращает errUnpackedTooLarge.
+func (a *zipArchive) readFile(f *zip.File, limit int64) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	// Читается не больше, чем осталось в бюджете, плюс байт для обнаружения превышения
+	data, err := io.ReadAll(io.LimitReader(rc, min(limit, a.left)+1))
+	a.left -= int64(len(data))
+	if a.left < 0 {
+		return nil, errUnpackedTooLarge
+	}
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("файл больше %d байт", limit)
+	}
+	return data, nil
+}
+
+// splitTitle отделяет заголовок от текста, если у записи нет отдельного заголовка:
+// им становится первая строка (без "#" Markdown-заголовка).
+func splitTitle(text string) (title, content string) {
+	text = strings.TrimLeft(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	first, rest, _ := strings.Cut(text, "\n")
+	if heading, ok := strings.CutPrefix(first, "#"); ok {
+		return strings.TrimSpace(strings.TrimLeft(heading, "#")), strings.TrimLeft(rest, "\n")
+	}
+	return strings.TrimSpace(first), strings.TrimLeft(rest, "\n")
+}
+
+// parseTime разбирает время в формате RFC 3339 или дату YYYY-MM-DD; пустая строка - нулевое время.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("неверное время %q: ожидается RFC 3339 или YYYY-MM-DD", s)
+	}
+	return t, nil
+}
@@ -0,0 +1,208 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// zipFile - файл тестового архива.
+type zipFile struct {
+	name string
+	data string
+}
+
+// makeZip собирает ZIP-архив в памяти. Время изменения всех файлов - modified.
+func makeZip(t *testing.T, files ...zipFile) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: modified})
+		if err != nil {
+			t.Fatalf("архив: %v", err)
+		}
+		w.Write([]byte(f.data))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("архив: %v", err)
+	}
+	return buf.Bytes()
+}
+
+var modified = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"ZIP с Markdown", makeZip(t, zipFile{"notes/a.md", "# A"}), FormatMarkdown},
+		{"ZIP с журналом Day One", makeZip(t, zipFile{"Journal.json", `{"entries":[]}`}), FormatDayOne},
+		{"JSON Day One", []byte(`{"metadata":{},"entries":[{"text":"A"}]}`), FormatDayOne},
+		{"выгрузка DiaryApp", []byte(`{"format":"diary-export","version":1,"notes":[]}`), FormatJSON},
+		{"служебные файлы не считаются", makeZip(t, zipFile{"__MACOSX/a.md", ""}, zipFile{".hidden.md", ""}), ""},
+		{"JSON без notes и entries", []byte(`{"items":[]}`), ""},
+		{"не JSON и не ZIP", []byte("просто текст"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Detect(tt.data)
+			if tt.want == "" {
+				if !errors.Is(err, ErrUnknownFormat) {
+					t.Fatalf("Detect = %q, %v; ожидалась ErrUnknownFormat", got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("Detect = %q, %v; ожидался %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMarkdown(t *testing.T) {
+	data := makeZip(t,
+		zipFile{"front.md", "---\ntitle: Из front matter\ntags: [работа, дом]\ncreated_at: 2023-05-01T10:00:00Z\nupdated_at: 2023-05-02T10:00:00Z\n---\n\nТекст\n"},
+		zipFile{"date.md", "---\ntags: отпуск, море\ndate: 2023-07-15\n---\n# Заголовок из текста\n\nТекст\r\n"},
+		zipFile{"dir/Имя файла.md", "\ufeffТекст без заголовка"},
+		zipFile{"encrypted.md", "---\nencrypted:\n  alg: AES-256-GCM\n  ciphertext: Y2lwaGVy\n  nonce: bm9uY2U=\n  wrapped_key: a2V5\n---\n"},
+		zipFile{"unclosed.md", "---\ntitle: A\n"},
+		zipFile{"bad-tags.md", "---\ntags: {a: b}\n---\n"},
+		zipFile{"bad-date.md", "---\ndate: вчера\n---\n"},
+		zipFile{"latin1.md", "\xe9t\xe9"},
+		zipFile{"readme.txt", "не заметка"},
+	)
+	res, err := Parse("", data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if res.Format != FormatMarkdown || len(res.Items) != 8 {
+		t.Fatalf("формат %q, записей %d", res.Format, len(res.Items))
+	}
+
+	front := res.Items[0].Note
+	if front.Title != "Из front matter" || front.Content != "Текст" || fmt.Sprint(front.Tags) != "[работа дом]" ||
+		!front.CreatedAt.Equal(time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)) || !front.UpdatedAt.Equal(time.Date(2023, 5, 2, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("front matter: %+v", front)
+	}
+	date := res.Items[1].Note
+	if date.Title != "Заголовок из текста" || date.Content != "Текст" || fmt.Sprint(date.Tags) != "[отпуск море]" ||
+		!date.CreatedAt.Equal(time.Date(2023, 7, 15, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("date и теги строкой: %+v", date)
+	}
+	plain := res.Items[2].Note
+	if plain.Title != "Имя файла" || plain.Content != "Текст без заголовка" || !plain.CreatedAt.Equal(modified) {
+		t.Errorf("без front matter: %+v", plain)
+	}
+	enc := res.Items[3].Note
+	if enc.Encrypted == nil || enc.Encrypted.Ciphertext != "Y2lwaGVy" || enc.Title != "" || !enc.CreatedAt.Equal(modified) {
+		t.Errorf("зашифрованная заметка: %+v", enc)
+	}
+
+	for i, want := range []string{"не закрыт", "tags", "неверное время", "UTF-8"} {
+		item := res.Items[4+i]
+		if item.Err == nil || !strings.Contains(item.Err.Error(), want) {
+			t.Errorf("%s: ошибка %v, ожидалась %q", item.Source, item.Err, want)
+		}
+	}
+}
+
+func TestParseDayOne(t *testing.T) {
+	journal := `{"entries":[
+		{"uuid":"A1","text":"# Утро\n\nКофе\n","tags":["быт"],"creationDate":"2022-01-02T08:00:00Z","modifiedDate":"2022-01-02T09:00:00Z"},
+		{"uuid":"B2","text":"Без даты","creationDate":"позавчера"},
+		"не объект"
+	]}`
+
+	t.Run("JSON", func(t *testing.T) {
+		res, err := Parse(FormatDayOne, []byte(journal))
+		if err != nil || len(res.Items) != 3 {
+			t.Fatalf("Parse: %v, %+v", err, res)
+		}
+		first := res.Items[0]
+		if first.Source != "entries[0] (A1)" || first.Note.Title != "Утро" || first.Note.Content != "Кофе" ||
+			fmt.Sprint(first.Note.Tags) != "[быт]" || !first.Note.UpdatedAt.Equal(time.Date(2022, 1, 2, 9, 0, 0, 0, time.UTC)) {
+			t.Errorf("запись: %+v", first)
+		}
+		if res.Items[1].Err == nil || res.Items[2].Err == nil {
+			t.Errorf("ожидались ошибки в записях: %+v", res.Items[1:])
+		}
+	})
+
+	t.Run("ZIP с двумя журналами", func(t *testing.T) {
+		data := makeZip(t,
+			zipFile{"Путешествия.json", `{"entries":[{"text":"Рим"}]}`},
+			zipFile{"Journal.json", journal},
+			zipFile{"photos/1.jpeg", "не JSON"},
+		)
+		res, err := Parse("", data)
+		if err != nil || res.Format != FormatDayOne || len(res.Items) != 4 {
+			t.Fatalf("Parse: %v, %+v", err, res)
+		}
+		// Журналы по имени файла
+		if res.Items[0].Source != "Journal.json: entries[0] (A1)" || res.Items[3].Source != "Путешествия.json: entries[0]" {
+			t.Errorf("источники: %q, %q", res.Items[0].Source, res.Items[3].Source)
+		}
+	})
+}
+
+func TestParseLimits(t *testing.T) {
+	var many []zipFile
+	for i := range MaxItems + 1 {
+		many = append(many, zipFile{fmt.Sprintf("%d.md", i), ""})
+	}
+	// Сжатые нули: архив - десятки килобайт, распакованный - больше MaxUnpackedSize
+	var bomb []zipFile
+	zeros := strings.Repeat("\x00", MaxFileSize)
+	for i := range MaxUnpackedSize/MaxFileSize + 1 {
+		bomb = append(bomb, zipFile{fmt.Sprintf("%d.md", i), zeros})
+	}
+	// Журналы в пределах maxJournalSize, но вместе больше MaxUnpackedSize
+	space := strings.Repeat(" ", maxJournalSize)
+	journals := makeZip(t, zipFile{"a.json", space}, zipFile{"b.json", space}, zipFile{"c.json", space})
+	entries := "{}" + strings.Repeat(",{}", MaxItems)
+
+	tests := []struct {
+		name   string
+		format string
+		data   []byte
+		want   string
+	}{
+		{"файлов больше MaxItems", FormatMarkdown, makeZip(t, many...), "файлов"},
+		{"файлов больше MaxItems при определении формата", "", makeZip(t, many...), "файлов"},
+		{"распакованный архив больше MaxUnpackedSize", FormatMarkdown, makeZip(t, bomb...), "после распаковки"},
+		{"журналы Day One больше MaxUnpackedSize", "", journals, "после распаковки"},
+		{"записей Day One больше MaxItems", FormatDayOne, []byte(`{"entries":[` + entries + `]}`), "заметок"},
+		{"заметок в выгрузке больше MaxItems", FormatJSON, []byte(`{"notes":[` + entries + `]}`), "заметок"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Parse(tt.format, tt.data)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Parse = %v; ожидалась ошибка %q", err, tt.want)
+			}
+			if res != nil {
+				t.Errorf("при ошибке возвращены записи: %d", len(res.Items))
+			}
+		})
+	}
+
+	t.Run("файл больше MaxFileSize", func(t *testing.T) {
+		data := makeZip(t, zipFile{"big.md", strings.Repeat("я", MaxFileSize)}, zipFile{"small.md", "Текст"})
+		res, err := Parse(FormatMarkdown, data)
+		if err != nil || len(res.Items) != 2 {
+			t.Fatalf("Parse: %v, %+v", err, res)
+		}
+		// Ошибка одного файла не мешает остальным
+		if res.Items[0].Err == nil || res.Items[1].Err != nil {
+			t.Errorf("ошибки: %v, %v", res.Items[0].Err, res.Items[1].Err)
+		}
+	})
+}
@@ -0,0 +1,181 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"diary-backend/export"
+	"diary-backend/models"
+)
+
+// exportFile - JSON-выгрузка DiaryApp (см. пакет export).
+type exportFile struct {
+	Format  string            `json:"format"`
+	Version int               `json:"version"`
+	Tags    []exportTag       `json:"tags"`
+	Notes   []json.RawMessage `json:"notes"`
+}
+
+type exportTag struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+type exportNote struct {
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Tags      []string  `json:"tags"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Encrypted *models.Envelope `json:"encrypted"`
+}
+
+// parseExport разбирает JSON-выгрузку DiaryApp. Заметки разбираются по отдельности:
+// ошибка в одной не мешает импорту остальных.
+func parseExport(data []byte) (*Result, error) {
+	var file exportFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("неверный JSON: %w", err)
+	}
+	if file.Format != "" && file.Format != "diary-export" {
+		return nil, fmt.Errorf("неизвестный формат выгрузки %q", file.Format)
+	}
+	if file.Version > export.Version {
+		return nil, fmt.Errorf("выгрузка версии %d новее поддерживаемой (%d)", file.Version, export.Version)
+	}
+
+	if len(file.Notes) > MaxItems {
+		return nil, errTooManyItems
+	}
+	res := &Result{Items: make([]Item, 0, len(file.Notes))}
+	for _, t := range file.Tags {
+		res.Tags = append(res.Tags, Tag{Name: t.Name, Color: t.Color})
+	}
+	for i, raw := range file.Notes {
+		item := Item{Source: fmt.Sprintf("notes[%d]", i)}
+		var n exportNote
+		if err := json.Unmarshal(raw, &n); err != nil {
+			item.Err = fmt.Errorf("неверная заметка: %w", err)
+		} else {
+			item.Note.Title = n.Title
+			item.Note.Content = n.Content
+			item.Note.Tags = n.Tags
+			item.Note.Encrypted = n.Encrypted
+			item.Note.CreatedAt = n.CreatedAt
+			item.Note.UpdatedAt = n.UpdatedAt
+		}
+		res.Items = append(res.Items, item)
+	}
+	return res, nil
+}
+
+// dayOneFile - JSON-архив Day One: файл журнала с массивом entries.
+type dayOneFile struct {
+	Entries []json.RawMessage `json:"entries"`
+}
+
+type dayOneEntry struct {
+	UUID         string   `json:"uuid"`
+	Text         string   `json:"text"`
+	Tags         []string `json:"tags"`
+	CreationDate string   `json:"creationDate"`
+	ModifiedDate string   `json:"modifiedDate"`
+}
+
+// maxJournalSize - байт в файле журнала Day One: он содержит все записи журнала.
+const maxJournalSize = 64 << 20
+
+// parseDayOne разбирает архив Day One: JSON журнала или ZIP с одним или несколькими
+// журналами. Заголовок заметки - первая строка текста записи.
+func parseDayOne(data []byte) (*Result, error) {
+	if !isZip(data) {
+		var file dayOneFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("неверный JSON: %w", err)
+		}
+		res := &Result{}
+		if err := appendDayOne(res, "", file.Entries); err != nil {
+			return nil, err
+		}
+		return res, nil
+	}
+
+	zr, err := openZip(data)
+	if err != nil {
+		return nil, err
+	}
+	journals, err := dayOneJournal(zr)
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{}
+	for _, name := range slices.Sorted(maps.Keys(journals)) {
+		if err := appendDayOne(res, name+": ", journals[name].Entries); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// dayOneJournal находит в ZIP-архиве файлы журналов Day One (JSON с массивом entries).
+func dayOneJournal(zr *zipArchive) (map[string]*dayOneFile, error) {
+	journals := make(map[string]*dayOneFile)
+	for _, f := range zr.File {
+		if skipFile(f) || !strings.EqualFold(path.Ext(f.Name), ".json") {
+			continue
+		}
+		data, err := zr.readFile(f, maxJournalSize)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		var file dayOneFile
+		if json.Unmarshal(data, &file) == nil && file.Entries != nil {
+			journals[f.Name] = &file
+		}
+	}
+	if len(journals) == 0 {
+		return nil, fmt.Errorf("в архиве нет журнала Day One (JSON с массивом entries)")
+	}
+	return journals, nil
+}
+
+func appendDayOne(res *Result, prefix string, entries []json.RawMessage) error {
+	if len(res.Items)+len(entries) > MaxItems {
+		return errTooManyItems
+	}
+	for i, raw := range entries {
+		item := Item{Source: fmt.Sprintf("%sentries[%d]", prefix, i)}
+		item.Err = parseDayOneEntry(raw, &item)
+		res.Items = append(res.Items, item)
+	}
+	return nil
+}
+
+func parseDayOneEntry(raw json.RawMessage, item *Item) error {
+	var e dayOneEntry
+	if err := json.Unmarshal(raw, &e); err != nil {
+		return fmt.Errorf("неверная запись: %w", err)
+	}
+	if e.UUID != "" {
+		item.Source += " (" + e.UUID + ")"
+	}
+
+	var err error
+	n := &item.Note
+	n.Title, n.Content = splitTitle(e.Text)
+	n.Content = strings.TrimRight(n.Content, "\n")
+	n.Tags = e.Tags
+	if n.CreatedAt, err = parseTime(e.CreationDate); err != nil {
+		return err
+	}
+	if n.UpdatedAt, err = parseTime(e.ModifiedDate); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package importer
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+
+	"diary-backend/models"
+)
+
+// frontMatter - поля YAML front matter, которые понимает импорт. Кроме полей выгрузки
+// DiaryApp принимается date (Jekyll, Obsidian) и tags строкой через запятую.
+type frontMatter struct {
+	Title     string `yaml:"title"`
+	Tags      any    `yaml:"tags"`
+	Date      string `yaml:"date"`
+	CreatedAt string `yaml:"created_at"`
+	UpdatedAt string `yaml:"updated_at"`
+
+	Encrypted *models.Envelope `yaml:"encrypted"` // Зашифрованная заметка из выгрузки; текст файла пуст
+}
+
+// parseMarkdownZip разбирает ZIP-архив: каждый файл .md - заметка. Заголовок берется
+// из front matter, иначе из первой строки "# ...", иначе из имени файла. Время
+// создания без front matter - время изменения файла в архиве.
+func parseMarkdownZip(data []byte) (*Result, error) {
+	if !isZip(data) {
+		return nil, fmt.Errorf("ожидается ZIP-архив с Markdown-файлами")
+	}
+	zr, err := openZip(data)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{}
+	for _, f := range zr.File {
+		if skipFile(f) || !strings.EqualFold(path.Ext(f.Name), ".md") {
+			continue
+		}
+		item := Item{Source: f.Name}
+		item.Err = parseMarkdownFile(zr, f, &item)
+		if errors.Is(item.Err, errUnpackedTooLarge) {
+			return nil, item.Err
+		}
+		res.Items = append(res.Items, item)
+	}
+	return res, nil
+}
+
+func parseMarkdownFile(zr *zipArchive, f *zip.File, item *Item) error {
+	data, err := zr.readFile(f, MaxFileSize)
+	if err != nil {
+		return err
+	}
+	if !utf8.Valid(data) {
+		return fmt.Errorf("файл не в кодировке UTF-8")
+	}
+
+	text := strings.TrimPrefix(strings.ReplaceAll(string(data), "\r\n", "\n"), "\ufeff")
+	front, body, hasFront, err := splitFrontMatter(text)
+	if err != nil {
+		return err
+	}
+
+	n := &item.Note
+	if hasFront {
+		n.Title = strings.TrimSpace(front.Title)
+		if n.Tags, err = frontTags(front.Tags); err != nil {
+			return err
+		}
+		created := front.CreatedAt
+		if created == "" {
+			created = front.Date
+		}
+		if n.CreatedAt, err = parseTime(created); err != nil {
+			return err
+		}
+		if n.UpdatedAt, err = parseTime(front.UpdatedAt); err != nil {
+			return err
+		}
+	}
+	if front.Encrypted != nil {
+		n.Encrypted = front.Encrypted
+		if n.CreatedAt.IsZero() {
+			n.CreatedAt = f.Modified
+		}
+		return nil
+	}
+	if n.Title == "" {
+		if strings.HasPrefix(strings.TrimLeft(body, "\n"), "# ") {
+			n.Title, body = splitTitle(body)
+		} else {
+			n.Title = strings.TrimSuffix(path.Base(f.Name), path.Ext(f.Name))
+		}
+	}
+	if n.CreatedAt.IsZero() {
+		n.CreatedAt = f.Modified
+	}
+	n.Content = strings.TrimRight(strings.TrimLeft(body, "\n"), "\n")
+	return nil
+}
+
+// splitFrontMatter отделяет YAML front matter между строками "---" от текста.
+func splitFrontMatter(text string) (front frontMatter, body string, ok bool, err error) {
+	rest, found := strings.CutPrefix(text, "---\n")
+	if !found {
+		return front, text, false, nil
+	}
+	var yamlText string
+	if strings.HasPrefix(rest, "---\n") || rest == "---" {
+		yamlText, body = "", strings.TrimPrefix(strings.TrimPrefix(rest, "---"), "\n")
+	} else {
+		var closed bool
+		yamlText, body, closed = strings.Cut(rest, "\n---\n")
+		if !closed {
+			if yamlText, closed = strings.CutSuffix(rest, "\n---"); !closed {
+				return front, "", false, fmt.Errorf("front matter не закрыт строкой ---")
+			}
+			body = ""
+		}
+	}
+	if err := yaml.Unmarshal([]byte(yamlText), &front); err != nil {
+		return front, "", false, fmt.Errorf("неверный front matter: %w", err)
+	}
+	return front, body, true, nil
+}
+
+// frontTags принимает теги списком или строкой через запятую.
+func frontTags(v any) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		var tags []string
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				tags = append(tags, t)
+			}
+		}
+		return tags, nil
+	case []any:
+		tags := make([]string, 0, len(v))
+		for _, t := range v {
+			s, ok := t.(string)
+			if !ok {
+				return nil, fmt.Errorf("tags: ожидается список строк")
+			}
+			tags = append(tags, s)
+		}
+		return tags, nil
+	default:
+		return nil, fmt.Errorf("tags: ожидается список строк или строка")
+	}
+}
@@ -0,0 +1,46 @@
+package mailer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileDrop сохраняет каждое письмо в отдельный .eml-файл в каталоге. Файлы открываются
+// любым почтовым клиентом; удобно для разработки без доступа к SMTP.
+type FileDrop struct {
+	dir  string
+	from string
+}
+
+// NewFileDrop создает Mailer, пишущий письма в dir. Каталог создается при необходимости.
+func NewFileDrop(dir, from string) (*FileDrop, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("не удалось создать каталог для писем: %w", err)
+	}
+	return &FileDrop{dir: dir, from: from}, nil
+}
+
+func (m *FileDrop) Send(_ context.Context, msg Message) error {
+	msg = withFrom(msg, m.from)
+	body, err := Build(msg)
+	if err != nil {
+		return err
+	}
+
+	// Имя файла сортируется по времени отправки
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	name := time.Now().UTC().Format("20060102T150405.000000000") + "-" + hex.EncodeToString(suffix) + ".eml"
+	path := filepath.Join(m.dir, name)
+
+	if err := os.WriteFile(path, body, 0o600); err != nil {
+		return fmt.Errorf("ошибка записи письма: %w", err)
+	}
+	logSent(msg, nil)
+	return nil
+}
@@ -0,0 +1,167 @@
+// Package mailer отправляет письма пользователям. Mailer - интерфейс отправки,
+// реализации выбираются секцией mail конфигурации: SMTP, HTTP API Resend,
+// .eml-файлы в каталоге, журнал сервера или память процесса (для тестов).
+// Тексты писем берутся из шаблонов (Templates) на языке получателя.
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"diary-backend/config"
+)
+
+// Message - одно письмо одному получателю.
+type Message struct {
+	From    string // Пустое значение - адрес отправителя из конфигурации
+	To      string
+	Subject string
+	Text    string // Текстовая версия
+	HTML    string // HTML-версия
+}
+
+// Mailer отправляет письма.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New создает Mailer по секциям mail и smtp конфигурации.
+func New(cfg config.MailConfig, smtpCfg config.SMTPConfig) (Mailer, error) {
+	switch cfg.Driver {
+	case "smtp":
+		return NewSMTP(smtpCfg, cfg.From), nil
+	case "resend":
+		return NewResend(cfg.Resend, cfg.From), nil
+	case "file":
+		return NewFileDrop(cfg.Dir, cfg.From)
+	case "log":
+		return NewLog(cfg.From), nil
+	case "memory":
+		return NewMemory(cfg.From), nil
+	default:
+		return nil, fmt.Errorf("неподдерживаемый драйвер почты %q", cfg.Driver)
+	}
+}
+
+// withFrom подставляет отправителя по умолчанию.
+func withFrom(msg Message, from string) Message {
+	if msg.From == "" {
+		msg.From = from
+	}
+	return msg
+}
+
+// Build формирует письмо по RFC 5322. Если заданы обе версии, тело - multipart/alternative
+// (сначала текст, затем HTML, как требует RFC 2046); части кодируются quoted-printable.
+func Build(msg Message) ([]byte, error) {
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный адрес отправителя %q: %w", msg.From, err)
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный адрес получателя %q: %w", msg.To, err)
+	}
+
+	var buf bytes.Buffer
+	header := func(name, value string) {
+		buf.WriteString(name + ": " + value + "\r\n")
+	}
+	header("From", from.String())
+	header("To", to.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", messageID(from.Address))
+	header("MIME-Version", "1.0")
+
+	if msg.Text == "" || msg.HTML == "" {
+		contentType, body := "text/plain", msg.Text
+		if msg.HTML != "" {
+			contentType, body = "text/html", msg.HTML
+		}
+		header("Content-Type", contentType+`; charset="UTF-8"`)
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, body); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+	header("Content-Type", mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": mw.Boundary()}))
+	buf.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain", msg.Text},
+		{"text/html", msg.HTML},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType + `; charset="UTF-8"`},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(pw, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeQuotedPrintable пишет body в кодировке quoted-printable.
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// messageID создает уникальный Message-ID в домене отправителя.
+func messageID(from string) string {
+	domain := "localhost"
+	if _, d, ok := strings.Cut(from, "@"); ok && d != "" {
+		domain = d
+	}
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
+
+// logSent пишет в журнал результат отправки.
+func logSent(msg Message, err error) {
+	if err != nil {
+		log.Printf("Ошибка при отправке письма на %s: %v", msg.To, err)
+		return
+	}
+	log.Printf("Письмо \"%s\" успешно отправлено на: %s", msg.Subject, msg.To)
+}
+
+// envelope возвращает адреса для команд MAIL FROM и RCPT TO.
+func envelope(msg Message) (from, to string, err error) {
+	f, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return "", "", err
+	}
+	t, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return "", "", err
+	}
+	return f.Address, t.Address, nil
+}
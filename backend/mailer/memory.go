@@ -0,0 +1,67 @@
+package mailer
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// Log не отправляет письма, а пишет их в журнал сервера. Подходит для локального
+// запуска: код верификации виден прямо в выводе.
+type Log struct {
+	from string
+}
+
+// NewLog создает Mailer, пишущий письма в журнал.
+func NewLog(from string) *Log {
+	return &Log{from: from}
+}
+
+func (m *Log) Send(_ context.Context, msg Message) error {
+	msg = withFrom(msg, m.from)
+	body := msg.Text
+	if body == "" {
+		body = msg.HTML
+	}
+	log.Printf("Письмо от %s для %s: %s\n%s", msg.From, msg.To, msg.Subject, body)
+	return nil
+}
+
+// Memory сохраняет письма в памяти процесса, чтобы тесты могли проверить их содержимое.
+type Memory struct {
+	from string
+
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewMemory создает пустой Memory.
+func NewMemory(from string) *Memory {
+	return &Memory{from: from}
+}
+
+func (m *Memory) Send(_ context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, withFrom(msg, m.from))
+	return nil
+}
+
+// Messages возвращает копию всех отправленных писем в порядке отправки.
+func (m *Memory) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
+
+// Last возвращает последнее письмо для адреса to.
+func (m *Memory) Last(to string) (Message, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].To == to {
+			return m.messages[i], true
+		}
+	}
+	return Message{}, false
+}
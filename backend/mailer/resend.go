@@ -0,0 +1,82 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"diary-backend/config"
+)
+
+// Resend отправляет письма через HTTP API Resend (POST /emails).
+type Resend struct {
+	apiKey  string
+	baseURL string
+	from    string
+	client  *http.Client
+}
+
+// NewResend создает Mailer для API Resend. cfg.BaseURL можно направить на локальную заглушку.
+func NewResend(cfg config.ResendConfig, from string) *Resend {
+	return &Resend{
+		apiKey:  cfg.APIKey,
+		baseURL: strings.TrimSuffix(cfg.BaseURL, "/"),
+		from:    from,
+		client:  &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+type resendRequest struct {
+	From    string   `json:"from"`
+	To      []string `json:"to"`
+	Subject string   `json:"subject"`
+	HTML    string   `json:"html,omitempty"`
+	Text    string   `json:"text,omitempty"`
+}
+
+func (m *Resend) Send(ctx context.Context, msg Message) error {
+	msg = withFrom(msg, m.from)
+	err := m.send(ctx, msg)
+	logSent(msg, err)
+	if err != nil {
+		return fmt.Errorf("ошибка отправки email: %w", err)
+	}
+	return nil
+}
+
+func (m *Resend) send(ctx context.Context, msg Message) error {
+	payload, err := json.Marshal(resendRequest{
+		From:    msg.From,
+		To:      []string{msg.To},
+		Subject: msg.Subject,
+		HTML:    msg.HTML,
+		Text:    msg.Text,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.baseURL+"/emails", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+m.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("API Resend ответил %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
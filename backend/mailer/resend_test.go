@@ -0,0 +1,81 @@
+package mailer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"diary-backend/config"
+)
+
+func TestResendSend(t *testing.T) {
+	var got resendRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/emails" {
+			t.Errorf("запрос %s %s, ожидался POST /emails", r.Method, r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer re_test_key" {
+			t.Errorf("Authorization: %q", auth)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type: %q", ct)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("тело запроса: %v", err)
+		}
+		w.Write([]byte(`{"id":"email-1"}`))
+	}))
+	defer srv.Close()
+
+	// Лишний слеш в base_url не должен давать "//emails"
+	m := NewResend(config.ResendConfig{APIKey: "re_test_key", BaseURL: srv.URL + "/"}, "Diary <noreply@example.com>")
+	err := m.Send(context.Background(), Message{To: "user@example.com", Subject: "Код", Text: "123456", HTML: "<p>123456</p>"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	want := resendRequest{
+		From:    "Diary <noreply@example.com>",
+		To:      []string{"user@example.com"},
+		Subject: "Код",
+		HTML:    "<p>123456</p>",
+		Text:    "123456",
+	}
+	if got.From != want.From || strings.Join(got.To, ",") != strings.Join(want.To, ",") ||
+		got.Subject != want.Subject || got.HTML != want.HTML || got.Text != want.Text {
+		t.Errorf("тело запроса %+v, ожидалось %+v", got, want)
+	}
+}
+
+func TestResendSendError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"неверный ключ", http.StatusUnauthorized, `{"message":"API key is invalid"}`},
+		{"превышен лимит", http.StatusTooManyRequests, `{"message":"Too many requests"}`},
+		{"ошибка сервера", http.StatusInternalServerError, "internal error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, tt.body, tt.status)
+			}))
+			defer srv.Close()
+
+			m := NewResend(config.ResendConfig{APIKey: "re_test_key", BaseURL: srv.URL}, "noreply@example.com")
+			err := m.Send(context.Background(), Message{To: "user@example.com", Subject: "Код", Text: "123456"})
+			if err == nil {
+				t.Fatal("ожидалась ошибка")
+			}
+			// В ошибке - статус и ответ API, чтобы причина была видна в email_outbox.last_error
+			if !strings.Contains(err.Error(), http.StatusText(tt.status)) || !strings.Contains(err.Error(), tt.body) {
+				t.Errorf("ошибка без статуса или ответа API: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+
+	"diary-backend/config"
+)
+
+// SMTP отправляет письма через SMTP-сервер.
+type SMTP struct {
+	cfg  config.SMTPConfig
+	from string
+}
+
+// NewSMTP создает Mailer для SMTP-сервера из конфигурации.
+func NewSMTP(cfg config.SMTPConfig, from string) *SMTP {
+	return &SMTP{cfg: cfg, from: from}
+}
+
+func (m *SMTP) Send(ctx context.Context, msg Message) error {
+	msg = withFrom(msg, m.from)
+	err := m.send(ctx, msg)
+	logSent(msg, err)
+	if err != nil {
+		return fmt.Errorf("ошибка отправки email: %w", err)
+	}
+	return nil
+}
+
+func (m *SMTP) send(ctx context.Context, msg Message) error {
+	body, err := Build(msg)
+	if err != nil {
+		return err
+	}
+
+	// 1. Подключение: сразу по TLS или открытым текстом (с возможным STARTTLS)
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	tlsConfig := &tls.Config{ServerName: m.cfg.Host}
+	var conn net.Conn
+	if m.cfg.TLS == "tls" {
+		conn, err = (&tls.Dialer{Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	// 2. STARTTLS
+	if m.cfg.TLS == "auto" || m.cfg.TLS == "starttls" {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		} else if m.cfg.TLS == "starttls" {
+			return errors.New("сервер не поддерживает STARTTLS")
+		}
+	}
+
+	// 3. Аутентификация (только если задано имя пользователя)
+	if m.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)); err != nil {
+			return err
+		}
+	}
+
+	// 4. Отправка письма
+	from, to, err := envelope(msg)
+	if err != nil {
+		return err
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
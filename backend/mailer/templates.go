@@ -0,0 +1,201 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Шаблоны писем по умолчанию. Для каждого языка и вида письма есть пара файлов:
+// <язык>/<вид>.txt (text/template с блоками "subject" и "text") и
+// <язык>/<вид>.html (html/template с блоком "content", вставляется в layout.html).
+//
+//go:embed templates
+var defaultTemplates embed.FS
+
+// Виды писем (имена файлов шаблонов).
+const (
+	TemplateVerification  = "verification"
+	TemplatePasswordReset = "password_reset"
+)
+
+var templateKinds = []string{TemplateVerification, TemplatePasswordReset}
+
+// CodeData - данные для писем с одноразовым кодом.
+type CodeData struct {
+	Username string
+	Code     string
+	TTL      time.Duration
+}
+
+// templateData - то, что видят шаблоны.
+type templateData struct {
+	CodeData
+	Locale     string
+	Subject    string
+	TTLMinutes int
+}
+
+// localeTemplates - разобранные шаблоны одного языка.
+type localeTemplates struct {
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+// Templates формирует письма из шаблонов на языке получателя.
+type Templates struct {
+	defaultLocale string
+	locales       map[string]*localeTemplates
+}
+
+// LoadTemplates разбирает встроенные шаблоны. Если dir не пуст, файлы из него
+// заменяют одноименные встроенные (можно переопределить только часть писем или
+// добавить новый язык). defaultLocale используется, когда язык получателя не поддерживается.
+func LoadTemplates(dir, defaultLocale string) (*Templates, error) {
+	embedded, err := fs.Sub(defaultTemplates, "templates")
+	if err != nil {
+		return nil, err
+	}
+	var fsys fs.FS = embedded
+	if dir != "" {
+		fsys = overlayFS{primary: os.DirFS(dir), fallback: embedded}
+	}
+
+	layout, err := htmltemplate.ParseFS(fsys, "layout.html")
+	if err != nil {
+		return nil, fmt.Errorf("шаблон layout.html: %w", err)
+	}
+
+	t := &Templates{defaultLocale: defaultLocale, locales: make(map[string]*localeTemplates)}
+	for _, locale := range localeDirs(fsys, embedded, dir) {
+		lt := &localeTemplates{
+			text: make(map[string]*texttemplate.Template),
+			html: make(map[string]*htmltemplate.Template),
+		}
+		for _, kind := range templateKinds {
+			base := path.Join(locale, kind)
+			text, err := texttemplate.ParseFS(fsys, base+".txt")
+			if err != nil {
+				return nil, fmt.Errorf("шаблон %s.txt: %w", base, err)
+			}
+			html, err := htmltemplate.Must(layout.Clone()).ParseFS(fsys, base+".html")
+			if err != nil {
+				return nil, fmt.Errorf("шаблон %s.html: %w", base, err)
+			}
+			lt.text[kind] = text
+			lt.html[kind] = html
+		}
+		t.locales[locale] = lt
+	}
+
+	if _, ok := t.locales[defaultLocale]; !ok {
+		return nil, fmt.Errorf("нет шаблонов писем для языка по умолчанию %q", defaultLocale)
+	}
+	return t, nil
+}
+
+// localeDirs возвращает языки: каталоги встроенных шаблонов и каталога dir.
+func localeDirs(fsys, embedded fs.FS, dir string) []string {
+	seen := make(map[string]bool)
+	collect := func(f fs.FS) {
+		entries, _ := fs.ReadDir(f, ".")
+		for _, e := range entries {
+			if e.IsDir() {
+				seen[e.Name()] = true
+			}
+		}
+	}
+	collect(embedded)
+	if dir != "" {
+		collect(os.DirFS(dir))
+	}
+	locales := make([]string, 0, len(seen))
+	for l := range seen {
+		locales = append(locales, l)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Locales возвращает поддерживаемые языки.
+func (t *Templates) Locales() []string {
+	locales := make([]string, 0, len(t.locales))
+	for l := range t.locales {
+		locales = append(locales, l)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Supports сообщает, есть ли шаблоны для языка.
+func (t *Templates) Supports(locale string) bool {
+	_, ok := t.locales[locale]
+	return ok
+}
+
+// DefaultLocale возвращает язык по умолчанию.
+func (t *Templates) DefaultLocale() string {
+	return t.defaultLocale
+}
+
+// Verification - письмо с кодом верификации аккаунта.
+func (t *Templates) Verification(locale, to string, data CodeData) (Message, error) {
+	return t.Render(TemplateVerification, locale, to, data)
+}
+
+// PasswordReset - письмо с кодом сброса пароля.
+func (t *Templates) PasswordReset(locale, to string, data CodeData) (Message, error) {
+	return t.Render(TemplatePasswordReset, locale, to, data)
+}
+
+// Render формирует письмо вида kind на языке locale (или на языке по умолчанию,
+// если locale не поддерживается): тема, текстовая и HTML-версии.
+func (t *Templates) Render(kind, locale, to string, data CodeData) (Message, error) {
+	lt, ok := t.locales[locale]
+	if !ok {
+		locale = t.defaultLocale
+		lt = t.locales[locale]
+	}
+	text, ok := lt.text[kind]
+	if !ok {
+		return Message{}, fmt.Errorf("неизвестный вид письма %q", kind)
+	}
+
+	td := templateData{CodeData: data, Locale: locale, TTLMinutes: int(data.TTL.Minutes())}
+
+	var subject, plain, html bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", td); err != nil {
+		return Message{}, err
+	}
+	td.Subject = strings.TrimSpace(subject.String())
+	if err := text.ExecuteTemplate(&plain, "text", td); err != nil {
+		return Message{}, err
+	}
+	if err := lt.html[kind].ExecuteTemplate(&html, "layout", td); err != nil {
+		return Message{}, err
+	}
+
+	return Message{To: to, Subject: td.Subject, Text: plain.String(), HTML: html.String()}, nil
+}
+
+// overlayFS читает файл из primary, а если его там нет - из fallback.
+type overlayFS struct {
+	primary, fallback fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	f, err := o.primary.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return o.fallback.Open(name)
+	}
+	return f, err
+}
@@ -0,0 +1,110 @@
+package mailer
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"diary-backend/config"
+	"diary-backend/models"
+	"diary-backend/store"
+)
+
+// Queued превращает письмо в запись очереди email_outbox вида kind.
+// Отправитель не сохраняется: его подставляет Mailer при доставке.
+func Queued(kind string, msg Message) *models.OutboxEmail {
+	return &models.OutboxEmail{
+		Kind:      kind,
+		Recipient: msg.To,
+		Subject:   msg.Subject,
+		TextBody:  msg.Text,
+		HTMLBody:  msg.HTML,
+	}
+}
+
+// Worker доставляет письма из очереди email_outbox. Неудачные попытки повторяются
+// с экспоненциально растущей паузой; после cfg.MaxAttempts неудач письмо получает
+// статус models.EmailDead и больше не отправляется.
+type Worker struct {
+	outbox store.OutboxStore
+	mailer Mailer
+	cfg    config.OutboxConfig
+}
+
+// NewWorker создает обработчик очереди.
+func NewWorker(outbox store.OutboxStore, mailer Mailer, cfg config.OutboxConfig) *Worker {
+	return &Worker{outbox: outbox, mailer: mailer, cfg: cfg}
+}
+
+// Run проверяет очередь каждые cfg.PollInterval, пока ctx не отменен.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Пока очередь отдает полные пачки, разбираем ее без паузы
+		for {
+			if w.deliverDue(ctx) < w.cfg.BatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliverDue отправляет одну пачку писем и возвращает ее размер.
+func (w *Worker) deliverDue(ctx context.Context) int {
+	// Письмо забирается на время отправки с запасом, чтобы его не отправил параллельный обработчик
+	emails, err := w.outbox.ClaimDueEmails(ctx, w.cfg.BatchSize, 2*w.cfg.SendTimeout)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Ошибка чтения очереди писем: %v", err)
+		}
+		return 0
+	}
+	for _, e := range emails {
+		w.deliver(ctx, e)
+	}
+	return len(emails)
+}
+
+// deliver отправляет одно письмо и записывает результат.
+func (w *Worker) deliver(ctx context.Context, e models.OutboxEmail) {
+	sendCtx, cancel := context.WithTimeout(ctx, w.cfg.SendTimeout)
+	err := w.mailer.Send(sendCtx, Message{To: e.Recipient, Subject: e.Subject, Text: e.TextBody, HTML: e.HTMLBody})
+	cancel()
+
+	if err == nil {
+		if err := w.outbox.MarkEmailSent(ctx, e.ID); err != nil {
+			log.Printf("Письмо %d отправлено, но статус не сохранен: %v", e.ID, err)
+		}
+		return
+	}
+
+	attempts := e.Attempts + 1
+	dead := attempts >= w.cfg.MaxAttempts
+	next := time.Now().Add(w.backoff(attempts))
+	if dead {
+		log.Printf("Письмо %d (%s) не доставлено после %d попыток: %v", e.ID, e.Kind, attempts, err)
+	}
+	if err := w.outbox.MarkEmailFailed(ctx, e.ID, err.Error(), next, dead); err != nil {
+		log.Printf("Ошибка сохранения статуса письма %d: %v", e.ID, err)
+	}
+}
+
+// backoff возвращает паузу перед следующей попыткой: RetryBackoff * 2^(attempts-1),
+// не больше MaxBackoff, со случайным разбросом ±20%, чтобы повторы не шли одной волной.
+func (w *Worker) backoff(attempts int) time.Duration {
+	d := w.cfg.RetryBackoff
+	for i := 1; i < attempts && d < w.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, w.cfg.MaxBackoff)
+	jitter := time.Duration(rand.Int64N(int64(d)/5*2+1)) - d/5
+	return d + jitter
+}
@@ -1,76 +1,183 @@
 package main
 
 import (
-	"database/sql"
+	"context"
+	"diary-backend/blobstore"  // содержимое вложений
+	"diary-backend/config"     // пакет конфигурации
+	"diary-backend/encryption" // шифрование заметок в БД
 	"diary-backend/handlers"   // пакет обработчиков
+	"diary-backend/mailer"     // отправка почты
 	"diary-backend/middleware" // пакет middleware
+	"diary-backend/trash"      // очистка корзины
+	"diary-backend/utils"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 
 	"github.com/gorilla/mux"
-	_ "github.com/lib/pq" // Драйвер PostgreSQL
-)
-
-const (
-    dbConnStr = "user=postgres password=postgreAdmin dbname=diarydb sslmode=disable"
-    port      = ":8080"
 )
 
 func main() {
-    // --- 1. Подключение к БД ---
-    db, err := sql.Open("postgres", dbConnStr)
-    if err != nil {
-        log.Fatalf("Ошибка при открытии подключения к БД: %v", err)
-    }
-    defer db.Close()
-
-    err = db.Ping()
-    if err != nil {
-        log.Fatalf("Ошибка подключения к БД: %v", err)
-    }
-    fmt.Println("Успешное подключение к PostgreSQL.")
-
-    // --- 2. Настройка маршрутизатора ---
-    r := mux.NewRouter()
-
-    // 3. Обработчики Аутентификации (публичный доступ)
-    // Эти функции вызываются с db в качестве аргумента, чтобы они могли получить доступ к базе данных.
-    r.HandleFunc("/register", handlers.RegisterHandler(db)).Methods("POST")  // Регистрация с отправкой email
-    r.HandleFunc("/login", handlers.LoginHandler(db)).Methods("POST")        // Вход (проверяет is_verified)
-    r.HandleFunc("/verify", handlers.VerifyHandler(db)).Methods("POST")      // Верификация аккаунта
-	r.HandleFunc("/resend-code", handlers.ResendCodeHandler(db)).Methods("POST")
-
-    // 4. Группа защищенных маршрутов (требуется JWT)
-    protectedRouter := r.PathPrefix("/notes").Subrouter()
-    // Применяем middleware для проверки токена (AuthMiddleware)
-    protectedRouter.Use(middleware.AuthMiddleware)
-
-    // 5. Обработчики Заметок (CRUD)
-    
-	protectedRouter.HandleFunc("", handlers.GetNotes(db)).Methods("GET")
-    protectedRouter.HandleFunc("", handlers.CreateNote(db)).Methods("POST")
-    protectedRouter.HandleFunc("/{id}", handlers.UpdateNote(db)).Methods("PUT")
-    protectedRouter.HandleFunc("/{id}", handlers.GetNote(db)).Methods("GET")
-    protectedRouter.HandleFunc("/{id}", handlers.DeleteNote(db)).Methods("DELETE")
-
-    // --- 6. Настройка CORS и Запуск сервера ---
-    handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-        // Устанавливаем заголовки CORS для разрешения запросов с фронтенда (React)
-        w.Header().Set("Access-Control-Allow-Origin", "*")
-        w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-        w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-        
-        // Ответ на предзапрос OPTIONS
-        if req.Method == "OPTIONS" {
-            w.WriteHeader(http.StatusOK)
-            return
-        }
-
-        r.ServeHTTP(w, req) // Передаем запрос роутеру
-    })
-
-    // 7. Запуск сервера
-    fmt.Printf("Сервер запущен на порту %s\n", port)
-    log.Fatal(http.ListenAndServe(port, handler)) // Используем handler с CORS
-}
\ No newline at end of file
+	// --- 0. Загрузка конфигурации ---
+	// Путь к файлу можно передать флагом -config или переменной окружения DIARY_CONFIG.
+	configPath := flag.String("config", os.Getenv(config.EnvConfigPath), "путь к YAML-файлу конфигурации")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Ошибка загрузки конфигурации: %v", err)
+	}
+
+	// Подкоманда "migrate up|down [N]|status" управляет схемой БД и завершает работу.
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(cfg.Database, flag.Args()[1:]); err != nil {
+			log.Fatalf("Ошибка миграции: %v", err)
+		}
+		return
+	}
+
+	// --- 1. Подключение к хранилищу ---
+	st, closeStore, err := openStore(cfg.Database)
+	if err != nil {
+		log.Fatalf("Ошибка подключения к БД: %v", err)
+	}
+	defer closeStore()
+
+	// Заголовки и тексты заметок шифруются в БД, если заданы мастер-ключи. Фоновое
+	// перешифрование работает с хранилищем напрямую: ему нужен шифртекст, а не заметки
+	if cfg.Encryption.Enabled() {
+		ring, err := encryption.NewKeyring(st, cfg.Encryption)
+		if err != nil {
+			log.Fatalf("Ошибка настройки шифрования: %v", err)
+		}
+		go encryption.NewRotator(st, ring, cfg.Encryption).Run(context.Background())
+		st = encryption.NewStore(st, ring)
+	}
+
+	tokens := utils.NewTokenManager(cfg.JWT)
+	mail, err := mailer.New(cfg.Mail, cfg.SMTP)
+	if err != nil {
+		log.Fatalf("Ошибка настройки почты: %v", err)
+	}
+	emails, err := mailer.LoadTemplates(cfg.Mail.TemplatesDir, cfg.Mail.DefaultLocale)
+	if err != nil {
+		log.Fatalf("Ошибка загрузки шаблонов писем: %v", err)
+	}
+	blobs, err := blobstore.New(cfg.Attachments)
+	if err != nil {
+		log.Fatalf("Ошибка настройки хранилища вложений: %v", err)
+	}
+	// Письма из очереди email_outbox отправляются в фоне
+	go mailer.NewWorker(st, mail, cfg.Mail.Outbox).Run(context.Background())
+	// Заметки, пролежавшие в корзине дольше cfg.Trash.Retention, удаляются в фоне
+	// вместе с содержимым их вложений
+	go trash.NewJanitor(st, st, blobs, cfg.Trash).Run(context.Background())
+
+	// --- 2. Настройка маршрутизатора ---
+	r := mux.NewRouter()
+
+	// 3. Обработчики Аутентификации (публичный доступ)
+	// Эти функции получают хранилище и зависимости из конфигурации в качестве аргументов.
+	r.HandleFunc("/register", handlers.RegisterHandler(st, emails, cfg.Verification)).Methods("POST") // Регистрация с отправкой email
+	r.HandleFunc("/login", handlers.LoginHandler(st, st, tokens, cfg.TwoFactor)).Methods("POST")      // Вход (проверяет is_verified)
+	r.HandleFunc("/verify", handlers.VerifyHandler(st, st, tokens, cfg.Verification)).Methods("POST") // Верификация аккаунта
+	r.HandleFunc("/resend-code", handlers.ResendCodeHandler(st, st, emails, cfg.Verification)).Methods("POST")
+	r.HandleFunc("/verification/status", handlers.VerificationEmailStatusHandler(st, st)).Methods("GET")               // Доставка письма с кодом
+	r.HandleFunc("/password/forgot", handlers.ForgotPasswordHandler(st, st, emails, cfg.Verification)).Methods("POST") // Запрос кода сброса пароля
+	r.HandleFunc("/password/reset", handlers.ResetPasswordHandler(st, cfg.Verification)).Methods("POST")               // Сброс пароля по коду
+	r.HandleFunc("/auth/refresh", handlers.RefreshHandler(st, tokens)).Methods("POST")                                 // Ротация токена обновления
+
+	// Проверка JWT с учетом отозванных токенов
+	authMiddleware := middleware.AuthMiddleware(tokens, st)
+
+	r.Handle("/auth/logout", authMiddleware(handlers.LogoutHandler(st, st))).Methods("POST")    // Выход из текущей сессии
+	r.Handle("/auth/logout-all", authMiddleware(handlers.LogoutAllHandler(st))).Methods("POST") // Выход на всех устройствах
+
+	r.Handle("/account/locale", authMiddleware(handlers.LocaleHandler(st, emails))).Methods("PUT") // Язык писем
+	r.Handle("/export", authMiddleware(handlers.ExportHandler(st, st, st))).Methods("GET")         // Выгрузка дневника
+	r.Handle("/import", authMiddleware(handlers.ImportHandler(st, st))).Methods("POST")            // Импорт из других дневников
+
+	// Ключи сквозного шифрования заметок: сервер хранит только зашифрованный мастер-ключ
+	r.Handle("/e2ee/keys", authMiddleware(handlers.GetUserKeys(st))).Methods("GET")
+	r.Handle("/e2ee/keys", authMiddleware(handlers.PutUserKeys(st))).Methods("PUT")
+
+	// Заметка по публичной ссылке - без входа в аккаунт; POST - с паролем из формы
+	r.HandleFunc("/s/{token}", handlers.ViewShare(st, st)).Methods("GET", "POST")
+
+	// Двухфакторная аутентификация (TOTP)
+	r.HandleFunc("/auth/2fa/verify", handlers.TwoFactorLoginHandler(st, st, st, st, tokens, cfg.TwoFactor)).Methods("POST") // Второй шаг входа
+	twoFactorRouter := r.PathPrefix("/auth/2fa").Subrouter()
+	twoFactorRouter.Use(authMiddleware)
+	twoFactorRouter.HandleFunc("/setup", handlers.TwoFactorSetupHandler(st, st, cfg.TwoFactor)).Methods("POST")
+	twoFactorRouter.HandleFunc("/confirm", handlers.TwoFactorConfirmHandler(st, st)).Methods("POST")
+	twoFactorRouter.HandleFunc("/disable", handlers.TwoFactorDisableHandler(st, st)).Methods("POST")
+	twoFactorRouter.HandleFunc("/recovery-codes", handlers.TwoFactorRecoveryCodesHandler(st, st)).Methods("POST")
+
+	// 4. Группа защищенных маршрутов (требуется JWT)
+	protectedRouter := r.PathPrefix("/notes").Subrouter()
+	// Применяем middleware для проверки токена (AuthMiddleware)
+	protectedRouter.Use(authMiddleware)
+
+	// 5. Обработчики Заметок (CRUD)
+
+	protectedRouter.HandleFunc("", handlers.GetNotes(st)).Methods("GET")
+	protectedRouter.HandleFunc("", handlers.CreateNote(st)).Methods("POST")
+	protectedRouter.HandleFunc("/search", handlers.SearchNotes(st)).Methods("GET") // До /{id}, иначе "search" примется за ID
+	protectedRouter.HandleFunc("/trash", handlers.ListTrash(st, cfg.Trash)).Methods("GET")
+	protectedRouter.HandleFunc("/trash", handlers.EmptyTrash(st)).Methods("DELETE")
+	protectedRouter.HandleFunc("/trash/{id}", handlers.PurgeNote(st)).Methods("DELETE") // Окончательное удаление
+	protectedRouter.HandleFunc("/{id}", handlers.UpdateNote(st)).Methods("PUT")
+	protectedRouter.HandleFunc("/{id}", handlers.PatchNote(st)).Methods("PATCH") // JSON Merge Patch
+	protectedRouter.HandleFunc("/{id}", handlers.GetNote(st)).Methods("GET")
+	protectedRouter.HandleFunc("/{id}", handlers.DeleteNote(st)).Methods("DELETE") // В корзину
+	protectedRouter.HandleFunc("/{id}/restore", handlers.RestoreNote(st)).Methods("POST")
+
+	// Вложения заметки
+	protectedRouter.HandleFunc("/{id}/attachments", handlers.ListAttachments(st)).Methods("GET")
+	protectedRouter.HandleFunc("/{id}/attachments", handlers.UploadAttachment(st, st, blobs, cfg.Attachments)).Methods("POST")
+	protectedRouter.HandleFunc("/{id}/attachments/{attachmentId:[0-9]+}", handlers.DownloadAttachment(st, blobs)).Methods("GET")
+	protectedRouter.HandleFunc("/{id}/attachments/{attachmentId:[0-9]+}", handlers.DeleteAttachment(st, blobs)).Methods("DELETE")
+
+	// Публичные ссылки на заметку
+	protectedRouter.HandleFunc("/{id}/shares", handlers.ListShares(st)).Methods("GET")
+	protectedRouter.HandleFunc("/{id}/shares", handlers.CreateShare(st, st)).Methods("POST")
+	protectedRouter.HandleFunc("/{id}/shares/{shareId:[0-9]+}", handlers.RevokeShare(st)).Methods("DELETE") // Отзыв
+
+	// История изменений заметки
+	protectedRouter.HandleFunc("/{id}/revisions", handlers.ListNoteRevisions(st)).Methods("GET")
+	protectedRouter.HandleFunc("/{id}/revisions/diff", handlers.DiffNoteRevisions(st)).Methods("GET")
+	protectedRouter.HandleFunc("/{id}/revisions/{rev:[0-9]+}", handlers.GetNoteRevision(st)).Methods("GET")
+	protectedRouter.HandleFunc("/{id}/revisions/{rev:[0-9]+}/restore", handlers.RestoreNoteRevision(st)).Methods("POST")
+
+	// Теги пользователя
+	tagsRouter := r.PathPrefix("/tags").Subrouter()
+	tagsRouter.Use(authMiddleware)
+	tagsRouter.HandleFunc("", handlers.ListTagsHandler(st)).Methods("GET")
+	tagsRouter.HandleFunc("", handlers.CreateTagHandler(st)).Methods("POST")
+	tagsRouter.HandleFunc("/{id}", handlers.UpdateTagHandler(st)).Methods("PATCH")
+	tagsRouter.HandleFunc("/{id}", handlers.DeleteTagHandler(st)).Methods("DELETE")
+	tagsRouter.HandleFunc("/{id}/merge", handlers.MergeTagsHandler(st)).Methods("POST") // Объединение с другим тегом
+
+	// --- 6. Настройка CORS и Запуск сервера ---
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// Устанавливаем заголовки CORS для разрешения запросов с фронтенда (React)
+		w.Header().Set("Access-Control-Allow-Origin", cfg.Server.CORSOrigin)
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, If-None-Match, Range, X-Share-Password")
+		w.Header().Set("Access-Control-Expose-Headers", "Link, X-Next-Cursor, ETag, Content-Disposition, Content-Range, Accept-Ranges") // Пагинация GET /notes, версии заметок, файлы
+
+		// Ответ на предзапрос OPTIONS
+		if req.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		r.ServeHTTP(w, req) // Передаем запрос роутеру
+	})
+
+	// 7. Запуск сервера
+	fmt.Printf("Сервер запущен на %s\n", cfg.Server.Addr)
+	log.Fatal(http.ListenAndServe(cfg.Server.Addr, handler)) // Используем handler с CORS
+}
@@ -2,47 +2,91 @@ package middleware
 
 import (
 	"context"
+	"diary-backend/store"
 	"diary-backend/utils"
+	"errors"
+	"log"
 	"net/http"
 	"strings"
 )
 
 // contextKey - это тип для ключей контекста, чтобы избежать коллизий
 type contextKey string
-const UserIDKey contextKey = "userID"
-
-// AuthMiddleware проверяет JWT-токен и добавляет UserID в контекст запроса
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// 1. Получение заголовка Authorization
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Требуется авторизация (отсутствует заголовок)", http.StatusUnauthorized)
-			return 
-		}
 
-		// Ожидаемый формат: "Bearer <token>"
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			http.Error(w, "Неверный формат токена", http.StatusUnauthorized)
-			return 
-		}
+const (
+	UserIDKey contextKey = "userID"
+	ClaimsKey contextKey = "claims"
+)
 
-		tokenString := parts[1]
+// AuthMiddleware проверяет JWT-токен, сверяет его со списком отозванных
+// и добавляет UserID и claims в контекст запроса
+func AuthMiddleware(tokens *utils.TokenManager, revocations store.RevocationStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// 1. Получение заголовка Authorization
+			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" {
+				http.Error(w, "Требуется авторизация (отсутствует заголовок)", http.StatusUnauthorized)
+				return
+			}
 
-		// 2. Валидация токена
-		claims, err := utils.ValidateToken(tokenString)
-		if err != nil {
-			http.Error(w, "Недействительный или истекший токен", http.StatusUnauthorized)
-			return 
-		}
+			// Ожидаемый формат: "Bearer <token>"
+			parts := strings.Split(authHeader, " ")
+			if len(parts) != 2 || parts[0] != "Bearer" {
+				http.Error(w, "Неверный формат токена", http.StatusUnauthorized)
+				return
+			}
+
+			tokenString := parts[1]
+
+			// 2. Валидация токена
+			claims, err := tokens.ValidateToken(tokenString)
+			if err != nil {
+				http.Error(w, "Недействительный или истекший токен", http.StatusUnauthorized)
+				return
+			}
+
+			// 3. Проверка отзыва (выход из сессии или из всех сессий)
+			revoked, err := isRevoked(r.Context(), revocations, claims)
+			if err != nil {
+				log.Printf("Ошибка проверки отзыва токена: %v", err)
+				http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+				return
+			}
+			if revoked {
+				http.Error(w, "Токен отозван", http.StatusUnauthorized)
+				return
+			}
 
-		// 3. Добавление UserID в контекст запроса
-		ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
+			// 4. Добавление UserID и claims в контекст запроса
+			ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
+			ctx = context.WithValue(ctx, ClaimsKey, claims)
 
-		// 4. Передача управления следующему обработчику с обновленным контекстом
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+			// 5. Передача управления следующему обработчику с обновленным контекстом
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// isRevoked проверяет jti токена и границу "токены, выпущенные раньше, недействительны".
+// iat в JWT хранится в секундах, поэтому граница тоже хранится с точностью до секунды:
+// токен, выпущенный в ту же секунду (например, сразу после смены пароля), действителен.
+func isRevoked(ctx context.Context, revocations store.RevocationStore, claims *utils.Claims) (bool, error) {
+	if claims.Id != "" {
+		revoked, err := revocations.IsTokenRevoked(ctx, claims.Id)
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+
+	validAfter, err := revocations.TokensValidAfter(ctx, claims.UserID)
+	if errors.Is(err, store.ErrNotFound) {
+		return true, nil // Пользователь удален
+	}
+	if err != nil {
+		return false, err
+	}
+	return !validAfter.IsZero() && claims.IssuedAt < validAfter.Unix(), nil
 }
 
 // GetUserIDFromContext - вспомогательная функция для извлечения UserID
@@ -50,4 +94,10 @@ func GetUserIDFromContext(ctx context.Context) (int, bool) {
 	// Извлекаем значение и проверяем его тип
 	userID, ok := ctx.Value(UserIDKey).(int)
 	return userID, ok
-}
\ No newline at end of file
+}
+
+// GetClaimsFromContext возвращает claims токена, которым авторизован запрос
+func GetClaimsFromContext(ctx context.Context) (*utils.Claims, bool) {
+	claims, ok := ctx.Value(ClaimsKey).(*utils.Claims)
+	return claims, ok
+}
@@ -0,0 +1,203 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"diary-backend/store/sqlstore"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sqlstore.OpenSQLite(filepath.Join(t.TempDir(), "diary.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// tables возвращает имена таблиц базы по алфавиту.
+func tables(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`)
+	if err != nil {
+		t.Fatalf("список таблиц: %v", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		rows.Scan(&name)
+		names = append(names, name)
+	}
+	return names
+}
+
+// hasColumn сообщает, есть ли в таблице колонка.
+func hasColumn(t *testing.T, db *sql.DB, table, column string) bool {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info($1) WHERE name = $2`, table, column).Scan(&n); err != nil {
+		t.Fatalf("колонки %s: %v", table, err)
+	}
+	return n > 0
+}
+
+// recorded возвращает версии из schema_migrations по возрастанию.
+func recorded(t *testing.T, db *sql.DB) []int {
+	t.Helper()
+	rows, err := db.Query(`SELECT version FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		t.Fatalf("schema_migrations: %v", err)
+	}
+	defer rows.Close()
+
+	var versions []int
+	for rows.Next() {
+		var v int
+		rows.Scan(&v)
+		versions = append(versions, v)
+	}
+	return versions
+}
+
+func TestLoad(t *testing.T) {
+	pg, err := load("postgres")
+	if err != nil {
+		t.Fatalf("load(postgres): %v", err)
+	}
+	lite, err := load("sqlite")
+	if err != nil {
+		t.Fatalf("load(sqlite): %v", err)
+	}
+	if len(pg) != len(lite) {
+		t.Fatalf("миграций postgres %d, sqlite %d", len(pg), len(lite))
+	}
+	// Версии идут подряд с 1, у диалектов одинаковые имена, у каждой миграции есть откат
+	for i := range lite {
+		if lite[i].Version != i+1 || pg[i].Version != i+1 || pg[i].Name != lite[i].Name {
+			t.Errorf("миграция %d: postgres %04d_%s, sqlite %04d_%s", i, pg[i].Version, pg[i].Name, lite[i].Version, lite[i].Name)
+		}
+		if lite[i].Down == "" || pg[i].Down == "" {
+			t.Errorf("миграция %04d_%s без отката", lite[i].Version, lite[i].Name)
+		}
+	}
+
+	if _, err := load("mysql"); err == nil {
+		t.Error("ожидалась ошибка для неизвестного диалекта")
+	}
+}
+
+func TestUpStatusDown(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	m, err := New(db, "sqlite")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	total := len(m.migrations)
+	last := m.migrations[total-1]
+
+	statuses, err := m.Status(ctx)
+	if err != nil || len(statuses) != total {
+		t.Fatalf("Status = %d, %v", len(statuses), err)
+	}
+	for _, st := range statuses {
+		if st.AppliedAt != nil {
+			t.Fatalf("миграция %04d применена в пустой базе", st.Version)
+		}
+	}
+
+	done, err := m.Up(ctx)
+	if err != nil || len(done) != total {
+		t.Fatalf("Up = %d, %v", len(done), err)
+	}
+	if got := recorded(t, db); len(got) != total || got[0] != 1 || got[total-1] != last.Version {
+		t.Fatalf("schema_migrations: %v", got)
+	}
+	if !slices.Contains(tables(t, db), "notes") || !hasColumn(t, db, "notes", "cipher_version") {
+		t.Fatalf("схема после Up: %v", tables(t, db))
+	}
+	statuses, _ = m.Status(ctx)
+	for _, st := range statuses {
+		if st.AppliedAt == nil {
+			t.Errorf("миграция %04d не отмечена примененной", st.Version)
+		}
+	}
+
+	// Повторный Up ничего не делает
+	if done, err := m.Up(ctx); err != nil || len(done) != 0 {
+		t.Fatalf("повторный Up = %d, %v", len(done), err)
+	}
+
+	// Откат последней миграции и повторное применение
+	done, err = m.Down(ctx, 1)
+	if err != nil || len(done) != 1 || done[0].Version != last.Version {
+		t.Fatalf("Down(1) = %+v, %v", done, err)
+	}
+	if hasColumn(t, db, "notes", "cipher_version") {
+		t.Error("колонка последней миграции осталась после отката")
+	}
+	statuses, _ = m.Status(ctx)
+	if statuses[total-1].AppliedAt != nil || statuses[total-2].AppliedAt == nil {
+		t.Errorf("Status после Down(1): %v, %v", statuses[total-2].AppliedAt, statuses[total-1].AppliedAt)
+	}
+	if done, err := m.Up(ctx); err != nil || len(done) != 1 || done[0].Version != last.Version {
+		t.Fatalf("Up после Down(1) = %+v, %v", done, err)
+	}
+
+	// Полный откат оставляет только таблицу учета
+	if done, err := m.Down(ctx, total+1); err != nil || len(done) != total {
+		t.Fatalf("полный Down = %d, %v", len(done), err)
+	}
+	if got := tables(t, db); !slices.Equal(got, []string{"schema_migrations"}) {
+		t.Errorf("таблицы после полного отката: %v", got)
+	}
+	if got := recorded(t, db); len(got) != 0 {
+		t.Errorf("schema_migrations после полного отката: %v", got)
+	}
+}
+
+func TestUpRollsBackFailedMigration(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	m := &Migrator{db: db, migrations: []Migration{
+		{Version: 1, Name: "first", Up: `CREATE TABLE first (id INTEGER);`, Down: `DROP TABLE first;`},
+		{Version: 2, Name: "broken", Up: `CREATE TABLE second (id INTEGER); INSERT INTO missing VALUES (1);`},
+		{Version: 3, Name: "third", Up: `CREATE TABLE third (id INTEGER);`},
+	}}
+
+	// Ошибка останавливает Up; изменения неудачной миграции откатываются вместе с записью о ней
+	done, err := m.Up(ctx)
+	if err == nil || !strings.Contains(err.Error(), "0002_broken") {
+		t.Fatalf("Up = %v; ожидалась ошибка миграции 0002_broken", err)
+	}
+	if len(done) != 1 || done[0].Version != 1 {
+		t.Errorf("применены: %+v", done)
+	}
+	if got := tables(t, db); !slices.Equal(got, []string{"first", "schema_migrations"}) {
+		t.Errorf("таблицы после ошибки: %v", got)
+	}
+	if got := recorded(t, db); !slices.Equal(got, []int{1}) {
+		t.Errorf("schema_migrations после ошибки: %v", got)
+	}
+
+	// Миграцию без .down.sql откатить нельзя: откат останавливается на ней
+	m.migrations[1].Up = `CREATE TABLE second (id INTEGER);`
+	if done, err := m.Up(ctx); err != nil || len(done) != 2 {
+		t.Fatalf("Up после исправления = %d, %v", len(done), err)
+	}
+	done, err = m.Down(ctx, 3)
+	if err == nil || !strings.Contains(err.Error(), "не поддерживает откат") || len(done) != 0 {
+		t.Fatalf("Down = %+v, %v; ожидалась ошибка отката 0003_third", done, err)
+	}
+	if got := recorded(t, db); !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("schema_migrations после неудачного отката: %v", got)
+	}
+}
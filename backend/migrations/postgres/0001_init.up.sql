@@ -0,0 +1,25 @@
+-- Базовая схема: пользователи и заметки.
+-- IF NOT EXISTS позволяет применить миграцию к базе, созданной ранее вручную.
+
+CREATE TABLE IF NOT EXISTS users (
+    id                SERIAL PRIMARY KEY,
+    username          VARCHAR(255) NOT NULL,
+    email             VARCHAR(255) NOT NULL UNIQUE,
+    password_hash     TEXT NOT NULL,
+    is_verified       BOOLEAN NOT NULL DEFAULT FALSE,
+    verification_code TEXT,
+    code_expiry_time  TIMESTAMPTZ,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS notes (
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    title      TEXT NOT NULL DEFAULT '',
+    content    TEXT NOT NULL DEFAULT '',
+    tag        VARCHAR(50) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS notes_user_created_idx ON notes (user_id, created_at DESC);
@@ -0,0 +1,11 @@
+-- Отзыв токенов доступа: список отозванных jti и граница "токены, выпущенные раньше, недействительны".
+
+ALTER TABLE users ADD COLUMN tokens_valid_after TIMESTAMPTZ;
+
+CREATE TABLE revoked_tokens (
+    jti        VARCHAR(64) PRIMARY KEY,
+    user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX revoked_tokens_expires_idx ON revoked_tokens (expires_at);
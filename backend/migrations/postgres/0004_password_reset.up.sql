@@ -0,0 +1,4 @@
+-- Одноразовый код сброса пароля.
+
+ALTER TABLE users ADD COLUMN reset_code TEXT;
+ALTER TABLE users ADD COLUMN reset_code_expiry TIMESTAMPTZ;
@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN reset_code_attempts;
+ALTER TABLE users DROP COLUMN verification_attempts;
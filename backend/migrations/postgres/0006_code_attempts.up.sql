@@ -0,0 +1,8 @@
+-- Счетчики неудачных попыток ввода кодов верификации и сброса пароля.
+-- Коды теперь хранятся как SHA-256, поэтому ранее выданные открытые коды сбрасываются:
+-- пользователю нужно запросить новый.
+
+ALTER TABLE users ADD COLUMN verification_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN reset_code_attempts INTEGER NOT NULL DEFAULT 0;
+
+UPDATE users SET verification_code = NULL, code_expiry_time = NULL, reset_code = NULL, reset_code_expiry = NULL;
@@ -0,0 +1,20 @@
+-- Очередь исходящих писем: письмо пишется в той же транзакции, что и изменение
+-- пользователя, и доставляется фоновым обработчиком с повторами.
+
+CREATE TABLE email_outbox (
+    id              SERIAL PRIMARY KEY,
+    user_id         INTEGER REFERENCES users (id) ON DELETE CASCADE,
+    kind            TEXT NOT NULL,
+    recipient       TEXT NOT NULL,
+    subject         TEXT NOT NULL,
+    html_body       TEXT NOT NULL,
+    status          TEXT NOT NULL DEFAULT 'pending',
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    last_error      TEXT NOT NULL DEFAULT '',
+    created_at      TIMESTAMPTZ NOT NULL,
+    sent_at         TIMESTAMPTZ
+);
+
+CREATE INDEX email_outbox_due_idx ON email_outbox (status, next_attempt_at);
+CREATE INDEX email_outbox_user_idx ON email_outbox (user_id, kind, id);
@@ -0,0 +1,4 @@
+-- Язык писем пользователя и текстовая версия писем в очереди.
+
+ALTER TABLE users ADD COLUMN locale TEXT NOT NULL DEFAULT 'ru';
+ALTER TABLE email_outbox ADD COLUMN text_body TEXT NOT NULL DEFAULT '';
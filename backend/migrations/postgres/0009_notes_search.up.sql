@@ -0,0 +1,10 @@
+-- Полнотекстовый поиск по заметкам: заголовок (вес A) и текст (вес B).
+-- Конфигурация 'simple' не зависит от языка: заметки пишут и по-русски, и по-английски.
+
+ALTER TABLE notes ADD COLUMN search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', title), 'A') ||
+        setweight(to_tsvector('simple', content), 'B')
+    ) STORED;
+
+CREATE INDEX notes_search_idx ON notes USING GIN (search_vector);
@@ -0,0 +1,16 @@
+-- Возвращает единственный тег: у заметки остается первый по алфавиту.
+
+ALTER TABLE notes ADD COLUMN tag TEXT NOT NULL DEFAULT '';
+
+UPDATE notes SET tag = COALESCE((
+    SELECT t.name
+    FROM note_tags nt JOIN tags t ON t.id = nt.tag_id
+    WHERE nt.note_id = notes.id
+    ORDER BY t.name
+    LIMIT 1
+), '');
+
+CREATE INDEX notes_user_tag_created_idx ON notes (user_id, tag, created_at, id);
+
+DROP TABLE note_tags;
+DROP TABLE tags;
@@ -0,0 +1,7 @@
+-- Прежняя схема не знает о корзине: заметки из нее удаляются окончательно,
+-- иначе они снова появятся в списке.
+
+DELETE FROM notes WHERE deleted_at IS NOT NULL;
+
+DROP INDEX notes_deleted_idx;
+ALTER TABLE notes DROP COLUMN deleted_at;
@@ -0,0 +1,4 @@
+-- Версия заметки для оптимистичной блокировки: увеличивается при каждом изменении
+-- заметки (в том числе переименовании ее тегов) и отдается клиенту как ETag.
+
+ALTER TABLE notes ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
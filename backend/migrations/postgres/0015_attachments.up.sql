@@ -0,0 +1,18 @@
+-- Вложения заметок. Содержимое файла хранится вне БД (blobstore) под ключом blob_key.
+-- При удалении вложения или окончательном удалении заметки note_id становится NULL:
+-- содержимое таких вложений удаляется, а затем и сами записи.
+
+CREATE TABLE attachments (
+    id           SERIAL PRIMARY KEY,
+    note_id      INTEGER REFERENCES notes (id) ON DELETE SET NULL,
+    user_id      INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    blob_key     TEXT NOT NULL UNIQUE,
+    filename     TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size         BIGINT NOT NULL,
+    sha256       TEXT NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX attachments_note_idx ON attachments (note_id);
+CREATE INDEX attachments_user_idx ON attachments (user_id);
@@ -0,0 +1,11 @@
+DROP TABLE user_keys;
+
+ALTER TABLE note_revisions DROP COLUMN wrapped_key;
+ALTER TABLE note_revisions DROP COLUMN nonce;
+ALTER TABLE note_revisions DROP COLUMN ciphertext;
+ALTER TABLE note_revisions DROP COLUMN enc_alg;
+
+ALTER TABLE notes DROP COLUMN wrapped_key;
+ALTER TABLE notes DROP COLUMN nonce;
+ALTER TABLE notes DROP COLUMN ciphertext;
+ALTER TABLE notes DROP COLUMN enc_alg;
@@ -0,0 +1,2 @@
+-- Выданные ссылки перестанут открываться.
+DROP TABLE note_shares;
@@ -0,0 +1,20 @@
+-- Публичные ссылки на заметки (/s/<токен>). Токен выдается владельцу один раз,
+-- в БД хранится только его SHA-256; пароль ссылки - bcrypt, пустой - без пароля.
+-- max_views = 0 - без ограничения просмотров. При окончательном удалении заметки
+-- ссылки удаляются вместе с ней.
+
+CREATE TABLE note_shares (
+    id                SERIAL PRIMARY KEY,
+    note_id           INTEGER NOT NULL REFERENCES notes (id) ON DELETE CASCADE,
+    user_id           INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash        TEXT NOT NULL UNIQUE,
+    password_hash     TEXT NOT NULL DEFAULT '',
+    expires_at        TIMESTAMPTZ,
+    max_views         INTEGER NOT NULL DEFAULT 0,
+    views             INTEGER NOT NULL DEFAULT 0,
+    password_failures INTEGER NOT NULL DEFAULT 0,
+    created_at        TIMESTAMPTZ NOT NULL,
+    revoked_at        TIMESTAMPTZ
+);
+
+CREATE INDEX note_shares_note_idx ON note_shares (note_id);
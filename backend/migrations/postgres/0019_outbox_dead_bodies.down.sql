@@ -0,0 +1,2 @@
+-- Стертые тела писем не восстановить; откат ничего не меняет.
+SELECT 1;
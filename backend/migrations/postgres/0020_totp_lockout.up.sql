@@ -0,0 +1,4 @@
+-- Блокировка второго фактора: после two_factor.max_attempts неверных кодов подряд ввод
+-- кода запрещен до totp_locked_until. Счетчик totp_failures больше не сбрасывается
+-- новым входом по паролю - только верным кодом.
+ALTER TABLE users ADD COLUMN totp_locked_until TIMESTAMPTZ;
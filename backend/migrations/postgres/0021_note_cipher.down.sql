@@ -0,0 +1,9 @@
+-- Тексты версии 2 прежний код не отличит от открытых и не расшифрует: откатывать миграцию
+-- можно, только пока в БД нет строк с cipher_version = 2.
+
+DROP INDEX note_revisions_data_key_idx;
+DROP INDEX notes_data_key_idx;
+ALTER TABLE note_revisions DROP COLUMN cipher_version;
+ALTER TABLE note_revisions DROP COLUMN data_key_id;
+ALTER TABLE notes DROP COLUMN cipher_version;
+ALTER TABLE notes DROP COLUMN data_key_id;
@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN reset_code_expiry;
+ALTER TABLE users DROP COLUMN reset_code;
@@ -0,0 +1,3 @@
+-- Полнотекстовый индекс есть только в PostgreSQL; в SQLite поиск выполняется
+-- в памяти (пакет search). Миграция оставлена, чтобы номера версий совпадали.
+SELECT 1;
@@ -0,0 +1,2 @@
+DROP INDEX notes_user_tag_created_idx;
+DROP INDEX notes_user_updated_idx;
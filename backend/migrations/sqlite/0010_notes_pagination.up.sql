@@ -0,0 +1,5 @@
+-- Индексы для постраничной выборки GET /notes: сортировка и фильтр по updated_at,
+-- фильтр по тегу. id - второй ключ курсора пагинации.
+
+CREATE INDEX notes_user_updated_idx ON notes (user_id, updated_at, id);
+CREATE INDEX notes_user_tag_created_idx ON notes (user_id, tag, created_at, id);
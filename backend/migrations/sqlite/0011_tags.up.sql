@@ -0,0 +1,41 @@
+-- Несколько тегов у заметки: теги пользователя (имя, цвет) и связь заметок с тегами.
+-- Единственный тег notes.tag переносится в новые таблицы, колонка удаляется.
+
+CREATE TABLE tags (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name       TEXT NOT NULL,
+    color      TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE note_tags (
+    note_id INTEGER NOT NULL REFERENCES notes (id) ON DELETE CASCADE,
+    tag_id  INTEGER NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    PRIMARY KEY (note_id, tag_id)
+);
+
+CREATE INDEX note_tags_tag_idx ON note_tags (tag_id);
+
+INSERT INTO tags (user_id, name, created_at)
+SELECT user_id, trim(tag), MIN(created_at)
+FROM notes
+WHERE trim(tag) <> ''
+GROUP BY user_id, trim(tag);
+
+-- Цвета четырех тегов, которые раньше были встроены во фронтенд
+UPDATE tags SET color = CASE name
+    WHEN 'work' THEN '#4f46e5'
+    WHEN 'personal' THEN '#7c3aed'
+    WHEN 'ideas' THEN '#10b981'
+    WHEN 'reminders' THEN '#f59e0b'
+    ELSE ''
+END;
+
+INSERT INTO note_tags (note_id, tag_id)
+SELECT n.id, t.id
+FROM notes n JOIN tags t ON t.user_id = n.user_id AND t.name = trim(n.tag);
+
+DROP INDEX notes_user_tag_created_idx;
+ALTER TABLE notes DROP COLUMN tag;
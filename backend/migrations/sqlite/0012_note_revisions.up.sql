@@ -0,0 +1,27 @@
+-- История изменений заметок: каждое сохранение добавляет ревизию с заголовком,
+-- текстом и тегами (JSON-массив имен). Текущее состояние существующих заметок
+-- становится их первой ревизией.
+
+CREATE TABLE note_revisions (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    note_id    INTEGER NOT NULL REFERENCES notes (id) ON DELETE CASCADE,
+    revision   INTEGER NOT NULL,
+    title      TEXT NOT NULL,
+    content    TEXT NOT NULL,
+    tags       TEXT NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE (note_id, revision)
+);
+
+INSERT INTO note_revisions (note_id, revision, title, content, tags, created_at)
+SELECT n.id, 1, n.title, n.content,
+       COALESCE((
+           SELECT json_group_array(name) FROM (
+               SELECT t.name
+               FROM note_tags nt JOIN tags t ON t.id = nt.tag_id
+               WHERE nt.note_id = n.id
+               ORDER BY t.name
+           )
+       ), '[]'),
+       n.updated_at
+FROM notes n;
@@ -0,0 +1,7 @@
+-- Корзина: DELETE /notes/{id} только проставляет deleted_at. Такие заметки не видны
+-- в списках, поиске и истории, пока их не восстановят; через trash.retention
+-- фоновая очистка удаляет их окончательно.
+
+ALTER TABLE notes ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX notes_deleted_idx ON notes (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,2 @@
+-- Содержимое файлов в blobstore не удаляется.
+DROP TABLE attachments;
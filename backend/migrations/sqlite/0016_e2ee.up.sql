@@ -0,0 +1,30 @@
+-- Сквозное шифрование заметок (по желанию пользователя). У зашифрованной заметки
+-- title и content пусты, а заголовок и текст хранятся шифртекстом, который сервер
+-- не может расшифровать: enc_alg - алгоритм, ciphertext и nonce - AES-GCM,
+-- wrapped_key - ключ заметки, зашифрованный мастер-ключом пользователя (все в base64).
+-- У открытых заметок эти колонки NULL.
+
+ALTER TABLE notes ADD COLUMN enc_alg TEXT;
+ALTER TABLE notes ADD COLUMN ciphertext TEXT;
+ALTER TABLE notes ADD COLUMN nonce TEXT;
+ALTER TABLE notes ADD COLUMN wrapped_key TEXT;
+
+ALTER TABLE note_revisions ADD COLUMN enc_alg TEXT;
+ALTER TABLE note_revisions ADD COLUMN ciphertext TEXT;
+ALTER TABLE note_revisions ADD COLUMN nonce TEXT;
+ALTER TABLE note_revisions ADD COLUMN wrapped_key TEXT;
+
+-- Мастер-ключ пользователя, зашифрованный ключом из его парольной фразы. Парольная
+-- фраза на сервер не передается: здесь только параметры KDF, соль и результат.
+CREATE TABLE user_keys (
+    user_id            INTEGER PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    kdf                TEXT NOT NULL,
+    iterations         INTEGER NOT NULL,
+    memory             INTEGER NOT NULL DEFAULT 0,
+    parallelism        INTEGER NOT NULL DEFAULT 0,
+    salt               TEXT NOT NULL,
+    wrapped_master_key TEXT NOT NULL,
+    version            INTEGER NOT NULL DEFAULT 1,
+    created_at         TIMESTAMP NOT NULL,
+    updated_at         TIMESTAMP NOT NULL
+);
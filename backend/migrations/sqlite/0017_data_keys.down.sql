@@ -0,0 +1,2 @@
+-- Заметки, зашифрованные ключами из этой таблицы, после отката прочитать будет нельзя.
+DROP TABLE data_keys;
@@ -0,0 +1,18 @@
+-- Ключи данных для шифрования заголовков и текстов заметок в БД (encryption.master_keys).
+-- Ключ зашифрован мастер-ключом сервера master_key_id и хранится в base64. Зашифрованные
+-- заголовки и тексты лежат в тех же колонках notes и note_revisions в виде
+-- "enc:v1:<id ключа>:<base64>". Ключ с retired_at выведен из оборота и удаляется,
+-- когда им больше ничего не зашифровано.
+
+CREATE TABLE data_keys (
+    id            INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id       INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    master_key_id TEXT NOT NULL,
+    wrapped_key   TEXT NOT NULL,
+    created_at    TIMESTAMP NOT NULL,
+    retired_at    TIMESTAMP
+);
+
+-- Не больше одного действующего ключа на пользователя
+CREATE UNIQUE INDEX data_keys_active_idx ON data_keys (user_id) WHERE retired_at IS NULL;
+CREATE INDEX data_keys_master_key_idx ON data_keys (master_key_id);
@@ -0,0 +1,3 @@
+-- Письма, недоставленные насовсем, хранили тело с открытым кодом из письма.
+-- Теперь оно стирается при переводе в dead, как у отправленных; здесь - у уже накопленных.
+UPDATE email_outbox SET text_body = '', html_body = '' WHERE status = 'dead';
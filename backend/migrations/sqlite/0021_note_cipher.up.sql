@@ -0,0 +1,26 @@
+-- Шифрование в БД: чем зашифрованы заголовок и текст заметки или ревизии, хранится явно,
+-- а не определяется по префиксу "enc:v1:" в тексте. data_key_id - ключ данных (NULL -
+-- открытый текст), cipher_version - формат: 1 - "enc:v1:<id ключа>:<base64>" без
+-- дополнительных данных, 2 - base64, шифртекст привязан к пользователю, заметке и полю.
+-- Уже зашифрованные тексты помечаются версией 1; фоновое перешифрование переводит их во 2.
+
+ALTER TABLE notes ADD COLUMN data_key_id INTEGER;
+ALTER TABLE notes ADD COLUMN cipher_version SMALLINT NOT NULL DEFAULT 0;
+ALTER TABLE note_revisions ADD COLUMN data_key_id INTEGER;
+ALTER TABLE note_revisions ADD COLUMN cipher_version SMALLINT NOT NULL DEFAULT 0;
+
+UPDATE notes
+SET data_key_id = CAST(substr(CASE WHEN title LIKE 'enc:v1:%' THEN title ELSE content END, 8,
+        instr(substr(CASE WHEN title LIKE 'enc:v1:%' THEN title ELSE content END, 8), ':') - 1) AS INTEGER),
+    cipher_version = 1
+WHERE title LIKE 'enc:v1:%' OR content LIKE 'enc:v1:%';
+
+UPDATE note_revisions
+SET data_key_id = CAST(substr(CASE WHEN title LIKE 'enc:v1:%' THEN title ELSE content END, 8,
+        instr(substr(CASE WHEN title LIKE 'enc:v1:%' THEN title ELSE content END, 8), ':') - 1) AS INTEGER),
+    cipher_version = 1
+WHERE title LIKE 'enc:v1:%' OR content LIKE 'enc:v1:%';
+
+-- Поиск ключей, которыми больше ничего не зашифровано
+CREATE INDEX notes_data_key_idx ON notes (data_key_id) WHERE data_key_id IS NOT NULL;
+CREATE INDEX note_revisions_data_key_idx ON note_revisions (data_key_id) WHERE data_key_id IS NOT NULL;
@@ -1,46 +1,437 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"diary-backend/diff"
+)
 
 // User представляет данные пользователя в базе данных
 type User struct {
-	ID           int    `json:"id"`
-	Username     string `json:"username"`
-	Email        string `json:"email"`
-	PasswordHash string `json:"-"`
-    IsVerified        bool      `json:"is_verified"`        // Статус верификации аккаунта
-    VerificationCode  string    `json:"-"`                  // Секретный код для подтверждения
-    CodeExpiryTime    time.Time `json:"-"`                  // Время истечения кода
+	ID                   int       `json:"id"`
+	Username             string    `json:"username"`
+	Email                string    `json:"email"`
+	PasswordHash         string    `json:"-"`
+	IsVerified           bool      `json:"is_verified"`  // Статус верификации аккаунта
+	Locale               string    `json:"locale"`       // Язык писем ("ru", "en")
+	VerificationCodeHash string    `json:"-"`            // SHA-256 кода подтверждения email
+	CodeExpiryTime       time.Time `json:"-"`            // Время истечения кода
+	VerificationAttempts int       `json:"-"`            // Неудачные попытки ввода кода подтверждения
+	ResetCodeHash        string    `json:"-"`            // SHA-256 кода сброса пароля
+	ResetCodeExpiry      time.Time `json:"-"`            // Время истечения кода сброса
+	ResetCodeAttempts    int       `json:"-"`            // Неудачные попытки ввода кода сброса
+	TOTPEnabled          bool      `json:"totp_enabled"` // Включена ли двухфакторная аутентификация
+	TOTPSecret           string    `json:"-"`            // Секрет TOTP (до подтверждения - ожидающий)
+	TOTPLastCounter      int64     `json:"-"`            // Последний использованный шаг TOTP (защита от повтора кода)
+	TOTPFailures         int       `json:"-"`            // Неудачные попытки ввода кода подряд (сбрасываются только верным кодом)
+	TOTPLockedUntil      time.Time `json:"-"`            // До этого момента ввод второго фактора запрещен
+	CreatedAt            time.Time `json:"created_at"`
+	TokensValidAfter     time.Time `json:"-"` // Токены доступа, выпущенные раньше, недействительны
+}
+
+// Виды писем в очереди email_outbox.
+const (
+	EmailKindVerification  = "verification"
+	EmailKindPasswordReset = "password_reset"
+)
+
+// Статусы доставки письма из очереди.
+const (
+	EmailPending   = "pending"   // Ожидает отправки или повтора
+	EmailSent      = "sent"      // Доставлено почтовому серверу
+	EmailDead      = "dead"      // Исчерпаны попытки отправки
+	EmailCancelled = "cancelled" // Заменено более новым письмом того же вида
+)
+
+// OutboxEmail - письмо в очереди на отправку. Ставится в очередь в одной транзакции
+// с изменением, которое его вызвало, и доставляется фоновым обработчиком.
+// После отправки тело письма (с одноразовым кодом) стирается.
+type OutboxEmail struct {
+	ID            int        `json:"id"`
+	UserID        int        `json:"-"`
+	Kind          string     `json:"kind"`
+	Recipient     string     `json:"-"`
+	Subject       string     `json:"-"`
+	TextBody      string     `json:"-"`
+	HTMLBody      string     `json:"-"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	LastError     string     `json:"-"`
+	CreatedAt     time.Time  `json:"created_at"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
 }
 
 // Note представляет заметку
 type Note struct {
-	ID		int		`json:"id"`
-	UserID	int		`json:"user_id"`
-	Title	string	`json:"title"`
-	Content	string	`json:"content"`
-	
-	Tag		string	`json:"tag"`
-	CreatedAt	time.Time `json:"created_at"`
-	UpdatedAt	time.Time `json:"updated_at"`
+	ID      int    `json:"id"`
+	UserID  int    `json:"user_id"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	// Только у зашифрованных заметок: заголовок и текст, зашифрованные на клиенте.
+	// Title и Content у них пусты.
+	Encrypted *Envelope `json:"encrypted,omitempty"`
+	// Cipher - чем Title и Content зашифрованы в БД (шифрование в БД). Хранилище отдает
+	// и сохраняет его как есть; наружу заметка выходит расшифрованной, с нулевым Cipher.
+	Cipher TextCipher `json:"-"`
+
+	Tags      []string  `json:"tags"`    // Имена тегов пользователя, по алфавиту
+	Tag       string    `json:"tag"`     // Устарело: первый из Tags, для клиентов с одним тегом
+	Version   int       `json:"version"` // Растет при каждом изменении; передается и в заголовке ETag
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Только у заметок в корзине: время удаления и время, после которого заметка
+	// будет удалена окончательно (заполняет обработчик по trash.retention).
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	PurgeAt   *time.Time `json:"purge_at,omitempty"`
+}
+
+// SetTags заменяет теги заметки и заполняет устаревшее поле Tag.
+func (n *Note) SetTags(tags []string) {
+	if tags == nil {
+		tags = []string{}
+	}
+	n.Tags = tags
+	n.Tag = ""
+	if len(tags) > 0 {
+		n.Tag = tags[0]
+	}
+}
+
+// EnvelopeAES256GCM - алгоритм шифрования заметок: текст - AES-256-GCM, ключ заметки -
+// AES-KW (RFC 3394) мастер-ключом пользователя.
+const EnvelopeAES256GCM = "AES-256-GCM"
+
+// Envelope - заголовок и текст заметки, зашифрованные на клиенте (сквозное шифрование).
+// Клиент шифрует JSON {"title", "content"} случайным ключом заметки, а сам ключ -
+// мастер-ключом пользователя (см. UserKeys). Все поля, кроме Algorithm, - base64;
+// сервер проверяет только их формат и расшифровать их не может.
+type Envelope struct {
+	Algorithm  string `json:"alg" yaml:"alg"`
+	Ciphertext string `json:"ciphertext" yaml:"ciphertext"`   // Шифртекст вместе с тегом GCM
+	Nonce      string `json:"nonce" yaml:"nonce"`             // 12 байт, свой у каждого шифрования
+	WrappedKey string `json:"wrapped_key" yaml:"wrapped_key"` // Ключ заметки (32 байта) после AES-KW
+}
+
+// Equal сообщает, совпадают ли конверты; nil равен только nil.
+func (e *Envelope) Equal(other *Envelope) bool {
+	if e == nil || other == nil {
+		return e == other
+	}
+	return *e == *other
+}
+
+// Функции вывода ключа из парольной фразы (UserKeys.KDF).
+const (
+	KDFPBKDF2   = "PBKDF2-SHA256"
+	KDFArgon2id = "argon2id"
+)
+
+// UserKeys - ключи сквозного шифрования пользователя. Мастер-ключ создается на клиенте
+// и хранится только зашифрованным (AES-KW) ключом, выведенным из парольной фразы
+// функцией KDF с солью Salt. Парольную фразу и мастер-ключ сервер не видит.
+// Смена парольной фразы перешифровывает тот же мастер-ключ, заметки не меняются.
+type UserKeys struct {
+	UserID           int       `json:"-"`
+	KDF              string    `json:"kdf"`
+	Iterations       int       `json:"iterations"`
+	Memory           int       `json:"memory,omitempty"`      // КиБ, только для argon2id
+	Parallelism      int       `json:"parallelism,omitempty"` // Только для argon2id
+	Salt             string    `json:"salt"`                  // base64
+	WrappedMasterKey string    `json:"wrapped_master_key"`    // base64, 40 байт
+	Version          int       `json:"version"`               // Растет при каждой замене; передается и в ETag
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// TextCipher - чем зашифрованы заголовок и текст заметки или ревизии в БД (шифрование в БД,
+// пакет encryption). Хранится в колонках data_key_id и cipher_version рядом с текстами:
+// зашифрован ли текст, определяется по ним, а не по содержимому. Нулевое значение -
+// открытый текст.
+type TextCipher struct {
+	KeyID   int // ID ключа данных
+	Version int // CipherV1 или CipherV2
+}
+
+// Форматы зашифрованных заголовков и текстов (TextCipher.Version).
+const (
+	// CipherV1 - "enc:v1:<ID ключа данных>:<base64 nonce и шифртекста>" без дополнительных
+	// данных. Так шифровались тексты до миграции 0021; фоновое перешифрование переводит их в CipherV2.
+	CipherV1 = 1
+	// CipherV2 - base64 nonce и шифртекста; дополнительные данные - ID пользователя и заметки
+	// и имя поля, так что шифртекст нельзя перенести в другую заметку или другое поле.
+	CipherV2 = 2
+)
+
+// EncryptedTextPrefix - начало текстов в формате CipherV1.
+const EncryptedTextPrefix = "enc:v1:"
+
+// DataKey - ключ данных пользователя для шифрования заголовков и текстов заметок в БД.
+// Сам ключ хранится только зашифрованным мастер-ключом сервера MasterKeyID. У пользователя
+// один действующий ключ; выведенный из оборота (RetiredAt) нужен, пока им зашифрованы
+// заметки или ревизии.
+type DataKey struct {
+	ID          int
+	UserID      int
+	MasterKeyID string
+	WrappedKey  string // base64: nonce и ключ, зашифрованный AES-256-GCM
+	CreatedAt   time.Time
+	RetiredAt   *time.Time
+}
+
+// NoteText - заголовок и текст заметки (Revision == 0) или ее ревизии в том виде, в каком
+// они лежат в БД. Нужен фоновому перешифрованию.
+type NoteText struct {
+	UserID   int
+	NoteID   int
+	Revision int
+	Title    string
+	Content  string
+	Cipher   TextCipher
+}
+
+// Tag - тег пользователя. Заметки ссылаются на тег по ID, поэтому переименование
+// тега сразу отражается во всех заметках.
+type Tag struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"-"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`      // "#rrggbb" или "" (цвет по умолчанию)
+	NoteCount int       `json:"note_count"` // Число заметок с этим тегом
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TagRequest - тело POST /tags и PATCH /tags/{id}. В PATCH отсутствующее поле не меняется.
+type TagRequest struct {
+	Name  *string `json:"name"`
+	Color *string `json:"color"`
+}
+
+// MergeTagsRequest - тело POST /tags/{id}/merge: тег {id} вливается в тег Into.
+type MergeTagsRequest struct {
+	Into int `json:"into"`
+}
+
+// Attachment - файл, прикрепленный к заметке. Содержимое хранится в blobstore под
+// ключом BlobKey; ContentType определяется по содержимому файла при загрузке.
+// NoteID == 0 - вложение или его заметка удалены, содержимое ждет удаления.
+type Attachment struct {
+	ID          int       `json:"id"`
+	NoteID      int       `json:"note_id"`
+	UserID      int       `json:"-"`
+	BlobKey     string    `json:"-"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Состояния публичной ссылки на заметку (NoteShare.Status).
+const (
+	ShareActive    = "active"
+	ShareExpired   = "expired"   // Истек срок действия
+	ShareExhausted = "exhausted" // Исчерпан лимит просмотров
+	ShareLocked    = "locked"    // Заблокирована после неверных паролей
+	ShareRevoked   = "revoked"
+)
+
+// NoteShare - публичная ссылка /s/<токен> на заметку: открывает ее без входа в аккаунт
+// только для чтения. Сам токен выдается владельцу один раз при создании; в БД хранится
+// только его SHA-256, как у токенов обновления.
+type NoteShare struct {
+	ID               int        `json:"id"`
+	NoteID           int        `json:"note_id"`
+	UserID           int        `json:"-"`
+	TokenHash        string     `json:"-"`
+	PasswordHash     string     `json:"-"` // bcrypt; пустой - ссылка без пароля
+	ExpiresAt        *time.Time `json:"expires_at"`
+	MaxViews         int        `json:"max_views"` // 0 - без ограничения
+	Views            int        `json:"views"`
+	PasswordFailures int        `json:"-"` // Неверные пароли подряд
+	CreatedAt        time.Time  `json:"created_at"`
+	RevokedAt        *time.Time `json:"revoked_at"`
+
+	// Заполняются обработчиками
+	HasPassword bool   `json:"has_password"`
+	Status      string `json:"status"`
+	Token       string `json:"token,omitempty"` // Только в ответе на создание
+	URL         string `json:"url,omitempty"`   // Путь /s/<токен>, только в ответе на создание
+}
+
+// CreateShareRequest - тело POST /notes/{id}/shares. Все поля необязательны.
+type CreateShareRequest struct {
+	ExpiresAt *time.Time `json:"expires_at"`
+	MaxViews  int        `json:"max_views"`
+	Password  string     `json:"password"`
+}
+
+// SharedNote - заметка, открытая по публичной ссылке: только заголовок, текст и даты,
+// без тегов и вложений.
+type SharedNote struct {
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NoteRevision - сохраненная версия заметки. Ревизия 1 - заметка при создании,
+// каждое изменение добавляет следующую; последняя ревизия совпадает с текущей заметкой.
+type NoteRevision struct {
+	NoteID    int        `json:"note_id"`
+	Revision  int        `json:"revision"`
+	Title     string     `json:"title"`
+	Content   string     `json:"content,omitempty"`   // Не заполняется в списке ревизий
+	Encrypted *Envelope  `json:"encrypted,omitempty"` // У зашифрованных ревизий - и в списке: в нем заголовок
+	Cipher    TextCipher `json:"-"`                   // Как у Note
+	Tags      []string   `json:"tags"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// NoteDiff - построчное сравнение двух ревизий заметки (GET /notes/{id}/revisions/diff).
+type NoteDiff struct {
+	From        int         `json:"from"`
+	To          int         `json:"to"`
+	OldTitle    string      `json:"old_title"`
+	NewTitle    string      `json:"new_title"`
+	TagsAdded   []string    `json:"tags_added"`
+	TagsRemoved []string    `json:"tags_removed"`
+	Lines       []diff.Line `json:"lines"`
+}
+
+// NoteSearchResult - заметка, найденная GET /notes/search.
+// TitleHighlight и Snippet - HTML: текст экранирован, найденные слова обернуты в <mark>.
+type NoteSearchResult struct {
+	Note
+	Rank           float64 `json:"rank"`
+	TitleHighlight string  `json:"title_highlight"`
+	Snippet        string  `json:"snippet"`
+}
+
+// Статусы записи в отчете об импорте.
+const (
+	ImportImported = "imported"
+	ImportSkipped  = "skipped" // Такая заметка уже есть
+	ImportFailed   = "failed"
+)
+
+// ImportReport - ответ POST /import: итоги и статус каждой записи источника по порядку.
+type ImportReport struct {
+	Format   string       `json:"format"`
+	Imported int          `json:"imported"`
+	Skipped  int          `json:"skipped"`
+	Failed   int          `json:"failed"`
+	Items    []ImportItem `json:"items"`
+}
+
+// ImportItem - результат импорта одной записи. Source - путь файла в архиве или номер
+// записи. NoteID - созданная заметка (imported) или уже существующая копия (skipped).
+type ImportItem struct {
+	Source string `json:"source"`
+	Status string `json:"status"`
+	NoteID int    `json:"note_id,omitempty"`
+	Title  string `json:"title,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// RefreshToken - долгоживущий токен обновления, хранящийся на сервере.
+// Сам токен выдается клиенту один раз; в БД хранится только его SHA-256 хеш.
+// Все токены, полученные цепочкой ротаций от одного входа, образуют семейство (FamilyID).
+type RefreshToken struct {
+	ID         int
+	UserID     int
+	FamilyID   string
+	TokenHash  string
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+	RevokedAt  *time.Time // Время отзыва или ротации; nil - токен действителен
+	ReplacedBy *int       // ID токена, выданного взамен при ротации
 }
 
 // LoginRequest и RegisterRequest используются для получения данных из тела HTTP-запроса
 type LoginRequest struct {
-	Email	string	`json:"username"`
-	Password	string	`json:"password"`
+	Email    string `json:"username"`
+	Password string `json:"password"`
 }
 
 type RegisterRequest struct {
-	Username	string	`json:"username"`
-	Email		string	`json:"email"`
-	Password	string	`json:"password"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Locale   string `json:"locale,omitempty"` // Язык писем; по умолчанию - из Accept-Language
+}
+
+// LocaleRequest - смена языка писем (PUT /account/locale).
+type LocaleRequest struct {
+	Locale string `json:"locale"`
 }
 
-// VerificationRequest используется для получения данных 
+// VerificationRequest используется для получения данных
 // из тела HTTP-запроса при подтверждении кода верификации.
 type VerificationRequest struct {
-    Email string `json:"email"`
-    Code  string `json:"code"`
+	Email string `json:"email"`
+	Code  string `json:"code"`
 }
 
+// ForgotPasswordRequest - тело запроса POST /password/forgot.
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordRequest - тело запроса POST /password/reset.
+type ResetPasswordRequest struct {
+	Email       string `json:"email"`
+	Code        string `json:"code"`
+	NewPassword string `json:"new_password"`
+}
+
+// RefreshRequest - тело запроса POST /auth/refresh и POST /auth/logout
+// (при выходе токен обновления необязателен).
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// TokenResponse - пара токенов, которую получает клиент после входа или обновления.
+type TokenResponse struct {
+	Token        string `json:"token"`         // Короткоживущий JWT доступа
+	RefreshToken string `json:"refresh_token"` // Токен для POST /auth/refresh
+	ExpiresIn    int    `json:"expires_in"`    // Время жизни JWT в секундах
+	Message      string `json:"message,omitempty"`
+}
+
+// TwoFactorSetupResponse - ответ POST /auth/2fa/setup.
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`      // Для ручного ввода в приложение
+	OTPAuthURI string `json:"otpauth_uri"` // Для отображения QR-кода
+}
+
+// TwoFactorCodeRequest - запрос с кодом из приложения-аутентификатора.
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// TwoFactorDisableRequest - тело POST /auth/2fa/disable: пароль и код TOTP или код восстановления.
+type TwoFactorDisableRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+// TwoFactorLoginRequest - второй шаг входа: промежуточный токен и код TOTP либо код восстановления.
+type TwoFactorLoginRequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// MFAChallengeResponse - ответ /login для аккаунта с 2FA: пароль верен, нужен второй фактор.
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// RecoveryCodesResponse - одноразовые коды восстановления. Показываются пользователю один раз.
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
@@ -0,0 +1,63 @@
+package search
+
+import (
+	"html"
+	"strings"
+)
+
+// Теги подсветки найденных слов во фрагменте.
+const (
+	markOpen  = "<mark>"
+	markClose = "</mark>"
+)
+
+// Highlight возвращает фрагмент text длиной не больше maxWords слов вокруг первого
+// вхождения запроса. Текст экранируется как HTML, найденные слова оборачиваются в <mark>,
+// поэтому фрагмент можно вставлять в страницу как разметку. Обрезанные края
+// обозначаются многоточием. Если вхождений нет, возвращается начало текста.
+func Highlight(text string, q Query, maxWords int) string {
+	tokens := tokenize(text)
+	if len(tokens) == 0 {
+		return html.EscapeString(text)
+	}
+
+	marked := make([]bool, len(tokens))
+	for _, t := range q.Terms {
+		t.count(tokens, marked)
+	}
+
+	// Окно начинается немного раньше первого вхождения, чтобы был виден контекст
+	first := 0
+	for i, m := range marked {
+		if m {
+			first = i
+			break
+		}
+	}
+	start := max(0, first-maxWords/4)
+	end := min(len(tokens), start+maxWords)
+	start = max(0, end-maxWords)
+
+	var b strings.Builder
+	pos := 0
+	if start > 0 {
+		b.WriteString("…")
+		pos = tokens[start].start
+	}
+	for i := start; i < end; i++ {
+		tok := tokens[i]
+		b.WriteString(html.EscapeString(text[pos:tok.start]))
+		if marked[i] {
+			b.WriteString(markOpen + html.EscapeString(text[tok.start:tok.end]) + markClose)
+		} else {
+			b.WriteString(html.EscapeString(text[tok.start:tok.end]))
+		}
+		pos = tok.end
+	}
+	if end < len(tokens) {
+		b.WriteString("…")
+	} else {
+		b.WriteString(html.EscapeString(text[pos:]))
+	}
+	return b.String()
+}
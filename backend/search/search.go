@@ -0,0 +1,203 @@
+// Package search разбирает поисковые запросы по заметкам и реализует поиск в памяти:
+// сопоставление, ранжирование и фрагменты с подсветкой. PostgreSQL ищет по индексу
+// tsvector (см. TSQuery), остальные хранилища - через Match и Rank; фрагменты
+// для ответа в обоих случаях строит Highlight.
+//
+// Синтаксис запроса: слова через пробел (должны встретиться все),
+// "фраза в кавычках" (слова подряд), слово* (поиск по префиксу).
+package search
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"diary-backend/models"
+)
+
+// MaxTerms - максимальное число условий в запросе.
+const MaxTerms = 16
+
+// ErrEmptyQuery - в запросе нет ни одного слова.
+var ErrEmptyQuery = errors.New("пустой поисковый запрос")
+
+// Term - одно условие запроса: слово или фраза (несколько слов подряд).
+// Prefix означает, что последнее слово может быть началом более длинного.
+type Term struct {
+	Words  []string
+	Prefix bool
+}
+
+// Query - разобранный запрос: все условия должны выполняться.
+type Query struct {
+	Terms []Term
+}
+
+// Parse разбирает строку запроса.
+func Parse(raw string) (Query, error) {
+	var q Query
+	for len(raw) > 0 {
+		raw = strings.TrimLeftFunc(raw, unicode.IsSpace)
+		if raw == "" {
+			break
+		}
+
+		var chunk string
+		phrase := raw[0] == '"'
+		if phrase {
+			end := strings.IndexByte(raw[1:], '"')
+			if end < 0 {
+				chunk, raw = raw[1:], "" // Незакрытая кавычка - фраза до конца строки
+			} else {
+				chunk, raw = raw[1:end+1], raw[end+2:]
+			}
+		} else {
+			end := strings.IndexFunc(raw, unicode.IsSpace)
+			if end < 0 {
+				end = len(raw)
+			}
+			chunk, raw = raw[:end], raw[end:]
+		}
+
+		prefix := strings.HasSuffix(chunk, "*")
+		words := make([]string, 0, 1)
+		for _, t := range tokenize(chunk) {
+			words = append(words, t.word)
+		}
+		if len(words) == 0 {
+			continue
+		}
+		// Слово из нескольких частей ("e-mail") ищется как фраза
+		q.Terms = append(q.Terms, Term{Words: words, Prefix: prefix})
+		if len(q.Terms) > MaxTerms {
+			return Query{}, errors.New("слишком много слов в поисковом запросе")
+		}
+	}
+	if len(q.Terms) == 0 {
+		return Query{}, ErrEmptyQuery
+	}
+	return q, nil
+}
+
+// TSQuery строит выражение для to_tsquery PostgreSQL: фразы через <->,
+// префиксы с :*, условия через &. Слова состоят только из букв и цифр,
+// поэтому экранирование не требуется.
+func (q Query) TSQuery() string {
+	terms := make([]string, len(q.Terms))
+	for i, t := range q.Terms {
+		lexemes := make([]string, len(t.Words))
+		for j, w := range t.Words {
+			lexemes[j] = "'" + w + "'"
+		}
+		if t.Prefix {
+			lexemes[len(lexemes)-1] += ":*"
+		}
+		terms[i] = "(" + strings.Join(lexemes, " <-> ") + ")"
+	}
+	return strings.Join(terms, " & ")
+}
+
+// token - слово текста в нижнем регистре и его байтовые границы в исходной строке.
+type token struct {
+	word       string
+	start, end int
+}
+
+// tokenize разбивает текст на слова из букв и цифр.
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			tokens = append(tokens, token{strings.ToLower(text[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{strings.ToLower(text[start:]), start, len(text)})
+	}
+	return tokens
+}
+
+// matchAt сообщает, начинается ли вхождение условия t с позиции i.
+func (t Term) matchAt(tokens []token, i int) bool {
+	if i+len(t.Words) > len(tokens) {
+		return false
+	}
+	for j, w := range t.Words {
+		got := tokens[i+j].word
+		if t.Prefix && j == len(t.Words)-1 {
+			if !strings.HasPrefix(got, w) {
+				return false
+			}
+		} else if got != w {
+			return false
+		}
+	}
+	return true
+}
+
+// count возвращает число вхождений условия и отмечает найденные слова в marked.
+func (t Term) count(tokens []token, marked []bool) int {
+	n := 0
+	for i := range tokens {
+		if t.matchAt(tokens, i) {
+			n++
+			if marked != nil {
+				for j := range t.Words {
+					marked[i+j] = true
+				}
+			}
+		}
+	}
+	return n
+}
+
+// Match сообщает, выполняются ли все условия запроса в заголовке или тексте заметки.
+func (q Query) Match(title, content string) bool {
+	titleTokens, contentTokens := tokenize(title), tokenize(content)
+	for _, t := range q.Terms {
+		if t.count(titleTokens, nil) == 0 && t.count(contentTokens, nil) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Rank оценивает релевантность заметки: вхождения в заголовке весят больше,
+// длинные тексты немного штрафуются (как нормализация длины в ts_rank).
+func (q Query) Rank(title, content string) float64 {
+	titleTokens, contentTokens := tokenize(title), tokenize(content)
+	score := 0.0
+	for _, t := range q.Terms {
+		score += 1.0*float64(t.count(titleTokens, nil)) + 0.4*float64(t.count(contentTokens, nil))
+	}
+	return score / (1 + math.Log1p(float64(len(titleTokens)+len(contentTokens))))
+}
+
+// Filter - поиск в памяти для хранилищ без полнотекстового индекса: заметки,
+// подходящие под запрос, по убыванию Rank (при равенстве - новые первыми), не больше limit.
+func Filter(notes []models.Note, q Query, limit int) []models.NoteSearchResult {
+	results := []models.NoteSearchResult{}
+	for _, n := range notes {
+		if q.Match(n.Title, n.Content) {
+			results = append(results, models.NoteSearchResult{Note: n, Rank: q.Rank(n.Title, n.Content)})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].CreatedAt.After(results[j].CreatedAt)
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
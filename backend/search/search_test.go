@@ -0,0 +1,153 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"diary-backend/models"
+)
+
+func mustParse(t *testing.T, raw string) Query {
+	t.Helper()
+	q, err := Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", raw, err)
+	}
+	return q
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string // Условия в виде fmt.Sprint(q.Terms); "" - ErrEmptyQuery
+	}{
+		{"кофе", "[{[кофе] false}]"},
+		{"  Кофе\tУтро  ", "[{[кофе] false} {[утро] false}]"},
+		{`"Утренний кофе" чай*`, "[{[утренний кофе] false} {[чай] true}]"},
+		{`"пил коф*"`, "[{[пил коф] true}]"},
+		{`"незакрытая фраза`, "[{[незакрытая фраза] false}]"},
+		{"e-mail", "[{[e mail] false}]"},
+		{"кофе, чай.", "[{[кофе] false} {[чай] false}]"},
+		{"", ""},
+		{`  "" * -- `, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			q, err := Parse(tt.raw)
+			if tt.want == "" {
+				if !errors.Is(err, ErrEmptyQuery) {
+					t.Fatalf("Parse = %v, %v; ожидалась ErrEmptyQuery", q.Terms, err)
+				}
+				return
+			}
+			if err != nil || fmt.Sprint(q.Terms) != tt.want {
+				t.Fatalf("Parse = %v, %v; ожидалось %s", q.Terms, err, tt.want)
+			}
+		})
+	}
+
+	// Условий больше MaxTerms - отдельная ошибка
+	raw := strings.Repeat("слово ", MaxTerms+1)
+	if _, err := Parse(raw); err == nil || errors.Is(err, ErrEmptyQuery) {
+		t.Errorf("Parse(%d слов) = %v", MaxTerms+1, err)
+	}
+	mustParse(t, strings.Repeat("слово ", MaxTerms))
+}
+
+func TestTSQuery(t *testing.T) {
+	q := mustParse(t, `"утренний кофе" чай* молоко`)
+	want := "('утренний' <-> 'кофе') & ('чай':*) & ('молоко')"
+	if got := q.TSQuery(); got != want {
+		t.Errorf("TSQuery = %q, ожидалось %q", got, want)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		query, title, content string
+		want                  bool
+	}{
+		{"кофе", "Утро", "Пил КОФЕ", true},
+		{"кофе утро", "Утро", "Пил кофе", true}, // Условия могут встретиться в разных полях
+		{"кофе чай", "Утро", "Пил кофе", false},
+		{`"пил кофе"`, "", "Утром пил крепкий кофе", false},
+		{`"крепкий кофе"`, "", "Утром пил крепкий кофе", true},
+		{"коф*", "", "Зашел в кофейню", true},
+		{"коф", "", "Зашел в кофейню", false},
+		{`"пил коф*"`, "", "пил кофеек", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query+"/"+tt.content, func(t *testing.T) {
+			if got := mustParse(t, tt.query).Match(tt.title, tt.content); got != tt.want {
+				t.Errorf("Match = %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterRanking(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	notes := []models.Note{
+		{ID: 1, Title: "Прогулка", Content: "Зашли за кофе по дороге домой и долго гуляли по парку", CreatedAt: day(1)},
+		{ID: 2, Title: "Кофе", Content: "Новый сорт", CreatedAt: day(2)},
+		{ID: 3, Title: "Утро", Content: "Кофе, кофе, кофе", CreatedAt: day(3)},
+		{ID: 4, Title: "Чай", Content: "Без единого нужного слова", CreatedAt: day(4)},
+		{ID: 5, Title: "Кофе", Content: "Новый сорт", CreatedAt: day(5)},
+	}
+
+	// Три вхождения в коротком тексте весят больше одного в заголовке, длинный текст
+	// с одним вхождением - последний, при равном ранге новые первыми
+	results := Filter(notes, mustParse(t, "кофе"), 10)
+	var ids []int
+	for i, r := range results {
+		ids = append(ids, r.ID)
+		if i > 0 && r.Rank > results[i-1].Rank {
+			t.Errorf("ранг %d больше предыдущего: %v > %v", r.ID, r.Rank, results[i-1].Rank)
+		}
+	}
+	if fmt.Sprint(ids) != "[3 5 2 1]" {
+		t.Errorf("порядок: %v, ожидалось [3 5 2 1]", ids)
+	}
+
+	if got := Filter(notes, mustParse(t, "кофе"), 2); len(got) != 2 || got[0].ID != 3 || got[1].ID != 5 {
+		t.Errorf("limit 2: %+v", got)
+	}
+	if got := Filter(notes, mustParse(t, "какао"), 10); got == nil || len(got) != 0 {
+		t.Errorf("без совпадений ожидался пустой список, получено %#v", got)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	var words []string
+	for i := 1; i <= 20; i++ {
+		words = append(words, fmt.Sprintf("w%d", i))
+	}
+	long := strings.Join(words, " ")
+
+	tests := []struct {
+		name, text, query string
+		maxWords          int
+		want              string
+	}{
+		{"слово", "Утром пил кофе", "кофе", 10, "Утром пил <mark>кофе</mark>"},
+		{"регистр сохраняется", "Кофе и КОФЕ", "кофе", 10, "<mark>Кофе</mark> и <mark>КОФЕ</mark>"},
+		{"фраза", "Утром пил кофе, пил и чай", `"пил кофе"`, 10, "Утром <mark>пил</mark> <mark>кофе</mark>, пил и чай"},
+		{"префикс", "Зашел в кофейню.", "коф*", 10, "Зашел в <mark>кофейню</mark>."},
+		{"окно вокруг вхождения", long, "w15", 4, "…w14 <mark>w15</mark> w16 w17…"},
+		{"вхождение в конце", long, "w20", 4, "…w17 w18 w19 <mark>w20</mark>"},
+		{"без вхождений - начало текста", long, "кофе", 3, "w1 w2 w3…"},
+		{"экранирование HTML", `<b>кофе</b> & "чай"`, "кофе", 10, `&lt;b&gt;<mark>кофе</mark>&lt;/b&gt; &amp; &#34;чай&#34;`},
+		{"разметка в тексте экранируется", "<mark>чай</mark>", "mark", 10, "&lt;<mark>mark</mark>&gt;чай&lt;/<mark>mark</mark>&gt;"},
+		{"текст без слов экранируется", "<>&", "кофе", 10, "&lt;&gt;&amp;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Highlight(tt.text, mustParse(t, tt.query), tt.maxWords); got != tt.want {
+				t.Errorf("Highlight = %q\nожидалось     %q", got, tt.want)
+			}
+		})
+	}
+}
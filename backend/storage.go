@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"diary-backend/config"
+	"diary-backend/migrations"
+	"diary-backend/store"
+	"diary-backend/store/memory"
+	"diary-backend/store/sqlstore"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	_ "github.com/lib/pq" // Драйвер PostgreSQL
+)
+
+// openDB открывает подключение к SQL-базе и возвращает диалект хранилища.
+func openDB(cfg config.DatabaseConfig) (*sql.DB, sqlstore.Dialect, error) {
+	if cfg.Driver == "sqlite" {
+		db, err := sqlstore.OpenSQLite(cfg.DSN)
+		if err != nil {
+			return nil, sqlstore.Dialect{}, err
+		}
+		fmt.Printf("Используется база SQLite: %s\n", cfg.DSN)
+		return db, sqlstore.SQLite, nil
+	}
+
+	db, err := sql.Open(cfg.Driver, cfg.DSN)
+	if err != nil {
+		return nil, sqlstore.Dialect{}, fmt.Errorf("ошибка при открытии подключения к БД: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, sqlstore.Dialect{}, err
+	}
+	fmt.Println("Успешное подключение к PostgreSQL.")
+	return db, sqlstore.Postgres, nil
+}
+
+// openStore создает хранилище по секции database конфигурации и, если включено,
+// применяет недостающие миграции. Возвращаемая функция закрывает подключение к БД.
+func openStore(cfg config.DatabaseConfig) (store.Store, func() error, error) {
+	if cfg.Driver == "memory" {
+		fmt.Println("Используется хранилище в памяти: данные не сохраняются между запусками.")
+		return memory.New(), func() error { return nil }, nil
+	}
+
+	db, dialect, err := openDB(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if cfg.AutoMigrate {
+		m, err := migrations.New(db, dialect.Name)
+		if err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		applied, err := m.Up(context.Background())
+		if err != nil {
+			db.Close()
+			return nil, nil, fmt.Errorf("автоматическая миграция: %w", err)
+		}
+		for _, mig := range applied {
+			fmt.Printf("Применена миграция %04d_%s\n", mig.Version, mig.Name)
+		}
+	}
+
+	return sqlstore.New(db, dialect), db.Close, nil
+}
+
+// runMigrate выполняет подкоманду migrate: up, down [N] или status.
+func runMigrate(cfg config.DatabaseConfig, args []string) error {
+	if cfg.Driver == "memory" {
+		return errors.New("хранилище в памяти не использует миграции")
+	}
+	if len(args) == 0 {
+		return errors.New("использование: migrate up | down [N] | status")
+	}
+
+	db, dialect, err := openDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	m, err := migrations.New(db, dialect.Name)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		for _, mig := range applied {
+			fmt.Printf("Применена миграция %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("Схема уже актуальна.")
+		}
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("некорректное число шагов отката: %q", args[1])
+			}
+		}
+		reverted, err := m.Down(ctx, steps)
+		for _, mig := range reverted {
+			fmt.Printf("Откачена миграция %04d_%s\n", mig.Version, mig.Name)
+		}
+		return err
+
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ВЕРСИЯ\tНАЗВАНИЕ\tПРИМЕНЕНА")
+		for _, st := range statuses {
+			applied := "нет"
+			if st.AppliedAt != nil {
+				applied = st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\n", st.Version, st.Name, applied)
+		}
+		return tw.Flush()
+
+	default:
+		return fmt.Errorf("неизвестная команда migrate %q", args[0])
+	}
+}
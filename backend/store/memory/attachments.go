@@ -0,0 +1,132 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"diary-backend/models"
+	"diary-backend/store"
+)
+
+func (s *Store) CreateAttachment(_ context.Context, a *models.Attachment, quota int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.note(a.UserID, a.NoteID, false) == nil {
+		return store.ErrNotFound
+	}
+	if s.usage(a.UserID)+a.Size > quota {
+		return store.ErrQuotaExceeded
+	}
+	for _, existing := range s.attachments {
+		if existing.BlobKey == a.BlobKey {
+			return store.ErrConflict
+		}
+	}
+	s.nextFileID++
+	a.ID = s.nextFileID
+	a.CreatedAt = time.Now()
+	stored := *a
+	s.attachments[a.ID] = &stored
+	return nil
+}
+
+func (s *Store) ListAttachments(_ context.Context, userID, noteID int) ([]models.Attachment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.note(userID, noteID, false) == nil {
+		return nil, store.ErrNotFound
+	}
+	attachments := []models.Attachment{}
+	for _, a := range s.attachments {
+		if a.NoteID == noteID && a.UserID == userID {
+			attachments = append(attachments, *a)
+		}
+	}
+	sort.Slice(attachments, func(i, j int) bool { return attachments[i].ID < attachments[j].ID })
+	return attachments, nil
+}
+
+func (s *Store) GetAttachment(_ context.Context, userID, noteID, attachmentID int) (*models.Attachment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := s.attachment(userID, noteID, attachmentID)
+	if a == nil {
+		return nil, store.ErrNotFound
+	}
+	copied := *a
+	return &copied, nil
+}
+
+func (s *Store) DeleteAttachment(_ context.Context, userID, noteID, attachmentID int) (*models.Attachment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := s.attachment(userID, noteID, attachmentID)
+	if a == nil {
+		return nil, store.ErrNotFound
+	}
+	copied := *a
+	a.NoteID = 0
+	return &copied, nil
+}
+
+func (s *Store) AttachmentUsage(_ context.Context, userID int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.usage(userID), nil
+}
+
+func (s *Store) ListOrphanAttachments(_ context.Context, limit int) ([]models.Attachment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attachments := []models.Attachment{}
+	for _, a := range s.attachments {
+		if a.NoteID == 0 {
+			attachments = append(attachments, *a)
+		}
+	}
+	sort.Slice(attachments, func(i, j int) bool { return attachments[i].ID < attachments[j].ID })
+	if len(attachments) > limit {
+		attachments = attachments[:limit]
+	}
+	return attachments, nil
+}
+
+func (s *Store) DeleteOrphanAttachment(_ context.Context, attachmentID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.attachments[attachmentID]
+	if !ok || a.NoteID != 0 {
+		return store.ErrNotFound
+	}
+	delete(s.attachments, attachmentID)
+	return nil
+}
+
+// attachment возвращает вложение заметки пользователя вне корзины или nil.
+// Вызывается под s.mu.
+func (s *Store) attachment(userID, noteID, attachmentID int) *models.Attachment {
+	a, ok := s.attachments[attachmentID]
+	if !ok || a.NoteID != noteID || a.UserID != userID || s.note(userID, noteID, false) == nil {
+		return nil
+	}
+	return a
+}
+
+// usage возвращает суммарный размер вложений пользователя. Вызывается под s.mu.
+func (s *Store) usage(userID int) int64 {
+	var used int64
+	for _, a := range s.attachments {
+		if a.UserID == userID {
+			used += a.Size
+		}
+	}
+	return used
+}
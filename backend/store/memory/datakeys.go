@@ -0,0 +1,227 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"diary-backend/models"
+	"diary-backend/store"
+)
+
+// activeDataKey возвращает действующий ключ пользователя или nil. Вызывается под s.mu.
+func (s *Store) activeDataKey(userID int) *models.DataKey {
+	for _, k := range s.dataKeys {
+		if k.UserID == userID && k.RetiredAt == nil {
+			return k
+		}
+	}
+	return nil
+}
+
+// dataKeyInUse сообщает, зашифрована ли ключом k хоть одна заметка или ревизия. Вызывается под s.mu.
+func (s *Store) dataKeyInUse(k *models.DataKey) bool {
+	for _, n := range s.notes {
+		if n.UserID != k.UserID {
+			continue
+		}
+		if n.Cipher.KeyID == k.ID {
+			return true
+		}
+		for _, r := range s.revisions[n.ID] {
+			if r.Cipher.KeyID == k.ID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *Store) ActiveDataKey(_ context.Context, userID int) (*models.DataKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := s.activeDataKey(userID)
+	if k == nil {
+		return nil, store.ErrNotFound
+	}
+	copied := *k
+	return &copied, nil
+}
+
+func (s *Store) GetDataKey(_ context.Context, id int) (*models.DataKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.dataKeys[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	copied := *k
+	return &copied, nil
+}
+
+func (s *Store) CreateDataKey(_ context.Context, k *models.DataKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.activeDataKey(k.UserID) != nil {
+		return store.ErrConflict
+	}
+	s.insertDataKey(k)
+	return nil
+}
+
+// insertDataKey сохраняет новый действующий ключ. Вызывается под s.mu.
+func (s *Store) insertDataKey(k *models.DataKey) {
+	s.nextKeyID++
+	k.ID = s.nextKeyID
+	k.CreatedAt = time.Now()
+	k.RetiredAt = nil
+	stored := *k
+	s.dataKeys[k.ID] = &stored
+}
+
+func (s *Store) RotateDataKey(_ context.Context, oldID int, next *models.DataKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.dataKeys[oldID]
+	if !ok || old.RetiredAt != nil {
+		return store.ErrConflict
+	}
+	retiredAt := time.Now()
+	old.RetiredAt = &retiredAt
+	s.insertDataKey(next)
+	return nil
+}
+
+// listDataKeys возвращает до limit ключей, подходящих под match, по возрастанию ID.
+// Вызывается под s.mu.
+func (s *Store) listDataKeys(limit int, match func(k *models.DataKey) bool) []models.DataKey {
+	keys := []models.DataKey{}
+	for _, k := range s.dataKeys {
+		if match(k) {
+			keys = append(keys, *k)
+		}
+	}
+	slices.SortFunc(keys, func(a, b models.DataKey) int { return cmp.Compare(a.ID, b.ID) })
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+	return keys
+}
+
+func (s *Store) ListDataKeysToRewrap(_ context.Context, masterKeyID string, limit int) ([]models.DataKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.listDataKeys(limit, func(k *models.DataKey) bool { return k.MasterKeyID != masterKeyID }), nil
+}
+
+func (s *Store) RewrapDataKey(_ context.Context, k *models.DataKey, oldMasterKeyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.dataKeys[k.ID]
+	if !ok || existing.MasterKeyID != oldMasterKeyID {
+		return store.ErrVersionConflict
+	}
+	existing.MasterKeyID = k.MasterKeyID
+	existing.WrappedKey = k.WrappedKey
+	return nil
+}
+
+func (s *Store) ListExpiredDataKeys(_ context.Context, before time.Time, limit int) ([]models.DataKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.listDataKeys(limit, func(k *models.DataKey) bool {
+		return k.RetiredAt == nil && k.CreatedAt.Before(before)
+	}), nil
+}
+
+func (s *Store) DeleteRetiredDataKeys(_ context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	for id, k := range s.dataKeys {
+		if k.RetiredAt != nil && k.RetiredAt.Before(before) && !s.dataKeyInUse(k) {
+			delete(s.dataKeys, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (s *Store) ListStaleNoteTexts(_ context.Context, after models.NoteText, limit int) ([]models.NoteText, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stale := func(t models.NoteText, active *models.DataKey) bool {
+		if cmp.Or(cmp.Compare(t.NoteID, after.NoteID), cmp.Compare(t.Revision, after.Revision)) <= 0 {
+			return false
+		}
+		return (t.Title != "" || t.Content != "") &&
+			(active == nil || t.Cipher != models.TextCipher{KeyID: active.ID, Version: models.CipherV2})
+	}
+	texts := []models.NoteText{}
+	for _, n := range s.notes {
+		active := s.activeDataKey(n.UserID)
+		if t := (models.NoteText{UserID: n.UserID, NoteID: n.ID, Title: n.Title, Content: n.Content, Cipher: n.Cipher}); stale(t, active) {
+			texts = append(texts, t)
+		}
+		for _, r := range s.revisions[n.ID] {
+			if t := (models.NoteText{UserID: n.UserID, NoteID: n.ID, Revision: r.Revision, Title: r.Title, Content: r.Content, Cipher: r.Cipher}); stale(t, active) {
+				texts = append(texts, t)
+			}
+		}
+	}
+	slices.SortFunc(texts, func(a, b models.NoteText) int {
+		return cmp.Or(cmp.Compare(a.NoteID, b.NoteID), cmp.Compare(a.Revision, b.Revision))
+	})
+	if len(texts) > limit {
+		texts = texts[:limit]
+	}
+	return texts, nil
+}
+
+func (s *Store) RewriteNoteText(_ context.Context, old, next models.NoteText) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, ok := s.notes[old.NoteID]
+	if !ok {
+		return store.ErrVersionConflict
+	}
+	if old.Revision == 0 {
+		if n.Title != old.Title || n.Content != old.Content {
+			return store.ErrVersionConflict
+		}
+		n.Title, n.Content, n.Cipher = next.Title, next.Content, next.Cipher
+		return nil
+	}
+	for i, r := range s.revisions[old.NoteID] {
+		if r.Revision == old.Revision && r.Title == old.Title && r.Content == old.Content {
+			s.revisions[old.NoteID][i].Title = next.Title
+			s.revisions[old.NoteID][i].Content = next.Content
+			s.revisions[old.NoteID][i].Cipher = next.Cipher
+			return nil
+		}
+	}
+	return store.ErrVersionConflict
+}
+
+func (s *Store) CreateSealedNote(_ context.Context, n *models.Note, imported bool, seal func(*models.Note) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := time.Now()
+	created, updated := t, t
+	if imported {
+		created, updated = n.CreatedAt, n.UpdatedAt
+	}
+	return s.insertNote(n, created, updated, seal)
+}
@@ -0,0 +1,48 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"diary-backend/models"
+	"diary-backend/store"
+)
+
+func (s *Store) GetUserKeys(_ context.Context, userID int) (*models.UserKeys, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.userKeys[userID]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	copied := *k
+	return &copied, nil
+}
+
+func (s *Store) SaveUserKeys(_ context.Context, k *models.UserKeys) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.userKeys[k.UserID]
+	switch {
+	case k.Version == 0 && ok:
+		return store.ErrConflict
+	case k.Version != 0 && !ok:
+		return store.ErrNotFound
+	case k.Version != 0 && existing.Version != k.Version:
+		return store.ErrVersionConflict
+	}
+
+	t := time.Now()
+	stored := *k
+	stored.Version++
+	stored.CreatedAt = t
+	if ok {
+		stored.CreatedAt = existing.CreatedAt
+	}
+	stored.UpdatedAt = t
+	s.userKeys[k.UserID] = &stored
+	*k = stored
+	return nil
+}
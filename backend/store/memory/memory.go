@@ -0,0 +1,557 @@
+// Package memory - хранилище в памяти процесса. Используется в тестах обработчиков
+// и для локального запуска без базы данных; данные теряются при перезапуске.
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"diary-backend/models"
+	"diary-backend/search"
+	"diary-backend/store"
+)
+
+// Store - потокобезопасная реализация store.Store на map.
+type Store struct {
+	mu sync.Mutex
+
+	users       map[int]*models.User
+	notes       map[int]*models.Note
+	tags        map[int]*models.Tag
+	noteTags    map[int]map[int]bool          // noteID -> ID тегов заметки
+	revisions   map[int][]models.NoteRevision // noteID -> ревизии по возрастанию
+	tokens      map[int]*models.RefreshToken
+	revoked     map[string]time.Time    // jti -> срок действия отозванного токена
+	recovery    map[int]map[string]bool // userID -> хеш кода восстановления -> использован
+	outbox      map[int]*models.OutboxEmail
+	attachments map[int]*models.Attachment
+	shares      map[int]*models.NoteShare
+	userKeys    map[int]*models.UserKeys
+	dataKeys    map[int]*models.DataKey
+	nextUserID  int
+	nextNoteID  int
+	nextTagID   int
+	nextTokenID int
+	nextEmailID int
+	nextFileID  int
+	nextKeyID   int
+	nextShareID int
+}
+
+var _ store.Store = (*Store)(nil)
+
+// New создает пустое хранилище.
+func New() *Store {
+	return &Store{
+		users:       make(map[int]*models.User),
+		notes:       make(map[int]*models.Note),
+		tags:        make(map[int]*models.Tag),
+		noteTags:    make(map[int]map[int]bool),
+		revisions:   make(map[int][]models.NoteRevision),
+		tokens:      make(map[int]*models.RefreshToken),
+		revoked:     make(map[string]time.Time),
+		recovery:    make(map[int]map[string]bool),
+		outbox:      make(map[int]*models.OutboxEmail),
+		attachments: make(map[int]*models.Attachment),
+		shares:      make(map[int]*models.NoteShare),
+		userKeys:    make(map[int]*models.UserKeys),
+		dataKeys:    make(map[int]*models.DataKey),
+	}
+}
+
+// --- Пользователи ---
+
+func (s *Store) CreateUser(_ context.Context, u *models.User, email *models.OutboxEmail) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.users {
+		if existing.Email == u.Email {
+			return store.ErrConflict
+		}
+	}
+	s.nextUserID++
+	u.ID = s.nextUserID
+	u.CreatedAt = time.Now()
+	stored := *u
+	s.users[u.ID] = &stored
+	s.enqueueFor(u.ID, email)
+	return nil
+}
+
+func (s *Store) GetUserByID(_ context.Context, id int) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	copied := *u
+	return &copied, nil
+}
+
+func (s *Store) GetUserByEmail(_ context.Context, email string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Email == email {
+			copied := *u
+			return &copied, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *Store) SetVerificationCode(_ context.Context, userID int, codeHash string, expiry time.Time, email *models.OutboxEmail) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	u.VerificationCodeHash = codeHash
+	u.CodeExpiryTime = expiry
+	u.VerificationAttempts = 0
+	s.enqueueFor(userID, email)
+	return nil
+}
+
+func (s *Store) RecordVerificationFailure(_ context.Context, userID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return 0, store.ErrNotFound
+	}
+	u.VerificationAttempts++
+	return u.VerificationAttempts, nil
+}
+
+func (s *Store) MarkVerified(_ context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	u.IsVerified = true
+	u.VerificationCodeHash = ""
+	u.CodeExpiryTime = time.Time{}
+	u.VerificationAttempts = 0
+	return nil
+}
+
+func (s *Store) SetPasswordResetCode(_ context.Context, userID int, codeHash string, expiry time.Time, email *models.OutboxEmail) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	u.ResetCodeHash = codeHash
+	u.ResetCodeExpiry = expiry
+	u.ResetCodeAttempts = 0
+	s.enqueueFor(userID, email)
+	return nil
+}
+
+func (s *Store) RecordResetCodeFailure(_ context.Context, userID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return 0, store.ErrNotFound
+	}
+	u.ResetCodeAttempts++
+	return u.ResetCodeAttempts, nil
+}
+
+func (s *Store) SetLocale(_ context.Context, userID int, locale string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	u.Locale = locale
+	return nil
+}
+
+func (s *Store) ResetPassword(_ context.Context, userID int, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	u.PasswordHash = passwordHash
+	u.ResetCodeHash = ""
+	u.ResetCodeExpiry = time.Time{}
+	u.ResetCodeAttempts = 0
+	s.revokeUserTokens(u, time.Now())
+	return nil
+}
+
+// --- Заметки ---
+
+func (s *Store) ListNotes(_ context.Context, userID int) ([]models.Note, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	notes := []models.Note{}
+	for _, n := range s.notes {
+		if n.UserID == userID && n.DeletedAt == nil {
+			notes = append(notes, s.withTags(n))
+		}
+	}
+	sort.Slice(notes, func(i, j int) bool {
+		if notes[i].CreatedAt.Equal(notes[j].CreatedAt) {
+			return notes[i].ID > notes[j].ID
+		}
+		return notes[i].CreatedAt.After(notes[j].CreatedAt)
+	})
+	return notes, nil
+}
+
+func (s *Store) QueryNotes(ctx context.Context, userID int, q store.NoteQuery) ([]models.Note, error) {
+	all, err := s.ListNotes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.FilterNotes(all, q), nil
+}
+
+func (s *Store) SearchNotes(ctx context.Context, userID int, q search.Query, limit int) ([]models.NoteSearchResult, error) {
+	notes, err := s.ListNotes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return search.Filter(notes, q, limit), nil
+}
+
+func (s *Store) GetNote(_ context.Context, userID, noteID int) (*models.Note, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := s.note(userID, noteID, false)
+	if n == nil {
+		return nil, store.ErrNotFound
+	}
+	copied := s.withTags(n)
+	return &copied, nil
+}
+
+func (s *Store) CreateNote(_ context.Context, n *models.Note) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := time.Now()
+	return s.insertNote(n, t, t, nil)
+}
+
+func (s *Store) ImportNote(_ context.Context, n *models.Note) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.insertNote(n, n.CreatedAt, n.UpdatedAt, nil)
+}
+
+// insertNote сохраняет новую заметку. seal, если задан, получает n с назначенным ID
+// до сохранения (см. store.DataKeyStore.CreateSealedNote). Вызывается под s.mu.
+func (s *Store) insertNote(n *models.Note, created, updated time.Time, seal func(*models.Note) error) error {
+	s.nextNoteID++
+	n.ID = s.nextNoteID
+	if seal != nil {
+		if err := seal(n); err != nil {
+			return err
+		}
+	}
+	n.Version = 1
+	n.CreatedAt = created
+	n.UpdatedAt = updated
+	stored := *n
+	s.notes[n.ID] = &stored
+	s.setNoteTags(n.UserID, n.ID, n.Tags)
+	*n = s.withTags(&stored)
+	s.addRevision(*n)
+	return nil
+}
+
+func (s *Store) UpdateNote(_ context.Context, n *models.Note) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing := s.note(n.UserID, n.ID, false)
+	if existing == nil {
+		return store.ErrNotFound
+	}
+	if n.Version != 0 && existing.Version != n.Version {
+		return store.ErrVersionConflict
+	}
+	existing.Title = n.Title
+	existing.Content = n.Content
+	existing.Encrypted = n.Encrypted
+	existing.Version++
+	existing.UpdatedAt = time.Now()
+	s.setNoteTags(n.UserID, n.ID, n.Tags)
+	*n = s.withTags(existing)
+	s.addRevision(*n)
+	return nil
+}
+
+func (s *Store) DeleteNote(_ context.Context, userID, noteID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := s.note(userID, noteID, false)
+	if n == nil {
+		return store.ErrNotFound
+	}
+	deletedAt := time.Now()
+	n.DeletedAt = &deletedAt
+	return nil
+}
+
+// note возвращает заметку пользователя: в корзине, если trashed, иначе не в корзине.
+// Вызывается под s.mu.
+func (s *Store) note(userID, noteID int, trashed bool) *models.Note {
+	n, ok := s.notes[noteID]
+	if !ok || n.UserID != userID || (n.DeletedAt != nil) != trashed {
+		return nil
+	}
+	return n
+}
+
+// addRevision сохраняет состояние заметки следующей ревизией, если оно отличается
+// от последней. Зашифрованная ревизия удаляет открытые ревизии заметки. Вызывается под s.mu.
+func (s *Store) addRevision(n models.Note) {
+	revisions := s.revisions[n.ID]
+	next := 1
+	if k := len(revisions); k > 0 {
+		last := revisions[k-1]
+		if last.Title == n.Title && last.Content == n.Content && last.Encrypted.Equal(n.Encrypted) &&
+			slices.Equal(last.Tags, n.Tags) {
+			return
+		}
+		next = last.Revision + 1
+	}
+	if n.Encrypted != nil {
+		revisions = slices.DeleteFunc(revisions, func(r models.NoteRevision) bool { return r.Encrypted == nil })
+	}
+	s.revisions[n.ID] = append(revisions, models.NoteRevision{
+		NoteID:    n.ID,
+		Revision:  next,
+		Title:     n.Title,
+		Content:   n.Content,
+		Encrypted: n.Encrypted,
+		Cipher:    n.Cipher,
+		Tags:      n.Tags,
+		CreatedAt: n.UpdatedAt,
+	})
+}
+
+func (s *Store) ListNoteRevisions(_ context.Context, userID, noteID int) ([]models.NoteRevision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.note(userID, noteID, false) == nil {
+		return nil, store.ErrNotFound
+	}
+	revisions := []models.NoteRevision{}
+	for _, r := range slices.Backward(s.revisions[noteID]) {
+		r.Content = ""
+		revisions = append(revisions, r)
+	}
+	return revisions, nil
+}
+
+func (s *Store) GetNoteRevision(_ context.Context, userID, noteID, rev int) (*models.NoteRevision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.note(userID, noteID, false) == nil {
+		return nil, store.ErrNotFound
+	}
+	// Номера ревизий идут по возрастанию, но после шифрования заметки могут начинаться не с 1
+	revisions := s.revisions[noteID]
+	i, found := slices.BinarySearchFunc(revisions, rev, func(r models.NoteRevision, rev int) int {
+		return cmp.Compare(r.Revision, rev)
+	})
+	if !found {
+		return nil, store.ErrNotFound
+	}
+	r := revisions[i]
+	return &r, nil
+}
+
+// withTags возвращает копию заметки с ее тегами. Вызывается под s.mu.
+func (s *Store) withTags(n *models.Note) models.Note {
+	copied := *n
+	names := []string{}
+	for tagID := range s.noteTags[n.ID] {
+		names = append(names, s.tags[tagID].Name)
+	}
+	sort.Strings(names)
+	copied.SetTags(names)
+	return copied
+}
+
+// setNoteTags заменяет теги заметки, создавая недостающие теги пользователя. Вызывается под s.mu.
+func (s *Store) setNoteTags(userID, noteID int, names []string) {
+	ids := make(map[int]bool, len(names))
+	for _, name := range names {
+		t := s.findTag(userID, name)
+		if t == nil {
+			s.nextTagID++
+			t = &models.Tag{ID: s.nextTagID, UserID: userID, Name: name, CreatedAt: time.Now()}
+			s.tags[t.ID] = t
+		}
+		ids[t.ID] = true
+	}
+	s.noteTags[noteID] = ids
+}
+
+// findTag ищет тег пользователя по имени. Вызывается под s.mu.
+func (s *Store) findTag(userID int, name string) *models.Tag {
+	for _, t := range s.tags {
+		if t.UserID == userID && t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// --- Теги ---
+
+// tagWithCount возвращает копию тега с числом заметок вне корзины. Вызывается под s.mu.
+func (s *Store) tagWithCount(t *models.Tag) models.Tag {
+	copied := *t
+	copied.NoteCount = 0
+	for noteID, ids := range s.noteTags {
+		if ids[t.ID] && s.notes[noteID].DeletedAt == nil {
+			copied.NoteCount++
+		}
+	}
+	return copied
+}
+
+func (s *Store) ListTags(_ context.Context, userID int) ([]models.Tag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tags := []models.Tag{}
+	for _, t := range s.tags {
+		if t.UserID == userID {
+			tags = append(tags, s.tagWithCount(t))
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, nil
+}
+
+func (s *Store) GetTag(_ context.Context, userID, tagID int) (*models.Tag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tags[tagID]
+	if !ok || t.UserID != userID {
+		return nil, store.ErrNotFound
+	}
+	copied := s.tagWithCount(t)
+	return &copied, nil
+}
+
+func (s *Store) CreateTag(_ context.Context, t *models.Tag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.findTag(t.UserID, t.Name) != nil {
+		return store.ErrConflict
+	}
+	s.nextTagID++
+	t.ID = s.nextTagID
+	t.CreatedAt = time.Now()
+	t.NoteCount = 0
+	stored := *t
+	s.tags[t.ID] = &stored
+	return nil
+}
+
+func (s *Store) UpdateTag(_ context.Context, t *models.Tag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.tags[t.ID]
+	if !ok || existing.UserID != t.UserID {
+		return store.ErrNotFound
+	}
+	if other := s.findTag(t.UserID, t.Name); other != nil && other.ID != t.ID {
+		return store.ErrConflict
+	}
+	if existing.Name != t.Name {
+		s.touchTagNotes(t.ID)
+	}
+	existing.Name = t.Name
+	existing.Color = t.Color
+	*t = s.tagWithCount(existing)
+	return nil
+}
+
+func (s *Store) MergeTags(_ context.Context, userID, fromID, intoID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range []int{fromID, intoID} {
+		if t, ok := s.tags[id]; !ok || t.UserID != userID {
+			return store.ErrNotFound
+		}
+	}
+	s.touchTagNotes(fromID)
+	for _, ids := range s.noteTags {
+		if ids[fromID] {
+			delete(ids, fromID)
+			ids[intoID] = true
+		}
+	}
+	delete(s.tags, fromID)
+	return nil
+}
+
+func (s *Store) DeleteTag(_ context.Context, userID, tagID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tags[tagID]
+	if !ok || t.UserID != userID {
+		return store.ErrNotFound
+	}
+	s.touchTagNotes(tagID)
+	for _, ids := range s.noteTags {
+		delete(ids, tagID)
+	}
+	delete(s.tags, tagID)
+	return nil
+}
+
+// touchTagNotes увеличивает версии заметок с тегом tagID. Вызывается под s.mu.
+func (s *Store) touchTagNotes(tagID int) {
+	for noteID, ids := range s.noteTags {
+		if ids[tagID] {
+			s.notes[noteID].Version++
+		}
+	}
+}
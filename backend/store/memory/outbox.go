@@ -0,0 +1,140 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"diary-backend/models"
+	"diary-backend/store"
+)
+
+func (s *Store) EnqueueEmail(_ context.Context, e *models.OutboxEmail) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enqueueEmail(e)
+	return nil
+}
+
+// enqueueEmail ставит письмо в очередь. Вызывается под s.mu.
+func (s *Store) enqueueEmail(e *models.OutboxEmail) {
+	if e.UserID != 0 {
+		for _, queued := range s.outbox {
+			if queued.UserID == e.UserID && queued.Kind == e.Kind && queued.Status == models.EmailPending {
+				queued.Status = models.EmailCancelled
+				queued.TextBody = ""
+				queued.HTMLBody = ""
+			}
+		}
+	}
+
+	s.nextEmailID++
+	e.ID = s.nextEmailID
+	e.Status = models.EmailPending
+	e.CreatedAt = time.Now()
+	e.NextAttemptAt = e.CreatedAt
+	stored := *e
+	s.outbox[e.ID] = &stored
+}
+
+// enqueueFor ставит письмо пользователю userID в очередь, если оно передано. Вызывается под s.mu.
+func (s *Store) enqueueFor(userID int, email *models.OutboxEmail) {
+	if email != nil {
+		email.UserID = userID
+		s.enqueueEmail(email)
+	}
+}
+
+func (s *Store) ClaimDueEmails(_ context.Context, limit int, lease time.Duration) ([]models.OutboxEmail, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := time.Now()
+	var due []*models.OutboxEmail
+	for _, e := range s.outbox {
+		if e.Status == models.EmailPending && !e.NextAttemptAt.After(current) {
+			due = append(due, e)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(due[j].NextAttemptAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]models.OutboxEmail, 0, len(due))
+	for _, e := range due {
+		e.NextAttemptAt = current.Add(lease)
+		claimed = append(claimed, *e)
+	}
+	return claimed, nil
+}
+
+func (s *Store) MarkEmailSent(_ context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.outbox[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	sentAt := time.Now()
+	e.Status = models.EmailSent
+	e.SentAt = &sentAt
+	e.TextBody = ""
+	e.HTMLBody = ""
+	e.LastError = ""
+	return nil
+}
+
+func (s *Store) MarkEmailFailed(_ context.Context, id int, errMsg string, next time.Time, dead bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.outbox[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	// Письмо, отмененное во время отправки, остается отмененным
+	if e.Status != models.EmailPending {
+		return nil
+	}
+	e.Attempts++
+	e.LastError = errMsg
+	e.NextAttemptAt = next
+	if dead {
+		e.Status = models.EmailDead
+		e.TextBody = ""
+		e.HTMLBody = ""
+	}
+	return nil
+}
+
+func (s *Store) LatestEmail(_ context.Context, userID int, kind string) (*models.OutboxEmail, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var latest *models.OutboxEmail
+	for _, e := range s.outbox {
+		if e.UserID == userID && e.Kind == kind && (latest == nil || e.ID > latest.ID) {
+			latest = e
+		}
+	}
+	if latest == nil {
+		return nil, store.ErrNotFound
+	}
+	copied := *latest
+	return &copied, nil
+}
+
+func (s *Store) CountEmails(_ context.Context, userID int, kind string, since time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, e := range s.outbox {
+		if e.UserID == userID && e.Kind == kind && !e.CreatedAt.Before(since) {
+			n++
+		}
+	}
+	return n, nil
+}
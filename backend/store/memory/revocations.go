@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"diary-backend/models"
+	"diary-backend/store"
+)
+
+func (s *Store) RevokeToken(_ context.Context, jti string, _ int, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, exp := range s.revoked {
+		if exp.Before(now) {
+			delete(s.revoked, id)
+		}
+	}
+	if _, ok := s.revoked[jti]; !ok {
+		s.revoked[jti] = expiresAt
+	}
+	return nil
+}
+
+func (s *Store) IsTokenRevoked(_ context.Context, jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.revoked[jti]
+	return ok, nil
+}
+
+func (s *Store) RevokeUserTokens(_ context.Context, userID int, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	s.revokeUserTokens(u, before)
+	return nil
+}
+
+// revokeUserTokens сдвигает границу действительности токенов и отзывает токены обновления.
+// Вызывается под s.mu.
+func (s *Store) revokeUserTokens(u *models.User, before time.Time) {
+	u.TokensValidAfter = before.Truncate(time.Second)
+
+	now := time.Now()
+	for _, t := range s.tokens {
+		if t.UserID == u.ID && t.RevokedAt == nil {
+			t.RevokedAt = &now
+		}
+	}
+}
+
+func (s *Store) TokensValidAfter(_ context.Context, userID int) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return time.Time{}, store.ErrNotFound
+	}
+	return u.TokensValidAfter, nil
+}
@@ -0,0 +1,106 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"diary-backend/models"
+	"diary-backend/store"
+)
+
+func (s *Store) CreateShare(_ context.Context, sh *models.NoteShare) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.note(sh.UserID, sh.NoteID, false) == nil {
+		return store.ErrNotFound
+	}
+	for _, existing := range s.shares {
+		if existing.TokenHash == sh.TokenHash {
+			return store.ErrConflict
+		}
+	}
+	s.nextShareID++
+	sh.ID = s.nextShareID
+	sh.CreatedAt = time.Now()
+	sh.Views, sh.PasswordFailures, sh.RevokedAt = 0, 0, nil
+	stored := *sh
+	s.shares[sh.ID] = &stored
+	return nil
+}
+
+func (s *Store) ListShares(_ context.Context, userID, noteID int) ([]models.NoteShare, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.note(userID, noteID, false) == nil {
+		return nil, store.ErrNotFound
+	}
+	shares := []models.NoteShare{}
+	for _, sh := range s.shares {
+		if sh.NoteID == noteID && sh.UserID == userID {
+			shares = append(shares, *sh)
+		}
+	}
+	sort.Slice(shares, func(i, j int) bool { return shares[i].ID > shares[j].ID })
+	return shares, nil
+}
+
+func (s *Store) RevokeShare(_ context.Context, userID, noteID, shareID int) (*models.NoteShare, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sh, ok := s.shares[shareID]
+	if !ok || sh.NoteID != noteID || sh.UserID != userID || s.note(userID, noteID, false) == nil {
+		return nil, store.ErrNotFound
+	}
+	if sh.RevokedAt == nil {
+		now := time.Now()
+		sh.RevokedAt = &now
+	}
+	copied := *sh
+	return &copied, nil
+}
+
+func (s *Store) GetShareByToken(_ context.Context, tokenHash string) (*models.NoteShare, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, sh := range s.shares {
+		if sh.TokenHash == tokenHash {
+			copied := *sh
+			return &copied, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *Store) UseShare(_ context.Context, shareID int, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sh, ok := s.shares[shareID]
+	if !ok {
+		return store.ErrConflict
+	}
+	if sh.RevokedAt != nil || (sh.ExpiresAt != nil && !sh.ExpiresAt.After(now)) ||
+		(sh.MaxViews > 0 && sh.Views >= sh.MaxViews) {
+		return store.ErrConflict
+	}
+	sh.Views++
+	sh.PasswordFailures = 0
+	return nil
+}
+
+func (s *Store) RecordSharePasswordFailure(_ context.Context, shareID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sh, ok := s.shares[shareID]
+	if !ok {
+		return 0, store.ErrNotFound
+	}
+	sh.PasswordFailures++
+	return sh.PasswordFailures, nil
+}
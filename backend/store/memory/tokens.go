@@ -0,0 +1,73 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"diary-backend/models"
+	"diary-backend/store"
+)
+
+func (s *Store) CreateRefreshToken(_ context.Context, t *models.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.createRefreshToken(t)
+}
+
+func (s *Store) createRefreshToken(t *models.RefreshToken) error {
+	for _, existing := range s.tokens {
+		if existing.TokenHash == t.TokenHash {
+			return store.ErrConflict
+		}
+	}
+	s.nextTokenID++
+	t.ID = s.nextTokenID
+	t.CreatedAt = time.Now()
+	stored := *t
+	s.tokens[t.ID] = &stored
+	return nil
+}
+
+func (s *Store) GetRefreshToken(_ context.Context, tokenHash string) (*models.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.tokens {
+		if t.TokenHash == tokenHash {
+			copied := *t
+			return &copied, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *Store) RotateRefreshToken(_ context.Context, oldID int, next *models.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.tokens[oldID]
+	if !ok || old.RevokedAt != nil {
+		return store.ErrConflict
+	}
+	if err := s.createRefreshToken(next); err != nil {
+		return err
+	}
+	now := time.Now()
+	old.RevokedAt = &now
+	replacedBy := next.ID
+	old.ReplacedBy = &replacedBy
+	return nil
+}
+
+func (s *Store) RevokeTokenFamily(_ context.Context, familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, t := range s.tokens {
+		if t.FamilyID == familyID && t.RevokedAt == nil {
+			t.RevokedAt = &now
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"diary-backend/models"
+	"diary-backend/store"
+)
+
+func (s *Store) ListTrash(_ context.Context, userID int) ([]models.Note, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	notes := []models.Note{}
+	for _, n := range s.notes {
+		if n.UserID == userID && n.DeletedAt != nil {
+			notes = append(notes, s.withTags(n))
+		}
+	}
+	sort.Slice(notes, func(i, j int) bool {
+		if notes[i].DeletedAt.Equal(*notes[j].DeletedAt) {
+			return notes[i].ID > notes[j].ID
+		}
+		return notes[i].DeletedAt.After(*notes[j].DeletedAt)
+	})
+	return notes, nil
+}
+
+func (s *Store) RestoreNote(_ context.Context, userID, noteID int) (*models.Note, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := s.note(userID, noteID, true)
+	if n == nil {
+		return nil, store.ErrNotFound
+	}
+	n.DeletedAt = nil
+	copied := s.withTags(n)
+	return &copied, nil
+}
+
+func (s *Store) PurgeNote(_ context.Context, userID, noteID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.note(userID, noteID, true) == nil {
+		return store.ErrNotFound
+	}
+	s.purge(noteID)
+	return nil
+}
+
+func (s *Store) EmptyTrash(_ context.Context, userID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for id, n := range s.notes {
+		if n.UserID == userID && n.DeletedAt != nil {
+			s.purge(id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Store) PurgeTrash(_ context.Context, before time.Time, limit int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for id, n := range s.notes {
+		if count == limit {
+			break
+		}
+		if n.DeletedAt != nil && n.DeletedAt.Before(before) {
+			s.purge(id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// purge окончательно удаляет заметку с ее тегами, ревизиями и ссылками; вложения заметки
+// остаются без заметки до удаления их содержимого. Вызывается под s.mu.
+func (s *Store) purge(noteID int) {
+	delete(s.notes, noteID)
+	delete(s.noteTags, noteID)
+	delete(s.revisions, noteID)
+	for id, sh := range s.shares {
+		if sh.NoteID == noteID {
+			delete(s.shares, id)
+		}
+	}
+	for _, a := range s.attachments {
+		if a.NoteID == noteID {
+			a.NoteID = 0
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"diary-backend/models"
+	"diary-backend/store"
+)
+
+// user возвращает пользователя для изменения. Вызывается под s.mu.
+func (s *Store) user(userID int) (*models.User, error) {
+	u, ok := s.users[userID]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return u, nil
+}
+
+func (s *Store) SetTOTPSecret(_ context.Context, userID int, secret string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, err := s.user(userID)
+	if err != nil {
+		return err
+	}
+	u.TOTPSecret = secret
+	u.TOTPEnabled = false
+	return nil
+}
+
+func (s *Store) EnableTOTP(_ context.Context, userID int, counter int64, recoveryHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, err := s.user(userID)
+	if err != nil {
+		return err
+	}
+	u.TOTPEnabled = true
+	u.TOTPLastCounter = counter
+	u.TOTPFailures = 0
+	u.TOTPLockedUntil = time.Time{}
+	s.replaceRecoveryCodes(userID, recoveryHashes)
+	return nil
+}
+
+func (s *Store) DisableTOTP(_ context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, err := s.user(userID)
+	if err != nil {
+		return err
+	}
+	u.TOTPEnabled = false
+	u.TOTPSecret = ""
+	u.TOTPLastCounter = 0
+	u.TOTPFailures = 0
+	u.TOTPLockedUntil = time.Time{}
+	delete(s.recovery, userID)
+	return nil
+}
+
+func (s *Store) ReplaceRecoveryCodes(_ context.Context, userID int, recoveryHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.user(userID); err != nil {
+		return err
+	}
+	s.replaceRecoveryCodes(userID, recoveryHashes)
+	return nil
+}
+
+// replaceRecoveryCodes вызывается под s.mu.
+func (s *Store) replaceRecoveryCodes(userID int, recoveryHashes []string) {
+	codes := make(map[string]bool, len(recoveryHashes))
+	for _, hash := range recoveryHashes {
+		codes[hash] = false
+	}
+	s.recovery[userID] = codes
+}
+
+func (s *Store) UseTOTPCounter(_ context.Context, userID int, counter int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, err := s.user(userID)
+	if err != nil {
+		return err
+	}
+	if counter <= u.TOTPLastCounter {
+		return store.ErrConflict
+	}
+	u.TOTPLastCounter = counter
+	u.TOTPFailures = 0
+	u.TOTPLockedUntil = time.Time{}
+	return nil
+}
+
+func (s *Store) UseRecoveryCode(_ context.Context, userID int, codeHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, err := s.user(userID)
+	if err != nil {
+		return err
+	}
+	used, ok := s.recovery[userID][codeHash]
+	if !ok || used {
+		return store.ErrNotFound
+	}
+	s.recovery[userID][codeHash] = true
+	u.TOTPFailures = 0
+	u.TOTPLockedUntil = time.Time{}
+	return nil
+}
+
+func (s *Store) RecordTOTPFailure(_ context.Context, userID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, err := s.user(userID)
+	if err != nil {
+		return 0, err
+	}
+	u.TOTPFailures++
+	return u.TOTPFailures, nil
+}
+
+func (s *Store) LockTOTP(_ context.Context, userID int, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, err := s.user(userID)
+	if err != nil {
+		return err
+	}
+	u.TOTPLockedUntil = until
+	return nil
+}
+
+func (s *Store) ResetTOTPFailures(_ context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, err := s.user(userID)
+	if err != nil {
+		return err
+	}
+	u.TOTPFailures = 0
+	u.TOTPLockedUntil = time.Time{}
+	return nil
+}
@@ -0,0 +1,123 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+
+	"diary-backend/models"
+	"diary-backend/store"
+)
+
+const attachmentColumns = `id, note_id, user_id, blob_key, filename, content_type, size, sha256, created_at`
+
+func scanAttachment(row interface{ Scan(...any) error }) (*models.Attachment, error) {
+	var a models.Attachment
+	var noteID sql.NullInt64
+	err := row.Scan(&a.ID, &noteID, &a.UserID, &a.BlobKey, &a.Filename, &a.ContentType, &a.Size, &a.SHA256, &a.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	a.NoteID = int(noteID.Int64)
+	return &a, nil
+}
+
+func (s *Store) queryAttachments(ctx context.Context, query string, args ...any) ([]models.Attachment, error) {
+	rows, err := s.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []models.Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, *a)
+	}
+	return attachments, rows.Err()
+}
+
+func (s *Store) CreateAttachment(ctx context.Context, a *models.Attachment, quota int64) error {
+	return s.withTx(ctx, func(tx *Store) error {
+		// Блокирует строку пользователя до конца транзакции: параллельные загрузки
+		// проверяют квоту по очереди и не превышают ее вместе
+		if _, err := tx.q.ExecContext(ctx, `UPDATE users SET id = id WHERE id = $1`, a.UserID); err != nil {
+			return err
+		}
+
+		var exists bool
+		err := tx.q.QueryRowContext(ctx, `
+			SELECT EXISTS (SELECT 1 FROM notes WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL)`,
+			a.NoteID, a.UserID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return store.ErrNotFound
+		}
+
+		used, err := tx.AttachmentUsage(ctx, a.UserID)
+		if err != nil {
+			return err
+		}
+		if used+a.Size > quota {
+			return store.ErrQuotaExceeded
+		}
+
+		err = tx.q.QueryRowContext(ctx, `
+			INSERT INTO attachments (note_id, user_id, blob_key, filename, content_type, size, sha256, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING id, created_at`,
+			a.NoteID, a.UserID, a.BlobKey, a.Filename, a.ContentType, a.Size, a.SHA256, now(),
+		).Scan(&a.ID, &a.CreatedAt)
+		return tx.mapError(err)
+	})
+}
+
+func (s *Store) ListAttachments(ctx context.Context, userID, noteID int) ([]models.Attachment, error) {
+	if _, err := s.GetNote(ctx, userID, noteID); err != nil {
+		return nil, err
+	}
+	return s.queryAttachments(ctx, `SELECT `+attachmentColumns+`
+		FROM attachments WHERE note_id = $1 AND user_id = $2
+		ORDER BY id`, noteID, userID)
+}
+
+func (s *Store) GetAttachment(ctx context.Context, userID, noteID, attachmentID int) (*models.Attachment, error) {
+	a, err := scanAttachment(s.q.QueryRowContext(ctx, `
+		SELECT a.id, a.note_id, a.user_id, a.blob_key, a.filename, a.content_type, a.size, a.sha256, a.created_at
+		FROM attachments a JOIN notes n ON n.id = a.note_id
+		WHERE a.id = $1 AND a.note_id = $2 AND a.user_id = $3 AND n.deleted_at IS NULL`,
+		attachmentID, noteID, userID))
+	return a, s.mapError(err)
+}
+
+func (s *Store) DeleteAttachment(ctx context.Context, userID, noteID, attachmentID int) (*models.Attachment, error) {
+	a, err := scanAttachment(s.q.QueryRowContext(ctx, `
+		UPDATE attachments SET note_id = NULL
+		WHERE id = $1 AND note_id = $2 AND user_id = $3
+		  AND note_id IN (SELECT id FROM notes WHERE deleted_at IS NULL)
+		RETURNING `+attachmentColumns,
+		attachmentID, noteID, userID))
+	return a, s.mapError(err)
+}
+
+func (s *Store) AttachmentUsage(ctx context.Context, userID int) (int64, error) {
+	var used int64
+	err := s.q.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(size), 0) FROM attachments WHERE user_id = $1`, userID).Scan(&used)
+	return used, err
+}
+
+func (s *Store) ListOrphanAttachments(ctx context.Context, limit int) ([]models.Attachment, error) {
+	return s.queryAttachments(ctx, `SELECT `+attachmentColumns+`
+		FROM attachments WHERE note_id IS NULL
+		ORDER BY id LIMIT $1`, limit)
+}
+
+func (s *Store) DeleteOrphanAttachment(ctx context.Context, attachmentID int) error {
+	return s.mustAffect(s.q.ExecContext(ctx, `
+		DELETE FROM attachments WHERE id = $1 AND note_id IS NULL`, attachmentID))
+}
@@ -0,0 +1,185 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"diary-backend/models"
+	"diary-backend/store"
+)
+
+const dataKeyColumns = `id, user_id, master_key_id, wrapped_key, created_at, retired_at`
+
+// staleText - условие "заголовок или текст строки t непусты и не зашифрованы действующим
+// ключом k в формате models.CipherV2".
+func staleText(t string) string {
+	return `((` + t + `.title <> '' OR ` + t + `.content <> '') AND (k.id IS NULL OR ` + t + `.data_key_id IS NULL
+		OR ` + t + `.data_key_id <> k.id OR ` + t + `.cipher_version <> ` + strconv.Itoa(models.CipherV2) + `))`
+}
+
+func scanDataKey(row interface{ Scan(...any) error }) (*models.DataKey, error) {
+	var k models.DataKey
+	var retiredAt sql.NullTime
+	if err := row.Scan(&k.ID, &k.UserID, &k.MasterKeyID, &k.WrappedKey, &k.CreatedAt, &retiredAt); err != nil {
+		return nil, err
+	}
+	if retiredAt.Valid {
+		k.RetiredAt = &retiredAt.Time
+	}
+	return &k, nil
+}
+
+func (s *Store) queryDataKeys(ctx context.Context, query string, args ...any) ([]models.DataKey, error) {
+	rows, err := s.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.DataKey{}
+	for rows.Next() {
+		k, err := scanDataKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+	return keys, rows.Err()
+}
+
+func (s *Store) ActiveDataKey(ctx context.Context, userID int) (*models.DataKey, error) {
+	k, err := scanDataKey(s.q.QueryRowContext(ctx, `
+		SELECT `+dataKeyColumns+` FROM data_keys WHERE user_id = $1 AND retired_at IS NULL`, userID))
+	return k, s.mapError(err)
+}
+
+func (s *Store) GetDataKey(ctx context.Context, id int) (*models.DataKey, error) {
+	k, err := scanDataKey(s.q.QueryRowContext(ctx, `
+		SELECT `+dataKeyColumns+` FROM data_keys WHERE id = $1`, id))
+	return k, s.mapError(err)
+}
+
+func (s *Store) CreateDataKey(ctx context.Context, k *models.DataKey) error {
+	k.CreatedAt = now()
+	k.RetiredAt = nil
+	// Второй действующий ключ не пропустит уникальный индекс data_keys_active_idx
+	err := s.q.QueryRowContext(ctx, `
+		INSERT INTO data_keys (user_id, master_key_id, wrapped_key, created_at)
+		VALUES ($1, $2, $3, $4) RETURNING id`,
+		k.UserID, k.MasterKeyID, k.WrappedKey, k.CreatedAt).Scan(&k.ID)
+	return s.mapError(err)
+}
+
+func (s *Store) RotateDataKey(ctx context.Context, oldID int, next *models.DataKey) error {
+	return s.withTx(ctx, func(tx *Store) error {
+		err := tx.mustAffect(tx.q.ExecContext(ctx, `
+			UPDATE data_keys SET retired_at = $1 WHERE id = $2 AND retired_at IS NULL`, now(), oldID))
+		if errors.Is(err, store.ErrNotFound) {
+			return store.ErrConflict // Ключ уже заменили
+		}
+		if err != nil {
+			return err
+		}
+		return tx.CreateDataKey(ctx, next)
+	})
+}
+
+func (s *Store) ListDataKeysToRewrap(ctx context.Context, masterKeyID string, limit int) ([]models.DataKey, error) {
+	return s.queryDataKeys(ctx, `
+		SELECT `+dataKeyColumns+` FROM data_keys WHERE master_key_id <> $1 ORDER BY id LIMIT $2`,
+		masterKeyID, limit)
+}
+
+func (s *Store) RewrapDataKey(ctx context.Context, k *models.DataKey, oldMasterKeyID string) error {
+	err := s.mustAffect(s.q.ExecContext(ctx, `
+		UPDATE data_keys SET master_key_id = $1, wrapped_key = $2 WHERE id = $3 AND master_key_id = $4`,
+		k.MasterKeyID, k.WrappedKey, k.ID, oldMasterKeyID))
+	if errors.Is(err, store.ErrNotFound) {
+		return store.ErrVersionConflict
+	}
+	return err
+}
+
+func (s *Store) ListExpiredDataKeys(ctx context.Context, before time.Time, limit int) ([]models.DataKey, error) {
+	return s.queryDataKeys(ctx, `
+		SELECT `+dataKeyColumns+` FROM data_keys
+		WHERE retired_at IS NULL AND created_at < $1 ORDER BY id LIMIT $2`,
+		before.UTC(), limit)
+}
+
+func (s *Store) DeleteRetiredDataKeys(ctx context.Context, before time.Time) (int, error) {
+	return s.affected(s.q.ExecContext(ctx, `
+		DELETE FROM data_keys
+		WHERE retired_at IS NOT NULL AND retired_at < $1
+		  AND NOT EXISTS (SELECT 1 FROM notes WHERE data_key_id = data_keys.id)
+		  AND NOT EXISTS (SELECT 1 FROM note_revisions WHERE data_key_id = data_keys.id)`,
+		before.UTC()))
+}
+
+func (s *Store) ListStaleNoteTexts(ctx context.Context, after models.NoteText, limit int) ([]models.NoteText, error) {
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT user_id, note_id, revision, title, content, data_key_id, cipher_version FROM (
+			SELECT n.user_id, n.id AS note_id, 0 AS revision, n.title, n.content, n.data_key_id, n.cipher_version
+			FROM notes n
+			LEFT JOIN data_keys k ON k.user_id = n.user_id AND k.retired_at IS NULL
+			WHERE `+staleText("n")+`
+			UNION ALL
+			SELECT n.user_id, r.note_id, r.revision, r.title, r.content, r.data_key_id, r.cipher_version
+			FROM note_revisions r
+			JOIN notes n ON n.id = r.note_id
+			LEFT JOIN data_keys k ON k.user_id = n.user_id AND k.retired_at IS NULL
+			WHERE `+staleText("r")+`
+		) stale
+		WHERE (note_id, revision) > ($1, $2)
+		ORDER BY note_id, revision
+		LIMIT $3`, after.NoteID, after.Revision, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	texts := []models.NoteText{}
+	for rows.Next() {
+		var t models.NoteText
+		var cipher cipherColumns
+		if err := rows.Scan(append([]any{&t.UserID, &t.NoteID, &t.Revision, &t.Title, &t.Content}, cipher.dest()...)...); err != nil {
+			return nil, err
+		}
+		t.Cipher = cipher.cipher()
+		texts = append(texts, t)
+	}
+	return texts, rows.Err()
+}
+
+func (s *Store) RewriteNoteText(ctx context.Context, old, next models.NoteText) error {
+	var res sql.Result
+	var err error
+	args := append([]any{next.Title, next.Content}, cipherArgs(next.Cipher)...)
+	if old.Revision == 0 {
+		res, err = s.q.ExecContext(ctx, `
+			UPDATE notes SET title = $1, content = $2, data_key_id = $3, cipher_version = $4
+			WHERE id = $5 AND title = $6 AND content = $7`,
+			append(args, old.NoteID, old.Title, old.Content)...)
+	} else {
+		res, err = s.q.ExecContext(ctx, `
+			UPDATE note_revisions SET title = $1, content = $2, data_key_id = $3, cipher_version = $4
+			WHERE note_id = $5 AND revision = $6 AND title = $7 AND content = $8`,
+			append(args, old.NoteID, old.Revision, old.Title, old.Content)...)
+	}
+	err = s.mustAffect(res, err)
+	if errors.Is(err, store.ErrNotFound) {
+		return store.ErrVersionConflict // Заметку изменили, пока ее перешифровывали
+	}
+	return err
+}
+
+func (s *Store) CreateSealedNote(ctx context.Context, n *models.Note, imported bool, seal func(*models.Note) error) error {
+	created, updated := now(), now()
+	if imported {
+		created, updated = n.CreatedAt.UTC(), n.UpdatedAt.UTC()
+	}
+	return s.insertNote(ctx, n, created, updated, seal)
+}
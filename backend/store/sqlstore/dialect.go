@@ -0,0 +1,45 @@
+package sqlstore
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Dialect описывает различия между поддерживаемыми СУБД.
+// Запросы хранилища написаны так, чтобы выполняться и в PostgreSQL, и в SQLite
+// (плейсхолдеры $N, RETURNING); здесь остается только то, что отличается.
+type Dialect struct {
+	// Name - имя драйвера database/sql.
+	Name string
+	// isUniqueViolation сообщает, что ошибка вызвана нарушением ограничения уникальности.
+	isUniqueViolation func(err error) bool
+	// fullTextSearch - в схеме есть колонка notes.search_vector (tsvector с GIN-индексом).
+	// Без нее поиск выполняется в памяти (пакет search).
+	fullTextSearch bool
+}
+
+// Postgres - диалект PostgreSQL (драйвер github.com/lib/pq).
+var Postgres = Dialect{
+	Name: "postgres",
+	isUniqueViolation: func(err error) bool {
+		var pqErr *pq.Error
+		return errors.As(err, &pqErr) && pqErr.Code == "23505" // 23505 - код ошибки уникальности PostgreSQL
+	},
+	fullTextSearch: true,
+}
+
+// SQLite - диалект встроенной SQLite (драйвер modernc.org/sqlite, без cgo).
+var SQLite = Dialect{
+	Name: "sqlite",
+	isUniqueViolation: func(err error) bool {
+		var sqliteErr *sqlite.Error
+		if !errors.As(err, &sqliteErr) {
+			return false
+		}
+		code := sqliteErr.Code()
+		return code == sqlite3.SQLITE_CONSTRAINT_UNIQUE || code == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	},
+}
@@ -0,0 +1,61 @@
+package sqlstore
+
+import (
+	"context"
+	"errors"
+
+	"diary-backend/models"
+	"diary-backend/store"
+)
+
+const userKeyColumns = `user_id, kdf, iterations, memory, parallelism, salt, wrapped_master_key, version, created_at, updated_at`
+
+func (s *Store) scanUserKeys(row interface{ Scan(...any) error }) (*models.UserKeys, error) {
+	var k models.UserKeys
+	err := row.Scan(&k.UserID, &k.KDF, &k.Iterations, &k.Memory, &k.Parallelism, &k.Salt, &k.WrappedMasterKey,
+		&k.Version, &k.CreatedAt, &k.UpdatedAt)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	return &k, nil
+}
+
+func (s *Store) GetUserKeys(ctx context.Context, userID int) (*models.UserKeys, error) {
+	return s.scanUserKeys(s.q.QueryRowContext(ctx, `
+		SELECT `+userKeyColumns+` FROM user_keys WHERE user_id = $1`, userID))
+}
+
+func (s *Store) SaveUserKeys(ctx context.Context, k *models.UserKeys) error {
+	t := now()
+	if k.Version == 0 {
+		saved, err := s.scanUserKeys(s.q.QueryRowContext(ctx, `
+			INSERT INTO user_keys (user_id, kdf, iterations, memory, parallelism, salt, wrapped_master_key, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)
+			RETURNING `+userKeyColumns,
+			k.UserID, k.KDF, k.Iterations, k.Memory, k.Parallelism, k.Salt, k.WrappedMasterKey, t))
+		if err != nil {
+			return err
+		}
+		*k = *saved
+		return nil
+	}
+
+	saved, err := s.scanUserKeys(s.q.QueryRowContext(ctx, `
+		UPDATE user_keys
+		SET kdf = $1, iterations = $2, memory = $3, parallelism = $4, salt = $5, wrapped_master_key = $6,
+		    updated_at = $7, version = version + 1
+		WHERE user_id = $8 AND version = $9
+		RETURNING `+userKeyColumns,
+		k.KDF, k.Iterations, k.Memory, k.Parallelism, k.Salt, k.WrappedMasterKey, t, k.UserID, k.Version))
+	if errors.Is(err, store.ErrNotFound) {
+		// Ключи есть, но их уже заменили - или их нет совсем
+		if _, getErr := s.GetUserKeys(ctx, k.UserID); getErr == nil {
+			return store.ErrVersionConflict
+		}
+	}
+	if err != nil {
+		return err
+	}
+	*k = *saved
+	return nil
+}
@@ -0,0 +1,324 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"diary-backend/models"
+	"diary-backend/search"
+	"diary-backend/store"
+)
+
+const noteColumns = `id, user_id, title, content, version, created_at, updated_at, deleted_at,
+	enc_alg, ciphertext, nonce, wrapped_key, data_key_id, cipher_version`
+
+func (s *Store) scanNote(row interface{ Scan(...any) error }, extra ...any) (*models.Note, error) {
+	var n models.Note
+	var deletedAt sql.NullTime
+	var env envelopeColumns
+	var cipher cipherColumns
+	dest := append([]any{&n.ID, &n.UserID, &n.Title, &n.Content, &n.Version, &n.CreatedAt, &n.UpdatedAt, &deletedAt}, env.dest()...)
+	dest = append(dest, cipher.dest()...)
+	dest = append(dest, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, s.mapError(err)
+	}
+	if deletedAt.Valid {
+		n.DeletedAt = &deletedAt.Time
+	}
+	n.Encrypted = env.envelope()
+	n.Cipher = cipher.cipher()
+	return &n, nil
+}
+
+// cipherColumns - колонки data_key_id и cipher_version заметки или ревизии.
+// У открытых текстов data_key_id - NULL.
+type cipherColumns struct {
+	keyID   sql.NullInt64
+	version int
+}
+
+func (c *cipherColumns) dest() []any {
+	return []any{&c.keyID, &c.version}
+}
+
+func (c *cipherColumns) cipher() models.TextCipher {
+	return models.TextCipher{KeyID: int(c.keyID.Int64), Version: c.version}
+}
+
+// cipherArgs возвращает значения колонок cipherColumns для записи c.
+func cipherArgs(c models.TextCipher) []any {
+	return []any{sql.NullInt64{Int64: int64(c.KeyID), Valid: c.KeyID != 0}, c.Version}
+}
+
+// envelopeColumns - колонки enc_alg, ciphertext, nonce, wrapped_key заметки или ревизии.
+// У открытых заметок все они NULL.
+type envelopeColumns struct {
+	alg, ciphertext, nonce, wrappedKey sql.NullString
+}
+
+func (c *envelopeColumns) dest() []any {
+	return []any{&c.alg, &c.ciphertext, &c.nonce, &c.wrappedKey}
+}
+
+func (c *envelopeColumns) envelope() *models.Envelope {
+	if !c.alg.Valid {
+		return nil
+	}
+	return &models.Envelope{
+		Algorithm:  c.alg.String,
+		Ciphertext: c.ciphertext.String,
+		Nonce:      c.nonce.String,
+		WrappedKey: c.wrappedKey.String,
+	}
+}
+
+// envelopeArgs возвращает значения колонок envelopeColumns для записи конверта e.
+func envelopeArgs(e *models.Envelope) []any {
+	if e == nil {
+		return []any{nil, nil, nil, nil}
+	}
+	return []any{e.Algorithm, e.Ciphertext, e.Nonce, e.WrappedKey}
+}
+
+// queryNotes выполняет запрос, выбирающий noteColumns, и загружает теги найденных заметок.
+func (s *Store) queryNotes(ctx context.Context, query string, args ...any) ([]models.Note, error) {
+	rows, err := s.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := []models.Note{}
+	for rows.Next() {
+		n, err := s.scanNote(rows)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, *n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close() // Освобождаем подключение до запроса тегов (в SQLite оно одно)
+
+	ptrs := make([]*models.Note, len(notes))
+	for i := range notes {
+		ptrs[i] = &notes[i]
+	}
+	return notes, s.loadTags(ctx, ptrs...)
+}
+
+func (s *Store) ListNotes(ctx context.Context, userID int) ([]models.Note, error) {
+	return s.queryNotes(ctx, `SELECT `+noteColumns+`
+		FROM notes WHERE user_id = $1 AND deleted_at IS NULL
+		ORDER BY created_at DESC, id DESC`, userID)
+}
+
+// noteSortColumns - допустимые поля сортировки и фильтрации по дате. Имена колонок
+// подставляются в запрос только из этого списка.
+var noteSortColumns = map[string]string{
+	store.NoteSortCreated: "created_at",
+	store.NoteSortUpdated: "updated_at",
+	store.NoteSortTitle:   "title",
+}
+
+func (s *Store) QueryNotes(ctx context.Context, userID int, q store.NoteQuery) ([]models.Note, error) {
+	sortColumn, ok := noteSortColumns[q.Sort]
+	if !ok {
+		sortColumn = "created_at"
+	}
+	dateColumn := "created_at"
+	if q.DateField == store.NoteSortUpdated {
+		dateColumn = "updated_at"
+	}
+
+	args := []any{userID}
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	where := []string{"user_id = $1", "deleted_at IS NULL"}
+	if q.Tag != "" {
+		where = append(where, `EXISTS (SELECT 1 FROM note_tags nt JOIN tags t ON t.id = nt.tag_id
+			WHERE nt.note_id = notes.id AND t.name = `+arg(q.Tag)+`)`)
+	}
+	if q.Encrypted != nil {
+		if *q.Encrypted {
+			where = append(where, "enc_alg IS NOT NULL")
+		} else {
+			where = append(where, "enc_alg IS NULL")
+		}
+	}
+	if !q.From.IsZero() {
+		where = append(where, dateColumn+" >= "+arg(q.From.UTC()))
+	}
+	if !q.To.IsZero() {
+		where = append(where, dateColumn+" < "+arg(q.To.UTC()))
+	}
+
+	op, direction := ">", "ASC"
+	if q.Desc {
+		op, direction = "<", "DESC"
+	}
+	if q.After != nil {
+		// Keyset-пагинация: строки строго после (значение, id) последней заметки страницы
+		var value any = q.After.Time.UTC()
+		if sortColumn == "title" {
+			value = q.After.Title
+		}
+		v, id := arg(value), arg(q.After.ID)
+		where = append(where, fmt.Sprintf("(%[1]s %[2]s %[3]s OR (%[1]s = %[3]s AND id %[2]s %[4]s))", sortColumn, op, v, id))
+	}
+
+	limit := ""
+	if q.Limit > 0 {
+		limit = " LIMIT " + arg(q.Limit)
+	}
+	return s.queryNotes(ctx, `SELECT `+noteColumns+`
+		FROM notes WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY `+sortColumn+` `+direction+`, id `+direction+limit, args...)
+}
+
+func (s *Store) SearchNotes(ctx context.Context, userID int, q search.Query, limit int) ([]models.NoteSearchResult, error) {
+	if !s.dialect.fullTextSearch {
+		notes, err := s.ListNotes(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		return search.Filter(notes, q, limit), nil
+	}
+
+	// Вес A у заголовка и B у текста задан в колонке search_vector (миграция 0009)
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT `+noteColumns+`, ts_rank_cd(search_vector, query) AS rank
+		FROM notes, to_tsquery('simple', $1) AS query
+		WHERE user_id = $2 AND deleted_at IS NULL AND search_vector @@ query
+		ORDER BY rank DESC, created_at DESC
+		LIMIT $3`,
+		q.TSQuery(), userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []models.NoteSearchResult{}
+	for rows.Next() {
+		var rank float64
+		n, err := s.scanNote(rows, &rank)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, models.NoteSearchResult{Note: *n, Rank: rank})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	ptrs := make([]*models.Note, len(results))
+	for i := range results {
+		ptrs[i] = &results[i].Note
+	}
+	return results, s.loadTags(ctx, ptrs...)
+}
+
+func (s *Store) GetNote(ctx context.Context, userID, noteID int) (*models.Note, error) {
+	n, err := s.scanNote(s.q.QueryRowContext(ctx, `SELECT `+noteColumns+`
+		FROM notes WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`, noteID, userID))
+	if err != nil {
+		return nil, err
+	}
+	return n, s.loadTags(ctx, n)
+}
+
+func (s *Store) CreateNote(ctx context.Context, n *models.Note) error {
+	t := now()
+	return s.insertNote(ctx, n, t, t, nil)
+}
+
+func (s *Store) ImportNote(ctx context.Context, n *models.Note) error {
+	return s.insertNote(ctx, n, n.CreatedAt.UTC(), n.UpdatedAt.UTC(), nil)
+}
+
+// insertNote сохраняет новую заметку. Если seal задан, он вызывается в той же транзакции
+// после назначения ID, и заголовок, текст и Cipher, которые он записал в n, заменяют вставленные.
+func (s *Store) insertNote(ctx context.Context, n *models.Note, created, updated time.Time, seal func(*models.Note) error) error {
+	return s.withTx(ctx, func(tx *Store) error {
+		args := append([]any{n.UserID, n.Title, n.Content, created, updated}, envelopeArgs(n.Encrypted)...)
+		args = append(args, cipherArgs(n.Cipher)...)
+		err := tx.q.QueryRowContext(ctx, `
+			INSERT INTO notes (user_id, title, content, created_at, updated_at,
+				enc_alg, ciphertext, nonce, wrapped_key, data_key_id, cipher_version)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			RETURNING id, version, created_at, updated_at`, args...,
+		).Scan(&n.ID, &n.Version, &n.CreatedAt, &n.UpdatedAt)
+		if err != nil {
+			return tx.mapError(err)
+		}
+		if seal != nil {
+			if err := seal(n); err != nil {
+				return err
+			}
+			_, err := tx.q.ExecContext(ctx, `
+				UPDATE notes SET title = $1, content = $2, data_key_id = $3, cipher_version = $4
+				WHERE id = $5`,
+				append(append([]any{n.Title, n.Content}, cipherArgs(n.Cipher)...), n.ID)...)
+			if err != nil {
+				return err
+			}
+		}
+		if err := tx.setNoteTags(ctx, n.UserID, n.ID, n.Tags); err != nil {
+			return err
+		}
+		if err := tx.loadTags(ctx, n); err != nil {
+			return err
+		}
+		return tx.addRevision(ctx, n)
+	})
+}
+
+func (s *Store) UpdateNote(ctx context.Context, n *models.Note) error {
+	return s.withTx(ctx, func(tx *Store) error {
+		args := append([]any{n.Title, n.Content, now(), n.ID, n.UserID, n.Version}, envelopeArgs(n.Encrypted)...)
+		args = append(args, cipherArgs(n.Cipher)...)
+		updated, err := tx.scanNote(tx.q.QueryRowContext(ctx, `
+			UPDATE notes
+			SET title = $1, content = $2, updated_at = $3, version = version + 1,
+			    enc_alg = $7, ciphertext = $8, nonce = $9, wrapped_key = $10,
+			    data_key_id = $11, cipher_version = $12
+			WHERE id = $4 AND user_id = $5 AND deleted_at IS NULL
+			  AND ($6 = 0 OR version = $6)
+			RETURNING `+noteColumns, args...))
+		if errors.Is(err, store.ErrNotFound) && n.Version != 0 {
+			// Заметка есть, но ее уже изменили - или ее нет совсем
+			if _, getErr := tx.GetNote(ctx, n.UserID, n.ID); getErr == nil {
+				return store.ErrVersionConflict
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.setNoteTags(ctx, n.UserID, n.ID, n.Tags); err != nil {
+			return err
+		}
+		*n = *updated
+		if err := tx.loadTags(ctx, n); err != nil {
+			return err
+		}
+		return tx.addRevision(ctx, n)
+	})
+}
+
+func (s *Store) DeleteNote(ctx context.Context, userID, noteID int) error {
+	return s.mustAffect(s.q.ExecContext(ctx, `
+		UPDATE notes SET deleted_at = $1
+		WHERE id = $2 AND user_id = $3 AND deleted_at IS NULL`,
+		now(), noteID, userID))
+}
@@ -0,0 +1,149 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"diary-backend/models"
+	"diary-backend/store"
+)
+
+const outboxColumns = `id, user_id, kind, recipient, subject, text_body, html_body, status, attempts,
+	next_attempt_at, last_error, created_at, sent_at`
+
+func (s *Store) scanEmail(row interface{ Scan(...any) error }) (*models.OutboxEmail, error) {
+	var e models.OutboxEmail
+	var userID sql.NullInt64
+	var sentAt sql.NullTime
+	err := row.Scan(&e.ID, &userID, &e.Kind, &e.Recipient, &e.Subject, &e.TextBody, &e.HTMLBody, &e.Status, &e.Attempts,
+		&e.NextAttemptAt, &e.LastError, &e.CreatedAt, &sentAt)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	e.UserID = int(userID.Int64)
+	if sentAt.Valid {
+		e.SentAt = &sentAt.Time
+	}
+	return &e, nil
+}
+
+func (s *Store) EnqueueEmail(ctx context.Context, e *models.OutboxEmail) error {
+	return s.withTx(ctx, func(tx *Store) error {
+		userID := sql.NullInt64{Int64: int64(e.UserID), Valid: e.UserID != 0}
+		if userID.Valid {
+			_, err := tx.q.ExecContext(ctx, `
+				UPDATE email_outbox
+				SET status = $1, text_body = '', html_body = ''
+				WHERE user_id = $2 AND kind = $3 AND status = $4`,
+				models.EmailCancelled, userID, e.Kind, models.EmailPending)
+			if err != nil {
+				return err
+			}
+		}
+
+		e.Status = models.EmailPending
+		e.CreatedAt = now()
+		e.NextAttemptAt = e.CreatedAt
+		err := tx.q.QueryRowContext(ctx, `
+			INSERT INTO email_outbox
+				(user_id, kind, recipient, subject, text_body, html_body, status, next_attempt_at, created_at)
+			VALUES
+				($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			RETURNING id`,
+			userID, e.Kind, e.Recipient, e.Subject, e.TextBody, e.HTMLBody, e.Status, e.NextAttemptAt, e.CreatedAt,
+		).Scan(&e.ID)
+		return tx.mapError(err)
+	})
+}
+
+func (s *Store) ClaimDueEmails(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEmail, error) {
+	var claimed []models.OutboxEmail
+	err := s.withTx(ctx, func(tx *Store) error {
+		current := now()
+		rows, err := tx.q.QueryContext(ctx, `
+			SELECT id FROM email_outbox
+			WHERE status = $1 AND next_attempt_at <= $2
+			ORDER BY next_attempt_at
+			LIMIT $3`,
+			models.EmailPending, current, limit)
+		if err != nil {
+			return err
+		}
+		var ids []int
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		// Условие на next_attempt_at повторяется в UPDATE: если письмо уже забрал
+		// другой обработчик, строка не изменится и письмо будет пропущено.
+		for _, id := range ids {
+			e, err := tx.scanEmail(tx.q.QueryRowContext(ctx, `
+				UPDATE email_outbox
+				SET next_attempt_at = $1
+				WHERE id = $2 AND status = $3 AND next_attempt_at <= $4
+				RETURNING `+outboxColumns,
+				current.Add(lease), id, models.EmailPending, current))
+			if err != nil {
+				if errors.Is(err, store.ErrNotFound) {
+					continue
+				}
+				return err
+			}
+			claimed = append(claimed, *e)
+		}
+		return nil
+	})
+	return claimed, err
+}
+
+func (s *Store) MarkEmailSent(ctx context.Context, id int) error {
+	return s.mustAffect(s.q.ExecContext(ctx, `
+		UPDATE email_outbox
+		SET status = $1, sent_at = $2, text_body = '', html_body = '', last_error = ''
+		WHERE id = $3`,
+		models.EmailSent, now(), id))
+}
+
+func (s *Store) MarkEmailFailed(ctx context.Context, id int, errMsg string, next time.Time, dead bool) error {
+	status, bodies := models.EmailPending, ""
+	if dead {
+		// Тело стирается, как у отправленного письма: в нем открытый код
+		status, bodies = models.EmailDead, ", text_body = '', html_body = ''"
+	}
+	// Письмо, отмененное во время отправки, остается отмененным
+	_, err := s.q.ExecContext(ctx, `
+		UPDATE email_outbox
+		SET status = $1, attempts = attempts + 1, last_error = $2, next_attempt_at = $3`+bodies+`
+		WHERE id = $4 AND status = $5`,
+		status, errMsg, next.UTC(), id, models.EmailPending)
+	return err
+}
+
+func (s *Store) LatestEmail(ctx context.Context, userID int, kind string) (*models.OutboxEmail, error) {
+	return s.scanEmail(s.q.QueryRowContext(ctx, `
+		SELECT `+outboxColumns+` FROM email_outbox
+		WHERE user_id = $1 AND kind = $2
+		ORDER BY id DESC
+		LIMIT 1`,
+		userID, kind))
+}
+
+func (s *Store) CountEmails(ctx context.Context, userID int, kind string, since time.Time) (int, error) {
+	var n int
+	err := s.q.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM email_outbox
+		WHERE user_id = $1 AND kind = $2 AND created_at >= $3`,
+		userID, kind, since.UTC()).Scan(&n)
+	return n, err
+}
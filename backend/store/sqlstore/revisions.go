@@ -0,0 +1,123 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"slices"
+
+	"diary-backend/models"
+	"diary-backend/store"
+)
+
+// addRevision сохраняет текущее состояние заметки n следующей ревизией, если оно
+// отличается от последней. Вызывается внутри транзакции после изменения заметки.
+// Зашифрованная ревизия удаляет открытые ревизии заметки.
+func (s *Store) addRevision(ctx context.Context, n *models.Note) error {
+	var (
+		last     int
+		title    string
+		content  string
+		tagsJSON string
+		env      envelopeColumns
+	)
+	err := s.q.QueryRowContext(ctx, `
+		SELECT revision, title, content, tags, enc_alg, ciphertext, nonce, wrapped_key FROM note_revisions
+		WHERE note_id = $1 ORDER BY revision DESC LIMIT 1`, n.ID,
+	).Scan(append([]any{&last, &title, &content, &tagsJSON}, env.dest()...)...)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	if last > 0 && title == n.Title && content == n.Content && env.envelope().Equal(n.Encrypted) {
+		var lastTags []string
+		if err := json.Unmarshal([]byte(tagsJSON), &lastTags); err != nil {
+			return err
+		}
+		if slices.Equal(lastTags, n.Tags) {
+			return nil // Ничего не изменилось
+		}
+	}
+
+	tags, err := json.Marshal(n.Tags)
+	if err != nil {
+		return err
+	}
+
+	args := append([]any{n.ID, last + 1, n.Title, n.Content, string(tags), n.UpdatedAt}, envelopeArgs(n.Encrypted)...)
+	args = append(args, cipherArgs(n.Cipher)...)
+	_, err = s.q.ExecContext(ctx, `
+		INSERT INTO note_revisions (note_id, revision, title, content, tags, created_at,
+			enc_alg, ciphertext, nonce, wrapped_key, data_key_id, cipher_version)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`, args...)
+	if err != nil || n.Encrypted == nil {
+		return s.mapError(err)
+	}
+	_, err = s.q.ExecContext(ctx, `
+		DELETE FROM note_revisions WHERE note_id = $1 AND enc_alg IS NULL`, n.ID)
+	return err
+}
+
+func (s *Store) ListNoteRevisions(ctx context.Context, userID, noteID int) ([]models.NoteRevision, error) {
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT r.note_id, r.revision, r.title, r.tags, r.created_at,
+		       r.enc_alg, r.ciphertext, r.nonce, r.wrapped_key, r.data_key_id, r.cipher_version
+		FROM note_revisions r JOIN notes n ON n.id = r.note_id
+		WHERE r.note_id = $1 AND n.user_id = $2 AND n.deleted_at IS NULL
+		ORDER BY r.revision DESC`, noteID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []models.NoteRevision{}
+	for rows.Next() {
+		var r models.NoteRevision
+		var tags string
+		var env envelopeColumns
+		var cipher cipherColumns
+		dest := append([]any{&r.NoteID, &r.Revision, &r.Title, &tags, &r.CreatedAt}, env.dest()...)
+		if err := rows.Scan(append(dest, cipher.dest()...)...); err != nil {
+			return nil, err
+		}
+		r.Encrypted = env.envelope()
+		r.Cipher = cipher.cipher()
+		if err := json.Unmarshal([]byte(tags), &r.Tags); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// У каждой заметки есть хотя бы одна ревизия: пустой список - чужая, удаленная или лежащая в корзине заметка
+	if len(revisions) == 0 {
+		return nil, store.ErrNotFound
+	}
+	return revisions, nil
+}
+
+func (s *Store) GetNoteRevision(ctx context.Context, userID, noteID, rev int) (*models.NoteRevision, error) {
+	var r models.NoteRevision
+	var tags string
+	var env envelopeColumns
+	var cipher cipherColumns
+	dest := append([]any{&r.NoteID, &r.Revision, &r.Title, &r.Content, &tags, &r.CreatedAt}, env.dest()...)
+	err := s.q.QueryRowContext(ctx, `
+		SELECT r.note_id, r.revision, r.title, r.content, r.tags, r.created_at,
+		       r.enc_alg, r.ciphertext, r.nonce, r.wrapped_key, r.data_key_id, r.cipher_version
+		FROM note_revisions r JOIN notes n ON n.id = r.note_id
+		WHERE r.note_id = $1 AND r.revision = $2 AND n.user_id = $3 AND n.deleted_at IS NULL`,
+		noteID, rev, userID,
+	).Scan(append(dest, cipher.dest()...)...)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	r.Encrypted = env.envelope()
+	r.Cipher = cipher.cipher()
+	if err := json.Unmarshal([]byte(tags), &r.Tags); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
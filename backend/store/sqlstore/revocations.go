@@ -0,0 +1,52 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+func (s *Store) RevokeToken(ctx context.Context, jti string, userID int, expiresAt time.Time) error {
+	return s.withTx(ctx, func(tx *Store) error {
+		// Заодно чистим записи, срок действия токенов которых уже истек
+		if _, err := tx.q.ExecContext(ctx, `DELETE FROM revoked_tokens WHERE expires_at < $1`, now()); err != nil {
+			return err
+		}
+		_, err := tx.q.ExecContext(ctx, `
+			INSERT INTO revoked_tokens (jti, user_id, expires_at)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (jti) DO NOTHING`,
+			jti, userID, expiresAt.UTC())
+		return tx.mapError(err)
+	})
+}
+
+func (s *Store) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var revoked bool
+	err := s.q.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)`, jti).Scan(&revoked)
+	return revoked, s.mapError(err)
+}
+
+func (s *Store) RevokeUserTokens(ctx context.Context, userID int, before time.Time) error {
+	return s.withTx(ctx, func(tx *Store) error {
+		err := tx.mustAffect(tx.q.ExecContext(ctx,
+			`UPDATE users SET tokens_valid_after = $1 WHERE id = $2`, before.UTC().Truncate(time.Second), userID))
+		if err != nil {
+			return err
+		}
+		_, err = tx.q.ExecContext(ctx,
+			`UPDATE refresh_tokens SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL`,
+			now(), userID)
+		return tx.mapError(err)
+	})
+}
+
+func (s *Store) TokensValidAfter(ctx context.Context, userID int) (time.Time, error) {
+	var validAfter sql.NullTime
+	err := s.q.QueryRowContext(ctx, `SELECT tokens_valid_after FROM users WHERE id = $1`, userID).Scan(&validAfter)
+	if err != nil {
+		return time.Time{}, s.mapError(err)
+	}
+	return validAfter.Time, nil
+}
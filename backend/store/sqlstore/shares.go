@@ -0,0 +1,125 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"diary-backend/models"
+	"diary-backend/store"
+)
+
+const shareColumns = `id, note_id, user_id, token_hash, password_hash, expires_at, max_views, views, password_failures, created_at, revoked_at`
+
+func scanShare(row interface{ Scan(...any) error }) (*models.NoteShare, error) {
+	var sh models.NoteShare
+	var expiresAt, revokedAt sql.NullTime
+	err := row.Scan(&sh.ID, &sh.NoteID, &sh.UserID, &sh.TokenHash, &sh.PasswordHash, &expiresAt,
+		&sh.MaxViews, &sh.Views, &sh.PasswordFailures, &sh.CreatedAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		sh.ExpiresAt = &expiresAt.Time
+	}
+	if revokedAt.Valid {
+		sh.RevokedAt = &revokedAt.Time
+	}
+	return &sh, nil
+}
+
+// noteExists проверяет, что заметка пользователя есть и не в корзине.
+func (s *Store) noteExists(ctx context.Context, userID, noteID int) error {
+	var exists bool
+	err := s.q.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM notes WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL)`,
+		noteID, userID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) CreateShare(ctx context.Context, sh *models.NoteShare) error {
+	if err := s.noteExists(ctx, sh.UserID, sh.NoteID); err != nil {
+		return err
+	}
+	var expiresAt sql.NullTime
+	if sh.ExpiresAt != nil {
+		expiresAt = nullTime(*sh.ExpiresAt)
+	}
+	sh.CreatedAt = now()
+	err := s.q.QueryRowContext(ctx, `
+		INSERT INTO note_shares (note_id, user_id, token_hash, password_hash, expires_at, max_views, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		sh.NoteID, sh.UserID, sh.TokenHash, sh.PasswordHash, expiresAt, sh.MaxViews, sh.CreatedAt,
+	).Scan(&sh.ID)
+	return s.mapError(err)
+}
+
+func (s *Store) ListShares(ctx context.Context, userID, noteID int) ([]models.NoteShare, error) {
+	if err := s.noteExists(ctx, userID, noteID); err != nil {
+		return nil, err
+	}
+	rows, err := s.q.QueryContext(ctx, `SELECT `+shareColumns+`
+		FROM note_shares WHERE note_id = $1 AND user_id = $2
+		ORDER BY id DESC`, noteID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := []models.NoteShare{}
+	for rows.Next() {
+		sh, err := scanShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, *sh)
+	}
+	return shares, rows.Err()
+}
+
+func (s *Store) RevokeShare(ctx context.Context, userID, noteID, shareID int) (*models.NoteShare, error) {
+	if err := s.noteExists(ctx, userID, noteID); err != nil {
+		return nil, err
+	}
+	sh, err := scanShare(s.q.QueryRowContext(ctx, `
+		UPDATE note_shares SET revoked_at = COALESCE(revoked_at, $1)
+		WHERE id = $2 AND note_id = $3 AND user_id = $4
+		RETURNING `+shareColumns,
+		now(), shareID, noteID, userID))
+	return sh, s.mapError(err)
+}
+
+func (s *Store) GetShareByToken(ctx context.Context, tokenHash string) (*models.NoteShare, error) {
+	sh, err := scanShare(s.q.QueryRowContext(ctx, `
+		SELECT `+shareColumns+` FROM note_shares WHERE token_hash = $1`, tokenHash))
+	return sh, s.mapError(err)
+}
+
+func (s *Store) UseShare(ctx context.Context, shareID int, at time.Time) error {
+	// Условия в самом UPDATE: параллельные просмотры не превысят лимит
+	err := s.mustAffect(s.q.ExecContext(ctx, `
+		UPDATE note_shares SET views = views + 1, password_failures = 0
+		WHERE id = $1 AND revoked_at IS NULL
+		  AND (expires_at IS NULL OR expires_at > $2)
+		  AND (max_views = 0 OR views < max_views)`,
+		shareID, at.UTC()))
+	if errors.Is(err, store.ErrNotFound) {
+		return store.ErrConflict
+	}
+	return err
+}
+
+func (s *Store) RecordSharePasswordFailure(ctx context.Context, shareID int) (int, error) {
+	var failures int
+	err := s.q.QueryRowContext(ctx, `
+		UPDATE note_shares SET password_failures = password_failures + 1
+		WHERE id = $1 RETURNING password_failures`, shareID).Scan(&failures)
+	return failures, s.mapError(err)
+}
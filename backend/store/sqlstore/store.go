@@ -0,0 +1,111 @@
+// Package sqlstore реализует интерфейсы store поверх database/sql.
+// Поддерживаются PostgreSQL и встроенная SQLite (см. Dialect).
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"time"
+
+	"diary-backend/store"
+)
+
+// querier - общее подмножество *sql.DB и *sql.Tx, которым пользуются запросы хранилища.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Store - реализация store.Store на database/sql.
+type Store struct {
+	db      *sql.DB
+	q       querier
+	dialect Dialect
+}
+
+var _ store.Store = (*Store)(nil)
+
+// New создает хранилище поверх открытого подключения с указанным диалектом.
+func New(db *sql.DB, dialect Dialect) *Store {
+	return &Store{db: db, q: db, dialect: dialect}
+}
+
+// OpenSQLite открывает (или создает) файл базы SQLite по пути path.
+// Схема создается миграциями (пакет migrations). Для SQLite используется одно
+// подключение: запись в файл все равно последовательная, а так не возникает ошибок SQLITE_BUSY.
+func OpenSQLite(path string) (*sql.DB, error) {
+	dsn := "file:" + path + "?" + url.Values{
+		"_pragma": {"foreign_keys(1)", "journal_mode(WAL)", "busy_timeout(5000)"},
+	}.Encode()
+
+	db, err := sql.Open(SQLite.Name, dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+// withTx выполняет fn в транзакции: fn получает копию хранилища, все запросы которой
+// идут через транзакцию. Если хранилище уже внутри транзакции, fn выполняется в ней же.
+func (s *Store) withTx(ctx context.Context, fn func(tx *Store) error) error {
+	if _, ok := s.q.(*sql.Tx); ok {
+		return fn(s)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(&Store{db: s.db, q: tx, dialect: s.dialect}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// mapError переводит ошибки драйвера в общие ошибки пакета store.
+func (s *Store) mapError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.ErrNotFound
+	}
+	if err != nil && s.dialect.isUniqueViolation(err) {
+		return store.ErrConflict
+	}
+	return err
+}
+
+// mustAffect возвращает store.ErrNotFound, если запрос не изменил ни одной строки.
+func (s *Store) mustAffect(res sql.Result, err error) error {
+	n, err := s.affected(res, err)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// affected возвращает число строк, измененных запросом.
+func (s *Store) affected(res sql.Result, err error) (int, error) {
+	if err != nil {
+		return 0, s.mapError(err)
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// now возвращает текущее время в UTC. Время всегда хранится в UTC, чтобы в SQLite
+// (где оно хранится текстом) сортировка по строке совпадала с хронологической.
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+// nullTime превращает нулевое время в NULL.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
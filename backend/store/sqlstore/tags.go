@@ -0,0 +1,207 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+
+	"diary-backend/models"
+)
+
+const tagColumns = `t.id, t.user_id, t.name, t.color, t.created_at`
+
+func (s *Store) scanTag(row interface{ Scan(...any) error }) (*models.Tag, error) {
+	var t models.Tag
+	if err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.Color, &t.CreatedAt, &t.NoteCount); err != nil {
+		return nil, s.mapError(err)
+	}
+	return &t, nil
+}
+
+// tagsWithCounts - выборка тегов с числом заметок (без заметок в корзине);
+// условие WHERE добавляет вызывающий.
+const tagsWithCounts = `SELECT ` + tagColumns + `, COUNT(n.id)
+	FROM tags t
+	LEFT JOIN note_tags nt ON nt.tag_id = t.id
+	LEFT JOIN notes n ON n.id = nt.note_id AND n.deleted_at IS NULL`
+
+func (s *Store) ListTags(ctx context.Context, userID int) ([]models.Tag, error) {
+	rows, err := s.q.QueryContext(ctx, tagsWithCounts+`
+		WHERE t.user_id = $1
+		GROUP BY `+tagColumns+`
+		ORDER BY t.name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []models.Tag{}
+	for rows.Next() {
+		t, err := s.scanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, *t)
+	}
+	return tags, rows.Err()
+}
+
+func (s *Store) GetTag(ctx context.Context, userID, tagID int) (*models.Tag, error) {
+	return s.scanTag(s.q.QueryRowContext(ctx, tagsWithCounts+`
+		WHERE t.id = $1 AND t.user_id = $2
+		GROUP BY `+tagColumns, tagID, userID))
+}
+
+func (s *Store) CreateTag(ctx context.Context, t *models.Tag) error {
+	err := s.q.QueryRowContext(ctx, `
+		INSERT INTO tags (user_id, name, color, created_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`,
+		t.UserID, t.Name, t.Color, now(),
+	).Scan(&t.ID, &t.CreatedAt)
+	return s.mapError(err)
+}
+
+func (s *Store) UpdateTag(ctx context.Context, t *models.Tag) error {
+	return s.withTx(ctx, func(tx *Store) error {
+		// Имя тега входит в заметки, цвет - нет
+		_, err := tx.q.ExecContext(ctx, `
+			UPDATE notes SET version = version + 1
+			WHERE id IN (SELECT nt.note_id FROM note_tags nt JOIN tags t ON t.id = nt.tag_id
+			             WHERE t.id = $1 AND t.user_id = $2 AND t.name <> $3)`,
+			t.ID, t.UserID, t.Name)
+		if err != nil {
+			return err
+		}
+		err = tx.mustAffect(tx.q.ExecContext(ctx,
+			`UPDATE tags SET name = $1, color = $2 WHERE id = $3 AND user_id = $4`,
+			t.Name, t.Color, t.ID, t.UserID))
+		if err != nil {
+			return err
+		}
+		updated, err := tx.GetTag(ctx, t.UserID, t.ID)
+		if err != nil {
+			return err
+		}
+		*t = *updated
+		return nil
+	})
+}
+
+func (s *Store) MergeTags(ctx context.Context, userID, fromID, intoID int) error {
+	return s.withTx(ctx, func(tx *Store) error {
+		for _, id := range []int{fromID, intoID} {
+			if _, err := tx.GetTag(ctx, userID, id); err != nil {
+				return err
+			}
+		}
+		// Заметки, у которых уже есть оба тега, сохраняют одну связь
+		_, err := tx.q.ExecContext(ctx, `
+			INSERT INTO note_tags (note_id, tag_id)
+			SELECT note_id, $1 FROM note_tags WHERE tag_id = $2
+			ON CONFLICT (note_id, tag_id) DO NOTHING`,
+			intoID, fromID)
+		if err != nil {
+			return err
+		}
+		return tx.DeleteTag(ctx, userID, fromID)
+	})
+}
+
+func (s *Store) DeleteTag(ctx context.Context, userID, tagID int) error {
+	return s.withTx(ctx, func(tx *Store) error {
+		_, err := tx.q.ExecContext(ctx, `
+			UPDATE notes SET version = version + 1
+			WHERE user_id = $1 AND id IN (SELECT note_id FROM note_tags WHERE tag_id = $2)`,
+			userID, tagID)
+		if err != nil {
+			return err
+		}
+		// Связи с заметками удаляются каскадно (note_tags.tag_id ON DELETE CASCADE)
+		return tx.mustAffect(tx.q.ExecContext(ctx, `DELETE FROM tags WHERE id = $1 AND user_id = $2`, tagID, userID))
+	})
+}
+
+// setNoteTags заменяет теги заметки noteID тегами с именами names, создавая недостающие.
+// Вызывается внутри транзакции.
+func (s *Store) setNoteTags(ctx context.Context, userID, noteID int, names []string) error {
+	if _, err := s.q.ExecContext(ctx, `DELETE FROM note_tags WHERE note_id = $1`, noteID); err != nil {
+		return err
+	}
+	for _, name := range names {
+		var tagID int
+		err := s.q.QueryRowContext(ctx,
+			`SELECT id FROM tags WHERE user_id = $1 AND name = $2`, userID, name).Scan(&tagID)
+		if errors.Is(err, sql.ErrNoRows) {
+			// Тега еще нет. ON CONFLICT - на случай, если его только что создал параллельный запрос.
+			_, err = s.q.ExecContext(ctx, `
+				INSERT INTO tags (user_id, name, color, created_at) VALUES ($1, $2, '', $3)
+				ON CONFLICT (user_id, name) DO NOTHING`,
+				userID, name, now())
+			if err == nil {
+				err = s.q.QueryRowContext(ctx,
+					`SELECT id FROM tags WHERE user_id = $1 AND name = $2`, userID, name).Scan(&tagID)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := s.q.ExecContext(ctx,
+			`INSERT INTO note_tags (note_id, tag_id) VALUES ($1, $2)`, noteID, tagID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadTagsBatch - сколько заметок загружается одним запросом (ограничение числа параметров).
+const loadTagsBatch = 500
+
+// loadTags заполняет теги заметок, по одному запросу на loadTagsBatch заметок.
+func (s *Store) loadTags(ctx context.Context, notes ...*models.Note) error {
+	for len(notes) > loadTagsBatch {
+		if err := s.loadTags(ctx, notes[:loadTagsBatch]...); err != nil {
+			return err
+		}
+		notes = notes[loadTagsBatch:]
+	}
+	if len(notes) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, 0, len(notes))
+	args := make([]any, 0, len(notes))
+	for _, n := range notes {
+		args = append(args, n.ID)
+		placeholders = append(placeholders, "$"+strconv.Itoa(len(args)))
+	}
+
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT nt.note_id, t.name
+		FROM note_tags nt JOIN tags t ON t.id = nt.tag_id
+		WHERE nt.note_id IN (`+strings.Join(placeholders, ", ")+`)
+		ORDER BY t.name`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	tags := make(map[int][]string, len(notes))
+	for rows.Next() {
+		var noteID int
+		var name string
+		if err := rows.Scan(&noteID, &name); err != nil {
+			return err
+		}
+		tags[noteID] = append(tags[noteID], name)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, n := range notes {
+		n.SetTags(tags[n.ID])
+	}
+	return nil
+}
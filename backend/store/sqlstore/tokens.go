@@ -0,0 +1,75 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"diary-backend/models"
+	"diary-backend/store"
+)
+
+const refreshTokenColumns = `id, user_id, family_id, token_hash, expires_at, created_at, revoked_at, replaced_by`
+
+func (s *Store) scanRefreshToken(row interface{ Scan(...any) error }) (*models.RefreshToken, error) {
+	var t models.RefreshToken
+	var revokedAt sql.NullTime
+	var replacedBy sql.NullInt64
+	err := row.Scan(&t.ID, &t.UserID, &t.FamilyID, &t.TokenHash, &t.ExpiresAt, &t.CreatedAt, &revokedAt, &replacedBy)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	if revokedAt.Valid {
+		t.RevokedAt = &revokedAt.Time
+	}
+	if replacedBy.Valid {
+		id := int(replacedBy.Int64)
+		t.ReplacedBy = &id
+	}
+	return &t, nil
+}
+
+func (s *Store) CreateRefreshToken(ctx context.Context, t *models.RefreshToken) error {
+	t.CreatedAt = now()
+	err := s.q.QueryRowContext(ctx, `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`,
+		t.UserID, t.FamilyID, t.TokenHash, t.ExpiresAt.UTC(), t.CreatedAt,
+	).Scan(&t.ID)
+	return s.mapError(err)
+}
+
+func (s *Store) GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	return s.scanRefreshToken(s.q.QueryRowContext(ctx,
+		`SELECT `+refreshTokenColumns+` FROM refresh_tokens WHERE token_hash = $1`, tokenHash))
+}
+
+func (s *Store) RotateRefreshToken(ctx context.Context, oldID int, next *models.RefreshToken) error {
+	return s.withTx(ctx, func(tx *Store) error {
+		// Сначала "занимаем" старый токен: условие revoked_at IS NULL гарантирует,
+		// что из двух параллельных ротаций успешной будет только одна.
+		err := tx.mustAffect(tx.q.ExecContext(ctx,
+			`UPDATE refresh_tokens SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL`,
+			now(), oldID))
+		if errors.Is(err, store.ErrNotFound) {
+			return store.ErrConflict
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := tx.CreateRefreshToken(ctx, next); err != nil {
+			return err
+		}
+		_, err = tx.q.ExecContext(ctx, `UPDATE refresh_tokens SET replaced_by = $1 WHERE id = $2`, next.ID, oldID)
+		return s.mapError(err)
+	})
+}
+
+func (s *Store) RevokeTokenFamily(ctx context.Context, familyID string) error {
+	_, err := s.q.ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = $1 WHERE family_id = $2 AND revoked_at IS NULL`,
+		now(), familyID)
+	return s.mapError(err)
+}
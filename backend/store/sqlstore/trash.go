@@ -0,0 +1,48 @@
+package sqlstore
+
+import (
+	"context"
+	"time"
+
+	"diary-backend/models"
+)
+
+func (s *Store) ListTrash(ctx context.Context, userID int) ([]models.Note, error) {
+	return s.queryNotes(ctx, `SELECT `+noteColumns+`
+		FROM notes WHERE user_id = $1 AND deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC, id DESC`, userID)
+}
+
+func (s *Store) RestoreNote(ctx context.Context, userID, noteID int) (*models.Note, error) {
+	n, err := s.scanNote(s.q.QueryRowContext(ctx, `
+		UPDATE notes SET deleted_at = NULL
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL
+		RETURNING `+noteColumns, noteID, userID))
+	if err != nil {
+		return nil, err
+	}
+	return n, s.loadTags(ctx, n)
+}
+
+func (s *Store) PurgeNote(ctx context.Context, userID, noteID int) error {
+	// Теги заметки и ее ревизии удаляются каскадно
+	return s.mustAffect(s.q.ExecContext(ctx, `
+		DELETE FROM notes WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL`,
+		noteID, userID))
+}
+
+func (s *Store) EmptyTrash(ctx context.Context, userID int) (int, error) {
+	return s.affected(s.q.ExecContext(ctx, `
+		DELETE FROM notes WHERE user_id = $1 AND deleted_at IS NOT NULL`, userID))
+}
+
+func (s *Store) PurgeTrash(ctx context.Context, before time.Time, limit int) (int, error) {
+	// Пачками, чтобы не держать долгую блокировку при большом числе удаленных заметок
+	return s.affected(s.q.ExecContext(ctx, `
+		DELETE FROM notes WHERE id IN (
+			SELECT id FROM notes
+			WHERE deleted_at IS NOT NULL AND deleted_at < $1
+			ORDER BY deleted_at
+			LIMIT $2
+		)`, before.UTC(), limit))
+}
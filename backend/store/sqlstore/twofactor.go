@@ -0,0 +1,109 @@
+package sqlstore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"diary-backend/store"
+)
+
+func (s *Store) SetTOTPSecret(ctx context.Context, userID int, secret string) error {
+	return s.mustAffect(s.q.ExecContext(ctx,
+		`UPDATE users SET totp_secret = $1, totp_enabled = FALSE WHERE id = $2`, secret, userID))
+}
+
+func (s *Store) EnableTOTP(ctx context.Context, userID int, counter int64, recoveryHashes []string) error {
+	return s.withTx(ctx, func(tx *Store) error {
+		err := tx.mustAffect(tx.q.ExecContext(ctx, `
+			UPDATE users
+			SET totp_enabled = TRUE, totp_last_counter = $1, totp_failures = 0, totp_locked_until = NULL
+			WHERE id = $2`,
+			counter, userID))
+		if err != nil {
+			return err
+		}
+		return tx.ReplaceRecoveryCodes(ctx, userID, recoveryHashes)
+	})
+}
+
+func (s *Store) DisableTOTP(ctx context.Context, userID int) error {
+	return s.withTx(ctx, func(tx *Store) error {
+		err := tx.mustAffect(tx.q.ExecContext(ctx, `
+			UPDATE users
+			SET totp_enabled = FALSE, totp_secret = NULL, totp_last_counter = 0, totp_failures = 0, totp_locked_until = NULL
+			WHERE id = $1`,
+			userID))
+		if err != nil {
+			return err
+		}
+		_, err = tx.q.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID)
+		return err
+	})
+}
+
+func (s *Store) ReplaceRecoveryCodes(ctx context.Context, userID int, recoveryHashes []string) error {
+	return s.withTx(ctx, func(tx *Store) error {
+		if _, err := tx.q.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+			return err
+		}
+		for _, hash := range recoveryHashes {
+			_, err := tx.q.ExecContext(ctx,
+				`INSERT INTO recovery_codes (user_id, code_hash, created_at) VALUES ($1, $2, $3)`,
+				userID, hash, now())
+			if err != nil {
+				return tx.mapError(err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Store) UseTOTPCounter(ctx context.Context, userID int, counter int64) error {
+	err := s.mustAffect(s.q.ExecContext(ctx, `
+		UPDATE users
+		SET totp_last_counter = $1, totp_failures = 0, totp_locked_until = NULL
+		WHERE id = $2 AND totp_last_counter < $1`,
+		counter, userID))
+	if !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	// Строка не обновилась: либо пользователя нет, либо шаг уже использован
+	if _, err := s.GetUserByID(ctx, userID); err != nil {
+		return err
+	}
+	return store.ErrConflict
+}
+
+func (s *Store) UseRecoveryCode(ctx context.Context, userID int, codeHash string) error {
+	return s.withTx(ctx, func(tx *Store) error {
+		err := tx.mustAffect(tx.q.ExecContext(ctx, `
+			UPDATE recovery_codes
+			SET used_at = $1
+			WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL`,
+			now(), userID, codeHash))
+		if err != nil {
+			return err
+		}
+		return tx.ResetTOTPFailures(ctx, userID)
+	})
+}
+
+func (s *Store) RecordTOTPFailure(ctx context.Context, userID int) (int, error) {
+	var failures int
+	err := s.q.QueryRowContext(ctx, `
+		UPDATE users SET totp_failures = totp_failures + 1
+		WHERE id = $1
+		RETURNING totp_failures`,
+		userID).Scan(&failures)
+	return failures, s.mapError(err)
+}
+
+func (s *Store) LockTOTP(ctx context.Context, userID int, until time.Time) error {
+	return s.mustAffect(s.q.ExecContext(ctx, `UPDATE users SET totp_locked_until = $1 WHERE id = $2`, until.UTC(), userID))
+}
+
+func (s *Store) ResetTOTPFailures(ctx context.Context, userID int) error {
+	return s.mustAffect(s.q.ExecContext(ctx,
+		`UPDATE users SET totp_failures = 0, totp_locked_until = NULL WHERE id = $1`, userID))
+}
@@ -0,0 +1,140 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"diary-backend/models"
+)
+
+const userColumns = `id, username, email, password_hash, is_verified, locale, verification_code, code_expiry_time, verification_attempts,
+	reset_code, reset_code_expiry, reset_code_attempts,
+	totp_enabled, totp_secret, totp_last_counter, totp_failures, totp_locked_until, created_at, tokens_valid_after`
+
+func (s *Store) scanUser(row interface{ Scan(...any) error }) (*models.User, error) {
+	var u models.User
+	var code, resetCode, totpSecret sql.NullString
+	var expiry, resetExpiry, lockedUntil, validAfter sql.NullTime
+	err := row.Scan(&u.ID, &u.Username, &u.Email, &u.PasswordHash, &u.IsVerified, &u.Locale, &code, &expiry, &u.VerificationAttempts,
+		&resetCode, &resetExpiry, &u.ResetCodeAttempts, &u.TOTPEnabled, &totpSecret, &u.TOTPLastCounter, &u.TOTPFailures,
+		&lockedUntil, &u.CreatedAt, &validAfter)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	u.VerificationCodeHash = code.String
+	u.CodeExpiryTime = expiry.Time
+	u.ResetCodeHash = resetCode.String
+	u.ResetCodeExpiry = resetExpiry.Time
+	u.TOTPSecret = totpSecret.String
+	u.TOTPLockedUntil = lockedUntil.Time
+	u.TokensValidAfter = validAfter.Time
+	return &u, nil
+}
+
+func (s *Store) CreateUser(ctx context.Context, u *models.User, email *models.OutboxEmail) error {
+	return s.withTx(ctx, func(tx *Store) error {
+		u.CreatedAt = now()
+		err := tx.q.QueryRowContext(ctx, `
+			INSERT INTO users
+				(username, email, password_hash, is_verified, locale, verification_code, code_expiry_time, created_at)
+			VALUES
+				($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING id`,
+			u.Username, u.Email, u.PasswordHash, u.IsVerified, u.Locale, u.VerificationCodeHash, nullTime(u.CodeExpiryTime), u.CreatedAt,
+		).Scan(&u.ID)
+		if err != nil {
+			return tx.mapError(err)
+		}
+		return tx.enqueueFor(ctx, u.ID, email)
+	})
+}
+
+// enqueueFor ставит письмо пользователю userID в очередь, если оно передано.
+func (s *Store) enqueueFor(ctx context.Context, userID int, email *models.OutboxEmail) error {
+	if email == nil {
+		return nil
+	}
+	email.UserID = userID
+	return s.EnqueueEmail(ctx, email)
+}
+
+func (s *Store) GetUserByID(ctx context.Context, id int) (*models.User, error) {
+	return s.scanUser(s.q.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, id))
+}
+
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return s.scanUser(s.q.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE email = $1`, email))
+}
+
+func (s *Store) SetVerificationCode(ctx context.Context, userID int, codeHash string, expiry time.Time, email *models.OutboxEmail) error {
+	return s.withTx(ctx, func(tx *Store) error {
+		err := tx.mustAffect(tx.q.ExecContext(ctx, `
+			UPDATE users
+			SET verification_code = $1, code_expiry_time = $2, verification_attempts = 0
+			WHERE id = $3`,
+			codeHash, expiry.UTC(), userID))
+		if err != nil {
+			return err
+		}
+		return tx.enqueueFor(ctx, userID, email)
+	})
+}
+
+func (s *Store) RecordVerificationFailure(ctx context.Context, userID int) (int, error) {
+	return s.incrementCounter(ctx, "verification_attempts", userID)
+}
+
+func (s *Store) MarkVerified(ctx context.Context, userID int) error {
+	return s.mustAffect(s.q.ExecContext(ctx, `
+		UPDATE users
+		SET is_verified = TRUE, verification_code = NULL, code_expiry_time = NULL, verification_attempts = 0
+		WHERE id = $1`,
+		userID))
+}
+
+func (s *Store) SetPasswordResetCode(ctx context.Context, userID int, codeHash string, expiry time.Time, email *models.OutboxEmail) error {
+	return s.withTx(ctx, func(tx *Store) error {
+		err := tx.mustAffect(tx.q.ExecContext(ctx, `
+			UPDATE users
+			SET reset_code = $1, reset_code_expiry = $2, reset_code_attempts = 0
+			WHERE id = $3`,
+			codeHash, expiry.UTC(), userID))
+		if err != nil {
+			return err
+		}
+		return tx.enqueueFor(ctx, userID, email)
+	})
+}
+
+func (s *Store) RecordResetCodeFailure(ctx context.Context, userID int) (int, error) {
+	return s.incrementCounter(ctx, "reset_code_attempts", userID)
+}
+
+// incrementCounter атомарно увеличивает счетчик column пользователя и возвращает новое значение.
+// column - всегда константа из кода, не пользовательский ввод.
+func (s *Store) incrementCounter(ctx context.Context, column string, userID int) (int, error) {
+	var n int
+	err := s.q.QueryRowContext(ctx,
+		`UPDATE users SET `+column+` = `+column+` + 1 WHERE id = $1 RETURNING `+column,
+		userID).Scan(&n)
+	return n, s.mapError(err)
+}
+
+func (s *Store) SetLocale(ctx context.Context, userID int, locale string) error {
+	return s.mustAffect(s.q.ExecContext(ctx, `UPDATE users SET locale = $1 WHERE id = $2`, locale, userID))
+}
+
+func (s *Store) ResetPassword(ctx context.Context, userID int, passwordHash string) error {
+	return s.withTx(ctx, func(tx *Store) error {
+		err := tx.mustAffect(tx.q.ExecContext(ctx, `
+			UPDATE users
+			SET password_hash = $1, reset_code = NULL, reset_code_expiry = NULL, reset_code_attempts = 0
+			WHERE id = $2`,
+			passwordHash, userID))
+		if err != nil {
+			return err
+		}
+		return tx.RevokeUserTokens(ctx, userID, now())
+	})
+}
@@ -0,0 +1,406 @@
+// Package store описывает интерфейсы хранилища, от которых зависят HTTP-обработчики.
+// Конкретные реализации находятся в подпакетах (sqlstore, memory).
+package store
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"diary-backend/models"
+	"diary-backend/search"
+)
+
+// Общие ошибки хранилища. Реализации обязаны возвращать именно их (или оборачивать через %w),
+// чтобы обработчики могли выбрать HTTP-статус без знания о конкретной БД.
+var (
+	ErrNotFound = errors.New("запись не найдена")
+	ErrConflict = errors.New("запись уже существует")
+	// ErrVersionConflict - запись изменилась с тех пор, как ее прочитал клиент.
+	ErrVersionConflict = errors.New("запись изменена другим запросом")
+	// ErrQuotaExceeded - запись превысила бы квоту пользователя.
+	ErrQuotaExceeded = errors.New("превышена квота")
+)
+
+// UserStore - операции с пользователями и их кодами верификации.
+type UserStore interface {
+	// CreateUser сохраняет пользователя и заполняет u.ID и u.CreatedAt.
+	// Возвращает ErrConflict, если email уже занят. Если email не nil, письмо
+	// ставится в очередь в той же транзакции (email.UserID заполняется).
+	CreateUser(ctx context.Context, u *models.User, email *models.OutboxEmail) error
+	GetUserByID(ctx context.Context, id int) (*models.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	// SetVerificationCode заменяет хеш кода верификации и время его истечения
+	// и обнуляет счетчик неудачных попыток. Письмо email (если не nil) ставится
+	// в очередь в той же транзакции, как в OutboxStore.EnqueueEmail.
+	SetVerificationCode(ctx context.Context, userID int, codeHash string, expiry time.Time, email *models.OutboxEmail) error
+	// RecordVerificationFailure увеличивает счетчик неудачных попыток ввода кода
+	// верификации и возвращает новое значение.
+	RecordVerificationFailure(ctx context.Context, userID int) (int, error)
+	// MarkVerified подтверждает аккаунт и очищает код верификации.
+	MarkVerified(ctx context.Context, userID int) error
+	// SetPasswordResetCode заменяет хеш кода сброса пароля и время его истечения
+	// и обнуляет счетчик неудачных попыток. Письмо email (если не nil) ставится
+	// в очередь в той же транзакции.
+	SetPasswordResetCode(ctx context.Context, userID int, codeHash string, expiry time.Time, email *models.OutboxEmail) error
+	// RecordResetCodeFailure увеличивает счетчик неудачных попыток ввода кода сброса
+	// и возвращает новое значение.
+	RecordResetCodeFailure(ctx context.Context, userID int) (int, error)
+	// SetLocale меняет язык писем пользователя.
+	SetLocale(ctx context.Context, userID int, locale string) error
+	// ResetPassword сохраняет новый хеш пароля, очищает код сброса и завершает
+	// все сессии пользователя (как RevocationStore.RevokeUserTokens) - атомарно.
+	ResetPassword(ctx context.Context, userID int, passwordHash string) error
+}
+
+// NoteStore - операции с заметками. Все методы ограничены заметками одного пользователя:
+// чужая заметка неотличима от несуществующей (ErrNotFound).
+// Возвращаемые заметки всегда содержат свои теги (Note.SetTags).
+// Заметки в корзине (см. TrashStore) для этих методов не существуют.
+type NoteStore interface {
+	// ListNotes возвращает все заметки пользователя, новые первыми.
+	ListNotes(ctx context.Context, userID int) ([]models.Note, error)
+	// QueryNotes возвращает до q.Limit заметок пользователя (все при q.Limit = 0), подходящих под фильтры q,
+	// в порядке q.Sort (вторым ключом - id в том же направлении), начиная после q.After.
+	QueryNotes(ctx context.Context, userID int, q NoteQuery) ([]models.Note, error)
+	GetNote(ctx context.Context, userID, noteID int) (*models.Note, error)
+	// CreateNote сохраняет заметку n.UserID и заполняет n.ID, n.CreatedAt и n.UpdatedAt.
+	// Теги n.Tags, которых у пользователя еще нет, создаются в той же транзакции.
+	// Заметка сохраняется и как ревизия 1.
+	CreateNote(ctx context.Context, n *models.Note) error
+	// ImportNote сохраняет заметку как CreateNote, но с временем создания и изменения
+	// из n.CreatedAt и n.UpdatedAt (импорт из других дневников).
+	ImportNote(ctx context.Context, n *models.Note) error
+	// UpdateNote перезаписывает title, content, encrypted и набор тегов заметки n.ID
+	// пользователя n.UserID, увеличивает ее версию и заполняет n значениями из БД.
+	// Новое состояние сохраняется следующей ревизией, если отличается от последней.
+	// Если n.Version не 0, заметка обновляется, только если ее текущая версия равна
+	// n.Version, иначе - ErrVersionConflict.
+	// Когда сохраняется зашифрованная ревизия, открытые ревизии заметки удаляются:
+	// после шифрования сервер не должен хранить ее текст.
+	UpdateNote(ctx context.Context, n *models.Note) error
+	// ListNoteRevisions возвращает ревизии заметки, новые первыми, без текста (Content).
+	ListNoteRevisions(ctx context.Context, userID, noteID int) ([]models.NoteRevision, error)
+	// GetNoteRevision возвращает ревизию rev заметки целиком.
+	GetNoteRevision(ctx context.Context, userID, noteID, rev int) (*models.NoteRevision, error)
+	// DeleteNote перемещает заметку в корзину. Теги и ревизии сохраняются до ее
+	// окончательного удаления.
+	DeleteNote(ctx context.Context, userID, noteID int) error
+	// SearchNotes возвращает до limit заметок пользователя, подходящих под запрос,
+	// по убыванию релевантности (Rank). Фрагменты с подсветкой не заполняются.
+	SearchNotes(ctx context.Context, userID int, q search.Query, limit int) ([]models.NoteSearchResult, error)
+}
+
+// Поля, по которым можно сортировать и фильтровать список заметок.
+const (
+	NoteSortCreated = "created_at"
+	NoteSortUpdated = "updated_at"
+	NoteSortTitle   = "title" // Только для сортировки
+)
+
+// NoteQuery - параметры выборки страницы заметок (NoteStore.QueryNotes).
+type NoteQuery struct {
+	Tag       string    // Только заметки с тегом с этим именем; "" - все
+	Encrypted *bool     // Только зашифрованные (true) или открытые (false) заметки; nil - все
+	DateField string    // Поле для From и To: NoteSortCreated или NoteSortUpdated
+	From      time.Time // Не раньше From (включительно); нулевое время - без ограничения
+	To        time.Time // Раньше To (не включительно); нулевое время - без ограничения
+	Sort      string    // Поле сортировки: NoteSortCreated, NoteSortUpdated или NoteSortTitle
+	Desc      bool      // По убыванию
+	After     *NoteCursor
+	Limit     int // Размер страницы; 0 - без ограничения
+}
+
+// NoteCursor - позиция в упорядоченном списке заметок: значение поля сортировки
+// и ID последней заметки предыдущей страницы. Для сортировки по времени используется
+// Time, по заголовку - Title.
+type NoteCursor struct {
+	Time  time.Time
+	Title string
+	ID    int
+}
+
+// CursorAfter возвращает курсор, указывающий на заметку n при сортировке по полю sort.
+func CursorAfter(n models.Note, sort string) NoteCursor {
+	c := NoteCursor{ID: n.ID}
+	switch sort {
+	case NoteSortUpdated:
+		c.Time = n.UpdatedAt
+	case NoteSortTitle:
+		c.Title = n.Title
+	default:
+		c.Time = n.CreatedAt
+	}
+	return c
+}
+
+// FilterNotes выбирает из notes страницу по q так же, как NoteStore.QueryNotes в БД.
+// Для хранилищ, которые не могут отфильтровать или отсортировать заметки запросом.
+func FilterNotes(notes []models.Note, q NoteQuery) []models.Note {
+	// compare сравнивает заметку с курсором в порядке возрастания поля сортировки и id
+	compare := func(n models.Note, c NoteCursor) int {
+		at := CursorAfter(n, q.Sort)
+		if r := strings.Compare(at.Title, c.Title); q.Sort == NoteSortTitle && r != 0 {
+			return r
+		}
+		if r := at.Time.Compare(c.Time); q.Sort != NoteSortTitle && r != 0 {
+			return r
+		}
+		return cmp.Compare(at.ID, c.ID)
+	}
+
+	page := []models.Note{}
+	for _, n := range notes {
+		date := n.CreatedAt
+		if q.DateField == NoteSortUpdated {
+			date = n.UpdatedAt
+		}
+		switch {
+		case q.Tag != "" && !slices.Contains(n.Tags, q.Tag),
+			q.Encrypted != nil && *q.Encrypted != (n.Encrypted != nil),
+			!q.From.IsZero() && date.Before(q.From),
+			!q.To.IsZero() && !date.Before(q.To):
+			continue
+		}
+		if q.After != nil {
+			if r := compare(n, *q.After); (q.Desc && r >= 0) || (!q.Desc && r <= 0) {
+				continue
+			}
+		}
+		page = append(page, n)
+	}
+
+	sort.Slice(page, func(i, j int) bool {
+		r := compare(page[i], CursorAfter(page[j], q.Sort))
+		if q.Desc {
+			return r > 0
+		}
+		return r < 0
+	})
+	if q.Limit > 0 && len(page) > q.Limit {
+		page = page[:q.Limit]
+	}
+	return page
+}
+
+// TagStore - теги пользователя. Имена тегов уникальны в пределах пользователя
+// (ErrConflict); чужой тег неотличим от несуществующего (ErrNotFound).
+type TagStore interface {
+	// ListTags возвращает теги пользователя по алфавиту с числом заметок у каждого.
+	ListTags(ctx context.Context, userID int) ([]models.Tag, error)
+	GetTag(ctx context.Context, userID, tagID int) (*models.Tag, error)
+	// CreateTag сохраняет тег t.UserID и заполняет t.ID и t.CreatedAt.
+	CreateTag(ctx context.Context, t *models.Tag) error
+	// UpdateTag меняет имя и цвет тега t.ID пользователя t.UserID и заполняет t из БД.
+	// При смене имени версии заметок с этим тегом увеличиваются.
+	UpdateTag(ctx context.Context, t *models.Tag) error
+	// MergeTags переносит тег fromID на все его заметки как intoID и удаляет fromID.
+	// Версии затронутых заметок увеличиваются, как и в DeleteTag.
+	MergeTags(ctx context.Context, userID, fromID, intoID int) error
+	// DeleteTag удаляет тег, снимает его со всех заметок и увеличивает их версии.
+	DeleteTag(ctx context.Context, userID, tagID int) error
+}
+
+// AttachmentStore - сведения о вложениях заметок. Содержимое файлов хранится отдельно
+// (blobstore) под ключом BlobKey. Вложения заметки в корзине недоступны; при окончательном
+// удалении заметки они становятся "осиротевшими" и ждут удаления содержимого.
+type AttachmentStore interface {
+	// CreateAttachment сохраняет вложение к заметке a.NoteID пользователя a.UserID и заполняет
+	// a.ID и a.CreatedAt. Если вместе с ним вложения пользователя займут больше quota байт -
+	// ErrQuotaExceeded; если заметки нет или она в корзине - ErrNotFound.
+	CreateAttachment(ctx context.Context, a *models.Attachment, quota int64) error
+	// ListAttachments возвращает вложения заметки в порядке добавления.
+	ListAttachments(ctx context.Context, userID, noteID int) ([]models.Attachment, error)
+	GetAttachment(ctx context.Context, userID, noteID, attachmentID int) (*models.Attachment, error)
+	// DeleteAttachment отвязывает вложение от заметки и возвращает его. Дальше оно
+	// удаляется как вложение удаленной заметки: содержимое, затем DeleteOrphanAttachment -
+	// сразу вызывающим или, если не вышло, фоновой очисткой.
+	DeleteAttachment(ctx context.Context, userID, noteID, attachmentID int) (*models.Attachment, error)
+	// AttachmentUsage возвращает суммарный размер вложений пользователя в байтах.
+	AttachmentUsage(ctx context.Context, userID int) (int64, error)
+	// ListOrphanAttachments возвращает до limit вложений, отвязанных от заметок (в том
+	// числе окончательно удаленных).
+	ListOrphanAttachments(ctx context.Context, limit int) ([]models.Attachment, error)
+	// DeleteOrphanAttachment удаляет сведения о вложении удаленной заметки после
+	// удаления его содержимого.
+	DeleteOrphanAttachment(ctx context.Context, attachmentID int) error
+}
+
+// ShareStore - публичные ссылки на заметки (models.NoteShare). Методы владельца работают
+// только со ссылками заметок не в корзине (иначе ErrNotFound); при окончательном удалении
+// заметки ее ссылки удаляются.
+type ShareStore interface {
+	// CreateShare сохраняет ссылку на заметку sh.NoteID пользователя sh.UserID и заполняет
+	// sh.ID и sh.CreatedAt.
+	CreateShare(ctx context.Context, sh *models.NoteShare) error
+	// ListShares возвращает ссылки заметки, в том числе отозванные, новые первыми.
+	ListShares(ctx context.Context, userID, noteID int) ([]models.NoteShare, error)
+	// RevokeShare отзывает ссылку и возвращает ее. Повторный отзыв не меняет время отзыва.
+	RevokeShare(ctx context.Context, userID, noteID, shareID int) (*models.NoteShare, error)
+	// GetShareByToken ищет ссылку (в том числе отозванную) по хешу токена.
+	GetShareByToken(ctx context.Context, tokenHash string) (*models.NoteShare, error)
+	// UseShare засчитывает просмотр и сбрасывает счетчик неверных паролей, если к моменту
+	// now ссылка не отозвана, не истекла и лимит просмотров не исчерпан (иначе ErrConflict).
+	UseShare(ctx context.Context, shareID int, now time.Time) error
+	// RecordSharePasswordFailure увеличивает счетчик неверных паролей и возвращает новое значение.
+	RecordSharePasswordFailure(ctx context.Context, shareID int) (int, error)
+}
+
+// UserKeyStore - ключи сквозного шифрования пользователей (models.UserKeys).
+type UserKeyStore interface {
+	// GetUserKeys возвращает ключи пользователя или ErrNotFound, если он их еще не сохранял.
+	GetUserKeys(ctx context.Context, userID int) (*models.UserKeys, error)
+	// SaveUserKeys сохраняет ключи k.UserID и заполняет k значениями из БД. Если k.Version
+	// равен 0, ключи создаются (ErrConflict, если они уже есть); иначе заменяются, только
+	// если их текущая версия равна k.Version (ErrVersionConflict; ErrNotFound, если ключей нет).
+	SaveUserKeys(ctx context.Context, k *models.UserKeys) error
+}
+
+// DataKeyStore - ключи данных для шифрования заметок в БД (models.DataKey) и доступ
+// к заголовкам и текстам в том виде, в каком они хранятся, для их перешифрования.
+type DataKeyStore interface {
+	// ActiveDataKey возвращает действующий ключ пользователя или ErrNotFound.
+	ActiveDataKey(ctx context.Context, userID int) (*models.DataKey, error)
+	// GetDataKey возвращает ключ по ID, в том числе выведенный из оборота.
+	GetDataKey(ctx context.Context, id int) (*models.DataKey, error)
+	// CreateDataKey сохраняет действующий ключ k.UserID и заполняет k.ID и k.CreatedAt.
+	// Если действующий ключ у пользователя уже есть - ErrConflict.
+	CreateDataKey(ctx context.Context, k *models.DataKey) error
+	// RotateDataKey выводит из оборота действующий ключ oldID и сохраняет next действующим
+	// в одной транзакции. Если oldID уже не действующий - ErrConflict.
+	RotateDataKey(ctx context.Context, oldID int, next *models.DataKey) error
+	// ListDataKeysToRewrap возвращает до limit ключей, зашифрованных не мастер-ключом masterKeyID.
+	ListDataKeysToRewrap(ctx context.Context, masterKeyID string, limit int) ([]models.DataKey, error)
+	// RewrapDataKey заменяет MasterKeyID и WrappedKey ключа k.ID, только если он все еще
+	// зашифрован мастер-ключом oldMasterKeyID (иначе ErrVersionConflict).
+	RewrapDataKey(ctx context.Context, k *models.DataKey, oldMasterKeyID string) error
+	// ListExpiredDataKeys возвращает до limit действующих ключей, созданных раньше before.
+	ListExpiredDataKeys(ctx context.Context, before time.Time, limit int) ([]models.DataKey, error)
+	// DeleteRetiredDataKeys удаляет ключи, выведенные из оборота раньше before, которыми
+	// не зашифрована ни одна заметка или ревизия, и возвращает их число.
+	DeleteRetiredDataKeys(ctx context.Context, before time.Time) (int, error)
+	// ListStaleNoteTexts возвращает до limit непустых заголовков и текстов заметок (в том
+	// числе в корзине) и ревизий, которые не зашифрованы действующим ключом их владельца:
+	// открытые, зашифрованные старым ключом или принадлежащие пользователю без ключа.
+	// Тексты упорядочены по (NoteID, Revision) и идут после позиции after; нулевой after -
+	// с начала. Так страница, которую не удалось перешифровать, не загораживает следующие.
+	ListStaleNoteTexts(ctx context.Context, after models.NoteText, limit int) ([]models.NoteText, error)
+	// RewriteNoteText заменяет заголовок, текст и Cipher заметки или ревизии old на next, не
+	// меняя ее версию и время изменения, - только если в БД все еще old (иначе ErrVersionConflict).
+	RewriteNoteText(ctx context.Context, old, next models.NoteText) error
+	// CreateSealedNote сохраняет новую заметку, как NoteStore.CreateNote (ImportNote, если
+	// imported), но в той же транзакции после назначения ID вызывает seal, и заголовок, текст
+	// и Cipher, записанные им в n, заменяют вставленные: шифртекст привязан к ID заметки.
+	// До вызова seal в БД попадают n.Title и n.Content как есть, поэтому их передают пустыми.
+	// seal вызывается внутри транзакции и не должен обращаться к хранилищу.
+	CreateSealedNote(ctx context.Context, n *models.Note, imported bool, seal func(*models.Note) error) error
+}
+
+// TrashStore - корзина: заметки, удаленные через NoteStore.DeleteNote.
+// Методы пользователя работают только с заметками в корзине (иначе ErrNotFound).
+type TrashStore interface {
+	// ListTrash возвращает заметки пользователя в корзине, недавно удаленные первыми.
+	ListTrash(ctx context.Context, userID int) ([]models.Note, error)
+	// RestoreNote достает заметку из корзины и возвращает ее.
+	RestoreNote(ctx context.Context, userID, noteID int) (*models.Note, error)
+	// PurgeNote окончательно удаляет заметку из корзины вместе с ревизиями.
+	PurgeNote(ctx context.Context, userID, noteID int) error
+	// EmptyTrash окончательно удаляет все заметки пользователя в корзине и возвращает их число.
+	EmptyTrash(ctx context.Context, userID int) (int, error)
+	// PurgeTrash окончательно удаляет до limit заметок всех пользователей, попавших
+	// в корзину раньше before, и возвращает их число.
+	PurgeTrash(ctx context.Context, before time.Time, limit int) (int, error)
+}
+
+// TokenStore - серверное хранилище токенов обновления.
+type TokenStore interface {
+	CreateRefreshToken(ctx context.Context, t *models.RefreshToken) error
+	// GetRefreshToken ищет токен (в том числе отозванный) по хешу.
+	GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	// RotateRefreshToken атомарно отзывает токен oldID и сохраняет next в том же семействе.
+	// Если oldID уже отозван (параллельная ротация), возвращает ErrConflict и ничего не сохраняет.
+	RotateRefreshToken(ctx context.Context, oldID int, next *models.RefreshToken) error
+	// RevokeTokenFamily отзывает все действующие токены семейства.
+	RevokeTokenFamily(ctx context.Context, familyID string) error
+}
+
+// RevocationStore - отзыв токенов доступа до истечения их срока.
+type RevocationStore interface {
+	// RevokeToken вносит jti токена в список отозванных до момента expiresAt.
+	// Повторный отзыв того же jti не является ошибкой.
+	RevokeToken(ctx context.Context, jti string, userID int, expiresAt time.Time) error
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+	// RevokeUserTokens делает недействительными все токены доступа пользователя,
+	// выпущенные раньше before (с точностью до секунды, как iat), и отзывает все его токены обновления.
+	RevokeUserTokens(ctx context.Context, userID int, before time.Time) error
+	// TokensValidAfter возвращает границу из RevokeUserTokens (нулевое время, если ее нет).
+	TokensValidAfter(ctx context.Context, userID int) (time.Time, error)
+}
+
+// TwoFactorStore - данные двухфакторной аутентификации (TOTP и коды восстановления).
+type TwoFactorStore interface {
+	// SetTOTPSecret сохраняет секрет, ожидающий подтверждения; 2FA остается выключенной.
+	SetTOTPSecret(ctx context.Context, userID int, secret string) error
+	// EnableTOTP включает 2FA, запоминает использованный шаг counter и заменяет коды восстановления.
+	EnableTOTP(ctx context.Context, userID int, counter int64, recoveryHashes []string) error
+	// DisableTOTP выключает 2FA, удаляет секрет и коды восстановления.
+	DisableTOTP(ctx context.Context, userID int) error
+	// ReplaceRecoveryCodes заменяет все коды восстановления новыми.
+	ReplaceRecoveryCodes(ctx context.Context, userID int, recoveryHashes []string) error
+	// UseTOTPCounter отмечает шаг counter использованным, сбрасывает счетчик ошибок и блокировку.
+	// Возвращает ErrConflict, если шаг не новее последнего использованного (повтор кода).
+	UseTOTPCounter(ctx context.Context, userID int, counter int64) error
+	// UseRecoveryCode гасит неиспользованный код восстановления, сбрасывает счетчик ошибок и блокировку.
+	// Возвращает ErrNotFound, если такого кода нет.
+	UseRecoveryCode(ctx context.Context, userID int, codeHash string) error
+	// RecordTOTPFailure увеличивает счетчик неудачных попыток и возвращает новое значение.
+	// Счетчик не зависит от входа по паролю: его сбрасывает только верный код.
+	RecordTOTPFailure(ctx context.Context, userID int) (int, error)
+	// LockTOTP запрещает ввод второго фактора до момента until.
+	LockTOTP(ctx context.Context, userID int, until time.Time) error
+	// ResetTOTPFailures обнуляет счетчик неудачных попыток и снимает блокировку.
+	ResetTOTPFailures(ctx context.Context, userID int) error
+}
+
+// OutboxStore - очередь писем email_outbox.
+type OutboxStore interface {
+	// EnqueueEmail ставит письмо в очередь и заполняет e.ID, e.Status, e.NextAttemptAt и e.CreatedAt.
+	// Еще не отправленные письма того же вида тому же пользователю отменяются: в них
+	// устаревший код.
+	EnqueueEmail(ctx context.Context, e *models.OutboxEmail) error
+	// ClaimDueEmails забирает до limit писем, срок отправки которых наступил, и откладывает
+	// их следующую попытку на lease, чтобы другой обработчик не отправил их повторно.
+	ClaimDueEmails(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEmail, error)
+	// MarkEmailSent отмечает письмо доставленным и стирает его тело.
+	MarkEmailSent(ctx context.Context, id int) error
+	// MarkEmailFailed записывает неудачную попытку: увеличивает счетчик, сохраняет ошибку
+	// и назначает следующую попытку на next. dead переводит письмо в статус EmailDead
+	// и стирает его тело, как MarkEmailSent.
+	// Письма не в статусе EmailPending (например, отмененные во время отправки) не меняются.
+	MarkEmailFailed(ctx context.Context, id int, errMsg string, next time.Time, dead bool) error
+	// LatestEmail возвращает последнее письмо вида kind пользователю.
+	LatestEmail(ctx context.Context, userID int, kind string) (*models.OutboxEmail, error)
+	// CountEmails возвращает число писем вида kind пользователю, поставленных в очередь
+	// не раньше since (в том числе отмененных).
+	CountEmails(ctx context.Context, userID int, kind string, since time.Time) (int, error)
+}
+
+// Store объединяет все интерфейсы хранилища.
+type Store interface {
+	UserStore
+	NoteStore
+	TagStore
+	TrashStore
+	AttachmentStore
+	ShareStore
+	UserKeyStore
+	DataKeyStore
+	TokenStore
+	RevocationStore
+	TwoFactorStore
+	OutboxStore
+}
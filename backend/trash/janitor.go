@@ -0,0 +1,103 @@
+// Package trash окончательно удаляет заметки, пролежавшие в корзине дольше
+// срока хранения (config.TrashConfig), и содержимое вложений удаленных заметок.
+package trash
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"diary-backend/blobstore"
+	"diary-backend/config"
+	"diary-backend/store"
+)
+
+// batchSize - заметок, удаляемых одним запросом.
+const batchSize = 500
+
+// Janitor периодически очищает корзину.
+type Janitor struct {
+	trash       store.TrashStore
+	attachments store.AttachmentStore
+	blobs       blobstore.BlobStore
+	cfg         config.TrashConfig
+}
+
+// NewJanitor создает фоновую очистку корзины.
+func NewJanitor(trash store.TrashStore, attachments store.AttachmentStore, blobs blobstore.BlobStore, cfg config.TrashConfig) *Janitor {
+	return &Janitor{trash: trash, attachments: attachments, blobs: blobs, cfg: cfg}
+}
+
+// Run удаляет просроченные заметки сразу и затем каждые cfg.PurgeInterval, пока ctx не отменен.
+func (j *Janitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.cfg.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		j.purge(ctx)
+		j.removeOrphans(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purge удаляет пачками все заметки, срок хранения которых истек.
+func (j *Janitor) purge(ctx context.Context) {
+	before := time.Now().Add(-j.cfg.Retention)
+	total := 0
+	for {
+		n, err := j.trash.PurgeTrash(ctx, before, batchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Ошибка очистки корзины: %v", err)
+			}
+			break
+		}
+		total += n
+		if n < batchSize {
+			break
+		}
+	}
+	if total > 0 {
+		log.Printf("Из корзины окончательно удалено заметок: %d", total)
+	}
+}
+
+// removeOrphans удаляет содержимое вложений окончательно удаленных заметок - и
+// удаленных этим проходом, и удаленных пользователем из корзины, - а затем их записи.
+// Вложение, содержимое которого удалить не удалось, останется до следующего прохода.
+func (j *Janitor) removeOrphans(ctx context.Context) {
+	total := 0
+	for {
+		orphans, err := j.attachments.ListOrphanAttachments(ctx, batchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Ошибка получения вложений удаленных заметок: %v", err)
+			}
+			break
+		}
+		removed := 0
+		for _, a := range orphans {
+			if err := j.blobs.Delete(ctx, a.BlobKey); err != nil {
+				log.Printf("Ошибка удаления содержимого вложения %d: %v", a.ID, err)
+				continue
+			}
+			if err := j.attachments.DeleteOrphanAttachment(ctx, a.ID); err != nil {
+				log.Printf("Ошибка удаления вложения %d: %v", a.ID, err)
+				continue
+			}
+			removed++
+		}
+		total += removed
+		// Пачка не полная или не удалось удалить ничего - повторять нет смысла
+		if len(orphans) < batchSize || removed == 0 {
+			break
+		}
+	}
+	if total > 0 {
+		log.Printf("Удалено вложений окончательно удаленных заметок: %d", total)
+	}
+}
@@ -4,38 +4,89 @@ import (
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+
+	"diary-backend/config"
 )
 
-// Ключ для подписи токена.
-var jwtKey = []byte("my_super_secret_jwt_key_that_is_very_long_and_complex_12345")
+// PurposeMFA - назначение промежуточного токена, выдаваемого после проверки пароля
+// аккаунту с двухфакторной аутентификацией. Такой токен не дает доступа к API.
+const PurposeMFA = "mfa"
 
 // Claims определяет структуру полезной нагрузки токена
 type Claims struct {
-	UserID	int	`json:"user_id"`
+	UserID  int    `json:"user_id"`
+	Purpose string `json:"purpose,omitempty"` // Пусто для токенов доступа
 	jwt.StandardClaims
 }
 
-// GenerateToken создает JWT для указанного UserID
-func GenerateToken(userID int) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour) //Токен действителен 24 часа
+// TokenManager выпускает и проверяет JWT с ключом и временем жизни из конфигурации
+type TokenManager struct {
+	key        []byte        // Ключ для подписи токена
+	ttl        time.Duration // Время жизни токена доступа
+	refreshTTL time.Duration // Время жизни токена обновления
+}
+
+// NewTokenManager создает TokenManager по секции jwt конфигурации
+func NewTokenManager(cfg config.JWTConfig) *TokenManager {
+	return &TokenManager{key: []byte(cfg.Secret), ttl: cfg.TTL, refreshTTL: cfg.RefreshTTL}
+}
+
+// TTL возвращает время жизни токена доступа
+func (m *TokenManager) TTL() time.Duration { return m.ttl }
+
+// RefreshTTL возвращает время жизни токена обновления
+func (m *TokenManager) RefreshTTL() time.Duration { return m.refreshTTL }
+
+// GenerateToken создает JWT доступа для указанного UserID
+func (m *TokenManager) GenerateToken(userID int) (string, error) {
+	return m.generate(userID, "", m.ttl)
+}
+
+// GenerateMFAToken создает промежуточный токен для второго шага входа
+func (m *TokenManager) GenerateMFAToken(userID int, ttl time.Duration) (string, error) {
+	return m.generate(userID, PurposeMFA, ttl)
+}
+
+func (m *TokenManager) generate(userID int, purpose string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	expirationTime := now.Add(ttl)
+
+	// jti нужен, чтобы отозвать конкретный токен при выходе
+	jti, err := randomString(16)
+	if err != nil {
+		return "", err
+	}
 
 	claims := &Claims{
-		UserID: userID,
+		UserID:  userID,
+		Purpose: purpose,
 		StandardClaims: jwt.StandardClaims{
+			Id:        jti,
+			IssuedAt:  now.Unix(),
 			ExpiresAt: expirationTime.Unix(),
 		},
 	}
 
 	// Создаем токен и подписываем его нашим секретным ключем
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtKey)
+	return token.SignedString(m.key)
+}
+
+// ValidateToken проверяет JWT доступа. Токены с другим назначением отклоняются.
+func (m *TokenManager) ValidateToken(tokenString string) (*Claims, error) {
+	return m.validate(tokenString, "")
 }
 
-func ValidateToken(tokenString string) (*Claims, error) {
+// ValidateMFAToken проверяет промежуточный токен второго шага входа
+func (m *TokenManager) ValidateMFAToken(tokenString string) (*Claims, error) {
+	return m.validate(tokenString, PurposeMFA)
+}
+
+func (m *TokenManager) validate(tokenString, purpose string) (*Claims, error) {
 	claims := &Claims{}
 
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return jwtKey, nil // Возвращаем ключ для проверки подписи
+		return m.key, nil // Возвращаем ключ для проверки подписи
 	})
 
 	if err != nil {
@@ -46,5 +97,9 @@ func ValidateToken(tokenString string) (*Claims, error) {
 		return nil, jwt.NewValidationError("Токен не действителен", jwt.ValidationErrorSignatureInvalid)
 	}
 
+	if claims.Purpose != purpose {
+		return nil, jwt.NewValidationError("Токен предназначен для другой операции", jwt.ValidationErrorClaimsInvalid)
+	}
+
 	return claims, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// GenerateRefreshToken создает случайный токен обновления и его хеш для хранения в БД.
+func GenerateRefreshToken() (token, hash string, err error) {
+	token, err = randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	return token, HashRefreshToken(token), nil
+}
+
+// HashRefreshToken возвращает SHA-256 токена в hex. Токен содержит 256 бит случайности,
+// поэтому медленный хеш (bcrypt) здесь не нужен, а поиск по хешу остается индексным.
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateTokenFamily создает идентификатор семейства токенов обновления.
+func GenerateTokenFamily() (string, error) {
+	return randomString(16)
+}
+
+// randomString возвращает n случайных байт из crypto/rand в base64url без выравнивания.
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
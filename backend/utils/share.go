@@ -0,0 +1,16 @@
+package utils
+
+// GenerateShareToken создает токен публичной ссылки на заметку и его хеш для хранения в БД.
+func GenerateShareToken() (token, hash string, err error) {
+	token, err = randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	return token, HashShareToken(token), nil
+}
+
+// HashShareToken возвращает SHA-256 токена ссылки в hex - по тем же причинам, что
+// и HashRefreshToken.
+func HashShareToken(token string) string {
+	return HashRefreshToken(token)
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret - ключ "12345678901234567890" из тестовых векторов RFC 4226 и RFC 6238 (SHA-1) в base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestHOTPVectors(t *testing.T) {
+	// RFC 4226, приложение D
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for counter, code := range want {
+		if got := hotp([]byte("12345678901234567890"), int64(counter)); got != code {
+			t.Errorf("HOTP(%d) = %s, ожидался %s", counter, got, code)
+		}
+	}
+}
+
+func TestValidateTOTPVectors(t *testing.T) {
+	// RFC 6238, приложение B (SHA-1): последние 6 цифр 8-значных кодов
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		counter, ok := ValidateTOTP(rfcSecret, tt.code, time.Unix(tt.unix, 0))
+		if !ok || counter != tt.unix/totpPeriod {
+			t.Errorf("ValidateTOTP(%s, %d) = %d, %v; ожидался шаг %d", tt.code, tt.unix, counter, ok, tt.unix/totpPeriod)
+		}
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	at := time.Unix(1111111109, 0) // Шаг 37037036, код 081804
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		shift  time.Duration
+		want   bool
+	}{
+		{"тот же шаг", rfcSecret, "081804", 0, true},
+		{"шаг назад", rfcSecret, "081804", totpPeriod * time.Second, true},
+		{"шаг вперед", rfcSecret, "081804", -totpPeriod * time.Second, true},
+		{"два шага назад", rfcSecret, "081804", 2 * totpPeriod * time.Second, false},
+		{"секрет в нижнем регистре и с =", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq====", "081804", 0, true},
+		{"неверный код", rfcSecret, "081805", 0, false},
+		{"короткий код", rfcSecret, "81804", 0, false},
+		{"испорченный секрет", "not base32!", "081804", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := ValidateTOTP(tt.secret, tt.code, at.Add(tt.shift)); ok != tt.want {
+				t.Errorf("ValidateTOTP = %v, ожидалось %v", ok, tt.want)
+			}
+		})
+	}
+}
@@ -1,13 +1,38 @@
 package utils
 
 import (
-    "fmt"
-    "math/rand"
-    "time"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"math/big"
 )
 
-// GenerateVerificationCode создает 6-значный код.
+// GenerateVerificationCode создает 6-значный код из криптографически стойкого генератора.
 func GenerateVerificationCode() string {
-    rand.Seed(time.Now().UnixNano()) 
-    return fmt.Sprintf("%06d", rand.Intn(1000000))
-}
\ No newline at end of file
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		panic(fmt.Sprintf("crypto/rand недоступен: %v", err))
+	}
+	return fmt.Sprintf("%06d", n.Int64())
+}
+
+// HashVerificationCode возвращает HMAC-SHA256 кода с ключом secret в hex. В БД хранится
+// только хеш, сам код знает лишь получатель письма. Без ключа все 10^6 кодов перебираются
+// мгновенно, поэтому хеш без secret по утечке БД не восстановить.
+func HashVerificationCode(secret, code string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CheckVerificationCode сравнивает код с сохраненным хешем за постоянное время.
+// Пустой хеш (код не выдавался или уже использован) не совпадает ни с чем.
+func CheckVerificationCode(secret, hash, code string) bool {
+	if hash == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(HashVerificationCode(secret, code))) == 1
+}
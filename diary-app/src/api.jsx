@@ -31,18 +31,30 @@ export const loginUser = async (email, password) => {
     body: JSON.stringify({ username: email, password }),
   });
 
+  if (response.status === 429) {
+    // Ввод второго фактора заблокирован после неверных кодов
+    throw new Error(await response.text());
+  }
   if (!response.ok) {
     throw new Error("Неверное имя пользователя или пароль");
   }
 
   const data = await response.json();
 
-  // Сохраняем JWT-токен
-  localStorage.setItem("authToken", data.token);
+  // Сохраняем JWT-токен и токен обновления
+  saveTokens(data);
 
   return data;
 };
 
+// Сохраняет пару токенов из ответа /login, /verify или /auth/refresh
+export const saveTokens = (data) => {
+  localStorage.setItem("authToken", data.token);
+  if (data.refresh_token) {
+    localStorage.setItem("refreshToken", data.refresh_token);
+  }
+};
+
 // --- Вспомогательная функция для защищенных запросов ---
 
 const getAuthHeaders = () => {
@@ -53,12 +65,63 @@ const getAuthHeaders = () => {
   };
 };
 
+// Обменивает токен обновления на новую пару токенов. Возвращает false, если сессия завершена.
+const refreshTokens = async () => {
+  const refreshToken = localStorage.getItem("refreshToken");
+  if (!refreshToken) {
+    return false;
+  }
+
+  const response = await fetch(`${API_BASE_URL}/auth/refresh`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ refresh_token: refreshToken }),
+  });
+
+  if (!response.ok) {
+    localStorage.removeItem("authToken");
+    localStorage.removeItem("refreshToken");
+    return false;
+  }
+
+  saveTokens(await response.json());
+  return true;
+};
+
+// fetch с JWT: при истекшем токене доступа один раз обновляет токены и повторяет запрос
+const authFetch = async (url, options = {}) => {
+  const send = () => {
+    // Токен читается заново: после обновления он уже другой
+    const headers = { ...getAuthHeaders(), ...options.headers };
+    if (options.body instanceof FormData) {
+      delete headers["Content-Type"]; // Content-Type с границей формы выставит браузер
+    }
+    return fetch(url, { ...options, headers });
+  };
+  let response = await send();
+  if (response.status === 401 && (await refreshTokens())) {
+    response = await send();
+  }
+  return response;
+};
+
 // --- Функции для заметок (CRUD) ---
 
-export const getNotes = async () => {
-  const response = await fetch(`${API_BASE_URL}/notes`, {
+// Размер страницы списка заметок. Без limit (и cursor) сервер отдает все заметки сразу.
+const NOTES_PAGE_SIZE = 50;
+
+// Загружает страницу заметок. params: tag, cursor, limit, sort, order, from, to.
+// Возвращает { notes, nextCursor }; nextCursor === null - это последняя страница.
+export const getNotes = async (params = {}) => {
+  const query = new URLSearchParams();
+  Object.entries({ limit: NOTES_PAGE_SIZE, ...params }).forEach(([key, value]) => {
+    if (value) {
+      query.set(key, value);
+    }
+  });
+
+  const response = await authFetch(`${API_BASE_URL}/notes?${query}`, {
     method: "GET",
-    headers: getAuthHeaders(),
   });
 
   if (response.status === 401) {
@@ -71,13 +134,30 @@ export const getNotes = async () => {
     throw new Error("Ошибка получения заметок");
   }
 
+  return {
+    notes: await response.json(),
+    nextCursor: response.headers.get("X-Next-Cursor"),
+  };
+};
+
+// Полнотекстовый поиск на сервере: "фразы в кавычках" и префиксы (слово*).
+// В ответе title_highlight и snippet - экранированный HTML с <mark> вокруг совпадений.
+export const searchNotes = async (query) => {
+  const response = await authFetch(
+    `${API_BASE_URL}/notes/search?q=${encodeURIComponent(query)}`,
+    { method: "GET" }
+  );
+
+  if (!response.ok) {
+    throw new Error("Ошибка поиска заметок");
+  }
+
   return response.json();
 };
 
 export const createNote = async (noteData) => {
-  const response = await fetch(`${API_BASE_URL}/notes`, {
+  const response = await authFetch(`${API_BASE_URL}/notes`, {
     method: "POST",
-    headers: getAuthHeaders(),
     body: JSON.stringify(noteData),
   });
 
@@ -88,25 +168,29 @@ export const createNote = async (noteData) => {
   return response.json();
 };
 
-// Функция для обновления заметки
-export const updateNoteApi = async (noteId, noteData) => {
+// Функция для обновления заметки. version - версия, которую редактировал пользователь:
+// если заметку успели изменить на другом устройстве, сервер ответит 412, и ошибка
+// будет содержать актуальную копию заметки в поле current.
+export const updateNoteApi = async (noteId, noteData, version) => {
   const token = localStorage.getItem("authToken");
   if (!token) {
     throw new Error("Unauthorized");
   }
 
-  const response = await fetch(`${API_BASE_URL}/notes/${noteId}`, {
+  const response = await authFetch(`${API_BASE_URL}/notes/${noteId}`, {
     method: "PUT",
-    headers: {
-      "Content-Type": "application/json",
-      Authorization: `Bearer ${token}`,
-    },
+    headers: version ? { "If-Match": `"${version}"` } : {},
     body: JSON.stringify(noteData),
   });
 
   if (response.status === 401) {
     throw new Error("Unauthorized");
   }
+  if (response.status === 412) {
+    const conflict = new Error("Note was changed on another device");
+    conflict.current = await response.json();
+    throw conflict;
+  }
   if (!response.ok) {
     // Go-бэкенд возвращает 404, если заметка не найдена/не принадлежит пользователю
     if (response.status === 404) {
@@ -120,20 +204,289 @@ export const updateNoteApi = async (noteId, noteData) => {
 };
 
 export const deleteNoteApi = async (noteId) => {
-  const response = await fetch(`${API_BASE_URL}/notes/${noteId}`, {
+  const response = await authFetch(`${API_BASE_URL}/notes/${noteId}`, {
     method: "DELETE",
-    headers: getAuthHeaders(),
   });
 
   if (!response.ok) {
     throw new Error("Ошибка удаления заметки");
   }
 
-  // Удаление обычно возвращает 204 No Content
+  // Заметка перемещается в корзину; ответ - 204 No Content
   return;
 };
 
-export const logoutUser = () => {
+// --- Корзина: удаленные заметки хранятся на сервере до окончательного удаления ---
+
+export const getTrash = async () => {
+  const response = await authFetch(`${API_BASE_URL}/notes/trash`, {
+    method: "GET",
+  });
+  await checkResponse(response, "Ошибка получения корзины");
+  return response.json();
+};
+
+export const restoreNoteApi = async (noteId) => {
+  const response = await authFetch(`${API_BASE_URL}/notes/${noteId}/restore`, {
+    method: "POST",
+  });
+  await checkResponse(response, "Ошибка восстановления заметки");
+  return response.json();
+};
+
+export const purgeNoteApi = async (noteId) => {
+  const response = await authFetch(`${API_BASE_URL}/notes/trash/${noteId}`, {
+    method: "DELETE",
+  });
+  await checkResponse(response, "Ошибка удаления заметки");
+};
+
+export const emptyTrash = async () => {
+  const response = await authFetch(`${API_BASE_URL}/notes/trash`, {
+    method: "DELETE",
+  });
+  await checkResponse(response, "Ошибка очистки корзины");
+  return response.json();
+};
+
+// --- Выгрузка дневника ---
+
+// Скачивает весь дневник файлом: format - "json", "markdown" (ZIP) или "csv"
+export const exportDiary = async (format) => {
+  const response = await authFetch(`${API_BASE_URL}/export?format=${format}`, {
+    method: "GET",
+  });
+  await checkResponse(response, "Ошибка выгрузки дневника");
+
+  const disposition = response.headers.get("Content-Disposition") || "";
+  const match = disposition.match(/filename="([^"]+)"/);
+  const url = URL.createObjectURL(await response.blob());
+  const link = document.createElement("a");
+  link.href = url;
+  link.download = match ? match[1] : `diary.${format}`;
+  link.click();
+  URL.revokeObjectURL(url);
+};
+
+// --- Импорт из других дневников ---
+
+// Загружает файл импорта: ZIP с Markdown, выгрузку DiaryApp в JSON или архив Day One.
+// format - "markdown", "json", "dayone" или "" (определить по содержимому).
+// Возвращает отчет { imported, skipped, failed, items }.
+export const importDiary = async (file, format) => {
+  const form = new FormData();
+  form.append("file", file);
+  if (format) {
+    form.append("format", format);
+  }
+  const response = await authFetch(`${API_BASE_URL}/import`, {
+    method: "POST",
+    body: form,
+  });
+  await checkResponse(response, "Ошибка импорта");
+  return response.json();
+};
+
+// --- История изменений заметки ---
+
+export const getNoteRevisions = async (noteId) => {
+  const response = await authFetch(`${API_BASE_URL}/notes/${noteId}/revisions`, {
+    method: "GET",
+  });
+  if (!response.ok) {
+    throw new Error("Ошибка получения истории заметки");
+  }
+  return response.json();
+};
+
+// Построчное сравнение двух ревизий в формате diff -u
+export const getRevisionDiff = async (noteId, from, to) => {
+  const response = await authFetch(
+    `${API_BASE_URL}/notes/${noteId}/revisions/diff?from=${from}&to=${to}&format=unified`,
+    { method: "GET" }
+  );
+  if (!response.ok) {
+    throw new Error("Ошибка сравнения ревизий");
+  }
+  return response.text();
+};
+
+// Возвращает заметку к ревизии; восстановление само становится новой ревизией
+export const restoreRevision = async (noteId, revision) => {
+  const response = await authFetch(
+    `${API_BASE_URL}/notes/${noteId}/revisions/${revision}/restore`,
+    { method: "POST" }
+  );
+  if (!response.ok) {
+    throw new Error("Ошибка восстановления ревизии");
+  }
+  return response.json();
+};
+
+// --- Вложения заметки ---
+
+export const getAttachments = async (noteId) => {
+  const response = await authFetch(`${API_BASE_URL}/notes/${noteId}/attachments`, {
+    method: "GET",
+  });
+  await checkResponse(response, "Ошибка получения вложений");
+  return response.json();
+};
+
+// Бросает ошибку с текстом ответа сервера (413 - файл больше лимита или превышена квота,
+// 415 - тип файла не поддерживается)
+export const uploadAttachment = async (noteId, file) => {
+  const form = new FormData();
+  form.append("file", file);
+  const response = await authFetch(`${API_BASE_URL}/notes/${noteId}/attachments`, {
+    method: "POST",
+    body: form,
+  });
+  await checkResponse(response, "Ошибка загрузки файла");
+  return response.json();
+};
+
+export const deleteAttachment = async (noteId, attachmentId) => {
+  const response = await authFetch(
+    `${API_BASE_URL}/notes/${noteId}/attachments/${attachmentId}`,
+    { method: "DELETE" }
+  );
+  await checkResponse(response, "Ошибка удаления вложения");
+};
+
+// Содержимое вложения как object URL (запрос требует токен, поэтому <img src> и
+// обычная ссылка не подходят). Вызывающий освобождает URL через URL.revokeObjectURL.
+export const getAttachmentUrl = async (noteId, attachmentId) => {
+  const response = await authFetch(
+    `${API_BASE_URL}/notes/${noteId}/attachments/${attachmentId}`,
+    { method: "GET" }
+  );
+  await checkResponse(response, "Ошибка загрузки вложения");
+  return URL.createObjectURL(await response.blob());
+};
+
+// --- Публичные ссылки на заметку ---
+
+export const getShares = async (noteId) => {
+  const response = await authFetch(`${API_BASE_URL}/notes/${noteId}/shares`, {
+    method: "GET",
+  });
+  await checkResponse(response, "Ошибка получения ссылок");
+  return response.json();
+};
+
+// options: { expires_at, max_views, password } - все необязательны. В ответе поле url
+// (путь /s/<токен>) есть только сейчас: сервер хранит лишь хеш токена
+export const createShare = async (noteId, options) => {
+  const response = await authFetch(`${API_BASE_URL}/notes/${noteId}/shares`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify(options),
+  });
+  await checkResponse(response, "Ошибка создания ссылки");
+  const share = await response.json();
+  return { ...share, url: API_BASE_URL.replace(/\/$/, "") + share.url };
+};
+
+export const revokeShare = async (noteId, shareId) => {
+  const response = await authFetch(`${API_BASE_URL}/notes/${noteId}/shares/${shareId}`, {
+    method: "DELETE",
+  });
+  await checkResponse(response, "Ошибка отзыва ссылки");
+};
+
+// --- Ключи сквозного шифрования ---
+
+// Возвращает ключи с полем version или null, если шифрование еще не включено
+export const getUserKeys = async () => {
+  const response = await authFetch(`${API_BASE_URL}/e2ee/keys`, { method: "GET" });
+  if (response.status === 404) {
+    return null;
+  }
+  await checkResponse(response, "Ошибка получения ключей шифрования");
+  return response.json();
+};
+
+// Сохраняет ключи. version - версия заменяемых ключей (смена парольной фразы);
+// без нее сервер только создает ключи и не даст перезаписать существующие.
+export const saveUserKeys = async (keys, version) => {
+  const response = await authFetch(`${API_BASE_URL}/e2ee/keys`, {
+    method: "PUT",
+    headers: version ? { "If-Match": `"${version}"` } : {},
+    body: JSON.stringify(keys),
+  });
+  await checkResponse(response, "Ошибка сохранения ключей шифрования");
+  return response.json();
+};
+
+// --- Функции для тегов ---
+
+// Бросает ошибку с текстом ответа сервера (например, 409 при совпадении имен тегов)
+const checkResponse = async (response, fallback) => {
+  if (!response.ok) {
+    const errorText = await response.text();
+    throw new Error(errorText || fallback);
+  }
+  return response;
+};
+
+export const getTags = async () => {
+  const response = await authFetch(`${API_BASE_URL}/tags`, { method: "GET" });
+  await checkResponse(response, "Ошибка получения тегов");
+  return response.json();
+};
+
+export const createTag = async (tagData) => {
+  const response = await authFetch(`${API_BASE_URL}/tags`, {
+    method: "POST",
+    body: JSON.stringify(tagData),
+  });
+  await checkResponse(response, "Ошибка создания тега");
+  return response.json();
+};
+
+// Переименование и смена цвета; новое имя сразу видно во всех заметках
+export const updateTag = async (tagId, tagData) => {
+  const response = await authFetch(`${API_BASE_URL}/tags/${tagId}`, {
+    method: "PATCH",
+    body: JSON.stringify(tagData),
+  });
+  await checkResponse(response, "Ошибка изменения тега");
+  return response.json();
+};
+
+// Переносит все заметки с тега tagId на тег intoId и удаляет tagId
+export const mergeTags = async (tagId, intoId) => {
+  const response = await authFetch(`${API_BASE_URL}/tags/${tagId}/merge`, {
+    method: "POST",
+    body: JSON.stringify({ into: intoId }),
+  });
+  await checkResponse(response, "Ошибка объединения тегов");
+  return response.json();
+};
+
+export const deleteTag = async (tagId) => {
+  const response = await authFetch(`${API_BASE_URL}/tags/${tagId}`, {
+    method: "DELETE",
+  });
+  await checkResponse(response, "Ошибка удаления тега");
+};
+
+export const logoutUser = async () => {
+  // Отзываем токены на сервере; локальный выход выполняется в любом случае
+  try {
+    await fetch(`${API_BASE_URL}/auth/logout`, {
+      method: "POST",
+      headers: getAuthHeaders(),
+      body: JSON.stringify({
+        refresh_token: localStorage.getItem("refreshToken") || "",
+      }),
+    });
+  } catch (err) {
+    console.error("Ошибка при выходе:", err);
+  }
+
   localStorage.removeItem("authToken");
+  localStorage.removeItem("refreshToken");
   window.location.href = "/auth";
 };
@@ -0,0 +1,127 @@
+// Сквозное шифрование заметок (Web Crypto API). Сервер получает только шифртекст:
+// заголовок и текст шифруются случайным ключом заметки (AES-256-GCM), ключ заметки -
+// мастер-ключом пользователя (AES-KW), мастер-ключ - ключом из парольной фразы (PBKDF2).
+// Мастер-ключ живет только в памяти вкладки и после перезагрузки запрашивается снова.
+
+const PBKDF2_ITERATIONS = 600000; // Не меньше минимума сервера
+const SALT_SIZE = 16;
+const NONCE_SIZE = 12;
+
+// Кусками: String.fromCharCode с сотнями тысяч аргументов переполняет стек
+const toBase64 = (buffer) => {
+  const bytes = new Uint8Array(buffer);
+  let binary = "";
+  for (let i = 0; i < bytes.length; i += 0x8000) {
+    binary += String.fromCharCode(...bytes.subarray(i, i + 0x8000));
+  }
+  return btoa(binary);
+};
+
+const fromBase64 = (text) => Uint8Array.from(atob(text), (c) => c.charCodeAt(0));
+
+const randomBytes = (size) => crypto.getRandomValues(new Uint8Array(size));
+
+// Ключ для шифрования мастер-ключа, выведенный из парольной фразы
+const deriveWrappingKey = async (passphrase, keys) => {
+  if (keys.kdf !== "PBKDF2-SHA256") {
+    throw new Error(`Функция ${keys.kdf} не поддерживается этим клиентом`);
+  }
+  const material = await crypto.subtle.importKey(
+    "raw",
+    new TextEncoder().encode(passphrase),
+    "PBKDF2",
+    false,
+    ["deriveKey"]
+  );
+  return crypto.subtle.deriveKey(
+    {
+      name: "PBKDF2",
+      hash: "SHA-256",
+      salt: fromBase64(keys.salt),
+      iterations: keys.iterations,
+    },
+    material,
+    { name: "AES-KW", length: 256 },
+    false,
+    ["wrapKey", "unwrapKey"]
+  );
+};
+
+// Создает мастер-ключ. Возвращает его и данные для PUT /e2ee/keys.
+export const createKeys = async (passphrase) => {
+  const keys = {
+    kdf: "PBKDF2-SHA256",
+    iterations: PBKDF2_ITERATIONS,
+    salt: toBase64(randomBytes(SALT_SIZE)),
+  };
+  const masterKey = await crypto.subtle.generateKey(
+    { name: "AES-KW", length: 256 },
+    true, // Извлекаемый: иначе его нельзя зашифровать для сервера
+    ["wrapKey", "unwrapKey"]
+  );
+  const wrappingKey = await deriveWrappingKey(passphrase, keys);
+  keys.wrapped_master_key = toBase64(
+    await crypto.subtle.wrapKey("raw", masterKey, wrappingKey, "AES-KW")
+  );
+  return { masterKey, keys };
+};
+
+// Расшифровывает мастер-ключ из ответа GET /e2ee/keys. Неверная парольная фраза
+// не проходит проверку целостности AES-KW.
+export const unlockKeys = async (passphrase, keys) => {
+  const wrappingKey = await deriveWrappingKey(passphrase, keys);
+  try {
+    return await crypto.subtle.unwrapKey(
+      "raw",
+      fromBase64(keys.wrapped_master_key),
+      wrappingKey,
+      "AES-KW",
+      { name: "AES-KW", length: 256 },
+      true,
+      ["wrapKey", "unwrapKey"]
+    );
+  } catch (err) {
+    throw new Error("Неверная парольная фраза");
+  }
+};
+
+// Шифрует заголовок и текст заметки; результат - поле encrypted для API
+export const encryptNote = async (masterKey, { title, content }) => {
+  const noteKey = await crypto.subtle.generateKey(
+    { name: "AES-GCM", length: 256 },
+    true,
+    ["encrypt", "decrypt"]
+  );
+  const nonce = randomBytes(NONCE_SIZE);
+  const ciphertext = await crypto.subtle.encrypt(
+    { name: "AES-GCM", iv: nonce },
+    noteKey,
+    new TextEncoder().encode(JSON.stringify({ title, content }))
+  );
+  const wrappedKey = await crypto.subtle.wrapKey("raw", noteKey, masterKey, "AES-KW");
+  return {
+    alg: "AES-256-GCM",
+    ciphertext: toBase64(ciphertext),
+    nonce: toBase64(nonce),
+    wrapped_key: toBase64(wrappedKey),
+  };
+};
+
+// Расшифровывает поле encrypted заметки или ревизии в { title, content }
+export const decryptNote = async (masterKey, envelope) => {
+  const noteKey = await crypto.subtle.unwrapKey(
+    "raw",
+    fromBase64(envelope.wrapped_key),
+    masterKey,
+    "AES-KW",
+    { name: "AES-GCM", length: 256 },
+    false,
+    ["decrypt"]
+  );
+  const plaintext = await crypto.subtle.decrypt(
+    { name: "AES-GCM", iv: fromBase64(envelope.nonce) },
+    noteKey,
+    fromBase64(envelope.ciphertext)
+  );
+  return JSON.parse(new TextDecoder().decode(plaintext));
+};
@@ -1,12 +1,36 @@
-import React, { useState, useEffect, useCallback } from "react";
+import React, { useState, useEffect, useCallback, useRef } from "react";
 import { Link, useNavigate } from "react-router-dom";
 import {
   getNotes,
+  searchNotes,
   createNote,
   updateNoteApi,
   deleteNoteApi,
+  getTrash,
+  restoreNoteApi,
+  purgeNoteApi,
+  emptyTrash,
+  exportDiary,
+  importDiary,
+  getTags,
+  getNoteRevisions,
+  getRevisionDiff,
+  restoreRevision,
+  getAttachments,
+  uploadAttachment,
+  deleteAttachment,
+  getAttachmentUrl,
+  getShares,
+  createShare,
+  revokeShare,
+  getUserKeys,
+  saveUserKeys,
+  updateTag,
+  mergeTags,
+  deleteTag,
   logoutUser,
 } from "../api";
+import { createKeys, unlockKeys, encryptNote, decryptNote } from "../e2ee";
 import "../styles/main.css";
 import "../styles/libs/bootstrap-grid.min.css";
 import "../styles/libs/bootstrap-reboot.min.css";
@@ -65,6 +89,782 @@ const Navbar = ({ handleLogout, isMenuOpen, toggleMenu }) => {
   );
 };
 
+// Приводит заметки от Go-бэкенда к формату карточек
+const formatNotes = (data) =>
+  data.map((note) => ({
+    ...note,
+    // Используем 'created_at' от Go-бэкенда в качестве основной даты
+    date: note.created_at || note.updated_at,
+    tags: note.tags || [],
+  }));
+
+const DEFAULT_TAG_COLOR = "#6b7280";
+
+// Стиль метки тега: выбранный тег залит своим цветом, остальные - светлым оттенком
+const tagStyle = (color, selected = false) => {
+  const base = color || DEFAULT_TAG_COLOR;
+  return selected
+    ? { backgroundColor: base, color: "white" }
+    : { backgroundColor: `${base}22`, color: base };
+};
+
+// Выбор тегов заметки: существующие теги пользователя и поле для нового тега
+const TagPicker = ({ tags, selected, onChange }) => {
+  const [newTag, setNewTag] = useState("");
+  const colors = Object.fromEntries(tags.map((t) => [t.name, t.color]));
+  // Новые теги, еще не сохраненные на сервере, тоже показываем
+  const names = [...new Set([...tags.map((t) => t.name), ...selected])];
+
+  const toggle = (name) =>
+    onChange(
+      selected.includes(name)
+        ? selected.filter((t) => t !== name)
+        : [...selected, name]
+    );
+
+  const addTag = () => {
+    const name = newTag.trim();
+    if (name && !selected.includes(name)) {
+      onChange([...selected, name]);
+    }
+    setNewTag("");
+  };
+
+  return (
+    <div className="tags-container">
+      {names.map((name) => (
+        <label key={name} className="tag-option">
+          <input
+            type="checkbox"
+            className="tag-radio"
+            checked={selected.includes(name)}
+            onChange={() => toggle(name)}
+          />
+          <span
+            className="tag-label"
+            style={tagStyle(colors[name], selected.includes(name))}
+          >
+            {name}
+          </span>
+        </label>
+      ))}
+      <input
+        type="text"
+        className="form-input tag-new-input"
+        placeholder="New tag + Enter"
+        value={newTag}
+        onChange={(e) => setNewTag(e.target.value)}
+        onKeyDown={(e) => {
+          if (e.key === "Enter") {
+            e.preventDefault(); // Не отправлять форму заметки
+            addTag();
+          }
+        }}
+      />
+    </div>
+  );
+};
+
+// Управление тегами: переименование, цвет, объединение и удаление
+const TagManager = ({ tags, onRename, onColor, onMerge, onDelete }) => {
+  if (tags.length === 0) {
+    return <p>Тегов пока нет. Добавьте тег к заметке, и он появится здесь.</p>;
+  }
+
+  return (
+    <div className="tag-manager">
+      {tags.map((tag) => (
+        <div key={tag.id} className="tag-manager-row">
+          <input
+            type="color"
+            defaultValue={tag.color || DEFAULT_TAG_COLOR}
+            // onBlur, а не onChange: иначе запрос уходит на каждое движение в палитре
+            onBlur={(e) => onColor(tag, e.target.value)}
+            title="Цвет тега"
+          />
+          <input
+            type="text"
+            className="form-input"
+            defaultValue={tag.name}
+            onBlur={(e) => onRename(tag, e.target.value.trim())}
+          />
+          <span className="note-date">{tag.note_count}</span>
+          <select
+            className="filter-select"
+            value=""
+            onChange={(e) => onMerge(tag, Number(e.target.value))}
+          >
+            <option value="">Merge into…</option>
+            {tags
+              .filter((t) => t.id !== tag.id)
+              .map((t) => (
+                <option key={t.id} value={t.id}>
+                  {t.name}
+                </option>
+              ))}
+          </select>
+          <button className="delete-btn" onClick={() => onDelete(tag)}>
+            <i className="fas fa-trash"></i>
+          </button>
+        </div>
+      ))}
+    </div>
+  );
+};
+
+// Корзина: удаленные заметки можно вернуть или удалить окончательно.
+// Без этого сервер удалит их сам после срока хранения (purge_at).
+const TrashModal = ({ onClose, onRestored }) => {
+  const [trash, setTrash] = useState([]);
+  const [trashError, setTrashError] = useState(null);
+
+  useEffect(() => {
+    getTrash()
+      .then(setTrash)
+      .catch((err) => setTrashError(err.message));
+  }, []);
+
+  const run = async (action) => {
+    try {
+      await action();
+    } catch (err) {
+      setTrashError(err.message);
+    }
+  };
+
+  const handleRestore = (note) =>
+    run(async () => {
+      onRestored(await restoreNoteApi(note.id));
+      setTrash((current) => current.filter((n) => n.id !== note.id));
+    });
+
+  const handlePurge = (note) =>
+    run(async () => {
+      await purgeNoteApi(note.id);
+      setTrash((current) => current.filter((n) => n.id !== note.id));
+    });
+
+  const handleEmpty = () =>
+    run(async () => {
+      await emptyTrash();
+      setTrash([]);
+    });
+
+  return (
+    <div className="modal-overlay active" onClick={onClose}>
+      <div className="modal" onClick={(e) => e.stopPropagation()}>
+        <div className="modal-header">
+          <h3 className="modal-title">Trash</h3>
+          <button className="modal-close" onClick={onClose}>
+            ×
+          </button>
+        </div>
+        <div className="modal-body">
+          {trashError && <p style={{ color: "red" }}>{trashError}</p>}
+          {trash.length === 0 ? (
+            <p>Корзина пуста.</p>
+          ) : (
+            <>
+              <ul className="trash-list">
+                {trash.map((note) => (
+                  <li key={note.id} className="trash-item">
+                    <div>
+                      <strong>{note.title}</strong>
+                      <div className="note-date">
+                        Удалится{" "}
+                        {new Date(note.purge_at).toLocaleDateString("ru-RU")}
+                      </div>
+                    </div>
+                    <button
+                      className="edit-btn"
+                      onClick={() => handleRestore(note)}
+                      title="Восстановить"
+                    >
+                      <i className="fas fa-undo"></i>
+                    </button>
+                    <button
+                      className="delete-btn"
+                      onClick={() => handlePurge(note)}
+                      title="Удалить навсегда"
+                    >
+                      <i className="fas fa-times"></i>
+                    </button>
+                  </li>
+                ))}
+              </ul>
+              <button className="submit-btn" onClick={handleEmpty}>
+                Empty trash
+              </button>
+            </>
+          )}
+        </div>
+      </div>
+    </div>
+  );
+};
+
+// Импорт заметок из файла с отчетом: пропущенные (уже есть) и неудачные записи
+// перечисляются по источнику.
+const ImportModal = ({ onClose, onImported }) => {
+  const [file, setFile] = useState(null);
+  const [format, setFormat] = useState("");
+  const [report, setReport] = useState(null);
+  const [importError, setImportError] = useState(null);
+  const [importing, setImporting] = useState(false);
+
+  const handleImport = async (e) => {
+    e.preventDefault();
+    setImporting(true);
+    setImportError(null);
+    try {
+      const result = await importDiary(file, format);
+      setReport(result);
+      if (result.imported > 0) {
+        onImported();
+      }
+    } catch (err) {
+      setImportError(err.message);
+    } finally {
+      setImporting(false);
+    }
+  };
+
+  return (
+    <div className="modal-overlay active" onClick={onClose}>
+      <div className="modal" onClick={(e) => e.stopPropagation()}>
+        <div className="modal-header">
+          <h3 className="modal-title">Import</h3>
+          <button className="modal-close" onClick={onClose}>
+            ×
+          </button>
+        </div>
+        <div className="modal-body">
+          {importError && <p style={{ color: "red" }}>{importError}</p>}
+          <form onSubmit={handleImport}>
+            <div className="form-group">
+              <input
+                type="file"
+                accept=".zip,.json"
+                onChange={(e) => setFile(e.target.files[0] || null)}
+              />
+            </div>
+            <div className="form-group">
+              <select
+                className="form-input"
+                value={format}
+                onChange={(e) => setFormat(e.target.value)}
+              >
+                <option value="">Определить автоматически</option>
+                <option value="markdown">Markdown (ZIP)</option>
+                <option value="json">DiaryApp JSON</option>
+                <option value="dayone">Day One (JSON или ZIP)</option>
+              </select>
+            </div>
+            <button
+              type="submit"
+              className="submit-btn"
+              disabled={!file || importing}
+            >
+              {importing ? "Importing…" : "Import"}
+            </button>
+          </form>
+          {report && (
+            <>
+              <p>
+                Импортировано: {report.imported}, пропущено: {report.skipped},
+                ошибок: {report.failed}
+              </p>
+              <ul className="trash-list">
+                {report.items
+                  .filter((item) => item.status !== "imported")
+                  .map((item) => (
+                    <li key={item.source} className="trash-item">
+                      <div>
+                        <strong>{item.title || item.source}</strong>
+                        <div className="note-date">
+                          {item.status === "skipped"
+                            ? "Уже есть в дневнике"
+                            : item.error}{" "}
+                          ({item.source})
+                        </div>
+                      </div>
+                    </li>
+                  ))}
+              </ul>
+            </>
+          )}
+        </div>
+      </div>
+    </div>
+  );
+};
+
+// История изменений заметки: список ревизий и отличия выбранной от текущей версии
+const HistoryModal = ({ note, onClose, onRestored }) => {
+  const [revisions, setRevisions] = useState([]);
+  const [selected, setSelected] = useState(null);
+  const [diffText, setDiffText] = useState("");
+  const [historyError, setHistoryError] = useState(null);
+
+  useEffect(() => {
+    getNoteRevisions(note.id)
+      .then(setRevisions)
+      .catch((err) => setHistoryError(err.message));
+  }, [note.id]);
+
+  const latest = revisions.length > 0 ? revisions[0].revision : 0;
+
+  const selectRevision = async (revision) => {
+    setSelected(revision);
+    setDiffText("");
+    if (revision === latest) return;
+    try {
+      setDiffText(await getRevisionDiff(note.id, revision, latest));
+    } catch (err) {
+      setHistoryError(err.message);
+    }
+  };
+
+  const handleRestore = async () => {
+    try {
+      onRestored(await restoreRevision(note.id, selected));
+    } catch (err) {
+      setHistoryError(err.message);
+    }
+  };
+
+  return (
+    <div className="modal-overlay active" onClick={onClose}>
+      <div className="modal" onClick={(e) => e.stopPropagation()}>
+        <div className="modal-header">
+          <h3 className="modal-title">History: {note.title}</h3>
+          <button className="modal-close" onClick={onClose}>
+            ×
+          </button>
+        </div>
+        <div className="modal-body">
+          {historyError && <p style={{ color: "red" }}>{historyError}</p>}
+          <ul className="revision-list">
+            {revisions.map((r) => (
+              <li key={r.revision}>
+                <button
+                  className={`revision-item ${
+                    selected === r.revision ? "active" : ""
+                  }`}
+                  onClick={() => selectRevision(r.revision)}
+                >
+                  #{r.revision} · {new Date(r.created_at).toLocaleString("ru-RU")}{" "}
+                  · {r.title}
+                  {r.revision === latest && " (current)"}
+                </button>
+              </li>
+            ))}
+          </ul>
+          {selected && selected !== latest && (
+            <>
+              <pre className="revision-diff">
+                {diffText || "Текст не отличается от текущей версии"}
+              </pre>
+              <button className="submit-btn" onClick={handleRestore}>
+                Restore version #{selected}
+              </button>
+            </>
+          )}
+        </div>
+      </div>
+    </div>
+  );
+};
+
+const formatSize = (size) =>
+  size < 1 << 20
+    ? `${Math.ceil(size / 1024)} КБ`
+    : `${(size / (1 << 20)).toFixed(1)} МБ`;
+
+// Вложения заметки: загрузка, превью изображений, скачивание и удаление
+const AttachmentsModal = ({ note, onClose }) => {
+  const [attachments, setAttachments] = useState([]);
+  const [previews, setPreviews] = useState({}); // id вложения -> object URL превью
+  const [attachmentsError, setAttachmentsError] = useState(null);
+  const [uploading, setUploading] = useState(false);
+
+  useEffect(() => {
+    getAttachments(note.id)
+      .then(setAttachments)
+      .catch((err) => setAttachmentsError(err.message));
+  }, [note.id]);
+
+  // Превью загружаются с токеном, поэтому через object URL
+  useEffect(() => {
+    attachments
+      .filter((a) => a.content_type.startsWith("image/") && !previews[a.id])
+      .forEach((a) =>
+        getAttachmentUrl(note.id, a.id)
+          .then((url) => setPreviews((prev) => ({ ...prev, [a.id]: url })))
+          .catch(() => {})
+      );
+  }, [attachments, note.id]); // eslint-disable-line react-hooks/exhaustive-deps
+
+  // Освобождаем object URL при закрытии окна
+  const previewsRef = useRef(previews);
+  previewsRef.current = previews;
+  useEffect(
+    () => () => Object.values(previewsRef.current).forEach(URL.revokeObjectURL),
+    []
+  );
+
+  const handleUpload = async (e) => {
+    const file = e.target.files[0];
+    e.target.value = "";
+    if (!file) return;
+    setUploading(true);
+    setAttachmentsError(null);
+    try {
+      const created = await uploadAttachment(note.id, file);
+      setAttachments([...attachments, created]);
+    } catch (err) {
+      setAttachmentsError(err.message);
+    } finally {
+      setUploading(false);
+    }
+  };
+
+  const handleDownload = async (attachment) => {
+    try {
+      const url = await getAttachmentUrl(note.id, attachment.id);
+      const link = document.createElement("a");
+      link.href = url;
+      link.download = attachment.filename;
+      link.click();
+      URL.revokeObjectURL(url);
+    } catch (err) {
+      setAttachmentsError(err.message);
+    }
+  };
+
+  const handleDelete = async (attachment) => {
+    try {
+      await deleteAttachment(note.id, attachment.id);
+      setAttachments(attachments.filter((a) => a.id !== attachment.id));
+    } catch (err) {
+      setAttachmentsError(err.message);
+    }
+  };
+
+  return (
+    <div className="modal-overlay active" onClick={onClose}>
+      <div className="modal" onClick={(e) => e.stopPropagation()}>
+        <div className="modal-header">
+          <h3 className="modal-title">Attachments: {note.title}</h3>
+          <button className="modal-close" onClick={onClose}>
+            ×
+          </button>
+        </div>
+        <div className="modal-body">
+          {attachmentsError && <p style={{ color: "red" }}>{attachmentsError}</p>}
+          {attachments.length === 0 ? (
+            <p>У заметки нет вложений</p>
+          ) : (
+            <ul className="trash-list">
+              {attachments.map((a) => (
+                <li key={a.id} className="trash-item">
+                  {previews[a.id] ? (
+                    <img
+                      className="attachment-preview"
+                      src={previews[a.id]}
+                      alt={a.filename}
+                    />
+                  ) : (
+                    <i className="fas fa-file attachment-icon"></i>
+                  )}
+                  <div>
+                    <strong>{a.filename}</strong>
+                    <div className="note-date">{formatSize(a.size)}</div>
+                  </div>
+                  <button
+                    className="edit-btn"
+                    onClick={() => handleDownload(a)}
+                    title="Скачать"
+                  >
+                    <i className="fas fa-download"></i>
+                  </button>
+                  <button
+                    className="delete-btn"
+                    onClick={() => handleDelete(a)}
+                    title="Удалить"
+                  >
+                    <i className="fas fa-trash"></i>
+                  </button>
+                </li>
+              ))}
+            </ul>
+          )}
+          <label className="submit-btn attachment-upload">
+            {uploading ? "Uploading…" : "Attach file"}
+            <input
+              type="file"
+              accept="image/*,application/pdf,text/plain"
+              onChange={handleUpload}
+              disabled={uploading}
+              hidden
+            />
+          </label>
+        </div>
+      </div>
+    </div>
+  );
+};
+
+const shareStatusLabels = {
+  active: "Active",
+  expired: "Expired",
+  exhausted: "View limit reached",
+  locked: "Locked (wrong passwords)",
+  revoked: "Revoked",
+};
+
+// Публичные ссылки на заметку: создание с необязательными сроком, лимитом просмотров
+// и паролем, список и отзыв. Полную ссылку сервер отдает только при создании
+const SharesModal = ({ note, onClose }) => {
+  const [shares, setShares] = useState([]);
+  const [sharesError, setSharesError] = useState(null);
+  const [options, setOptions] = useState({ days: "", maxViews: "", password: "" });
+  const [createdUrl, setCreatedUrl] = useState(null);
+
+  useEffect(() => {
+    getShares(note.id)
+      .then(setShares)
+      .catch((err) => setSharesError(err.message));
+  }, [note.id]);
+
+  const handleCreate = async (e) => {
+    e.preventDefault();
+    setSharesError(null);
+    const request = {};
+    if (options.days) {
+      request.expires_at = new Date(Date.now() + options.days * 86400000).toISOString();
+    }
+    if (options.maxViews) request.max_views = Number(options.maxViews);
+    if (options.password) request.password = options.password;
+    try {
+      const created = await createShare(note.id, request);
+      setShares([created, ...shares]);
+      setCreatedUrl(created.url);
+      setOptions({ days: "", maxViews: "", password: "" });
+    } catch (err) {
+      setSharesError(err.message);
+    }
+  };
+
+  const handleRevoke = async (share) => {
+    try {
+      await revokeShare(note.id, share.id);
+      setShares(
+        shares.map((s) =>
+          s.id === share.id ? { ...s, status: "revoked", revoked_at: new Date().toISOString() } : s
+        )
+      );
+    } catch (err) {
+      setSharesError(err.message);
+    }
+  };
+
+  return (
+    <div className="modal-overlay active" onClick={onClose}>
+      <div className="modal" onClick={(e) => e.stopPropagation()}>
+        <div className="modal-header">
+          <h3 className="modal-title">Share: {note.title}</h3>
+          <button className="modal-close" onClick={onClose}>
+            ×
+          </button>
+        </div>
+        <div className="modal-body">
+          {sharesError && <p style={{ color: "red" }}>{sharesError}</p>}
+          {note.encrypted ? (
+            <p>End-to-end encrypted notes can't be shared by link.</p>
+          ) : (
+            <form onSubmit={handleCreate}>
+              <div className="form-group">
+                <label className="form-label">Expires in (days, optional)</label>
+                <input
+                  type="number"
+                  min="1"
+                  className="form-input"
+                  value={options.days}
+                  onChange={(e) => setOptions({ ...options, days: e.target.value })}
+                />
+              </div>
+              <div className="form-group">
+                <label className="form-label">View limit (optional)</label>
+                <input
+                  type="number"
+                  min="1"
+                  className="form-input"
+                  value={options.maxViews}
+                  onChange={(e) => setOptions({ ...options, maxViews: e.target.value })}
+                />
+              </div>
+              <div className="form-group">
+                <label className="form-label">Password (optional)</label>
+                <input
+                  type="password"
+                  minLength={4}
+                  className="form-input"
+                  autoComplete="new-password"
+                  value={options.password}
+                  onChange={(e) => setOptions({ ...options, password: e.target.value })}
+                />
+              </div>
+              <button type="submit" className="submit-btn">
+                Create link
+              </button>
+            </form>
+          )}
+          {createdUrl && (
+            <div className="share-created">
+              <p>Copy the link now - it won't be shown again:</p>
+              <input
+                className="form-input"
+                readOnly
+                value={createdUrl}
+                onFocus={(e) => e.target.select()}
+              />
+              <button
+                className="edit-btn"
+                onClick={() => navigator.clipboard.writeText(createdUrl)}
+                title="Copy"
+              >
+                <i className="fas fa-copy"></i>
+              </button>
+            </div>
+          )}
+          {shares.length > 0 && (
+            <ul className="trash-list">
+              {shares.map((s) => (
+                <li key={s.id} className="trash-item">
+                  <div>
+                    <strong>{shareStatusLabels[s.status] || s.status}</strong>
+                    {s.has_password && <i className="fas fa-key share-password" title="Password"></i>}
+                    <div className="note-date">
+                      Views: {s.views}
+                      {s.max_views > 0 && ` / ${s.max_views}`}
+                      {s.expires_at && ` · until ${new Date(s.expires_at).toLocaleString()}`}
+                    </div>
+                  </div>
+                  {!s.revoked_at && (
+                    <button
+                      className="delete-btn"
+                      onClick={() => handleRevoke(s)}
+                      title="Revoke"
+                    >
+                      <i className="fas fa-ban"></i>
+                    </button>
+                  )}
+                </li>
+              ))}
+            </ul>
+          )}
+        </div>
+      </div>
+    </div>
+  );
+};
+
+// Включение сквозного шифрования или разблокировка мастер-ключа парольной фразой.
+// Парольная фраза не покидает браузер: сервер хранит только зашифрованный мастер-ключ.
+const E2EEModal = ({ onClose, onUnlocked }) => {
+  const [keys, setKeys] = useState(undefined); // undefined - загрузка, null - шифрование не включено
+  const [passphrase, setPassphrase] = useState("");
+  const [confirmation, setConfirmation] = useState("");
+  const [e2eeError, setE2eeError] = useState(null);
+  const [busy, setBusy] = useState(false);
+
+  useEffect(() => {
+    getUserKeys()
+      .then(setKeys)
+      .catch((err) => setE2eeError(err.message));
+  }, []);
+
+  const handleSubmit = async (e) => {
+    e.preventDefault();
+    if (keys === null && passphrase !== confirmation) {
+      setE2eeError("Парольные фразы не совпадают");
+      return;
+    }
+    setE2eeError(null);
+    setBusy(true);
+    try {
+      if (keys === null) {
+        const created = await createKeys(passphrase);
+        await saveUserKeys(created.keys);
+        onUnlocked(created.masterKey);
+      } else {
+        onUnlocked(await unlockKeys(passphrase, keys));
+      }
+    } catch (err) {
+      setE2eeError(err.message);
+      setBusy(false);
+    }
+  };
+
+  return (
+    <div className="modal-overlay active">
+      <div className="modal">
+        <div className="modal-header">
+          <h3 className="modal-title">
+            {keys === null ? "Enable encryption" : "Unlock encrypted notes"}
+          </h3>
+          <button className="modal-close" onClick={onClose}>
+            ×
+          </button>
+        </div>
+        <form className="modal-body" onSubmit={handleSubmit}>
+          {e2eeError && <p style={{ color: "red" }}>{e2eeError}</p>}
+          {keys === null && (
+            <p>
+              Зашифрованные заметки сервер хранит только в виде шифртекста.
+              Забытую парольную фразу восстановить нельзя: вместе с ней будут
+              потеряны и зашифрованные заметки.
+            </p>
+          )}
+          {keys !== undefined && (
+            <>
+              <div className="form-group">
+                <label className="form-label">Passphrase</label>
+                <input
+                  type="password"
+                  className="form-input"
+                  value={passphrase}
+                  onChange={(e) => setPassphrase(e.target.value)}
+                  minLength={keys === null ? 8 : undefined}
+                  autoComplete={keys === null ? "new-password" : "current-password"}
+                  required
+                />
+              </div>
+              {keys === null && (
+                <div className="form-group">
+                  <label className="form-label">Repeat passphrase</label>
+                  <input
+                    type="password"
+                    className="form-input"
+                    value={confirmation}
+                    onChange={(e) => setConfirmation(e.target.value)}
+                    autoComplete="new-password"
+                    required
+                  />
+                </div>
+              )}
+              <button type="submit" className="submit-btn" disabled={busy}>
+                {busy ? "Please wait…" : keys === null ? "Enable" : "Unlock"}
+              </button>
+            </>
+          )}
+        </form>
+      </div>
+    </div>
+  );
+};
+
 function DiaryPage() {
   const navigate = useNavigate();
 
@@ -76,40 +876,64 @@ function DiaryPage() {
   const [newNote, setNewNote] = useState({
     title: "",
     content: "",
-    tag: "ideas",
+    tags: [],
+    encrypt: false,
   });
+  const [tags, setTags] = useState([]); // Теги пользователя с числом заметок
+  const [showTagsModal, setShowTagsModal] = useState(false);
+  const [showTrashModal, setShowTrashModal] = useState(false);
+  const [showImportModal, setShowImportModal] = useState(false);
   const [showAddModal, setShowAddModal] = useState(false);
   const [noteToDelete, setNoteToDelete] = useState(null);
   const [noteToEdit, setNoteToEdit] = useState(null); // Хранит объект заметки для редактирования
   const [showEditModal, setShowEditModal] = useState(false); // Управление модальным окном редактирования
+  const [noteHistory, setNoteHistory] = useState(null); // Заметка, историю которой смотрим
+  const [noteAttachments, setNoteAttachments] = useState(null); // Заметка, вложения которой открыты
+  const [noteShares, setNoteShares] = useState(null); // Заметка, ссылки на которую открыты
+  const [masterKey, setMasterKey] = useState(null); // Ключ шифрования заметок, только в памяти вкладки
+  const [showE2EEModal, setShowE2EEModal] = useState(false);
   // СОСТОЯНИЕ ДЛЯ МОДАЛЬНОГО ОКНА ОБРАТНОЙ СВЯЗИ
   const [showFeedbackModal, setShowFeedbackModal] = useState(false);
   // СОСТОЯНИЕ ДЛЯ ТЕКСТА ОБРАТНОЙ СВЯЗИ
 
   const [search, setSearch] = useState("");
+  const [searchResults, setSearchResults] = useState(null); // null - поиск не активен
   const [filterTag, setFilterTag] = useState("all");
   const [loading, setLoading] = useState(true);
+  const [nextCursor, setNextCursor] = useState(null); // Курсор следующей страницы заметок
+  const [loadingMore, setLoadingMore] = useState(false);
   const [error, setError] = useState(null);
 
-  // --- ФУНКЦИЯ ЗАГРУЗКИ ЗАМЕТОК ---
+  // --- РАСШИФРОВКА (без мастер-ключа зашифрованные заметки показываются закрытыми) ---
+  const decryptNotes = useCallback(
+    (list) =>
+      Promise.all(
+        list.map(async (note) => {
+          if (!note.encrypted) return note;
+          if (masterKey) {
+            try {
+              return { ...note, ...(await decryptNote(masterKey, note.encrypted)) };
+            } catch (err) {
+              console.error("Ошибка расшифровки заметки:", err);
+            }
+          }
+          return { ...note, title: "Encrypted note", content: "", locked: true };
+        })
+      ),
+    [masterKey]
+  );
+
+  // --- ФУНКЦИЯ ЗАГРУЗКИ ЗАМЕТОК (первая страница; тег фильтруется на сервере) ---
   const fetchNotes = useCallback(async () => {
     setLoading(true);
     setError(null);
     try {
-      const data = await getNotes();
-
-      // if (data.length > 0) {
-      //     console.log("Сырая дата от Go для первой заметки:", data[0].created_at);
-      // }
-
-      const formattedNotes = data.map((note) => ({
-        ...note,
-        // Используем 'created_at' от Go-бэкенда в качестве основной даты
-        date: note.created_at || note.updated_at,
-        tag: note.tag || "ideas",
-      }));
+      const page = await getNotes({
+        tag: filterTag === "all" ? "" : filterTag,
+      });
 
-      setNotes(formattedNotes);
+      setNotes(await decryptNotes(formatNotes(page.notes)));
+      setNextCursor(page.nextCursor);
     } catch (err) {
       setError("Не удалось загрузить заметки. Возможно, сессия истекла.");
       if (err.message === "Unauthorized") {
@@ -118,35 +942,120 @@ function DiaryPage() {
     } finally {
       setLoading(false);
     }
-  }, [navigate]);
+  }, [navigate, filterTag, decryptNotes]);
+
+  // --- ЗАГРУЗКА СЛЕДУЮЩЕЙ СТРАНИЦЫ ---
+  const loadMoreNotes = async () => {
+    if (!nextCursor) return;
+    setLoadingMore(true);
+    try {
+      const page = await getNotes({
+        tag: filterTag === "all" ? "" : filterTag,
+        cursor: nextCursor,
+      });
+      const more = await decryptNotes(formatNotes(page.notes));
+      setNotes((prev) => [...prev, ...more]);
+      setNextCursor(page.nextCursor);
+    } catch (err) {
+      setError("Не удалось загрузить заметки.");
+    } finally {
+      setLoadingMore(false);
+    }
+  };
 
   useEffect(() => {
     fetchNotes();
   }, [fetchNotes]);
 
+  // --- ЗАГРУЗКА ТЕГОВ ---
+  const fetchTags = useCallback(async () => {
+    try {
+      setTags(await getTags());
+    } catch (err) {
+      console.error("Ошибка загрузки тегов:", err);
+    }
+  }, []);
+
+  useEffect(() => {
+    fetchTags();
+  }, [fetchTags]);
+
+  // --- УПРАВЛЕНИЕ ТЕГАМИ (после изменений перезагружаем теги и заметки) ---
+  const runTagAction = async (action) => {
+    try {
+      await action();
+      setError(null);
+    } catch (err) {
+      setError(err.message);
+    }
+    await fetchTags();
+    await fetchNotes();
+  };
+
+  const handleRenameTag = (tag, name) => {
+    if (!name || name === tag.name) return;
+    runTagAction(() => updateTag(tag.id, { name }));
+  };
+
+  const handleTagColor = (tag, color) => {
+    if (color === (tag.color || DEFAULT_TAG_COLOR)) return;
+    runTagAction(() => updateTag(tag.id, { color }));
+  };
+
+  const handleMergeTag = (tag, intoId) => {
+    if (!intoId) return;
+    runTagAction(() => mergeTags(tag.id, intoId));
+  };
+
+  const handleDeleteTag = (tag) => {
+    if (filterTag === tag.name) setFilterTag("all");
+    runTagAction(() => deleteTag(tag.id));
+  };
+
+  // --- ПОИСК НА СЕРВЕРЕ (с задержкой, чтобы не отправлять запрос на каждый символ) ---
+  useEffect(() => {
+    const query = search.trim();
+    if (!query) {
+      setSearchResults(null);
+      return;
+    }
+
+    let cancelled = false;
+    const timer = setTimeout(async () => {
+      try {
+        const data = await searchNotes(query);
+        if (!cancelled) {
+          setSearchResults(formatNotes(data));
+        }
+      } catch (err) {
+        if (!cancelled) {
+          setSearchResults([]);
+        }
+      }
+    }, 300);
+
+    return () => {
+      cancelled = true;
+      clearTimeout(timer);
+    };
+  }, [search, notes]);
+
   // --- ФУНКЦИЯ ДОБАВЛЕНИЯ ЗАМЕТКИ ---
   const handleAddNote = async (e) => {
     e.preventDefault();
     if (!newNote.title.trim() || !newNote.content.trim()) return;
 
-    const noteData = {
-      title: newNote.title,
-      content: newNote.content,
-      tag: newNote.tag,
-    };
-
     try {
+      // Зашифрованная заметка уходит на сервер без заголовка и текста
+      const noteData = newNote.encrypt
+        ? { encrypted: await encryptNote(masterKey, newNote), tags: newNote.tags }
+        : { title: newNote.title, content: newNote.content, tags: newNote.tags };
       const createdNote = await createNote(noteData);
 
-      const newNoteWithTag = {
-        ...createdNote,
-        date: createdNote.created_at,
-        tag: newNote.tag,
-      };
-
-      setNotes([newNoteWithTag, ...notes]);
-      setNewNote({ title: "", content: "", tag: "ideas" });
+      setNotes([...(await decryptNotes(formatNotes([createdNote]))), ...notes]);
+      setNewNote({ title: "", content: "", tags: [], encrypt: false });
       setShowAddModal(false);
+      fetchTags(); // Новые теги и счетчики заметок
     } catch (err) {
       setError("Ошибка при создании заметки.");
     }
@@ -158,18 +1067,21 @@ function DiaryPage() {
     if (!noteToEdit || !noteToEdit.title.trim() || !noteToEdit.content.trim())
       return;
 
-    const noteData = {
-      title: noteToEdit.title,
-      content: noteToEdit.content,
-      tag: noteToEdit.tag || "ideas",
-    };
-
     try {
+      // Без поля encrypted сервер сохранит заметку открытой
+      const noteData = noteToEdit.encrypt
+        ? { encrypted: await encryptNote(masterKey, noteToEdit), tags: noteToEdit.tags }
+        : { title: noteToEdit.title, content: noteToEdit.content, tags: noteToEdit.tags };
+
       // ВЫЗОВ API ДЛЯ ОБНОВЛЕНИЯ
-      const updatedNote = await updateNoteApi(noteToEdit.id, noteData);
+      const updatedNote = await updateNoteApi(
+        noteToEdit.id,
+        noteData,
+        noteToEdit.version
+      );
 
       const formattedUpdatedNote = {
-        ...updatedNote,
+        ...(await decryptNotes(formatNotes([updatedNote])))[0],
         // Используем updated_at от Go-бэкенда в качестве основной даты
         date: updatedNote.updated_at,
       };
@@ -184,7 +1096,19 @@ function DiaryPage() {
       // Закрываем модальное окно
       setNoteToEdit(null);
       setShowEditModal(false);
+      fetchTags();
     } catch (err) {
+      if (err.current) {
+        // Заметку изменили на другом устройстве: показываем серверную копию в списке,
+        // а повторное сохранение из редактора перезапишет ее осознанно
+        const [current] = await decryptNotes(formatNotes([err.current]));
+        setNotes(notes.map((n) => (n.id === current.id ? current : n)));
+        setNoteToEdit({ ...noteToEdit, version: current.version });
+        setError(
+          "Заметка изменена на другом устройстве. Сохраните еще раз, чтобы перезаписать ее."
+        );
+        return;
+      }
       console.error("Ошибка при обновлении заметки:", err);
       setError("Ошибка при обновлении заметки.");
     }
@@ -199,19 +1123,19 @@ function DiaryPage() {
       // Если API успешно удалило, обновляем локальный стейт
       setNotes(notes.filter((n) => n.id !== id));
       setNoteToDelete(null);
+      fetchTags();
     } catch (err) {
       setError("Ошибка при удалении заметки.");
       setNoteToDelete(null); // Закрываем модальное окно даже при ошибке
     }
   };
 
-  const filteredNotes = notes.filter((note) => {
-    const matchesSearch =
-      note.title.toLowerCase().includes(search.toLowerCase()) ||
-      note.content.toLowerCase().includes(search.toLowerCase());
-    const matchesTag = filterTag === "all" || note.tag === filterTag;
-    return matchesSearch && matchesTag;
-  });
+  // Результаты поиска уже отсортированы сервером по релевантности
+  const filteredNotes = (searchResults ?? notes).filter(
+    (note) => filterTag === "all" || note.tags.includes(filterTag)
+  );
+
+  const tagColors = Object.fromEntries(tags.map((t) => [t.name, t.color]));
 
   // ФУНКЦИЯ ФОРМАТИРОВАНИЕ ДАТЫ
   const formatDate = (dateString) => {
@@ -258,8 +1182,8 @@ function DiaryPage() {
         a: "Чаще всего это происходит из-за истечения срока действия вашей сессии (токена). Попробуйте выйти из системы, а затем снова войти. Если проблема сохранится, возможно, есть временные проблемы с бэкендом (Go-сервером).",
       },
       {
-        q: "Как я могу изменить тег заметки после ее создания?",
-        a: "В текущей версии приложения изменение тега доступно только при создании заметки. Мы работаем над добавлением функции редактирования для изменения тегов и контента уже существующих записей.",
+        q: "Как я могу изменить теги заметки после ее создания?",
+        a: "Откройте заметку кнопкой редактирования и отметьте нужные теги или введите новый. У заметки может быть несколько тегов. Кнопка Tags открывает список всех ваших тегов: там их можно переименовать, перекрасить, объединить или удалить, и изменения сразу применятся ко всем заметкам.",
       },
       {
         q: "Безопасно ли хранить данные в DiaryApp?",
@@ -331,11 +1255,44 @@ function DiaryPage() {
                 className="filter-select"
               >
                 <option value="all">All notes</option>
-                <option value="work">Work</option>
-                <option value="personal">Personal</option>
-                <option value="ideas">Ideas</option>
-                <option value="reminders">Reminders</option>
+                {tags.map((tag) => (
+                  <option key={tag.id} value={tag.name}>
+                    {tag.name} ({tag.note_count})
+                  </option>
+                ))}
+              </select>
+              <button className="add-btn" onClick={() => setShowTagsModal(true)}>
+                <i className="fas fa-tags"></i> Tags
+              </button>
+              <button className="add-btn" onClick={() => setShowTrashModal(true)}>
+                <i className="fas fa-trash"></i> Trash
+              </button>
+              <select
+                className="filter-select"
+                value=""
+                onChange={(e) =>
+                  exportDiary(e.target.value).catch((err) =>
+                    setError(err.message)
+                  )
+                }
+              >
+                <option value="">Export…</option>
+                <option value="json">JSON</option>
+                <option value="markdown">Markdown (ZIP)</option>
+                <option value="csv">CSV</option>
               </select>
+              <button className="add-btn" onClick={() => setShowImportModal(true)}>
+                <i className="fas fa-file-import"></i> Import
+              </button>
+              <button
+                className="add-btn"
+                onClick={() => setShowE2EEModal(true)}
+                disabled={!!masterKey}
+                title={masterKey ? "Encrypted notes are unlocked" : "Encryption"}
+              >
+                <i className={`fas ${masterKey ? "fa-lock-open" : "fa-lock"}`}></i>{" "}
+                {masterKey ? "Unlocked" : "Encryption"}
+              </button>
               <button className="add-btn" onClick={() => setShowAddModal(true)}>
                 <i className="fas fa-plus"></i> Add
               </button>
@@ -355,7 +1312,23 @@ function DiaryPage() {
               <div key={note.id} className={`note-card fade-in`}>
                 <div className="note-content">
                   <div className="note-header">
-                    <h4 className="note-title">{note.title}</h4>
+                    {/* Подсветка приходит с сервера уже экранированной */}
+                    {note.title_highlight ? (
+                      <h4
+                        className="note-title"
+                        dangerouslySetInnerHTML={{ __html: note.title_highlight }}
+                      />
+                    ) : (
+                      <h4 className="note-title">
+                        {note.encrypted && (
+                          <i
+                            className={`fas ${note.locked ? "fa-lock" : "fa-lock-open"} note-lock`}
+                            title="End-to-end encrypted"
+                          ></i>
+                        )}
+                        {note.title}
+                      </h4>
+                    )}
                     <div className="note-actions">
                       {" "}
                       {/* контейнер для кнопок */}
@@ -363,12 +1336,41 @@ function DiaryPage() {
                       <button
                         className="edit-btn"
                         onClick={() => {
-                          setNoteToEdit(note); // Устанавливаем текущую заметку для редактирования
+                          if (note.locked) {
+                            setShowE2EEModal(true); // Сначала нужен мастер-ключ
+                            return;
+                          }
+                          // Устанавливаем текущую заметку для редактирования
+                          setNoteToEdit({ ...note, encrypt: !!note.encrypted });
                           setShowEditModal(true); // Открываем модальное окно
                         }}
                       >
                         <i className="fas fa-pen"></i>
                       </button>
+                      {/* КНОПКА ИСТОРИИ ИЗМЕНЕНИЙ */}
+                      <button
+                        className="edit-btn"
+                        onClick={() => setNoteHistory(note)}
+                        title="History"
+                      >
+                        <i className="fas fa-history"></i>
+                      </button>
+                      {/* КНОПКА ВЛОЖЕНИЙ */}
+                      <button
+                        className="edit-btn"
+                        onClick={() => setNoteAttachments(note)}
+                        title="Attachments"
+                      >
+                        <i className="fas fa-paperclip"></i>
+                      </button>
+                      {/* КНОПКА ПУБЛИЧНЫХ ССЫЛОК */}
+                      <button
+                        className="edit-btn"
+                        onClick={() => setNoteShares(note)}
+                        title="Share"
+                      >
+                        <i className="fas fa-share-alt"></i>
+                      </button>
                       {/* Кнопка удаления */}
                       <button
                         className="delete-btn"
@@ -378,11 +1380,28 @@ function DiaryPage() {
                       </button>
                     </div>
                   </div>
-                  <p className="note-text">{note.content}</p>
+                  {note.snippet ? (
+                    <p
+                      className="note-text"
+                      dangerouslySetInnerHTML={{ __html: note.snippet }}
+                    />
+                  ) : (
+                    <p className="note-text">
+                      {note.locked
+                        ? "Enter your passphrase to read this note."
+                        : note.content}
+                    </p>
+                  )}
                   <div className="note-footer">
-                    <span className={`note-tag tag-${note.tag}`}>
-                      {note.tag.charAt(0).toUpperCase() + note.tag.slice(1)}
-                    </span>
+                    {note.tags.map((tag) => (
+                      <span
+                        key={tag}
+                        className="note-tag"
+                        style={tagStyle(tagColors[tag])}
+                      >
+                        {tag}
+                      </span>
+                    ))}
                     {/* Используем исправленную функцию formatDate */}
                     <span className="note-date">{formatDate(note.date)}</span>
                   </div>
@@ -392,6 +1411,19 @@ function DiaryPage() {
           </div>
         )}
 
+        {/* Следующая страница (не во время поиска: результаты поиска приходят целиком) */}
+        {nextCursor && searchResults === null && (
+          <div style={{ textAlign: "center", margin: "20px 0" }}>
+            <button
+              className="add-btn"
+              onClick={loadMoreNotes}
+              disabled={loadingMore}
+            >
+              {loadingMore ? "Loading..." : "Load more"}
+            </button>
+          </div>
+        )}
+
         {/* Add Note Modal */}
         {showAddModal && (
           <div className="modal-overlay active">
@@ -432,26 +1464,25 @@ function DiaryPage() {
                   />
                 </div>
 
-                <div className="tags-container">
-                  {["work", "personal", "ideas", "reminders"].map((tag) => (
-                    <label key={tag} className="tag-option">
-                      <input
-                        type="radio"
-                        name="noteTag"
-                        value={tag}
-                        className="tag-radio"
-                        checked={newNote.tag === tag}
-                        onChange={(e) =>
-                          setNewNote({ ...newNote, tag: e.target.value })
-                        }
-                      />
-
-                      <span className={`tag-label tag-${tag}`}>
-                        {tag.charAt(0).toUpperCase() + tag.slice(1)}
-                      </span>
-                    </label>
-                  ))}
-                </div>
+                <TagPicker
+                  tags={tags}
+                  selected={newNote.tags}
+                  onChange={(selected) =>
+                    setNewNote({ ...newNote, tags: selected })
+                  }
+                />
+                {masterKey && (
+                  <label className="encrypt-toggle">
+                    <input
+                      type="checkbox"
+                      checked={newNote.encrypt}
+                      onChange={(e) =>
+                        setNewNote({ ...newNote, encrypt: e.target.checked })
+                      }
+                    />{" "}
+                    Encrypt title and content
+                  </label>
+                )}
                 <button type="submit" className="submit-btn">
                   Save Note
                 </button>
@@ -466,7 +1497,7 @@ function DiaryPage() {
             <div className="modal confirmation-modal">
               <div className="modal-body">
                 <p className="confirmation-text">
-                  Are you sure you want to delete this note?
+                  Move this note to the trash? You can restore it later.
                 </p>
                 <div className="confirmation-buttons">
                   <button
@@ -531,25 +1562,25 @@ function DiaryPage() {
                   />
                 </div>
 
-                <div className="tags-container">
-                  {["work", "personal", "ideas", "reminders"].map((tag) => (
-                    <label key={tag} className="tag-option">
-                      <input
-                        type="radio"
-                        name="noteTag"
-                        value={tag}
-                        className="tag-radio"
-                        checked={noteToEdit.tag === tag}
-                        onChange={(e) =>
-                          setNoteToEdit({ ...noteToEdit, tag: e.target.value })
-                        }
-                      />
-                      <span className={`tag-label tag-${tag}`}>
-                        {tag.charAt(0).toUpperCase() + tag.slice(1)}
-                      </span>
-                    </label>
-                  ))}
-                </div>
+                <TagPicker
+                  tags={tags}
+                  selected={noteToEdit.tags}
+                  onChange={(selected) =>
+                    setNoteToEdit({ ...noteToEdit, tags: selected })
+                  }
+                />
+                {masterKey && (
+                  <label className="encrypt-toggle">
+                    <input
+                      type="checkbox"
+                      checked={noteToEdit.encrypt}
+                      onChange={(e) =>
+                        setNoteToEdit({ ...noteToEdit, encrypt: e.target.checked })
+                      }
+                    />{" "}
+                    Encrypt title and content
+                  </label>
+                )}
                 <button type="submit" className="submit-btn">
                   Save Changes
                 </button>
@@ -558,6 +1589,97 @@ function DiaryPage() {
           </div>
         )}
 
+        {/* History Modal: ревизии заметки */}
+        {noteHistory && (
+          <HistoryModal
+            note={noteHistory}
+            onClose={() => setNoteHistory(null)}
+            onRestored={async (restored) => {
+              const [formatted] = await decryptNotes(formatNotes([restored]));
+              setNotes(notes.map((n) => (n.id === formatted.id ? formatted : n)));
+              setNoteHistory(null);
+              fetchTags();
+            }}
+          />
+        )}
+
+        {/* Attachments Modal: файлы заметки */}
+        {noteAttachments && (
+          <AttachmentsModal
+            note={noteAttachments}
+            onClose={() => setNoteAttachments(null)}
+          />
+        )}
+
+        {/* Shares Modal: публичные ссылки на заметку */}
+        {noteShares && (
+          <SharesModal note={noteShares} onClose={() => setNoteShares(null)} />
+        )}
+
+        {/* E2EE Modal: включение шифрования и разблокировка заметок */}
+        {showE2EEModal && (
+          <E2EEModal
+            onClose={() => setShowE2EEModal(false)}
+            onUnlocked={(key) => {
+              // Смена ключа перезагружает заметки через fetchNotes
+              setMasterKey(key);
+              setShowE2EEModal(false);
+            }}
+          />
+        )}
+
+        {/* Trash Modal: удаленные заметки */}
+        {showTrashModal && (
+          <TrashModal
+            onClose={() => setShowTrashModal(false)}
+            onRestored={() => {
+              // Восстановленная заметка встает на свое место по дате создания
+              fetchNotes();
+              fetchTags();
+            }}
+          />
+        )}
+
+        {/* Import Modal: заметки из других дневников */}
+        {showImportModal && (
+          <ImportModal
+            onClose={() => setShowImportModal(false)}
+            onImported={() => {
+              fetchNotes();
+              fetchTags();
+            }}
+          />
+        )}
+
+        {/* Tags Modal: управление тегами пользователя */}
+        {showTagsModal && (
+          <div
+            className="modal-overlay active"
+            onClick={() => setShowTagsModal(false)}
+          >
+            <div className="modal" onClick={(e) => e.stopPropagation()}>
+              <div className="modal-header">
+                <h3 className="modal-title">Tags</h3>
+                <button
+                  className="modal-close"
+                  onClick={() => setShowTagsModal(false)}
+                >
+                  ×
+                </button>
+              </div>
+              <div className="modal-body">
+                <TagManager
+                  tags={tags}
+                  onRename={handleRenameTag}
+                  onColor={handleTagColor}
+                  onMerge={handleMergeTag}
+                  onDelete={handleDeleteTag}
+                />
+              </div>
+            </div>
+          </div>
+        )}
+
         {/* ПЛАВАЮЩАЯ КНОПКА */}
         <button
           className="floating-feedback-btn"
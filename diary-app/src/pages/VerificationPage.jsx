@@ -1,5 +1,6 @@
 import React, { useState } from "react";
 import { useNavigate, useLocation, Link } from "react-router-dom";
+import { saveTokens } from "../api";
 import "../styles/register.css"; // Импорт стилей
 
 const VerificationPage = () => {
@@ -66,8 +67,8 @@ const VerificationPage = () => {
       const data = await response.json();
 
       if (response.ok) {
-        // Успешная верификация: получаем токен, сохраняем его и переходим на главную
-        localStorage.setItem("authToken", data.token);
+        // Успешная верификация: получаем токены, сохраняем их и переходим на главную
+        saveTokens(data);
         console.log(data.message || "Аккаунт успешно верифицирован!");
         navigate("/", { replace: true });
       } else {